@@ -2,6 +2,7 @@ package account
 
 import (
 	"fmt"
+	"mfp/money"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -9,13 +10,60 @@ import (
 
 // структура аккаунта
 type Account struct {
-	ID           string        `json:"id"`
-	Password     string        `json:"password"` //хешированный пароль
-	CVC2         string        `json:"cvc2"`     // Card Verification Code
-	Balance      float64       `json:"balance"`
-	Name         string        `json:"name"`
-	Phone        string        `json:"phone"`
-	Age          int           `json:"age"`
+	ID       string      `json:"id"`
+	Password string      `json:"password"`       //хешированный пароль
+	CVC2     string      `json:"cvc2,omitempty"` // CVC2 карты старого формата, см. migrateLegacyCard
+	Balance  money.Money `json:"balance"`
+	Name     string      `json:"name"`
+	Phone    string      `json:"phone,omitempty"` // в файле хранится зашифрованным в PhoneEnc
+	Age      int         `json:"age"`
+	Role     string      `json:"role"`
+	OwnerID  string      `json:"owner_id,omitempty"` // основной аккаунт клиента, если это валютный счёт
+
+	// телефон, зашифрованный ключом данных версии KeyVersion; заполняется
+	// только в файле, в памяти телефон расшифрован
+	PhoneEnc   string `json:"phone_enc,omitempty"`
+	KeyVersion int    `json:"key_version,omitempty"`
+
+	Status          string    `json:"status"`
+	StatusReason    string    `json:"status_reason"`
+	StatusChangedAt time.Time `json:"status_changed_at"`
+
+	// баланс может уйти в минус до лимита; на отрицательный остаток
+	// ежедневно начисляются проценты по годовой ставке
+	OverdraftLimit money.Money `json:"overdraft_limit"`
+	OverdraftRate  string      `json:"overdraft_rate,omitempty"` // процентов годовых
+	// последний день, за который начислены проценты за овердрафт
+	OverdraftThrough *time.Time `json:"overdraft_through,omitempty"`
+
+	Limits        SpendingLimits `json:"limits"`
+	PendingLimits *PendingLimits `json:"pending_limits,omitempty"`
+	// переводы больше порога исполняются после подтверждения PIN
+	// или кодом; nil — порог банка по умолчанию
+	ConfirmThreshold *money.Money `json:"confirm_threshold,omitempty"`
+
+	// проценты начисляются ежедневно на остаток на конец дня
+	// и капитализируются раз в месяц
+	Product      string     `json:"product,omitempty"`       // current, savings, deposit
+	InterestRate string     `json:"interest_rate,omitempty"` // процентов годовых
+	MaturityDate *time.Time `json:"maturity_date,omitempty"` // окончание срока вклада
+	Accrual      *Accrual   `json:"accrual,omitempty"`
+
+	// карты счёта; в PostgreSQL хранятся в отдельной таблице и здесь
+	// не заполняются, читать их нужно через Store.GetCards
+	Cards []Card `json:"cards,omitempty"`
+	// суммы, заблокированные по авторизациям карт; уменьшают доступный
+	// остаток, но не баланс. Holds, как и Cards, заполняется не везде
+	Held  money.Money `json:"held"`
+	Holds []Hold      `json:"holds,omitempty"`
+
+	// второй фактор входа; в PostgreSQL хранится отдельно и здесь
+	// не заполняется, читать его нужно через Store.GetTwoFactor
+	TwoFactor *TwoFactor `json:"two_factor,omitempty"`
+	// неудачные попытки входа; в PostgreSQL хранятся отдельно,
+	// читать их нужно через Store.GetLoginAttempts
+	LoginAttempts *LoginAttempts `json:"login_attempts,omitempty"`
+
 	CreatedAt    time.Time     `json:"created_at"`
 	ExpiredAt    time.Time     `json:"expired_at"`
 	Transactions []Transaction `json:"transactions"`
@@ -29,37 +77,88 @@ func NewAccount(password, name, phone string, age int) *Account {
 
 	generator := NewCardGenerator()
 	return &Account{
-		ID:           generator.GenerateCardNumber(), //генерация номера аккаунта
-		Password:     hashPassword(password),         //хеширование пароля
-		CVC2:         generator.GenerateCVC(),        //генерация CVC2
-		Balance:      0,
+		ID:           generator.GenerateAccountNumber(), //генерация номера аккаунта
+		Password:     hashPassword(password),            //хеширование пароля
+		Balance:      money.Zero(money.DefaultCurrency),
 		Name:         name,
 		Phone:        phone,
 		Age:          age,
+		Role:         RoleCustomer,
+		Status:       StatusActive,
 		CreatedAt:    time.Now(),
 		ExpiredAt:    time.Now().AddDate(5, 0, 0), // срок действия аккаунта 5 лет
 		Transactions: []Transaction{},
 	}
 }
 
+// валютный счёт клиента: отдельный номер и баланс в другой валюте,
+// вход и личные данные общие с основным аккаунтом
+func NewSubAccount(owner *Account, currency string) (*Account, error) {
+	if err := money.ValidateCurrency(currency); err != nil {
+		return nil, err
+	}
+	if owner.OwnerID != "" {
+		return nil, fmt.Errorf("sub-account cannot own other accounts")
+	}
+	if owner.EffectiveStatus() != StatusActive {
+		return nil, fmt.Errorf("account is %s", owner.EffectiveStatus())
+	}
+
+	generator := NewCardGenerator()
+	return &Account{
+		ID:           generator.GenerateAccountNumber(),
+		Password:     owner.Password,
+		Balance:      money.Zero(money.NormalizeCurrency(currency)),
+		Name:         owner.Name,
+		Phone:        owner.Phone,
+		Age:          owner.Age,
+		Role:         RoleCustomer,
+		OwnerID:      owner.ID,
+		Status:       StatusActive,
+		CreatedAt:    time.Now(),
+		ExpiredAt:    owner.ExpiredAt,
+		Transactions: []Transaction{},
+	}, nil
+}
+
+// валюта счёта
+func (acc *Account) Currency() string {
+	return money.NormalizeCurrency(acc.Balance.Currency)
+}
+
+// основной аккаунт клиента, к которому относится счёт
+func (acc *Account) ClientID() string {
+	if acc.OwnerID != "" {
+		return acc.OwnerID
+	}
+	return acc.ID
+}
+
 // проверка на истечение срока действия аккаунта
 func (acc *Account) IsExpired() bool {
 	return time.Now().After(acc.ExpiredAt)
 }
 
 // пополнение баланса
-func (acc *Account) Deposit(amount float64) error {
+func (acc *Account) Deposit(amount money.Money) error {
 	if acc.IsExpired() {
 		return fmt.Errorf("account is expired")
 	}
-	if amount <= 0 {
+	if err := CheckCredit(acc.Status); err != nil {
+		return err
+	}
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
-	acc.Balance += amount
+	balance, err := acc.Balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	acc.Balance = balance
 
 	transaction := Transaction{
 		ID:          len(acc.Transactions) + 1,
-		Type:        "deposit",
+		Type:        TypeDeposit,
 		FromAccount: "",
 		ToAccount:   acc.ID,
 		Amount:      amount,
@@ -72,21 +171,34 @@ func (acc *Account) Deposit(amount float64) error {
 }
 
 // снятие средств
-func (acc *Account) Withdraw(amount float64) error {
+func (acc *Account) Withdraw(amount money.Money) error {
 	if acc.IsExpired() {
 		return fmt.Errorf("account is expired")
 	}
-	if amount <= 0 {
+	if err := CheckDebit(acc.Status); err != nil {
+		return err
+	}
+	if err := CheckTerm(acc.EffectiveProduct(), acc.MaturityDate, time.Now()); err != nil {
+		return err
+	}
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
-	if acc.Balance < amount {
-		return fmt.Errorf("insufficient funds")
+	if err := CheckFunds(acc.Available(), acc.Overdraft(), amount); err != nil {
+		return err
+	}
+	if err := acc.checkSpending(amount); err != nil {
+		return err
+	}
+	balance, err := acc.Balance.Sub(amount)
+	if err != nil {
+		return err
 	}
-	acc.Balance -= amount
+	acc.Balance = balance
 
 	transaction := Transaction{
 		ID:          len(acc.Transactions) + 1,
-		Type:        "withdrawal",
+		Type:        TypeWithdrawal,
 		FromAccount: acc.ID,
 		ToAccount:   "",
 		Amount:      amount,
@@ -98,6 +210,26 @@ func (acc *Account) Withdraw(amount float64) error {
 	return nil
 }
 
+// списание банком процентов за овердрафт или комиссии: проходит при
+// любом статусе счёта и может увеличить долг сверх лимита
+func (acc *Account) charge(txType string, amount money.Money, at time.Time) error {
+	balance, err := acc.Balance.Sub(amount)
+	if err != nil {
+		return err
+	}
+	acc.Balance = balance
+
+	acc.Transactions = append(acc.Transactions, Transaction{
+		ID:          len(acc.Transactions) + 1,
+		Type:        txType,
+		FromAccount: acc.ID,
+		Amount:      amount,
+		Timestamp:   at,
+		Status:      "completed",
+	})
+	return nil
+}
+
 // валидация пароля
 func validatePassword(pass string) error {
 	if len(pass) != 4 {
@@ -1,27 +1,54 @@
 package account
 
 import (
+	"errors"
 	"fmt"
+	"mfp/keyring"
+	"mfp/money"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// аккаунт не найден ни по ID, ни по номеру телефона
+var ErrAccountNotFound = errors.New("account not found")
+
 // структура для хранения списка аккаунтов
 type AccountList struct {
 	accounts         map[string]*Account //мапа аккаунтов по ID
 	accountsbyNumber map[string]*Account //мапа аккаунтов по номеру телефона
 	mu               sync.RWMutex        // для потокобезопасности
+	lastHoldID       int64               // последний выданный ID блокировки по карте
+
+	keys  *keyring.Keyring // шифрование телефонов в файле и коды CVC2
+	stale int              // записей в файле, ещё не зашифрованных текущим ключом
 }
 
-// создание нового списка аккаунтов
+// создание нового списка аккаунтов; без SetKeyring ключи живут
+// только в памяти процесса
 func NewAccountList() *AccountList {
 	return &AccountList{
 		accounts: make(map[string]*Account), accountsbyNumber: make(map[string]*Account),
+		keys: keyring.NewEphemeral(),
 	}
 }
 
+// связка ключей для файла; задаётся до UnmarshalSealed
+func (al *AccountList) SetKeyring(k *keyring.Keyring) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.keys = k
+}
+
 // добавление аккаунта в список
 func (al *AccountList) AddAccount(account *Account) error {
+	return al.AddAccountWithCard(account, nil)
+}
+
+// добавление аккаунта вместе с первой картой c под одной блокировкой:
+// если карту выпустить нельзя, аккаунт не добавляется; c может быть nil
+func (al *AccountList) AddAccountWithCard(account *Account, c *Card) error {
 	if err := account.Validate(); err != nil {
 		return err
 	}
@@ -32,39 +59,483 @@ func (al *AccountList) AddAccount(account *Account) error {
 	if _, exists := al.accounts[account.ID]; exists {
 		return fmt.Errorf("account with ID %s already exists", account.ID)
 	}
-	if _, exists := al.accountsbyNumber[account.Phone]; exists {
+	if account.OwnerID != "" {
+		// валютный счёт делит телефон с основным аккаунтом
+		if _, exists := al.accounts[account.OwnerID]; !exists {
+			return ErrAccountNotFound
+		}
+	} else if _, exists := al.accountsbyNumber[account.Phone]; exists {
 		return fmt.Errorf("account with phone %s number already exists", account.Phone)
 	}
-	al.accounts[account.ID] = account
-	al.accountsbyNumber[account.Phone] = account
 
-	go func() {
-		al.saveToFile()
-	}()
-	// if al.saveToFile() != nil {
-	// 	log.Printf("❌ Failed to save account list after adding account ID %s", account.ID)
-	// }
+	stored := account.clone()
+	if c != nil {
+		if err := CheckCardIssue(stored.Status, nil, time.Now()); err != nil {
+			return err
+		}
+		if _, _, err := al.findCardByPAN(c.PAN); err == nil {
+			return fmt.Errorf("card number collision, try again")
+		}
+		stored.Cards = append(stored.Cards, c.sealed(al.keys))
+	}
+	al.accounts[stored.ID] = stored
+	if stored.OwnerID == "" {
+		al.accountsbyNumber[stored.Phone] = stored
+	}
 	return nil
 }
 
-// получение всех аккаунтов
+// получение всех аккаунтов; возвращаются копии,
+// чтобы вызывающий код не мог изменить список в обход блокировки
 func (al *AccountList) GetAccounts() []*Account {
 	al.mu.RLock()
 	defer al.mu.RUnlock()
 
 	accounts := make([]*Account, 0, len(al.accounts))
 	for _, acc := range al.accounts {
-		accounts = append(accounts, acc)
+		accounts = append(accounts, acc.clone())
 	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt.Before(accounts[j].CreatedAt) })
 	return accounts
 }
 
-// получение аккаунта по ID или номеру телефона
+// основной аккаунт клиента и его валютные счета
+func (al *AccountList) GetOwnedAccounts(ownerID string) []*Account {
+	result := []*Account{}
+	for _, acc := range al.GetAccounts() {
+		if acc.ID == ownerID || acc.OwnerID == ownerID {
+			result = append(result, acc)
+		}
+	}
+	return result
+}
+
+// поиск аккаунтов по части ID, имени или номера телефона без учёта регистра
+func (al *AccountList) SearchAccounts(query string) []*Account {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	result := []*Account{}
+	for _, acc := range al.GetAccounts() {
+		if strings.Contains(acc.ID, query) || strings.Contains(acc.Phone, query) ||
+			strings.Contains(strings.ToLower(acc.Name), query) {
+			result = append(result, acc)
+		}
+	}
+	return result
+}
+
+// получение копии аккаунта по ID или номеру телефона
 func (al *AccountList) GetAccount(id string) (*Account, error) {
 	al.mu.RLock()
 	defer al.mu.RUnlock()
 
-	return al.findAccount(id)
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return nil, err
+	}
+	return acc.clone(), nil
+}
+
+// история операций аккаунта, новые сверху
+func (al *AccountList) GetTransactions(id string) ([]*Transaction, error) {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]*Transaction, 0, len(acc.Transactions))
+	for i := len(acc.Transactions) - 1; i >= 0; i-- {
+		tx := acc.Transactions[i]
+		transactions = append(transactions, &tx)
+	}
+	return transactions, nil
+}
+
+// смена роли аккаунта
+func (al *AccountList) SetRole(id, role string) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return err
+	}
+	acc.Role = role
+	return nil
+}
+
+// смена статуса аккаунта; история операций сохраняется
+func (al *AccountList) SetStatus(id, status, reason string) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return err
+	}
+	return acc.SetStatus(status, reason)
+}
+
+// установка лимита и годовой ставки овердрафта; дни до вчерашнего
+// включительно начисляются по прежней ставке
+func (al *AccountList) SetOverdraft(id string, limit money.Money, annualRate string) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return err
+	}
+	if err := ValidateOverdraft(acc.Currency(), limit, annualRate); err != nil {
+		return err
+	}
+	if _, err := acc.chargeOverdraftInterest(UTCDate(time.Now()).AddDate(0, 0, -1)); err != nil {
+		return err
+	}
+	acc.OverdraftLimit = limit
+	acc.OverdraftRate = annualRate
+	return nil
+}
+
+// порог подтверждения переводов; nil возвращает порог по умолчанию
+func (al *AccountList) SetConfirmThreshold(id string, threshold *money.Money) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return err
+	}
+	if err := ValidateConfirmThreshold(acc.Currency(), threshold); err != nil {
+		return err
+	}
+	if threshold == nil {
+		acc.ConfirmThreshold = nil
+		return nil
+	}
+	copied := *threshold
+	acc.ConfirmThreshold = &copied
+	return nil
+}
+
+// списание процентов за отрицательный остаток за дни по through
+// включительно; возвращает сумму процентов. обработанные дни сохраняются
+// в счёте, поэтому повторный вызов после перезапуска ничего не списывает
+func (al *AccountList) ChargeOverdraftInterest(id string, through time.Time) (money.Money, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return acc.chargeOverdraftInterest(through)
+}
+
+// запрос клиента на смену лимитов, см. RequestLimits
+func (al *AccountList) RequestLimits(id string, requested SpendingLimits, cooling time.Duration) (SpendingLimits, *PendingLimits, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return SpendingLimits{}, nil, err
+	}
+	if err := requested.Validate(acc.Currency()); err != nil {
+		return SpendingLimits{}, nil, err
+	}
+
+	now := time.Now()
+	acc.Limits, acc.PendingLimits = RequestLimits(acc.SpendingLimits(now), requested, now, cooling)
+	return acc.Limits, acc.PendingLimits, nil
+}
+
+// установка лимитов администратором; ожидающее повышение снимается
+func (al *AccountList) SetLimits(id string, limits SpendingLimits) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return err
+	}
+	if err := limits.Validate(acc.Currency()); err != nil {
+		return err
+	}
+	acc.Limits = limits
+	acc.PendingLimits = nil
+	return nil
+}
+
+// смена продукта и ставки: сначала проценты начисляются по прежним
+// условиям за все дни до вчерашнего включительно, новые условия действуют
+// с сегодняшнего дня
+func (al *AccountList) SetProduct(id, product, annualRate string, maturity *time.Time) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return err
+	}
+	return acc.setProduct(product, annualRate, maturity, time.Now())
+}
+
+// начисление процентов по through включительно, см. Account.accrueInterest
+func (al *AccountList) AccrueInterest(id string, through time.Time) (money.Money, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(id)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return acc.accrueInterest(through)
+}
+
+// выпуск карты к счёту c.AccountID; номер карты уникален среди всех счетов
+func (al *AccountList) IssueCard(c *Card) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(c.AccountID)
+	if err != nil {
+		return err
+	}
+	if err := CheckCardIssue(acc.Status, acc.cardPointers(), time.Now()); err != nil {
+		return err
+	}
+	if _, _, err := al.findCardByPAN(c.PAN); err == nil {
+		return fmt.Errorf("card number collision, try again")
+	}
+	acc.Cards = append(acc.Cards, c.sealed(al.keys))
+	return nil
+}
+
+// копия карты по ID
+func (al *AccountList) GetCard(id string) (*Card, error) {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	c, err := al.findCard(id)
+	if err != nil {
+		return nil, err
+	}
+	copied := *c
+	return &copied, nil
+}
+
+// карты перечисленных счетов, новые сверху
+func (al *AccountList) GetCards(accountIDs []string) []*Card {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	cards := []*Card{}
+	for _, id := range accountIDs {
+		acc, exists := al.accounts[id]
+		if !exists {
+			continue
+		}
+		for _, c := range acc.Cards {
+			copied := c
+			cards = append(cards, &copied)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].CreatedAt.After(cards[j].CreatedAt) })
+	return cards
+}
+
+// смена статуса карты, см. Card.SetStatus
+func (al *AccountList) SetCardStatus(id, status, reason string) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	c, err := al.findCard(id)
+	if err != nil {
+		return err
+	}
+	return c.SetStatus(status, reason, time.Now())
+}
+
+// смена PIN карты
+func (al *AccountList) SetCardPIN(id, pinHash string) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	c, err := al.findCard(id)
+	if err != nil {
+		return err
+	}
+	c.PINHash = pinHash
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// перевыпуск карты, см. Card.Reissue; счёт должен быть активен
+func (al *AccountList) ReissueCard(id string) (*Card, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	c, err := al.findCard(id)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := al.findAccount(c.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc.EffectiveStatus() != StatusActive {
+		return nil, fmt.Errorf("account is %s", acc.EffectiveStatus())
+	}
+
+	previous := *c
+	replacement, err := c.Reissue(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := al.findCardByPAN(replacement.PAN); err == nil {
+		*c = previous
+		return nil, fmt.Errorf("card number collision, try again")
+	}
+	acc.Cards = append(acc.Cards, replacement.sealed(al.keys))
+	return replacement, nil
+}
+
+// авторизация платежа по карте: проверка реквизитов, доступного
+// остатка и лимитов и блокировка суммы. в истории появляется операция
+// card_payment в статусе pending
+func (al *AccountList) Authorize(a *Authorization) (*Hold, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	now := time.Now()
+	card, acc, err := al.findCardByPAN(a.PAN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid card details")
+	}
+	if err := card.Verify(al.keys, a.ExpiryMonth, a.ExpiryYear, a.CVC2, now); err != nil {
+		if errors.Is(err, ErrInvalidCardDetails) {
+			card.FailDetails(a.Policy, now)
+		}
+		return nil, err
+	}
+	if acc.IsExpired() {
+		return nil, fmt.Errorf("account is expired")
+	}
+	if err := a.Validate(acc.Status, acc.EffectiveProduct(), acc.MaturityDate, acc.Currency(), now); err != nil {
+		return nil, err
+	}
+	if err := CheckFunds(acc.Available(), acc.Overdraft(), a.Amount); err != nil {
+		return nil, err
+	}
+	if err := acc.checkSpending(a.Amount); err != nil {
+		return nil, err
+	}
+
+	card.Attempts = nil
+	hold := NewHold(card, a, now)
+	al.lastHoldID++
+	hold.ID = al.lastHoldID
+	acc.Holds = append(acc.Holds, *hold)
+	acc.Held = money.FromMinor(acc.Held.Amount+hold.Amount.Amount, acc.Currency())
+	acc.Transactions = append(acc.Transactions, Transaction{
+		ID:          len(acc.Transactions) + 1,
+		Type:        TypeCardPayment,
+		FromAccount: acc.ID,
+		ToAccount:   hold.Merchant,
+		Amount:      hold.Amount,
+		Timestamp:   now,
+		Status:      HoldPending,
+		HoldID:      hold.ID,
+	})
+	return hold, nil
+}
+
+// копия блокировки по ID
+func (al *AccountList) GetHold(id int64) (*Hold, error) {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	hold, _, err := al.findHold(id)
+	if err != nil {
+		return nil, err
+	}
+	copied := *hold
+	return &copied, nil
+}
+
+// списание по блокировке: баланс уменьшается на amount,
+// блокировка снимается целиком
+func (al *AccountList) CaptureHold(id int64, amount money.Money) (*Hold, error) {
+	return al.releaseHold(id, func(hold *Hold, now time.Time) error {
+		return hold.Capture(amount, now)
+	})
+}
+
+// отмена блокировки без списания
+func (al *AccountList) ReverseHold(id int64) (*Hold, error) {
+	return al.releaseHold(id, func(hold *Hold, now time.Time) error {
+		return hold.Reverse(now)
+	})
+}
+
+// снятие блокировок со сроком не позже now; возвращает их число
+func (al *AccountList) ExpireHolds(now time.Time) int {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	expired := 0
+	for _, acc := range al.accounts {
+		for i := range acc.Holds {
+			if acc.Holds[i].Expire(now) {
+				acc.settleHold(&acc.Holds[i], now)
+				expired++
+			}
+		}
+	}
+	return expired
+}
+
+func (al *AccountList) releaseHold(id int64, change func(hold *Hold, now time.Time) error) (*Hold, error) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	hold, acc, err := al.findHold(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := change(hold, now); err != nil {
+		return nil, err
+	}
+	acc.settleHold(hold, now)
+	copied := *hold
+	return &copied, nil
+}
+
+// снятие блокировки со счёта и обновление операции в истории;
+// списанная сумма проводится при любом статусе счёта
+func (acc *Account) settleHold(hold *Hold, now time.Time) {
+	acc.Held = money.FromMinor(acc.Held.Amount-hold.Amount.Amount, acc.Currency())
+	acc.Balance.Amount -= hold.Captured.Amount
+	for i := range acc.Transactions {
+		tx := &acc.Transactions[i]
+		if tx.Type != TypeCardPayment || tx.HoldID != hold.ID {
+			continue
+		}
+		tx.Status = hold.transactionStatus()
+		if hold.Status == HoldCaptured {
+			tx.Amount = hold.Captured
+			tx.Timestamp = now
+		}
+	}
 }
 
 // удаление аккаунта по ID
@@ -74,63 +545,79 @@ func (al *AccountList) RemoveAccount(id string) error {
 
 	acc, exists := al.accounts[id]
 	if !exists {
-		return fmt.Errorf("account not found")
+		return ErrAccountNotFound
 	}
 
-	delete(al.accountsbyNumber, acc.Phone)
+	if acc.OwnerID == "" {
+		delete(al.accountsbyNumber, acc.Phone)
+	}
 	delete(al.accounts, id)
-
-	return al.saveToFile()
+	return nil
 }
 
 // пополнение баланса
-func (al *AccountList) Deposit(accountID string, amount float64) error {
+func (al *AccountList) Deposit(accountID string, amount money.Money) error {
 	al.mu.Lock()
 	defer al.mu.Unlock()
 
 	acc, err := al.findAccount(accountID)
 	if err != nil {
-		return fmt.Errorf("account not found")
-	}
-
-	if err := acc.Deposit(amount); err != nil {
 		return err
 	}
-	go func() {
-		al.saveToFile()
-	}()
-	// if err := al.saveToFile(); err != nil {
-	// 	return err
-	// }
-	return nil
+	return acc.Deposit(amount)
 }
 
-// снятие средств
-func (al *AccountList) Withdraw(accountID string, amount float64) error {
+// снятие средств; комиссия fee списывается отдельной операцией,
+// баланса должно хватить на сумму вместе с комиссией
+func (al *AccountList) Withdraw(accountID string, amount, fee money.Money) error {
 	al.mu.Lock()
 	defer al.mu.Unlock()
 
 	acc, err := al.findAccount(accountID)
 	if err != nil {
-		return fmt.Errorf("account not found")
+		return err
+	}
+	if err := checkFundsWithFee(acc, amount, fee); err != nil {
+		return err
 	}
-
 	if err := acc.Withdraw(amount); err != nil {
 		return err
 	}
+	return acc.chargeFee(fee)
+}
 
-	go func() {
-		al.saveToFile()
-	}()
-	// if err := al.saveToFile(); err != nil {
-	// 	return err
-	// }
+// перевод средств между аккаунтами одной валюты
+func (al *AccountList) Transfer(from string, to string, amount, fee money.Money) error {
+	return al.transfer(from, to, amount, amount, "", fee)
+}
 
-	return nil
+// перевод с конвертацией: debit списывается в валюте отправителя,
+// credit зачисляется в валюте получателя по курсу rate
+func (al *AccountList) TransferConverted(from, to string, debit, credit money.Money, rate string, fee money.Money) error {
+	return al.transfer(from, to, debit, credit, rate, fee)
 }
 
-// перевод средств между аккаунтами
-func (al *AccountList) Transfer(from string, to string, amount float64) error {
+// хватает ли средств на сумму вместе с комиссией
+func checkFundsWithFee(acc *Account, amount, fee money.Money) error {
+	if fee.IsZero() {
+		return nil
+	}
+	total, err := amount.Add(fee)
+	if err != nil {
+		return err
+	}
+	return CheckFunds(acc.Available(), acc.Overdraft(), total)
+}
+
+// комиссия за операцию, проведённую только что
+func (acc *Account) chargeFee(fee money.Money) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+	return acc.charge(TypeFee, fee, time.Now())
+}
+
+func (al *AccountList) transfer(from, to string, debit, credit money.Money, rate string, fee money.Money) error {
 	al.mu.Lock()
 	defer al.mu.Unlock()
 
@@ -144,50 +631,83 @@ func (al *AccountList) Transfer(from string, to string, amount float64) error {
 		return fmt.Errorf("destination account not found")
 	}
 
-	if fromAcc.IsExpired() || toAcc.IsExpired() {
+	if fromAcc == toAcc {
+		return fmt.Errorf("cannot transfer to the same account")
+	}
+
+	if fromAcc.IsExpired() {
 		return fmt.Errorf("source account is expired")
 	}
+	if toAcc.IsExpired() {
+		return fmt.Errorf("destination account is expired")
+	}
+	if err := CheckDebit(fromAcc.Status); err != nil {
+		return fmt.Errorf("source %v", err)
+	}
+	if err := CheckTerm(fromAcc.EffectiveProduct(), fromAcc.MaturityDate, time.Now()); err != nil {
+		return err
+	}
+	if err := CheckCredit(toAcc.Status); err != nil {
+		return fmt.Errorf("destination %v", err)
+	}
 
-	if amount <= 0 {
+	if !debit.IsPositive() || !credit.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
 
-	if fromAcc.Balance < amount {
-		return fmt.Errorf("insufficient funds")
+	if err := CheckFunds(fromAcc.Available(), fromAcc.Overdraft(), debit); err != nil {
+		return err
+	}
+	if err := checkFundsWithFee(fromAcc, debit, fee); err != nil {
+		return err
+	}
+	if err := fromAcc.checkSpending(debit); err != nil {
+		return err
+	}
+	fromBalance, err := fromAcc.Balance.Sub(debit)
+	if err != nil {
+		return err
+	}
+	toBalance, err := toAcc.Balance.Add(credit)
+	if err != nil {
+		return err
 	}
 
-	fromAcc.Balance -= amount
-	toAcc.Balance += amount
+	fromAcc.Balance = fromBalance
+	toAcc.Balance = toBalance
 
+	var converted *money.Money
+	if rate != "" {
+		converted = &credit
+	}
+
+	timestamp := time.Now()
 	transactionOutAcc := Transaction{
-		ID:          len(fromAcc.Transactions) + 1,
-		Type:        "transfer_out",
-		FromAccount: fromAcc.ID,
-		ToAccount:   toAcc.ID,
-		Amount:      amount,
-		Timestamp:   time.Now(),
-		Status:      "completed",
+		ID:              len(fromAcc.Transactions) + 1,
+		Type:            TypeTransferOut,
+		FromAccount:     fromAcc.ID,
+		ToAccount:       toAcc.ID,
+		Amount:          debit,
+		Timestamp:       timestamp,
+		Status:          "completed",
+		ConvertedAmount: converted,
+		Rate:            rate,
 	}
 	fromAcc.Transactions = append(fromAcc.Transactions, transactionOutAcc)
 
 	transactionInAcc := Transaction{
 		ID:          len(toAcc.Transactions) + 1,
-		Type:        "transfer_in",
+		Type:        TypeTransferIn,
 		FromAccount: fromAcc.ID,
 		ToAccount:   toAcc.ID,
-		Amount:      amount,
-		Timestamp:   time.Now(),
+		Amount:      credit,
+		Timestamp:   timestamp,
 		Status:      "completed",
+		Rate:        rate,
 	}
 	toAcc.Transactions = append(toAcc.Transactions, transactionInAcc)
 
-	go func() {
-		al.saveToFile()
-	}()
-	// if err := al.saveToFile(); err != nil {
-	// 	return err
-	// }
-	return nil
+	return fromAcc.chargeFee(fee)
 }
 
 func (al *AccountList) findAccount(id string) (*Account, error) { // внутренний метод для поиска аккаунта по ID
@@ -197,9 +717,92 @@ func (al *AccountList) findAccount(id string) (*Account, error) { // внутр
 	if acc, exists := al.accountsbyNumber[id]; exists {
 		return acc, nil
 	}
-	return nil, fmt.Errorf("account not found")
+	return nil, ErrAccountNotFound
 }
 
-func (al *AccountList) saveToFile() error {
-	return al.SaveToFile("accounts.json")
+// поиск карты по ID среди всех счетов
+func (al *AccountList) findCard(id string) (*Card, error) {
+	for _, acc := range al.accounts {
+		for i := range acc.Cards {
+			if acc.Cards[i].ID == id {
+				return &acc.Cards[i], nil
+			}
+		}
+	}
+	return nil, ErrCardNotFound
+}
+
+// поиск карты и её счёта по номеру карты
+func (al *AccountList) findCardByPAN(pan string) (*Card, *Account, error) {
+	for _, acc := range al.accounts {
+		for i := range acc.Cards {
+			if acc.Cards[i].PAN == pan {
+				return &acc.Cards[i], acc, nil
+			}
+		}
+	}
+	return nil, nil, ErrCardNotFound
+}
+
+// поиск блокировки и её счёта по ID
+func (al *AccountList) findHold(id int64) (*Hold, *Account, error) {
+	for _, acc := range al.accounts {
+		for i := range acc.Holds {
+			if acc.Holds[i].ID == id {
+				return &acc.Holds[i], acc, nil
+			}
+		}
+	}
+	return nil, nil, ErrHoldNotFound
+}
+
+func (acc *Account) cardPointers() []*Card {
+	cards := make([]*Card, 0, len(acc.Cards))
+	for i := range acc.Cards {
+		cards = append(cards, &acc.Cards[i])
+	}
+	return cards
+}
+
+// копия аккаунта вместе с историей операций
+func (acc *Account) clone() *Account {
+	copied := *acc
+	if acc.PendingLimits != nil {
+		pending := *acc.PendingLimits
+		copied.PendingLimits = &pending
+	}
+	if acc.ConfirmThreshold != nil {
+		threshold := *acc.ConfirmThreshold
+		copied.ConfirmThreshold = &threshold
+	}
+	if acc.MaturityDate != nil {
+		maturity := *acc.MaturityDate
+		copied.MaturityDate = &maturity
+	}
+	if acc.Accrual != nil {
+		accrual := *acc.Accrual
+		copied.Accrual = &accrual
+	}
+	if acc.OverdraftThrough != nil {
+		through := *acc.OverdraftThrough
+		copied.OverdraftThrough = &through
+	}
+	if acc.TwoFactor != nil {
+		copied.TwoFactor = acc.TwoFactor.clone()
+	}
+	if acc.LoginAttempts != nil {
+		copied.LoginAttempts = acc.LoginAttempts.clone()
+	}
+	copied.Cards = cloneCards(acc.Cards)
+	if acc.Holds != nil {
+		copied.Holds = append([]Hold{}, acc.Holds...)
+	}
+	copied.Transactions = append([]Transaction{}, acc.Transactions...)
+	for i, tx := range copied.Transactions {
+		if tx.ConvertedAmount != nil {
+			converted := *tx.ConvertedAmount
+			copied.Transactions[i].ConvertedAmount = &converted
+		}
+	}
+	return &copied
 }
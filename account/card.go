@@ -0,0 +1,282 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// карта не найдена
+var ErrCardNotFound = errors.New("card not found")
+
+// статусы карты
+const (
+	CardActive  = "active"
+	CardBlocked = "blocked" // временная блокировка, владелец может снять
+	CardLost    = "lost"    // утеряна или украдена, блокировка навсегда
+	CardExpired = "expired" // срок действия истёк; не хранится, вычисляется по сроку
+)
+
+// причина блокировки карты после неверных реквизитов подряд
+const CardReasonInvalidDetails = "too many invalid card details"
+
+// действующих и заблокированных карт на одном счёте
+const MaxCardsPerAccount = 5
+
+// срок действия новой карты
+const cardValidityYears = 5
+
+// банковская карта, выпущенная к счёту; номер карты не связан с номером счёта
+type Card struct {
+	ID           string    `json:"id"`
+	AccountID    string    `json:"account_id"`
+	PAN          string    `json:"pan"`                // номер карты
+	CVC2         string    `json:"cvc2,omitempty"`     // Card Verification Code; открыт только в ответе на выпуск
+	CVC2MAC      string    `json:"cvc2_mac,omitempty"` // хранится вместо CVC2, позволяет только проверить код
+	KeyVersion   int       `json:"key_version,omitempty"`
+	PINHash      string    `json:"pin_hash"` // хешированный PIN, отдельный от пароля входа
+	ExpiryMonth  int       `json:"expiry_month"`
+	ExpiryYear   int       `json:"expiry_year"`
+	Status       string    `json:"status"` // active, blocked, lost
+	StatusReason string    `json:"status_reason,omitempty"`
+	ReplacedBy   string    `json:"replaced_by,omitempty"` // карта, выпущенная взамен
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// неверные реквизиты подряд при авторизации; сбрасываются успешной
+	// авторизацией и снятием блокировки
+	Attempts *LoginAttempts `json:"attempts,omitempty"`
+}
+
+// выпуск новой карты к счёту
+func NewCard(accountID, pin string) (*Card, error) {
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+	return newCard(accountID, hashPassword(pin), time.Now()), nil
+}
+
+func newCard(accountID, pinHash string, now time.Time) *Card {
+	generator := NewCardGenerator()
+	expiry := now.UTC().AddDate(cardValidityYears, 0, 0)
+	return &Card{
+		ID:          newCardID(),
+		AccountID:   accountID,
+		PAN:         generator.GenerateCardNumber(),
+		CVC2:        generator.GenerateCVC(),
+		PINHash:     pinHash,
+		ExpiryMonth: int(expiry.Month()),
+		ExpiryYear:  expiry.Year(),
+		Status:      CardActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// случайный идентификатор карты, не раскрывающий номер
+func newCardID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("Card ID generation failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// карта действует до конца месяца окончания срока (UTC)
+func (c *Card) IsExpired(now time.Time) bool {
+	end := time.Date(c.ExpiryYear, time.Month(c.ExpiryMonth)+1, 1, 0, 0, 0, 0, time.UTC)
+	return !now.Before(end)
+}
+
+// статус с учётом срока действия
+func (c *Card) EffectiveStatus(now time.Time) string {
+	if c.Status != CardLost && c.IsExpired(now) {
+		return CardExpired
+	}
+	return c.Status
+}
+
+// срок действия в формате MM/YY, как на карте
+func (c *Card) Expiry() string {
+	return fmt.Sprintf("%02d/%02d", c.ExpiryMonth, c.ExpiryYear%100)
+}
+
+// разбор срока действия MM/YY; год возвращается четырьмя цифрами
+func ParseExpiry(s string) (month, year int, err error) {
+	if len(s) != 5 || s[2] != '/' {
+		return 0, 0, fmt.Errorf("expiry must be MM/YY")
+	}
+	if month, err = strconv.Atoi(s[:2]); err != nil || month < 1 || month > 12 {
+		return 0, 0, fmt.Errorf("expiry must be MM/YY")
+	}
+	if year, err = strconv.Atoi(s[3:]); err != nil || year < 0 {
+		return 0, 0, fmt.Errorf("expiry must be MM/YY")
+	}
+	return month, 2000 + year, nil
+}
+
+// номер с открытыми первыми шестью и последними четырьмя цифрами
+func MaskPAN(pan string) string {
+	if len(pan) < 10 {
+		return pan
+	}
+	masked := []byte(pan)
+	for i := 6; i < len(masked)-4; i++ {
+		masked[i] = '*'
+	}
+	return string(masked)
+}
+
+// проверка PIN карты
+func (c *Card) CheckPIN(pin string) bool {
+	return CheckPasswordHash(pin, c.PINHash)
+}
+
+// хеш нового PIN
+func HashPIN(pin string) (string, error) {
+	if err := validatePIN(pin); err != nil {
+		return "", err
+	}
+	return hashPassword(pin), nil
+}
+
+// смена статуса: блокировку снимает только владелец незаменённой карты,
+// утерянная и истёкшая карты не разблокируются
+func (c *Card) SetStatus(status, reason string, now time.Time) error {
+	current := c.EffectiveStatus(now)
+	if current == status {
+		return fmt.Errorf("card is already %s", status)
+	}
+	switch {
+	case current == CardLost:
+		return fmt.Errorf("card is reported lost, reissue it")
+	case status == CardLost:
+	case current == CardExpired:
+		return fmt.Errorf("card is expired, reissue it")
+	case status == CardBlocked:
+	case status == CardActive:
+		if c.ReplacedBy != "" {
+			return fmt.Errorf("card has been reissued as %s", c.ReplacedBy)
+		}
+	default:
+		return fmt.Errorf("unknown card status %q", status)
+	}
+	c.Status = status
+	c.StatusReason = reason
+	c.UpdatedAt = now
+	if status == CardActive {
+		c.Attempts = nil
+	}
+	return nil
+}
+
+// неверные реквизиты при авторизации: после p.MaxFailures неудач подряд
+// действующая карта блокируется, как вход после неверных паролей, и
+// снова работает, только когда владелец снимет блокировку. Паузы
+// политики не действуют. Возвращает true, если карта заблокирована
+func (c *Card) FailDetails(p LoginPolicy, now time.Time) bool {
+	if p.MaxFailures < 1 {
+		return false
+	}
+	// счётчик заменяется копией: прежний мог попасть в копии карты
+	attempts := &LoginAttempts{}
+	if c.Attempts != nil {
+		attempts = c.Attempts.clone()
+	}
+	locked := attempts.Fail(now, p)
+	c.Attempts = attempts
+	c.UpdatedAt = now
+	if !locked || c.Status != CardActive {
+		return false
+	}
+	c.Status = CardBlocked
+	c.StatusReason = CardReasonInvalidDetails
+	return true
+}
+
+// перевыпуск с новыми номером, CVC2 и сроком и прежним PIN;
+// действующая старая карта блокируется. возвращает новую карту
+func (c *Card) Reissue(now time.Time) (*Card, error) {
+	if c.ReplacedBy != "" {
+		return nil, fmt.Errorf("card has already been reissued as %s", c.ReplacedBy)
+	}
+	replacement := newCard(c.AccountID, c.PINHash, now)
+	if c.Status == CardActive {
+		c.Status = CardBlocked
+		c.StatusReason = "reissued"
+	}
+	c.ReplacedBy = replacement.ID
+	c.UpdatedAt = now
+	return replacement, nil
+}
+
+// карта в работе: не утеряна, не заменена и не истекла
+func (c *Card) isLive(now time.Time) bool {
+	status := c.EffectiveStatus(now)
+	return c.ReplacedBy == "" && (status == CardActive || status == CardBlocked)
+}
+
+// можно ли выпустить ещё одну карту к счёту в статусе accountStatus
+// с картами cards; общая для всех хранилищ
+func CheckCardIssue(accountStatus string, cards []*Card, now time.Time) error {
+	if normalizeStatus(accountStatus) != StatusActive {
+		return fmt.Errorf("account is %s", normalizeStatus(accountStatus))
+	}
+	live := 0
+	for _, c := range cards {
+		if c.isLive(now) {
+			live++
+		}
+	}
+	if live >= MaxCardsPerAccount {
+		return fmt.Errorf("account already has %d cards", MaxCardsPerAccount)
+	}
+	return nil
+}
+
+// PIN карты: ровно 4 цифры
+func validatePIN(pin string) error {
+	if len(pin) != 4 {
+		return fmt.Errorf("PIN must be exactly 4 digits")
+	}
+	for _, ch := range pin {
+		if ch < '0' || ch > '9' {
+			return fmt.Errorf("PIN must contain only digits")
+		}
+	}
+	return nil
+}
+
+// копия карт счёта
+func cloneCards(cards []Card) []Card {
+	if cards == nil {
+		return nil
+	}
+	return append([]Card{}, cards...)
+}
+
+// перенос карты старого формата, номер которой был ID счёта, в список
+// карт; PIN такой карты до смены совпадает с паролем входа
+func (acc *Account) migrateLegacyCard() {
+	if acc.CVC2 == "" {
+		return
+	}
+	if len(acc.Cards) == 0 {
+		acc.Cards = append(acc.Cards, Card{
+			ID:          newCardID(),
+			AccountID:   acc.ID,
+			PAN:         acc.ID,
+			CVC2:        acc.CVC2,
+			PINHash:     acc.Password,
+			ExpiryMonth: int(acc.ExpiredAt.UTC().Month()),
+			ExpiryYear:  acc.ExpiredAt.UTC().Year(),
+			Status:      CardActive,
+			CreatedAt:   acc.CreatedAt,
+			UpdatedAt:   time.Now(),
+		})
+	}
+	acc.CVC2 = ""
+}
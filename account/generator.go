@@ -34,6 +34,21 @@ func (cg *CardGenerator) GenerateCardNumber() string {
 	return cg.formatCardNumber(digits)
 }
 
+// генерация номера счёта: 20 цифр с контрольной цифрой по алгоритму Луна;
+// по длине не совпадает ни с номером карты, ни с телефоном
+func (cg *CardGenerator) GenerateAccountNumber() string {
+	digits := []int{4, 0, 8, 1, 7}
+
+	for i := 0; i < 14; i++ {
+		digits = append(digits, cg.rng.Intn(10))
+	}
+
+	checkDigit := cg.calculateLuhnCheckDigit(digits)
+	digits = append(digits, checkDigit)
+
+	return cg.formatCardNumber(digits)
+}
+
 // генерация CVC кода
 func (cg *CardGenerator) GenerateCVC() string {
 	cvc := cg.rng.Intn(1000)
@@ -0,0 +1,156 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"mfp/keyring"
+	"mfp/money"
+	"time"
+)
+
+// блокировка по карте не найдена
+var ErrHoldNotFound = errors.New("hold not found")
+
+// срок или CVC2 не совпадают с картой; такие отказы считаются
+// Card.FailDetails
+var ErrInvalidCardDetails = errors.New("invalid card details")
+
+// статусы блокировки суммы по карте
+const (
+	HoldPending  = "pending"  // сумма заблокирована и ждёт списания
+	HoldCaptured = "captured" // списана полностью или частично
+	HoldReversed = "reversed" // отменена продавцом
+	HoldExpired  = "expired"  // не списана до окончания срока
+)
+
+// запрос на авторизацию платежа по карте
+type Authorization struct {
+	PAN         string
+	ExpiryMonth int
+	ExpiryYear  int // четыре цифры
+	CVC2        string
+	Amount      money.Money
+	Merchant    string
+	TTL         time.Duration // сколько держать блокировку без списания
+	Policy      LoginPolicy   // блокировка карты после неверных реквизитов подряд
+}
+
+// сумма, заблокированная на счёте по авторизации карты: доступный остаток
+// уменьшается сразу, баланс — только при списании
+type Hold struct {
+	ID        int64       `json:"id"`
+	CardID    string      `json:"card_id"`
+	AccountID string      `json:"account_id"`
+	Merchant  string      `json:"merchant"`
+	Amount    money.Money `json:"amount"`   // заблокированная сумма
+	Captured  money.Money `json:"captured"` // списанная сумма
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// проверка реквизитов карты при авторизации; несовпадение срока
+// и CVC2 не различаются, чтобы их нельзя было подбирать по отдельности
+func (c *Card) Verify(k *keyring.Keyring, expiryMonth, expiryYear int, cvc2 string, now time.Time) error {
+	if !c.CheckCVC2(k, cvc2) || expiryMonth != c.ExpiryMonth || expiryYear != c.ExpiryYear {
+		return ErrInvalidCardDetails
+	}
+	if status := c.EffectiveStatus(now); status != CardActive {
+		return fmt.Errorf("card is %s", status)
+	}
+	return nil
+}
+
+// проверка авторизации по счёту: статус, срок вклада, валюта и сумма;
+// средства и лимиты проверяет хранилище
+func (a *Authorization) Validate(accountStatus, product string, maturity *time.Time, currency string, now time.Time) error {
+	if err := CheckDebit(accountStatus); err != nil {
+		return err
+	}
+	if err := CheckTerm(product, maturity, now); err != nil {
+		return err
+	}
+	if a.Amount.Currency != currency {
+		return fmt.Errorf("card account is in %s, amount is in %s", currency, a.Amount.Currency)
+	}
+	if !a.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	if a.TTL <= 0 {
+		return fmt.Errorf("hold TTL must be positive")
+	}
+	return nil
+}
+
+// новая блокировка по авторизации; ID назначает хранилище
+func NewHold(card *Card, a *Authorization, now time.Time) *Hold {
+	return &Hold{
+		CardID:    card.ID,
+		AccountID: card.AccountID,
+		Merchant:  a.Merchant,
+		Amount:    a.Amount,
+		Captured:  money.Zero(a.Amount.Currency),
+		Status:    HoldPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.TTL),
+		UpdatedAt: now,
+	}
+}
+
+// списание amount из заблокированной суммы; остаток разблокируется,
+// повторное списание по той же блокировке невозможно
+func (h *Hold) Capture(amount money.Money, now time.Time) error {
+	if h.Status != HoldPending {
+		return fmt.Errorf("hold is %s", h.Status)
+	}
+	if !now.Before(h.ExpiresAt) {
+		return fmt.Errorf("hold has expired")
+	}
+	if amount.Currency != h.Amount.Currency {
+		return fmt.Errorf("hold is in %s, amount is in %s", h.Amount.Currency, amount.Currency)
+	}
+	if !amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	if amount.Amount > h.Amount.Amount {
+		return fmt.Errorf("capture amount exceeds authorized %s", h.Amount.Format())
+	}
+	h.Captured = amount
+	h.Status = HoldCaptured
+	h.UpdatedAt = now
+	return nil
+}
+
+// отмена блокировки продавцом, в том числе после окончания срока
+func (h *Hold) Reverse(now time.Time) error {
+	if h.Status != HoldPending {
+		return fmt.Errorf("hold is %s", h.Status)
+	}
+	h.Status = HoldReversed
+	h.UpdatedAt = now
+	return nil
+}
+
+// снятие несписанной блокировки по окончании срока; false — срок не вышел
+func (h *Hold) Expire(now time.Time) bool {
+	if h.Status != HoldPending || now.Before(h.ExpiresAt) {
+		return false
+	}
+	h.Status = HoldExpired
+	h.UpdatedAt = now
+	return true
+}
+
+// статус операции в истории для блокировки в этом статусе
+func (h *Hold) transactionStatus() string {
+	if h.Status == HoldCaptured {
+		return "completed"
+	}
+	return h.Status
+}
+
+// доступный остаток: баланс без заблокированных по картам сумм
+func (acc *Account) Available() money.Money {
+	return money.FromMinor(acc.Balance.Amount-acc.Held.Amount, acc.Currency())
+}
@@ -0,0 +1,222 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"mfp/money"
+	"strings"
+	"time"
+)
+
+// списание со срочного вклада до окончания срока
+var ErrDepositTerm = errors.New("deposit term has not ended")
+
+// счётные продукты
+const (
+	ProductCurrent = "current" // расчётный счёт, проценты не начисляются
+	ProductSavings = "savings" // накопительный: проценты, снятие в любой момент
+	ProductDeposit = "deposit" // срочный вклад: проценты, списания только после срока
+)
+
+// знаков после запятой у начисленных, но ещё не капитализированных процентов
+const accrualDigits = 8
+
+// проценты, начисленные по дням и ещё не зачисленные на счёт
+type Accrual struct {
+	Interest string    `json:"interest"` // в валюте счёта, до 8 знаков после запятой
+	Through  time.Time `json:"through"`  // последний день (UTC), за который начислено
+}
+
+// продукт счёта; у счетов, сохранённых до появления продуктов, он пустой
+func (acc *Account) EffectiveProduct() string {
+	if acc.Product == "" {
+		return ProductCurrent
+	}
+	return acc.Product
+}
+
+// проверка продукта: на расчётном счёте ставка нулевая, у вклада
+// срок окончания позже сегодняшнего дня
+func ValidateProduct(product, annualRate string, maturity *time.Time, now time.Time) error {
+	rate, err := parseAnnualRate(annualRate, "interest")
+	if err != nil {
+		return err
+	}
+	switch product {
+	case ProductCurrent:
+		if rate.Sign() != 0 {
+			return fmt.Errorf("current accounts do not earn interest")
+		}
+	case ProductSavings:
+	case ProductDeposit:
+		if maturity == nil {
+			return fmt.Errorf("deposit requires a maturity date")
+		}
+		if !UTCDate(now).Before(UTCDate(*maturity)) {
+			return fmt.Errorf("maturity date must be in the future")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown product %q", product)
+	}
+	if maturity != nil {
+		return fmt.Errorf("maturity date is only allowed for deposits")
+	}
+	return nil
+}
+
+// списания со срочного вклада разрешены с даты окончания срока
+func CheckTerm(product string, maturity *time.Time, now time.Time) error {
+	if product != ProductDeposit || maturity == nil || !UTCDate(now).Before(UTCDate(*maturity)) {
+		return nil
+	}
+	return fmt.Errorf("%w: withdrawals are allowed from %s", ErrDepositTerm, UTCDate(*maturity).Format("2006-01-02"))
+}
+
+// начало суток в UTC; даты начисления процентов хранятся так
+func UTCDate(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// дней в году: 366 в високосный
+func DaysInYear(year int) int {
+	if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		return 366
+	}
+	return 365
+}
+
+// начисление процентов за день day на остаток на конец дня:
+// остаток × ставка / 100 / число дней в году дня day. отрицательный остаток
+// процентов не приносит, по вкладу начисление идёт до даты окончания срока.
+// в последний день месяца и в последний день срока вклада начисленное
+// капитализируется: целые минорные единицы возвращаются для зачисления,
+// остаток переносится дальше. общая для AccountList и репозитория PostgreSQL
+func AccrueDay(accrued string, balance money.Money, annualRate string, maturity *time.Time, day time.Time) (string, money.Money, error) {
+	capitalized := money.Zero(balance.Currency)
+	total, err := parseAccrued(accrued)
+	if err != nil {
+		return accrued, capitalized, err
+	}
+	rate, err := parseAnnualRate(annualRate, "interest")
+	if err != nil {
+		return accrued, capitalized, err
+	}
+
+	day = UTCDate(day)
+	earning := maturity == nil || day.Before(UTCDate(*maturity))
+	if earning && balance.IsPositive() && rate.Sign() > 0 {
+		daily, _ := new(big.Rat).SetString(balance.String())
+		daily.Mul(daily, rate)
+		daily.Quo(daily, big.NewRat(100*int64(DaysInYear(day.Year())), 1))
+		total.Add(total, daily)
+	}
+
+	next := day.AddDate(0, 0, 1)
+	endOfTerm := maturity != nil && next.Equal(UTCDate(*maturity))
+	if next.Month() != day.Month() || endOfTerm {
+		// целые тиыны и центы вниз, дробная часть остаётся в начислении
+		minor := new(big.Int).Quo(new(big.Int).Mul(total.Num(), big.NewInt(100)), total.Denom())
+		capitalized = money.FromMinor(minor.Int64(), balance.Currency)
+		total.Sub(total, new(big.Rat).SetFrac(minor, big.NewInt(100)))
+	}
+	return formatAccrued(total), capitalized, nil
+}
+
+// начисленные проценты: пустая строка — ноль
+func parseAccrued(s string) (*big.Rat, error) {
+	if s == "" {
+		return new(big.Rat), nil
+	}
+	total, ok := new(big.Rat).SetString(s)
+	if !ok || total.Sign() < 0 {
+		return nil, fmt.Errorf("invalid accrued interest %q", s)
+	}
+	return total, nil
+}
+
+// десятичная запись без лишних нулей: "0.12340000" -> "0.1234"
+func formatAccrued(total *big.Rat) string {
+	s := total.FloatString(accrualDigits)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// остаток на конец дня day: текущий баланс без операций, проведённых позже
+func (acc *Account) balanceAt(day time.Time) money.Money {
+	end := UTCDate(day).AddDate(0, 0, 1)
+	balance := acc.Balance
+	for _, tx := range acc.Transactions {
+		if tx.Timestamp.Before(end) || !tx.IsBooked() {
+			continue
+		}
+		if tx.IsIncoming() {
+			balance.Amount -= tx.Amount.Amount
+		} else {
+			balance.Amount += tx.Amount.Amount
+		}
+	}
+	return balance
+}
+
+// начисление процентов за дни после последнего начисленного по through
+// включительно; капитализированные проценты зачисляются операцией interest
+// в начале следующего дня. возвращает сумму зачислений
+func (acc *Account) accrueInterest(through time.Time) (money.Money, error) {
+	credited := money.Zero(acc.Currency())
+	if acc.Accrual == nil {
+		return credited, nil
+	}
+
+	through = UTCDate(through)
+	for day := acc.Accrual.Through.AddDate(0, 0, 1); !day.After(through); day = day.AddDate(0, 0, 1) {
+		accrued, capitalized, err := AccrueDay(acc.Accrual.Interest, acc.balanceAt(day), acc.InterestRate, acc.MaturityDate, day)
+		if err != nil {
+			return credited, err
+		}
+		if capitalized.IsPositive() {
+			acc.creditInterest(capitalized, day.AddDate(0, 0, 1))
+			credited.Amount += capitalized.Amount
+		}
+		acc.Accrual = &Accrual{Interest: accrued, Through: day}
+	}
+	return credited, nil
+}
+
+// смена продукта или ставки: дни до вчерашнего включительно начисляются
+// по прежней ставке, новая действует с сегодняшнего дня
+func (acc *Account) setProduct(product, annualRate string, maturity *time.Time, now time.Time) error {
+	if err := ValidateProduct(product, annualRate, maturity, now); err != nil {
+		return err
+	}
+
+	yesterday := UTCDate(now).AddDate(0, 0, -1)
+	if _, err := acc.accrueInterest(yesterday); err != nil {
+		return err
+	}
+	if acc.Accrual == nil && product != ProductCurrent {
+		acc.Accrual = &Accrual{Interest: "0", Through: yesterday}
+	}
+	acc.Product = product
+	acc.InterestRate = annualRate
+	acc.MaturityDate = nil
+	if maturity != nil {
+		date := UTCDate(*maturity)
+		acc.MaturityDate = &date
+	}
+	return nil
+}
+
+// зачисление капитализированных процентов; проходит при любом статусе счёта
+func (acc *Account) creditInterest(amount money.Money, at time.Time) {
+	acc.Balance.Amount += amount.Amount
+	acc.Transactions = append(acc.Transactions, Transaction{
+		ID:        len(acc.Transactions) + 1,
+		Type:      TypeInterest,
+		ToAccount: acc.ID,
+		Amount:    amount,
+		Timestamp: at,
+		Status:    "completed",
+	})
+}
@@ -0,0 +1,218 @@
+package account
+
+import (
+	"mfp/money"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDaysInYear(t *testing.T) {
+	tests := []struct {
+		year int
+		want int
+	}{
+		{year: 2023, want: 365},
+		{year: 2024, want: 366},
+		{year: 1900, want: 365},
+		{year: 2000, want: 366},
+		{year: 2100, want: 365},
+	}
+
+	for _, tt := range tests {
+		if got := DaysInYear(tt.year); got != tt.want {
+			t.Errorf("DaysInYear(%d) = %d, want %d", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestAccrueDay(t *testing.T) {
+	kzt := func(amount int64) money.Money { return money.FromMinor(amount, "KZT") }
+	maturity := date(2024, 3, 10)
+
+	tests := []struct {
+		name            string
+		accrued         string
+		balance         money.Money
+		rate            string
+		maturity        *time.Time
+		day             time.Time
+		wantAccrued     string
+		wantCapitalized int64
+	}{
+		{
+			name:        "leap year divides by 366",
+			balance:     kzt(3660000),
+			rate:        "10",
+			day:         date(2024, 2, 28),
+			wantAccrued: "10",
+		},
+		{
+			name:        "common year divides by 365",
+			balance:     kzt(3650000),
+			rate:        "10",
+			day:         date(2023, 2, 27),
+			wantAccrued: "10",
+		},
+		{
+			name:            "february 29 ends the month",
+			accrued:         "0.005",
+			balance:         kzt(3660000),
+			rate:            "10",
+			day:             date(2024, 2, 29),
+			wantAccrued:     "0.005",
+			wantCapitalized: 1000,
+		},
+		{
+			name:        "february 28 of a leap year does not capitalize",
+			balance:     kzt(3660000),
+			rate:        "10",
+			day:         date(2024, 2, 28),
+			wantAccrued: "10",
+		},
+		{
+			name:            "february 28 of a common year capitalizes",
+			balance:         kzt(3650000),
+			rate:            "10",
+			day:             date(2023, 2, 28),
+			wantAccrued:     "0",
+			wantCapitalized: 1000,
+		},
+		{
+			name:        "fraction of a minor unit is carried",
+			balance:     kzt(10000),
+			rate:        "1",
+			day:         date(2024, 1, 15),
+			wantAccrued: "0.00273224",
+		},
+		{
+			name:        "negative balance earns nothing",
+			accrued:     "1.5",
+			balance:     kzt(-10000),
+			rate:        "10",
+			day:         date(2024, 1, 15),
+			wantAccrued: "1.5",
+		},
+		{
+			name:            "last day before maturity capitalizes",
+			accrued:         "2.5",
+			balance:         kzt(3660000),
+			rate:            "10",
+			maturity:        &maturity,
+			day:             date(2024, 3, 9),
+			wantAccrued:     "0",
+			wantCapitalized: 1250,
+		},
+		{
+			name:        "no interest after maturity",
+			balance:     kzt(3660000),
+			rate:        "10",
+			maturity:    &maturity,
+			day:         date(2024, 3, 10),
+			wantAccrued: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accrued, capitalized, err := AccrueDay(tt.accrued, tt.balance, tt.rate, tt.maturity, tt.day)
+			if err != nil {
+				t.Fatalf("AccrueDay() unexpected error: %v", err)
+			}
+			if accrued != tt.wantAccrued {
+				t.Errorf("accrued = %q, want %q", accrued, tt.wantAccrued)
+			}
+			if capitalized.Amount != tt.wantCapitalized {
+				t.Errorf("capitalized = %s, want %s", capitalized, kzt(tt.wantCapitalized))
+			}
+		})
+	}
+}
+
+// весь високосный год с постоянным остатком: 366 дней по 1.00
+func TestAccrueLeapYear(t *testing.T) {
+	balance := money.FromMinor(1000000, "KZT")
+	accrued := ""
+	var total int64
+	days, capitalizations := 0, 0
+
+	for day := date(2024, 1, 1); day.Year() == 2024; day = day.AddDate(0, 0, 1) {
+		var (
+			capitalized money.Money
+			err         error
+		)
+		accrued, capitalized, err = AccrueDay(accrued, balance, "3.66", nil, day)
+		if err != nil {
+			t.Fatalf("AccrueDay(%s): %v", day.Format("2006-01-02"), err)
+		}
+		if capitalized.IsPositive() {
+			capitalizations++
+		}
+		if day.Month() == time.February && day.Day() == 29 && capitalized.Amount != 2900 {
+			t.Errorf("capitalized on 2024-02-29 = %s, want 29.00", capitalized)
+		}
+		total += capitalized.Amount
+		days++
+	}
+
+	if days != 366 {
+		t.Fatalf("iterated %d days, want 366", days)
+	}
+	if capitalizations != 12 {
+		t.Errorf("capitalizations = %d, want 12", capitalizations)
+	}
+	if total != 36600 || accrued != "0" {
+		t.Errorf("total = %d, accrued = %q, want 36600 and \"0\"", total, accrued)
+	}
+}
+
+// смена ставки в середине месяца: дни до смены начисляются по прежней
+// ставке, до капитализации в конце месяца
+func TestSetProductRateChangeMidMonth(t *testing.T) {
+	acc := &Account{
+		ID:           "A1",
+		Balance:      money.FromMinor(3660000, "KZT"),
+		Product:      ProductSavings,
+		InterestRate: "10",
+		Accrual:      &Accrual{Interest: "0", Through: date(2024, 2, 9)},
+	}
+
+	// 10..15 февраля по 10%: 6 × 10.00
+	if err := acc.setProduct(ProductSavings, "5", nil, date(2024, 2, 16).Add(12*time.Hour)); err != nil {
+		t.Fatalf("setProduct() unexpected error: %v", err)
+	}
+	if acc.InterestRate != "5" {
+		t.Errorf("InterestRate = %q, want 5", acc.InterestRate)
+	}
+	if got := acc.Accrual; got.Interest != "60" || !got.Through.Equal(date(2024, 2, 15)) {
+		t.Fatalf("Accrual after rate change = %+v, want 60 through 2024-02-15", got)
+	}
+	if len(acc.Transactions) != 0 {
+		t.Fatalf("interest capitalized before the end of the month: %+v", acc.Transactions)
+	}
+
+	// 16..29 февраля по 5%: 14 × 5.00
+	credited, err := acc.accrueInterest(date(2024, 2, 29))
+	if err != nil {
+		t.Fatalf("accrueInterest() unexpected error: %v", err)
+	}
+	if credited.Amount != 13000 {
+		t.Errorf("credited = %s, want 130.00", credited)
+	}
+	if acc.Balance.Amount != 3673000 {
+		t.Errorf("balance = %s, want 36730.00", acc.Balance)
+	}
+	if len(acc.Transactions) != 1 {
+		t.Fatalf("transactions = %d, want 1", len(acc.Transactions))
+	}
+	tx := acc.Transactions[0]
+	if tx.Type != TypeInterest || !tx.Timestamp.Equal(date(2024, 3, 1)) {
+		t.Errorf("capitalization = %s at %s, want interest at 2024-03-01", tx.Type, tx.Timestamp)
+	}
+	if acc.Accrual.Interest != "0" {
+		t.Errorf("accrued after capitalization = %q, want 0", acc.Accrual.Interest)
+	}
+}
@@ -0,0 +1,175 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"mfp/money"
+	"time"
+)
+
+// списание превышает лимит расходов
+var ErrLimitExceeded = errors.New("spending limit exceeded")
+
+// лимиты на снятие и исходящие переводы в валюте счёта; ноль — без лимита
+type SpendingLimits struct {
+	PerTransaction money.Money `json:"per_transaction"`
+	Daily          money.Money `json:"daily"`
+	Monthly        money.Money `json:"monthly"`
+}
+
+// повышение лимитов, ожидающее одобрения администратора или окончания
+// периода охлаждения
+type PendingLimits struct {
+	SpendingLimits
+	EffectiveAt time.Time `json:"effective_at"`
+}
+
+// проверка лимитов: в валюте счёта и не отрицательные
+func (l SpendingLimits) Validate(currency string) error {
+	for _, limit := range []struct {
+		name  string
+		value money.Money
+	}{
+		{"per_transaction", l.PerTransaction},
+		{"daily", l.Daily},
+		{"monthly", l.Monthly},
+	} {
+		if limit.value.Currency != currency {
+			return fmt.Errorf("%s limit must be in %s", limit.name, currency)
+		}
+		if limit.value.IsNegative() {
+			return fmt.Errorf("%s limit must not be negative", limit.name)
+		}
+	}
+	return nil
+}
+
+// ни одного лимита не задано
+func (l SpendingLimits) IsZero() bool {
+	return l.PerTransaction.IsZero() && l.Daily.IsZero() && l.Monthly.IsZero()
+}
+
+// новый лимит выше текущего; снятие лимита тоже считается повышением
+func raises(current, requested money.Money) bool {
+	if current.IsZero() {
+		return false
+	}
+	return requested.IsZero() || requested.Amount > current.Amount
+}
+
+// есть ли среди запрошенных лимитов повышение
+func (l SpendingLimits) Raises(requested SpendingLimits) bool {
+	return raises(l.PerTransaction, requested.PerTransaction) ||
+		raises(l.Daily, requested.Daily) ||
+		raises(l.Monthly, requested.Monthly)
+}
+
+// меньший из двух лимитов с учётом того, что ноль — отсутствие лимита
+func lower(current, requested money.Money) money.Money {
+	if raises(current, requested) {
+		return current
+	}
+	return requested
+}
+
+// запрос клиента на смену лимитов: понижения действуют сразу,
+// повышения откладываются до now+cooling; новый запрос заменяет
+// прежнее ожидающее повышение
+func RequestLimits(current SpendingLimits, requested SpendingLimits, now time.Time, cooling time.Duration) (SpendingLimits, *PendingLimits) {
+	applied := SpendingLimits{
+		PerTransaction: lower(current.PerTransaction, requested.PerTransaction),
+		Daily:          lower(current.Daily, requested.Daily),
+		Monthly:        lower(current.Monthly, requested.Monthly),
+	}
+	if !current.Raises(requested) {
+		return applied, nil
+	}
+	return applied, &PendingLimits{SpendingLimits: requested, EffectiveAt: now.Add(cooling)}
+}
+
+// действующие лимиты: ожидающее повышение вступает в силу по окончании охлаждения
+func EffectiveLimits(limits SpendingLimits, pending *PendingLimits, now time.Time) SpendingLimits {
+	if pending != nil && !now.Before(pending.EffectiveAt) {
+		return pending.SpendingLimits
+	}
+	return limits
+}
+
+// проверка списания по лимитам; spentToday и spentMonth — сумма снятий
+// и исходящих переводов с начала дня и месяца (UTC). общая для AccountList
+// и репозитория PostgreSQL
+func CheckSpending(limits SpendingLimits, amount, spentToday, spentMonth money.Money) error {
+	if !limits.PerTransaction.IsZero() && amount.Amount > limits.PerTransaction.Amount {
+		return fmt.Errorf("%w: per-transaction limit is %s", ErrLimitExceeded, limits.PerTransaction)
+	}
+	if !limits.Daily.IsZero() && spentToday.Amount+amount.Amount > limits.Daily.Amount {
+		return fmt.Errorf("%w: daily limit is %s, already spent %s", ErrLimitExceeded, limits.Daily, spentToday)
+	}
+	if !limits.Monthly.IsZero() && spentMonth.Amount+amount.Amount > limits.Monthly.Amount {
+		return fmt.Errorf("%w: monthly limit is %s, already spent %s", ErrLimitExceeded, limits.Monthly, spentMonth)
+	}
+	return nil
+}
+
+// начало суток и месяца в UTC, от которых считаются расходы
+func SpendingPeriods(now time.Time) (dayStart, monthStart time.Time) {
+	now = now.UTC()
+	dayStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return dayStart, monthStart
+}
+
+// лимиты счёта в валюте счёта; у счетов, сохранённых до появления
+// лимитов, валюта сумм пустая
+func (acc *Account) SpendingLimits(now time.Time) SpendingLimits {
+	limits := EffectiveLimits(acc.Limits, acc.PendingLimits, now)
+	limits.PerTransaction.Currency = acc.Currency()
+	limits.Daily.Currency = acc.Currency()
+	limits.Monthly.Currency = acc.Currency()
+	return limits
+}
+
+// сумма снятий, исходящих переводов и оплат картой, включая ещё не
+// списанные, с начала дня и месяца (UTC); по ней проверяются лимиты
+// и выбирается ступень тарифа комиссии
+func (acc *Account) Spent(now time.Time) (today, month money.Money) {
+	dayStart, monthStart := SpendingPeriods(now)
+	today, month = money.Zero(acc.Currency()), money.Zero(acc.Currency())
+	for _, tx := range acc.Transactions {
+		switch {
+		case tx.Type == TypeWithdrawal || tx.Type == TypeTransferOut:
+		case tx.Type == TypeCardPayment && (tx.IsBooked() || tx.Status == HoldPending):
+		default:
+			continue
+		}
+		if !tx.Timestamp.Before(monthStart) {
+			month.Amount += tx.Amount.Amount
+		}
+		if !tx.Timestamp.Before(dayStart) {
+			today.Amount += tx.Amount.Amount
+		}
+	}
+	return today, month
+}
+
+// проверка списания amount по лимитам счёта
+func (acc *Account) checkSpending(amount money.Money) error {
+	now := time.Now()
+	today, month := acc.Spent(now)
+	return CheckSpending(acc.SpendingLimits(now), amount, today, month)
+}
+
+// порог подтверждения переводов: в валюте счёта и не отрицательный;
+// ноль требует подтверждать любой перевод
+func ValidateConfirmThreshold(currency string, threshold *money.Money) error {
+	if threshold == nil {
+		return nil
+	}
+	if threshold.Currency != currency {
+		return fmt.Errorf("confirmation threshold must be in %s", currency)
+	}
+	if threshold.IsNegative() {
+		return fmt.Errorf("confirmation threshold must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package account
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// блокировка дольше суток не растёт
+const MaxLockout = 24 * time.Hour
+
+// защита входа от перебора PIN: после каждой неудачи следующая попытка
+// возможна через удваивающуюся паузу, после MaxFailures неудач подряд
+// вход блокируется на Lockout, каждая следующая блокировка вдвое дольше
+type LoginPolicy struct {
+	MaxFailures int
+	BaseDelay   time.Duration // пауза после первой неудачи
+	MaxDelay    time.Duration
+	Lockout     time.Duration // первая блокировка
+}
+
+// пауза после failures неудач подряд
+func (p LoginPolicy) Delay(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	return doubled(p.BaseDelay, failures-1, p.MaxDelay)
+}
+
+// срок lockouts-й блокировки подряд
+func (p LoginPolicy) LockoutDuration(lockouts int) time.Duration {
+	return doubled(p.Lockout, lockouts-1, MaxLockout)
+}
+
+func doubled(base time.Duration, times int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < times && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+// неудачные попытки входа клиента; сбрасываются успешным входом
+// или администратором
+type LoginAttempts struct {
+	Failures      int        `json:"failures"` // неудач подряд с последнего входа или блокировки
+	Lockouts      int        `json:"lockouts"` // блокировок подряд с последнего входа
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// вход временно невозможен
+type LoginBlockedError struct {
+	Until  time.Time
+	Locked bool // блокировка после MaxFailures неудач, а не пауза между попытками
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("login locked until %s", e.Until.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("next login attempt allowed at %s", e.Until.Format(time.RFC3339))
+}
+
+func (a *LoginAttempts) Locked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// начало попытки входа. Пауза до следующей попытки занимается заранее,
+// как если бы пароль оказался неверным: параллельные запросы не успеют
+// проверить пароль, пока неудача не записана
+func (a *LoginAttempts) Begin(now time.Time, p LoginPolicy) error {
+	if a.LockedUntil != nil {
+		if now.Before(*a.LockedUntil) {
+			return &LoginBlockedError{Until: *a.LockedUntil, Locked: true}
+		}
+		// блокировка истекла: неудачи считаются заново
+		a.Failures, a.LockedUntil = 0, nil
+	}
+	if a.NextAttemptAt != nil && now.Before(*a.NextAttemptAt) {
+		return &LoginBlockedError{Until: *a.NextAttemptAt}
+	}
+	next := now.Add(p.Delay(a.Failures + 1))
+	a.NextAttemptAt = &next
+	return nil
+}
+
+// неудачная попытка; возвращает true, если вход заблокирован
+func (a *LoginAttempts) Fail(now time.Time, p LoginPolicy) bool {
+	a.Failures++
+	a.LastFailureAt = &now
+	if a.Failures >= p.MaxFailures {
+		a.Lockouts++
+		until := now.Add(p.LockoutDuration(a.Lockouts))
+		a.LockedUntil, a.NextAttemptAt = &until, nil
+		return true
+	}
+	next := now.Add(p.Delay(a.Failures))
+	a.NextAttemptAt = &next
+	return false
+}
+
+// успешный вход или разблокировка администратором
+func (a *LoginAttempts) Reset() {
+	*a = LoginAttempts{}
+}
+
+func (a *LoginAttempts) clone() *LoginAttempts {
+	copied := *a
+	for _, t := range []**time.Time{&copied.LastFailureAt, &copied.NextAttemptAt, &copied.LockedUntil} {
+		if *t != nil {
+			v := **t
+			*t = &v
+		}
+	}
+	return &copied
+}
+
+// хеш, с которым сравнивается пароль, когда телефон не найден:
+// ответ тогда занимает столько же, сколько при неверном пароле
+var noPasswordHash = sync.OnceValue(func() string {
+	return hashPassword("no-password")
+})
+
+// проверка пароля для неизвестного телефона; всегда false
+func CheckNoPassword(password string) bool {
+	CheckPasswordHash(password, noPasswordHash())
+	return false
+}
+
+// счётчик неудачных входов клиента; у клиента без неудач он пустой
+func (al *AccountList) GetLoginAttempts(accountID string) (*LoginAttempts, error) {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	acc, err := al.findAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc.LoginAttempts == nil {
+		return &LoginAttempts{}, nil
+	}
+	return acc.LoginAttempts.clone(), nil
+}
+
+// изменение счётчика под блокировкой; при ошибке change изменения
+// не сохраняются
+func (al *AccountList) UpdateLoginAttempts(accountID string, change func(a *LoginAttempts) error) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(accountID)
+	if err != nil {
+		return err
+	}
+	attempts := &LoginAttempts{}
+	if acc.LoginAttempts != nil {
+		attempts = acc.LoginAttempts.clone()
+	}
+	if err := change(attempts); err != nil {
+		return err
+	}
+	if *attempts == (LoginAttempts{}) {
+		attempts = nil
+	}
+	acc.LoginAttempts = attempts
+	return nil
+}
@@ -0,0 +1,145 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"mfp/money"
+	"strings"
+	"time"
+)
+
+// списание превышает баланс вместе с лимитом овердрафта
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// наибольшая годовая ставка по овердрафту и вкладам, в процентах
+const maxAnnualRate = 100
+
+// проверка доступных средств: после списания баланс не может опуститься
+// ниже минус лимита овердрафта; общая для AccountList и репозитория PostgreSQL
+func CheckFunds(balance, overdraftLimit, amount money.Money) error {
+	if overdraftLimit.Currency == "" {
+		overdraftLimit = money.Zero(balance.Currency)
+	}
+	available, err := balance.Add(overdraftLimit)
+	if err != nil {
+		return err
+	}
+	cmp, err := available.Cmp(amount)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return fmt.Errorf("%w: available %s, need %s", ErrInsufficientFunds, available, amount)
+	}
+	return nil
+}
+
+// проверка параметров овердрафта: лимит в валюте счёта, ставка от 0 до 100% годовых
+func ValidateOverdraft(currency string, limit money.Money, annualRate string) error {
+	if limit.Currency != currency {
+		return fmt.Errorf("overdraft limit must be in %s", currency)
+	}
+	if limit.IsNegative() {
+		return fmt.Errorf("overdraft limit must not be negative")
+	}
+	_, err := parseAnnualRate(annualRate, "overdraft")
+	return err
+}
+
+// проценты за один день на отрицательный остаток: долг × ставка / 100 / 365,
+// с округлением до минимальной единицы валюты; при положительном балансе — ноль
+func OverdraftInterest(balance money.Money, annualRate string) (money.Money, error) {
+	zero := money.Zero(balance.Currency)
+	if !balance.IsNegative() {
+		return zero, nil
+	}
+	rate, err := parseAnnualRate(annualRate, "overdraft")
+	if err != nil {
+		return zero, err
+	}
+
+	interest := new(big.Rat).Mul(big.NewRat(-balance.Amount, 1), rate)
+	interest.Quo(interest, big.NewRat(100*365, 1))
+
+	// округление половины вверх: долг положителен
+	minor := new(big.Int).Quo(
+		new(big.Int).Add(new(big.Int).Mul(interest.Num(), big.NewInt(2)), interest.Denom()),
+		new(big.Int).Mul(interest.Denom(), big.NewInt(2)),
+	)
+	return money.FromMinor(minor.Int64(), balance.Currency), nil
+}
+
+// годовая ставка в процентах, например "24" или "18.5"; пустая строка — ноль.
+// kind попадает в текст ошибки: "overdraft", "interest"
+func parseAnnualRate(s, kind string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return new(big.Rat), nil
+	}
+	if strings.ContainsAny(s, "eE/") {
+		return nil, fmt.Errorf("invalid %s rate %q", kind, s)
+	}
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s rate %q", kind, s)
+	}
+	if rate.Sign() < 0 || rate.Cmp(big.NewRat(maxAnnualRate, 1)) > 0 {
+		return nil, fmt.Errorf("%s rate must be between 0 and %d percent", kind, maxAnnualRate)
+	}
+	return rate, nil
+}
+
+// лимит овердрафта в валюте счёта; у счетов, сохранённых до появления
+// овердрафта, валюта лимита пустая
+func (acc *Account) Overdraft() money.Money {
+	if acc.OverdraftLimit.Currency == "" {
+		return money.Zero(acc.Currency())
+	}
+	return acc.OverdraftLimit
+}
+
+// первый день, за который проценты за овердрафт ещё не списаны.
+// chargedThrough — последний обработанный день; у счетов, где он ещё
+// не сохранён, отсчёт идёт со дня после последнего списания lastCharge
+// (раньше проценты списывались в тот же день), а без списаний — с through
+func OverdraftFrom(chargedThrough, lastCharge *time.Time, through time.Time) time.Time {
+	switch {
+	case chargedThrough != nil:
+		return UTCDate(*chargedThrough).AddDate(0, 0, 1)
+	case lastCharge != nil:
+		return UTCDate(*lastCharge).AddDate(0, 0, 1)
+	}
+	return UTCDate(through)
+}
+
+// списание процентов за отрицательный остаток на конец каждого дня после
+// последнего обработанного по through включительно; проценты за день
+// списываются в начале следующего дня. возвращает сумму списаний
+func (acc *Account) chargeOverdraftInterest(through time.Time) (money.Money, error) {
+	charged := money.Zero(acc.Currency())
+	through = UTCDate(through)
+
+	var lastCharge *time.Time
+	for i, tx := range acc.Transactions {
+		if tx.Type == TypeOverdraftInterest && (lastCharge == nil || tx.Timestamp.After(*lastCharge)) {
+			lastCharge = &acc.Transactions[i].Timestamp
+		}
+	}
+
+	for day := OverdraftFrom(acc.OverdraftThrough, lastCharge, through); !day.After(through); day = day.AddDate(0, 0, 1) {
+		interest, err := OverdraftInterest(acc.balanceAt(day), acc.OverdraftRate)
+		if err != nil {
+			return charged, err
+		}
+		if !interest.IsZero() {
+			if err := acc.charge(TypeOverdraftInterest, interest, day.AddDate(0, 0, 1)); err != nil {
+				return charged, err
+			}
+			charged.Amount += interest.Amount
+		}
+		chargedThrough := day
+		acc.OverdraftThrough = &chargedThrough
+	}
+	return charged, nil
+}
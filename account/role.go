@@ -0,0 +1,28 @@
+package account
+
+import "fmt"
+
+// роли пользователей
+const (
+	RoleCustomer = "customer" // клиент: только свои счета
+	RoleTeller   = "teller"   // операционист: пополнение и снятие за клиента
+	RoleAdmin    = "admin"    // администратор: все операции
+	RoleAuditor  = "auditor"  // аудитор: только чтение
+)
+
+// проверка названия роли
+func ValidateRole(role string) error {
+	switch role {
+	case RoleCustomer, RoleTeller, RoleAdmin, RoleAuditor:
+		return nil
+	}
+	return fmt.Errorf("unknown role %q", role)
+}
+
+// роль аккаунта; у старых записей без роли — клиент
+func (acc *Account) EffectiveRole() string {
+	if acc.Role == "" {
+		return RoleCustomer
+	}
+	return acc.Role
+}
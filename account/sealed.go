@@ -0,0 +1,60 @@
+package account
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"mfp/keyring"
+)
+
+// CVC2 не хранится: вместо него сохраняется HMAC от номера карты
+// и кода, по которому код можно только проверить. открытый CVC2
+// остаётся в c только для ответа на выпуск
+func (c *Card) SealCVC2(k *keyring.Keyring) {
+	if c.CVC2 == "" {
+		return
+	}
+	c.CVC2MAC, c.KeyVersion = k.Digest(c.CVC2, c.PAN)
+}
+
+// копия карты для хранения: без открытого CVC2
+func (c *Card) sealed(k *keyring.Keyring) Card {
+	stored := *c
+	stored.SealCVC2(k)
+	stored.CVC2 = ""
+	return stored
+}
+
+// проверка CVC2; у карт, ещё не перешифрованных после обновления,
+// код сравнивается открытым
+func (c *Card) CheckCVC2(k *keyring.Keyring, cvc2 string) bool {
+	if c.CVC2MAC == "" {
+		return c.CVC2 != "" && subtle.ConstantTimeCompare([]byte(cvc2), []byte(c.CVC2)) == 1
+	}
+	return k.Verify(c.CVC2MAC, c.KeyVersion, cvc2, c.PAN)
+}
+
+// шифрование телефона для записи в файл
+func (acc *Account) sealPhone(k *keyring.Keyring) error {
+	if acc.Phone == "" {
+		return nil
+	}
+	sealed, version, err := k.Encrypt(acc.Phone, acc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt phone of %s: %v", acc.ID, err)
+	}
+	acc.Phone, acc.PhoneEnc, acc.KeyVersion = "", sealed, version
+	return nil
+}
+
+// расшифровка телефона после чтения файла
+func (acc *Account) openPhone(k *keyring.Keyring) error {
+	if acc.PhoneEnc == "" {
+		return nil
+	}
+	phone, err := k.Decrypt(acc.PhoneEnc, acc.KeyVersion, acc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt phone of %s: %v", acc.ID, err)
+	}
+	acc.Phone, acc.PhoneEnc, acc.KeyVersion = phone, "", 0
+	return nil
+}
@@ -0,0 +1,114 @@
+package account
+
+import (
+	"fmt"
+	"time"
+)
+
+// статусы аккаунта
+const (
+	StatusActive  = "active"  // все операции разрешены
+	StatusFrozen  = "frozen"  // расследование: зачисления разрешены, списания запрещены
+	StatusBlocked = "blocked" // все операции и вход запрещены
+	StatusClosed  = "closed"  // аккаунт закрыт, история сохраняется
+)
+
+// допустимые переходы между статусами; из closed выхода нет
+var statusTransitions = map[string][]string{
+	StatusActive:  {StatusFrozen, StatusBlocked, StatusClosed},
+	StatusFrozen:  {StatusActive, StatusBlocked, StatusClosed},
+	StatusBlocked: {StatusActive, StatusFrozen, StatusClosed},
+}
+
+// проверка названия статуса
+func ValidateStatus(status string) error {
+	switch status {
+	case StatusActive, StatusFrozen, StatusBlocked, StatusClosed:
+		return nil
+	}
+	return fmt.Errorf("unknown status %q", status)
+}
+
+// проверка перехода; закрытие требует нулевого баланса
+func CheckStatusTransition(from, to string, balanceIsZero bool) error {
+	if err := ValidateStatus(to); err != nil {
+		return err
+	}
+	if from == to {
+		return fmt.Errorf("account is already %s", to)
+	}
+
+	allowed := false
+	for _, next := range statusTransitions[normalizeStatus(from)] {
+		if next == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("cannot change status from %s to %s", normalizeStatus(from), to)
+	}
+
+	if to == StatusClosed && !balanceIsZero {
+		return fmt.Errorf("account balance must be zero to close it")
+	}
+	return nil
+}
+
+// можно ли списывать средства со счёта в этом статусе
+func CheckDebit(status string) error {
+	switch normalizeStatus(status) {
+	case StatusActive:
+		return nil
+	case StatusFrozen:
+		return fmt.Errorf("account is frozen")
+	case StatusBlocked:
+		return fmt.Errorf("account is blocked")
+	}
+	return fmt.Errorf("account is closed")
+}
+
+// можно ли зачислять средства на счёт в этом статусе
+func CheckCredit(status string) error {
+	switch normalizeStatus(status) {
+	case StatusActive, StatusFrozen:
+		return nil
+	case StatusBlocked:
+		return fmt.Errorf("account is blocked")
+	}
+	return fmt.Errorf("account is closed")
+}
+
+// можно ли входить в систему
+func CheckLogin(status string) error {
+	switch normalizeStatus(status) {
+	case StatusActive, StatusFrozen:
+		return nil
+	case StatusBlocked:
+		return fmt.Errorf("account is blocked")
+	}
+	return fmt.Errorf("account is closed")
+}
+
+// статус аккаунта; у старых записей без статуса — активный
+func (acc *Account) EffectiveStatus() string {
+	return normalizeStatus(acc.Status)
+}
+
+// смена статуса с проверкой перехода
+func (acc *Account) SetStatus(status, reason string) error {
+	if err := CheckStatusTransition(acc.EffectiveStatus(), status, acc.Balance.IsZero()); err != nil {
+		return err
+	}
+	acc.Status = status
+	acc.StatusReason = reason
+	acc.StatusChangedAt = time.Now()
+	return nil
+}
+
+func normalizeStatus(status string) string {
+	if status == "" {
+		return StatusActive
+	}
+	return status
+}
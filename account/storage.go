@@ -3,46 +3,138 @@ package account
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 )
 
-//const nameFile = "accounts.json"
-
-// сохранение данных в файл
-func (al *AccountList) SaveToFile(filename string) error {
+// аккаунты для записи в файл: телефоны и секреты TOTP шифруются
+// текущим ключом при каждом сохранении
+func (al *AccountList) MarshalSealed() ([]byte, error) {
 	al.mu.RLock()
 	defer al.mu.RUnlock()
 
-	data, err := json.MarshalIndent(al.accounts, "", "  ")
+	sealed := make(map[string]*Account, len(al.accounts))
+	for id, acc := range al.accounts {
+		stored := *acc
+		if err := stored.sealPhone(al.keys); err != nil {
+			return nil, err
+		}
+		if acc.TwoFactor != nil {
+			stored.TwoFactor = acc.TwoFactor.clone()
+			if err := stored.TwoFactor.SealSecret(al.keys, id); err != nil {
+				return nil, err
+			}
+		}
+		sealed[id] = &stored
+	}
+	data, err := json.Marshal(sealed)
 	if err != nil {
-		return fmt.Errorf("failed to marshal accounts: %v", err)
+		return nil, fmt.Errorf("failed to marshal accounts: %v", err)
 	}
+	return data, nil
+}
 
-	if err = os.WriteFile(filename, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %v", err)
-	}
-	return nil
+// все записи сохранены текущим ключом
+func (al *AccountList) MarkSaved() {
+	al.mu.Lock()
+	al.stale = 0
+	al.mu.Unlock()
 }
 
-// загрузка данных из файла
-func (al *AccountList) LoadFromFile(filename string) error {
+// число записей, которые в файле ещё открыты или зашифрованы не текущим
+// ключом; обнуляется следующим сохранением
+func (al *AccountList) StaleRecords() int {
 	al.mu.RLock()
 	defer al.mu.RUnlock()
+	return al.stale
+}
 
-	data, err := os.ReadFile(filename)
-
-	if err != nil {
-		return fmt.Errorf("failed to read file: %v", err)
-	}
-
-	if err := json.Unmarshal(data, &al.accounts); err != nil {
+// загрузка аккаунтов, записанных MarshalSealed или прежними версиями
+func (al *AccountList) UnmarshalSealed(data []byte) error {
+	accounts := make(map[string]*Account)
+	if err := json.Unmarshal(data, &accounts); err != nil {
 		return fmt.Errorf("failed to unmarshal accounts: %v", err)
 	}
 
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.accounts = accounts
 	al.accountsbyNumber = make(map[string]*Account)
+	al.stale = 0
+	current := al.keys.Current()
 	for _, acc := range al.accounts {
-		al.accountsbyNumber[acc.Phone] = acc
+		if acc.Transactions == nil {
+			acc.Transactions = []Transaction{}
+		}
+		acc.migrateLegacyCard()
+
+		if acc.PhoneEnc == "" || acc.KeyVersion != current {
+			al.stale++
+		}
+		if err := acc.openPhone(al.keys); err != nil {
+			return err
+		}
+		if tf := acc.TwoFactor; tf != nil {
+			if tf.Secret != "" || (tf.SecretEnc != "" && tf.KeyVersion != current) {
+				al.stale++
+			}
+			if err := tf.OpenSecret(al.keys, acc.ID); err != nil {
+				return err
+			}
+		}
+		for i := range acc.Cards {
+			if acc.Cards[i].CVC2 != "" {
+				acc.Cards[i] = acc.Cards[i].sealed(al.keys)
+				al.stale++
+			}
+		}
+
+		for _, hold := range acc.Holds {
+			al.lastHoldID = max(al.lastHoldID, hold.ID)
+		}
+		if acc.OwnerID == "" {
+			al.accountsbyNumber[acc.Phone] = acc
+		}
 	}
 
 	return nil
 }
+
+// копия списка, например для изменения, которое применяется только
+// после записи в файл
+func (al *AccountList) Clone() *AccountList {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	copied := &AccountList{
+		accounts:         make(map[string]*Account, len(al.accounts)),
+		accountsbyNumber: make(map[string]*Account, len(al.accountsbyNumber)),
+		lastHoldID:       al.lastHoldID,
+		keys:             al.keys,
+		stale:            al.stale,
+	}
+	for id, acc := range al.accounts {
+		stored := acc.clone()
+		copied.accounts[id] = stored
+		if stored.OwnerID == "" {
+			copied.accountsbyNumber[stored.Phone] = stored
+		}
+	}
+	return copied
+}
+
+// замена содержимого списка содержимым other; other дальше не используется
+func (al *AccountList) Replace(other *AccountList) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.accounts, al.accountsbyNumber = other.accounts, other.accountsbyNumber
+	al.lastHoldID, al.stale = other.lastHoldID, other.stale
+}
+
+// слепой индекс значения ключом списка, чтобы хранить, например,
+// телефоны без открытого текста
+func (al *AccountList) BlindIndex(value string) string {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	return al.keys.BlindIndex(value)
+}
@@ -1,13 +1,56 @@
 package account
 
-import "time"
+import (
+	"mfp/money"
+	"time"
+)
+
+// типы операций в истории счёта
+const (
+	TypeDeposit     = "deposit"
+	TypeWithdrawal  = "withdrawal"
+	TypeTransferIn  = "transfer_in"
+	TypeTransferOut = "transfer_out"
+
+	TypeOverdraftInterest = "overdraft_interest" // проценты за отрицательный остаток
+	TypeInterest          = "interest"           // капитализация процентов по вкладу
+	TypeFee               = "fee"                // комиссия за снятие или перевод
+	TypeCardPayment       = "card_payment"       // оплата картой: pending до списания блокировки
+)
 
 type Transaction struct {
-	ID          int       `json:"id"`
-	Type        string    `json:"type"` // deposit, withdrawal, transfer
-	FromAccount string    `json:"from_account"`
-	ToAccount   string    `json:"to_account"`
-	Amount      float64   `json:"amount"`
-	Timestamp   time.Time `json:"timestamp"`
-	Status      string    `json:"status"` // pending, completed, failed
+	ID          int         `json:"id"`
+	Type        string      `json:"type"` // deposit, withdrawal, transfer_in, transfer_out, overdraft_interest, interest, fee, card_payment
+	FromAccount string      `json:"from_account"`
+	ToAccount   string      `json:"to_account"`
+	Amount      money.Money `json:"amount"`
+	Timestamp   time.Time   `json:"timestamp"`
+	Status      string      `json:"status"`            // pending, completed, failed, reversed, expired
+	HoldID      int64       `json:"hold_id,omitempty"` // блокировка по карте для card_payment
+
+	// для перевода с конвертацией: сумма в валюте получателя и курс
+	ConvertedAmount *money.Money `json:"converted_amount,omitempty"`
+	Rate            string       `json:"rate,omitempty"`
+}
+
+// операция увеличивает баланс счёта
+func (tx *Transaction) IsIncoming() bool {
+	switch tx.Type {
+	case TypeDeposit, TypeTransferIn, TypeInterest:
+		return true
+	}
+	return false
+}
+
+// операция проведена и изменила баланс; у старых записей статус пустой
+func (tx *Transaction) IsBooked() bool {
+	return tx.Status == "completed" || tx.Status == ""
+}
+
+// другая сторона операции: получатель исходящего перевода или отправитель входящего
+func (tx *Transaction) Counterparty() string {
+	if tx.IsIncoming() {
+		return tx.FromAccount
+	}
+	return tx.ToAccount
 }
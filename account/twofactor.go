@@ -0,0 +1,202 @@
+package account
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"mfp/keyring"
+	"mfp/otp"
+	"slices"
+	"time"
+)
+
+// второй фактор у клиента не подключён
+var ErrTwoFactorNotFound = errors.New("two-factor authentication is not enabled")
+
+// код второго фактора не подошёл
+var ErrInvalidCode = errors.New("invalid code")
+
+// способы второго фактора
+const (
+	TwoFactorTOTP = "totp" // код из приложения-аутентификатора
+	TwoFactorSMS  = "sms"  // одноразовый код на телефон
+)
+
+// второй фактор входа; до подтверждения первым кодом вход по нему
+// не требуется. в PostgreSQL хранится в отдельной таблице
+type TwoFactor struct {
+	Method string `json:"method"`
+	// секрет TOTP; в файле и базе хранится зашифрованным в SecretEnc
+	Secret     string `json:"secret,omitempty"`
+	SecretEnc  string `json:"secret_enc,omitempty"`
+	KeyVersion int    `json:"key_version,omitempty"`
+	Confirmed  bool   `json:"confirmed"`
+
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"` // хеши неиспользованных кодов восстановления
+	LastStep      int64      `json:"last_step,omitempty"`      // последний принятый шаг TOTP, чтобы код не вводили дважды
+	CodeHash      string     `json:"code_hash,omitempty"`      // хеш отправленного одноразового кода
+	CodeExpiresAt *time.Time `json:"code_expires_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// новый неподтверждённый второй фактор
+func NewTwoFactor(method string, now time.Time) (*TwoFactor, error) {
+	tf := &TwoFactor{Method: method, CreatedAt: now}
+	switch method {
+	case TwoFactorTOTP:
+		tf.Secret = otp.GenerateSecret()
+	case TwoFactorSMS:
+	default:
+		return nil, fmt.Errorf("method must be totp or sms")
+	}
+	return tf, nil
+}
+
+// новый одноразовый код для отправки; прежний код перестаёт действовать
+func (tf *TwoFactor) IssueCode(now time.Time, ttl time.Duration) string {
+	code := otp.NumericCode()
+	expires := now.Add(ttl)
+	tf.CodeHash, tf.CodeExpiresAt = otp.HashCode(code), &expires
+	return code
+}
+
+// проверка кода: TOTP или отправленный код, а у подтверждённого фактора
+// ещё и код восстановления. принятый код повторно не принимается.
+// возвращает true, если использован код восстановления
+func (tf *TwoFactor) Verify(code string, now time.Time) (bool, error) {
+	switch tf.Method {
+	case TwoFactorTOTP:
+		if step, ok := otp.Validate(tf.Secret, code, now, tf.LastStep); ok {
+			tf.LastStep = step
+			return false, nil
+		}
+	case TwoFactorSMS:
+		if tf.CodeHash != "" && tf.CodeExpiresAt != nil && now.Before(*tf.CodeExpiresAt) &&
+			subtle.ConstantTimeCompare([]byte(otp.HashCode(code)), []byte(tf.CodeHash)) == 1 {
+			tf.CodeHash, tf.CodeExpiresAt = "", nil
+			return false, nil
+		}
+	}
+
+	if tf.Confirmed {
+		hash := otp.HashCode(code)
+		for i, stored := range tf.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(stored)) == 1 {
+				tf.RecoveryCodes = slices.Delete(tf.RecoveryCodes, i, i+1)
+				return true, nil
+			}
+		}
+	}
+	return false, ErrInvalidCode
+}
+
+// подтверждение подключения первым кодом; возвращает коды восстановления,
+// которые показываются клиенту один раз
+func (tf *TwoFactor) Confirm(code string, now time.Time) ([]string, error) {
+	if tf.Confirmed {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if _, err := tf.Verify(code, now); err != nil {
+		return nil, err
+	}
+	tf.Confirmed = true
+	return tf.NewRecoveryCodes(), nil
+}
+
+// новый набор кодов восстановления взамен прежнего
+func (tf *TwoFactor) NewRecoveryCodes() []string {
+	codes, hashes := otp.GenerateRecoveryCodes()
+	tf.RecoveryCodes = hashes
+	return codes
+}
+
+func (tf *TwoFactor) clone() *TwoFactor {
+	copied := *tf
+	copied.RecoveryCodes = slices.Clone(tf.RecoveryCodes)
+	if tf.CodeExpiresAt != nil {
+		expires := *tf.CodeExpiresAt
+		copied.CodeExpiresAt = &expires
+	}
+	return &copied
+}
+
+// шифрование секрета TOTP для записи; accountID привязывает
+// шифротекст к клиенту
+func (tf *TwoFactor) SealSecret(k *keyring.Keyring, accountID string) error {
+	if tf.Secret == "" {
+		return nil
+	}
+	sealed, version, err := k.Encrypt(tf.Secret, accountID+":totp")
+	if err != nil {
+		return fmt.Errorf("failed to encrypt TOTP secret of %s: %v", accountID, err)
+	}
+	tf.Secret, tf.SecretEnc, tf.KeyVersion = "", sealed, version
+	return nil
+}
+
+// расшифровка секрета TOTP после чтения
+func (tf *TwoFactor) OpenSecret(k *keyring.Keyring, accountID string) error {
+	if tf.SecretEnc == "" {
+		return nil
+	}
+	secret, err := k.Decrypt(tf.SecretEnc, tf.KeyVersion, accountID+":totp")
+	if err != nil {
+		return fmt.Errorf("failed to decrypt TOTP secret of %s: %v", accountID, err)
+	}
+	tf.Secret, tf.SecretEnc, tf.KeyVersion = secret, "", 0
+	return nil
+}
+
+// второй фактор клиента; ErrTwoFactorNotFound, если не подключался
+func (al *AccountList) GetTwoFactor(accountID string) (*TwoFactor, error) {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	acc, err := al.findAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc.TwoFactor == nil {
+		return nil, ErrTwoFactorNotFound
+	}
+	return acc.TwoFactor.clone(), nil
+}
+
+// замена второго фактора клиента, например при новом подключении;
+// nil отключает второй фактор
+func (al *AccountList) SetTwoFactor(accountID string, tf *TwoFactor) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(accountID)
+	if err != nil {
+		return err
+	}
+	if tf == nil {
+		acc.TwoFactor = nil
+		return nil
+	}
+	acc.TwoFactor = tf.clone()
+	return nil
+}
+
+// изменение второго фактора под блокировкой; при ошибке change
+// изменения не сохраняются
+func (al *AccountList) UpdateTwoFactor(accountID string, change func(tf *TwoFactor) error) error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	acc, err := al.findAccount(accountID)
+	if err != nil {
+		return err
+	}
+	if acc.TwoFactor == nil {
+		return ErrTwoFactorNotFound
+	}
+	tf := acc.TwoFactor.clone()
+	if err := change(tf); err != nil {
+		return err
+	}
+	acc.TwoFactor = tf
+	return nil
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"mfp/account"
+	"mfp/money"
+	"mfp/storage"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// обработчик получения всех аккаунтов; ?q= ищет по ID, имени или телефону
+func (s *Server) handleGetAccounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+
+	var accounts []*account.Account
+	var err error
+	if query != "" {
+		accounts, err = s.store.SearchAccounts(query)
+	} else {
+		accounts, err = s.store.GetAccounts()
+	}
+	if err != nil {
+		http.Error(w, "Couldnt get accounts from database", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, "list_accounts", "", "q="+query)
+
+	response := make([]AccountResponse, 0, len(accounts))
+	for _, acc := range accounts {
+		response = append(response, AccountToResponse(acc))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// обработчик получения аккаунта по ID
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing account ID", http.StatusBadRequest)
+		return
+	}
+	acc, err := s.store.GetAccount(id)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	s.audit(r, "view_account", acc.ID, "")
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AccountToResponse(acc))
+}
+
+// история операций любого аккаунта
+func (s *Server) handleGetAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if s.transactions(w, r, id) {
+		s.audit(r, "view_transactions", id, r.URL.RawQuery)
+	}
+}
+
+// пополнение счёта клиента операционистом
+func (s *Server) handleAccountDeposit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if s.deposit(w, r, id) {
+		s.audit(r, "deposit", id, "amount="+r.URL.Query().Get("amount"))
+	}
+}
+
+// снятие со счёта клиента операционистом
+func (s *Server) handleAccountWithdraw(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if s.withdraw(w, r, id) {
+		s.audit(r, "withdraw", id, "amount="+r.URL.Query().Get("amount"))
+	}
+}
+
+// смена роли пользователя администратором
+func (s *Server) handleSetRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetRole(id, req.Role); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit(r, "set_role", id, "role="+req.Role)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Role updated",
+		"id":      id,
+		"role":    req.Role,
+	})
+}
+
+// смена статуса аккаунта администратором: заморозка, блокировка, закрытие
+func (s *Server) handleSetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetStatus(id, req.Status, req.Reason); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit(r, "set_status", id, "status="+req.Status+" reason="+req.Reason)
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Status updated",
+		"id":      id,
+		"status":  req.Status,
+	})
+}
+
+// лимит овердрафта в валюте счёта и годовая ставка в процентах;
+// нулевой лимит отключает овердрафт, но не гасит уже возникший долг
+func (s *Server) handleSetOverdraft(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Limit      string `json:"limit"`
+		AnnualRate string `json:"annual_rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	acc, err := s.store.GetAccount(id)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	limit, err := money.Parse(req.Limit, acc.Currency())
+	if err != nil {
+		http.Error(w, "Invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetOverdraft(acc.ID, limit, req.AnnualRate); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit(r, "set_overdraft", acc.ID, "limit="+limit.Format()+" rate="+req.AnnualRate)
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":     "Overdraft updated",
+		"id":          acc.ID,
+		"limit":       limit,
+		"annual_rate": req.AnnualRate,
+	})
+}
+
+// продукт счёта и годовая ставка: current, savings или deposit
+// с датой окончания срока; новая ставка действует с сегодняшнего дня
+func (s *Server) handleSetProduct(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Product      string `json:"product"`
+		AnnualRate   string `json:"annual_rate"`
+		MaturityDate string `json:"maturity_date"` // YYYY-MM-DD, только для deposit
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var maturity *time.Time
+	if req.MaturityDate != "" {
+		date, err := time.Parse("2006-01-02", req.MaturityDate)
+		if err != nil {
+			http.Error(w, "Invalid maturity_date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		maturity = &date
+	}
+
+	if err := s.store.SetProduct(id, req.Product, req.AnnualRate, maturity); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.audit(r, "set_product", id, "product="+req.Product+" rate="+req.AnnualRate+" maturity="+req.MaturityDate)
+
+	acc, err := s.store.GetAccount(id)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(AccountToResponse(acc))
+}
+
+// журнал аудита, ?limit= ограничивает число записей
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	events, err := s.store.GetAuditLog(limit)
+	if err != nil {
+		http.Error(w, "Failed to get audit log", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(events)
+}
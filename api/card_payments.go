@@ -0,0 +1,194 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"mfp/account"
+	"mfp/money"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ответ процессингу о блокировке по карте
+type HoldResponse struct {
+	ID        int64       `json:"id"`
+	Status    string      `json:"status"`
+	Merchant  string      `json:"merchant"`
+	Amount    money.Money `json:"amount"`
+	Captured  money.Money `json:"captured"`
+	CreatedAt string      `json:"created_at"`
+	ExpiresAt string      `json:"expires_at"`
+}
+
+func HoldToResponse(h *account.Hold) HoldResponse {
+	return HoldResponse{
+		ID:        h.ID,
+		Status:    h.Status,
+		Merchant:  h.Merchant,
+		Amount:    h.Amount,
+		Captured:  h.Captured,
+		CreatedAt: h.CreatedAt.Format("2006-01-02 15:04:05"),
+		ExpiresAt: h.ExpiresAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// доступ процессинга карт по общему ключу; без настроенного ключа
+// авторизации выключены
+func (s *Server) requireProcessingKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ProcessingKey == "" {
+			http.Error(w, "Card processing is disabled", http.StatusServiceUnavailable)
+			return
+		}
+		key := r.Header.Get("X-Processing-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.ProcessingKey)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// авторизация платежа: при успехе сумма блокируется на счёте карты,
+// при отказе возвращается 402 с причиной
+func (s *Server) handleAuthorizeCard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		PAN      string `json:"pan"`
+		Expiry   string `json:"expiry"` // MM/YY
+		CVC2     string `json:"cvc2"`
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+		Merchant string `json:"merchant"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	month, year, err := account.ParseExpiry(req.Expiry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	amount, err := parseAmount(req.Amount, strings.ToUpper(req.Currency))
+	if err != nil {
+		http.Error(w, "Valid amount required: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Merchant == "" {
+		http.Error(w, "merchant is required", http.StatusBadRequest)
+		return
+	}
+
+	hold, err := s.store.AuthorizeCard(&account.Authorization{
+		PAN:         req.PAN,
+		ExpiryMonth: month,
+		ExpiryYear:  year,
+		CVC2:        req.CVC2,
+		Amount:      amount,
+		Merchant:    req.Merchant,
+		TTL:         s.HoldTTL,
+		Policy:      s.CardPolicy,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusPaymentRequired)
+		json.NewEncoder(w).Encode(map[string]string{"status": "declined", "reason": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(HoldToResponse(hold))
+}
+
+func (s *Server) handleGetHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := holdID(w, r)
+	if !ok {
+		return
+	}
+	hold, err := s.store.GetHold(id)
+	if err != nil {
+		writeHoldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(HoldToResponse(hold))
+}
+
+// списание по блокировке; без суммы в теле списывается вся блокировка
+func (s *Server) handleCaptureHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := holdID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Amount string `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var amount *money.Money
+	if req.Amount != "" {
+		hold, err := s.store.GetHold(id)
+		if err != nil {
+			writeHoldError(w, err)
+			return
+		}
+		parsed, err := parseAmount(req.Amount, hold.Amount.Currency)
+		if err != nil {
+			http.Error(w, "Valid amount required: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		amount = &parsed
+	}
+
+	hold, err := s.store.CaptureHold(id, amount)
+	if err != nil {
+		writeHoldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(HoldToResponse(hold))
+}
+
+func (s *Server) handleReverseHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := holdID(w, r)
+	if !ok {
+		return
+	}
+	hold, err := s.store.ReverseHold(id)
+	if err != nil {
+		writeHoldError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(HoldToResponse(hold))
+}
+
+func holdID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid hold ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// блокировка не найдена — 404, недопустимое списание или отмена — 409
+func writeHoldError(w http.ResponseWriter, err error) {
+	if errors.Is(err, account.ErrHoldNotFound) {
+		http.Error(w, "Hold not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusConflict)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mfp/account"
+	"mfp/storage"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// карты всех счетов клиента
+func (s *Server) handleMyCards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ids, err := s.ownAccountIDs(userID)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return
+	}
+	s.writeCards(w, ids)
+}
+
+// выпуск карты к своему счёту; ?currency= выбирает валютный счёт.
+// полный номер и CVC2 возвращаются только в этом ответе
+func (s *Server) handleIssueCard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		PIN string `json:"pin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	card, err := account.NewCard(accountID, req.PIN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.IssueCard(card); err != nil {
+		writeCardError(w, "Error issuing card:\n", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CardToResponse(card, true))
+}
+
+// блокировка своей карты; "lost": true блокирует навсегда, тело необязательно
+func (s *Server) handleBlockCard(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Lost   bool   `json:"lost"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status := account.CardBlocked
+	if req.Lost {
+		status = account.CardLost
+	}
+	s.setMyCardStatus(w, r, status, req.Reason)
+}
+
+func (s *Server) handleUnblockCard(w http.ResponseWriter, r *http.Request) {
+	s.setMyCardStatus(w, r, account.CardActive, "")
+}
+
+func (s *Server) setMyCardStatus(w http.ResponseWriter, r *http.Request, status, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	card, ok := s.ownCard(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.SetCardStatus(card.ID, status, reason); err != nil {
+		writeCardError(w, "", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": fmt.Sprintf("Card %s is %s", account.MaskPAN(card.PAN), status),
+		"status":  status,
+	})
+}
+
+// перевыпуск своей карты: новые номер, CVC2 и срок, прежний PIN
+func (s *Server) handleReissueCard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	card, ok := s.ownCard(w, r)
+	if !ok {
+		return
+	}
+	replacement, err := s.store.ReissueCard(card.ID)
+	if err != nil {
+		writeCardError(w, "Error reissuing card:\n", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CardToResponse(replacement, true))
+}
+
+// смена PIN своей карты по текущему PIN
+func (s *Server) handleChangeCardPIN(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		CurrentPIN string `json:"current_pin"`
+		NewPIN     string `json:"new_pin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	card, ok := s.ownCard(w, r)
+	if !ok {
+		return
+	}
+	if !card.CheckPIN(req.CurrentPIN) {
+		http.Error(w, "Invalid PIN", http.StatusForbidden)
+		return
+	}
+	pinHash, err := account.HashPIN(req.NewPIN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.SetCardPIN(card.ID, pinHash); err != nil {
+		writeCardError(w, "", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "PIN changed"})
+}
+
+// карты любого аккаунта и его валютных счетов
+func (s *Server) handleGetAccountCards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if _, err := s.store.GetAccount(id); err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	ids, err := s.ownAccountIDs(id)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return
+	}
+	if s.writeCards(w, ids) {
+		s.audit(r, "view_cards", id, "")
+	}
+}
+
+// смена статуса любой карты сотрудником банка, например по звонку клиента
+func (s *Server) handleSetCardStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains([]string{account.CardActive, account.CardBlocked, account.CardLost}, req.Status) {
+		http.Error(w, "status must be active, blocked or lost", http.StatusBadRequest)
+		return
+	}
+
+	card, err := s.store.GetCard(chi.URLParam(r, "id"))
+	if err != nil {
+		writeCardError(w, "", err)
+		return
+	}
+	if err := s.store.SetCardStatus(card.ID, req.Status, req.Reason); err != nil {
+		writeCardError(w, "", err)
+		return
+	}
+	s.audit(r, "set_card_status", card.AccountID, fmt.Sprintf("card=%s status=%s reason=%s", card.ID, req.Status, req.Reason))
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": fmt.Sprintf("Card %s is %s", account.MaskPAN(card.PAN), req.Status),
+		"status":  req.Status,
+	})
+}
+
+// карта клиента по ID из пути; чужие карты не отличаются от несуществующих
+func (s *Server) ownCard(w http.ResponseWriter, r *http.Request) (*account.Card, bool) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	card, err := s.store.GetCard(chi.URLParam(r, "id"))
+	if err != nil {
+		writeCardError(w, "", err)
+		return nil, false
+	}
+	ids, err := s.ownAccountIDs(userID)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !slices.Contains(ids, card.AccountID) {
+		http.Error(w, "Card not found", http.StatusNotFound)
+		return nil, false
+	}
+	return card, true
+}
+
+func (s *Server) writeCards(w http.ResponseWriter, accountIDs []string) bool {
+	cards, err := s.store.GetCards(accountIDs)
+	if err != nil {
+		http.Error(w, "Failed to get cards", http.StatusInternalServerError)
+		return false
+	}
+
+	response := make([]CardResponse, 0, len(cards))
+	for _, card := range cards {
+		response = append(response, CardToResponse(card, false))
+	}
+	json.NewEncoder(w).Encode(response)
+	return true
+}
+
+// ошибки операций с картами: не найдена — 404, недопустимый
+// переход или выпуск — 409
+func writeCardError(w http.ResponseWriter, prefix string, err error) {
+	switch {
+	case errors.Is(err, account.ErrCardNotFound):
+		http.Error(w, "Card not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrAccountNotFound):
+		http.Error(w, "Account not found", http.StatusNotFound)
+	default:
+		http.Error(w, prefix+err.Error(), http.StatusConflict)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"net/http"
+)
+
+// счёт клиента в указанной валюте: основной или валютный;
+// пустая валюта означает основной счёт
+func (s *Server) ownAccount(userID, currency string) (string, error) {
+	if currency == "" {
+		return userID, nil
+	}
+	currency = money.NormalizeCurrency(currency)
+
+	accounts, err := s.store.GetOwnedAccounts(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, acc := range accounts {
+		if acc.Currency() == currency {
+			return acc.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no %s account, open one with POST /accounts/me/currencies", currency)
+}
+
+// основной аккаунт и валютные счета клиента
+func (s *Server) handleMyCurrencyAccounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	accounts, err := s.store.GetOwnedAccounts(userID)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]AccountResponse, 0, len(accounts))
+	for _, acc := range accounts {
+		response = append(response, AccountToResponse(acc))
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// открытие валютного счёта; в каждой валюте у клиента один счёт
+func (s *Server) handleOpenCurrencyAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Currency string `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.ownAccount(userID, req.Currency); err == nil {
+		http.Error(w, "Account in this currency already exists", http.StatusConflict)
+		return
+	}
+
+	owner, err := s.store.GetAccount(userID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	acc, err := account.NewSubAccount(owner, req.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.CreateAccount(acc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(AccountToResponse(acc))
+}
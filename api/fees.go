@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"mfp/fees"
+	"mfp/money"
+	"mfp/storage"
+	"net/http"
+	"strings"
+)
+
+// действующие тарифы комиссий
+func (s *Server) handleGetFeeRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rules, err := s.store.GetFeeRules()
+	if err != nil {
+		http.Error(w, "Failed to get fee rules", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(rules)
+}
+
+// замена тарифов администратором; пустой список отменяет все комиссии
+func (s *Server) handleSetFeeRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rules []fees.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetFeeRules(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.Operation+"/"+rule.Currency)
+	}
+	s.audit(r, "set_fee_rules", "", strings.Join(names, " "))
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Fee rules updated"})
+}
+
+// комиссия до выполнения операции: {"operation": "transfer", "to": "...",
+// "amount": {"amount": "100.00", "currency": "KZT"}}; валюта суммы выбирает
+// счёт списания, как и в самом переводе
+func (s *Server) handleFeeQuote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Operation string      `json:"operation"` // transfer или withdrawal
+		To        string      `json:"to"`
+		Amount    money.Money `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch req.Operation {
+	case fees.OpTransfer:
+		if req.To == "" {
+			http.Error(w, "Destination account required", http.StatusBadRequest)
+			return
+		}
+	case fees.OpWithdrawal:
+	default:
+		http.Error(w, "operation must be transfer or withdrawal", http.StatusBadRequest)
+		return
+	}
+	if !req.Amount.IsPositive() {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+
+	accountID, err := s.ownAccount(userID, req.Amount.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fee, err := s.store.EstimateFee(accountID, req.Operation, req.To, req.Amount)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	total, err := req.Amount.Add(fee)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"operation":  req.Operation,
+		"account_id": accountID,
+		"to":         req.To,
+		"amount":     req.Amount,
+		"fee":        fee,
+		"total":      total,
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"mfp/fx"
+	"mfp/money"
+	"net/http"
+	"strings"
+)
+
+// текущие курсы обмена
+func (s *Server) handleGetRates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rates, err := s.store.GetRates()
+	if err != nil {
+		http.Error(w, "Failed to get rates", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(rates)
+}
+
+// загрузка курсов администратором; пары, которых нет в запросе, не меняются
+func (s *Server) handleSetRates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rates []fx.Rate
+	if err := json.NewDecoder(r.Body).Decode(&rates); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(rates) == 0 {
+		http.Error(w, "At least one rate is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetRates(rates); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pairs := make([]string, 0, len(rates))
+	for _, rate := range rates {
+		pairs = append(pairs, rate.From+"/"+rate.To+"="+rate.Rate)
+	}
+	s.audit(r, "set_rates", "", strings.Join(pairs, " "))
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Rates updated"})
+}
+
+// котировка обмена суммы со счёта клиента в валюту to_currency;
+// исполняется переводом с quote_id до истечения expires_at
+func (s *Server) handleCreateQuote(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Amount     money.Money `json:"amount"`
+		ToCurrency string      `json:"to_currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := money.ValidateCurrency(req.ToCurrency); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	accountID, err := s.ownAccount(userID, req.Amount.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rates, err := s.store.GetRates()
+	if err != nil {
+		http.Error(w, "Failed to get rates", http.StatusInternalServerError)
+		return
+	}
+	quote, err := fx.NewQuote(rates, accountID, req.Amount, req.ToCurrency, s.QuoteTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.CreateQuote(quote); err != nil {
+		http.Error(w, "Failed to save quote", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(quote)
+}
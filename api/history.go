@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mfp/money"
+	"mfp/storage"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// размер страницы истории по умолчанию и максимальный
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// страница истории операций счёта accountID; возвращает true при успехе
+func (s *Server) transactions(w http.ResponseWriter, r *http.Request, accountID string) bool {
+	acc, err := s.store.GetAccount(accountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return false
+	}
+
+	filter, err := parseTransactionFilter(r, acc.Currency())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	page, err := s.store.QueryTransactions(acc.ID, filter)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get transactions: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	json.NewEncoder(w).Encode(page)
+	return true
+}
+
+// фильтр истории из параметров запроса:
+// from, to (дата 2006-01-02 включительно или RFC 3339), type (через запятую),
+// min_amount, max_amount, counterparty, sort, cursor, limit
+func parseTransactionFilter(r *http.Request, currency string) (storage.TransactionFilter, error) {
+	query := r.URL.Query()
+	filter := storage.TransactionFilter{
+		Counterparty: query.Get("counterparty"),
+		Sort:         query.Get("sort"),
+		Cursor:       query.Get("cursor"),
+		Limit:        defaultPageSize,
+	}
+
+	if raw := query.Get("from"); raw != "" {
+		from, _, err := parseDate(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.From = from
+	}
+	if raw := query.Get("to"); raw != "" {
+		to, dateOnly, err := parseDate(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %v", err)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1) // дата включается целиком
+		}
+		filter.To = to
+	}
+
+	if raw := query.Get("type"); raw != "" {
+		for _, t := range strings.Split(raw, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	for name, target := range map[string]**money.Money{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if raw := query.Get(name); raw != "" {
+			amount, err := money.Parse(raw, currency)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %v", name, err)
+			}
+			*target = &amount
+		}
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, filter.Validate()
+}
+
+// дата в формате 2006-01-02 или время в RFC 3339; второй результат —
+// была ли указана только дата
+func parseDate(raw string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	return t, false, err
+}
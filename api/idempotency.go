@@ -0,0 +1,56 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/storage"
+	"net/http"
+	"strings"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// построение параметров идемпотентности из заголовка Idempotency-Key;
+// без заголовка возвращается nil и операция выполняется как обычно
+func idempotencyFromRequest(r *http.Request, endpoint string, status int, response []byte, payload ...string) (*storage.Idempotency, error) {
+	key := strings.TrimSpace(r.Header.Get(idempotencyHeader))
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("%s must be at most %d characters", idempotencyHeader, maxIdempotencyKeyLength)
+	}
+
+	hash := sha256.Sum256([]byte(endpoint + "\n" + strings.Join(payload, "\n")))
+	return &storage.Idempotency{
+		Key:         key,
+		Endpoint:    endpoint,
+		RequestHash: hex.EncodeToString(hash[:]),
+		Response:    storage.StoredResponse{StatusCode: status, Body: response},
+	}, nil
+}
+
+// повтор сохранённого ответа для уже выполненного запроса
+func writeStoredResponse(w http.ResponseWriter, stored *storage.StoredResponse) {
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+// ответ на ошибку денежной операции
+func writeOperationError(w http.ResponseWriter, prefix string, err error) {
+	if errors.Is(err, storage.ErrIdempotencyKeyReused) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, account.ErrLimitExceeded) || errors.Is(err, account.ErrDepositTerm) {
+		http.Error(w, prefix+err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+}
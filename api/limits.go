@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"mfp/storage"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// лимиты в запросе: десятичные строки в валюте счёта, "0" — без лимита;
+// отсутствующее поле оставляет текущее значение
+type limitsRequest struct {
+	PerTransaction *string `json:"per_transaction"`
+	Daily          *string `json:"daily"`
+	Monthly        *string `json:"monthly"`
+}
+
+// новые лимиты поверх текущих
+func (req limitsRequest) apply(current account.SpendingLimits, currency string) (account.SpendingLimits, error) {
+	limits := current
+	for _, field := range []struct {
+		name  string
+		raw   *string
+		value *money.Money
+	}{
+		{"per_transaction", req.PerTransaction, &limits.PerTransaction},
+		{"daily", req.Daily, &limits.Daily},
+		{"monthly", req.Monthly, &limits.Monthly},
+	} {
+		if field.raw == nil {
+			continue
+		}
+		amount, err := money.Parse(*field.raw, currency)
+		if err != nil {
+			return limits, fmt.Errorf("invalid %s: %v", field.name, err)
+		}
+		*field.value = amount
+	}
+	return limits, nil
+}
+
+// ответ с действующими и ожидающими лимитами счёта
+func limitsResponse(acc *account.Account) map[string]any {
+	now := time.Now()
+	pending := acc.PendingLimits
+	if pending != nil && !now.Before(pending.EffectiveAt) {
+		pending = nil // уже вступили в силу
+	}
+	return map[string]any{
+		"account_id":     acc.ID,
+		"limits":         acc.SpendingLimits(now),
+		"pending_limits": pending,
+	}
+}
+
+// лимиты своего счёта; ?currency= выбирает валютный счёт
+func (s *Server) handleMyLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	acc, err := s.store.GetAccount(accountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(limitsResponse(acc))
+}
+
+// смена лимитов клиентом: понижение действует сразу, повышение —
+// через LimitCooling или после одобрения администратором
+func (s *Server) handleRequestLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req limitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	acc, err := s.store.GetAccount(accountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	requested, err := req.apply(acc.SpendingLimits(time.Now()), acc.Currency())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limits, pending, err := s.store.RequestLimits(acc.ID, requested, s.LimitCooling)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message := "Limits updated"
+	if pending != nil {
+		message = "Limit increase takes effect at " + pending.EffectiveAt.Format(time.RFC3339) + " unless approved earlier"
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":        message,
+		"account_id":     acc.ID,
+		"limits":         limits,
+		"pending_limits": pending,
+	})
+}
+
+// установка лимитов администратором; ожидающее повышение снимается
+func (s *Server) handleSetLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req limitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	acc, err := s.store.GetAccount(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	limits, err := req.apply(acc.SpendingLimits(time.Now()), acc.Currency())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !s.setLimits(w, acc.ID, limits) {
+		return
+	}
+	s.audit(r, "set_limits", acc.ID, fmt.Sprintf("per_transaction=%s daily=%s monthly=%s",
+		limits.PerTransaction, limits.Daily, limits.Monthly))
+
+	acc.Limits, acc.PendingLimits = limits, nil
+	json.NewEncoder(w).Encode(limitsResponse(acc))
+}
+
+// одобрение ожидающего повышения лимитов до окончания охлаждения
+func (s *Server) handleApproveLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	acc, err := s.store.GetAccount(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if acc.PendingLimits == nil {
+		http.Error(w, "No pending limit increase", http.StatusConflict)
+		return
+	}
+
+	limits := acc.PendingLimits.SpendingLimits
+	if !s.setLimits(w, acc.ID, limits) {
+		return
+	}
+	s.audit(r, "approve_limits", acc.ID, fmt.Sprintf("per_transaction=%s daily=%s monthly=%s",
+		limits.PerTransaction, limits.Daily, limits.Monthly))
+
+	acc.Limits, acc.PendingLimits = limits, nil
+	json.NewEncoder(w).Encode(limitsResponse(acc))
+}
+
+func (s *Server) setLimits(w http.ResponseWriter, accountID string, limits account.SpendingLimits) bool {
+	if err := s.store.SetLimits(accountID, limits); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return false
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
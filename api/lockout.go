@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mfp/account"
+	"mfp/storage"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// неверный телефон и неверный пароль неотличимы, чтобы по ответу
+// нельзя было узнать, зарегистрирован ли номер
+const invalidCredentials = "Invalid phone or password"
+
+// запись ошибки: http.Error для входа через cookie, writeJSONError
+// для клиентов с токенами
+type errorWriter func(w http.ResponseWriter, message string, status int)
+
+// изменение счётчика неудачных входов под блокировкой хранилища
+type attemptsUpdater func(change func(a *account.LoginAttempts) error) error
+
+// проверка телефона и пароля со счётчиком неудач; при отказе
+// ответ пишется через writeError. Незарегистрированный телефон получает
+// те же паузы, блокировку и ответы, что и неверный пароль
+func (s *Server) checkCredentials(w http.ResponseWriter, r *http.Request, phone, password string, writeError errorWriter) (*account.Account, bool) {
+	acc, err := s.store.GetAccountByPhone(phone)
+	if err != nil {
+		update := func(change func(a *account.LoginAttempts) error) error {
+			return s.store.UpdateUnknownLoginAttempts(phone, change)
+		}
+		if !s.beginAttempt(w, r, "", update, writeError) {
+			return nil, false
+		}
+		account.CheckNoPassword(password)
+		s.failAttempt(r, "", update, "unknown_phone")
+		writeError(w, invalidCredentials, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if !s.beginLogin(w, r, acc.ID, writeError) {
+		return nil, false
+	}
+	if !account.CheckPasswordHash(password, acc.Password) {
+		s.failLogin(r, acc.ID, "password")
+		writeError(w, invalidCredentials, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if err := account.CheckLogin(acc.Status); err != nil {
+		writeError(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return acc, true
+}
+
+// начало попытки входа; при паузе между попытками или блокировке
+// отвечает 429 с Retry-After
+func (s *Server) beginLogin(w http.ResponseWriter, r *http.Request, accountID string, writeError errorWriter) bool {
+	return s.beginAttempt(w, r, accountID, s.accountAttempts(accountID), writeError)
+}
+
+// счётчик неудачных входов клиента
+func (s *Server) accountAttempts(accountID string) attemptsUpdater {
+	return func(change func(a *account.LoginAttempts) error) error {
+		return s.store.UpdateLoginAttempts(accountID, change)
+	}
+}
+
+// начало попытки по счётчику update; accountID пустой для
+// незарегистрированного телефона
+func (s *Server) beginAttempt(w http.ResponseWriter, r *http.Request, accountID string, update attemptsUpdater, writeError errorWriter) bool {
+	now := time.Now()
+	err := update(func(a *account.LoginAttempts) error {
+		return a.Begin(now, s.LoginPolicy)
+	})
+	var blocked *account.LoginBlockedError
+	if errors.As(err, &blocked) {
+		s.loginEvent(r, "login_blocked", accountID, fmt.Sprintf("ip=%s until=%s", s.clientIP(r), blocked.Until.Format(time.RFC3339)))
+		retry := int(blocked.Until.Sub(now).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		writeError(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return false
+	}
+	if err != nil {
+		writeError(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// неудачная попытка: растёт пауза до следующей, после MaxFailures
+// вход блокируется; factor — password, second_factor, step_up
+// (подтверждение перевода) или unknown_phone.
+// Возвращает true, если вход заблокирован
+func (s *Server) failLogin(r *http.Request, accountID, factor string) bool {
+	return s.failAttempt(r, accountID, s.accountAttempts(accountID), factor)
+}
+
+// неудача по счётчику update, см. failLogin
+func (s *Server) failAttempt(r *http.Request, accountID string, update attemptsUpdater, factor string) bool {
+	var attempts account.LoginAttempts
+	locked := false
+	err := update(func(a *account.LoginAttempts) error {
+		locked = a.Fail(time.Now(), s.LoginPolicy)
+		attempts = *a
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record failed login for %s: %v", accountID, err)
+	}
+
+	details := fmt.Sprintf("ip=%s factor=%s failures=%d", s.clientIP(r), factor, attempts.Failures)
+	if locked {
+		details += " locked_until=" + attempts.LockedUntil.Format(time.RFC3339)
+	}
+	s.loginEvent(r, "login_failed", accountID, details)
+	return locked
+}
+
+// фактор пройден. Пока вход ждёт второй фактор, снимается только пауза:
+// неудачи с кодом продолжают счёт неудач с паролем
+func (s *Server) passLogin(accountID string, complete bool) {
+	err := s.store.UpdateLoginAttempts(accountID, func(a *account.LoginAttempts) error {
+		if complete {
+			a.Reset()
+		} else {
+			a.NextAttemptAt = nil
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to reset login attempts for %s: %v", accountID, err)
+	}
+}
+
+// событие входа в журнале аудита; действующего лица нет,
+// счёт — объект события
+func (s *Server) loginEvent(r *http.Request, action, accountID, details string) {
+	err := s.store.RecordAudit(&storage.AuditEvent{
+		Action:   action,
+		TargetID: accountID,
+		Details:  details,
+	})
+	if err != nil {
+		log.Printf("Failed to record audit event %s for %s: %v", action, accountID, err)
+	}
+}
+
+// неудачные попытки входа клиента
+func (s *Server) handleGetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID := chi.URLParam(r, "id")
+	attempts, err := s.store.GetLoginAttempts(accountID)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get login attempts", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"account_id":     accountID,
+		"locked":         attempts.Locked(time.Now()),
+		"login_attempts": attempts,
+	})
+}
+
+// снятие блокировки входа и сброс счётчика неудач
+func (s *Server) handleUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID := chi.URLParam(r, "id")
+	err := s.store.UpdateLoginAttempts(accountID, func(a *account.LoginAttempts) error {
+		a.Reset()
+		return nil
+	})
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unlock login", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, "unlock_login", accountID, "")
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":    "Login unlocked",
+		"account_id": accountID,
+	})
+}
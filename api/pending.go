@@ -0,0 +1,372 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"mfp/pending"
+	"mfp/storage"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// порог подтверждения переводов счёта: свой или банка по умолчанию
+// в валюте счёта; nil — переводы не подтверждаются
+func (s *Server) confirmThreshold(acc *account.Account) *money.Money {
+	return pending.Threshold(acc, s.ConfirmThreshold)
+}
+
+// крупный перевод сохраняется до подтверждения: клиенту со вторым
+// фактором — кодом, остальным — PIN входа
+func (s *Server) createPendingTransfer(w http.ResponseWriter, userID, fromID, to string, amount money.Money, quoteID string, idem *storage.Idempotency) {
+	method, confirmWith := pending.MethodPIN, pending.MethodPIN
+	tf, err := s.store.GetTwoFactor(userID)
+	if err != nil && !errors.Is(err, account.ErrTwoFactorNotFound) {
+		http.Error(w, "Failed to check second factor", http.StatusInternalServerError)
+		return
+	}
+	if tf != nil && tf.Confirmed {
+		method, confirmWith = pending.MethodOTP, tf.Method
+	}
+
+	t := pending.New(fromID, to, amount, quoteID, method, time.Now(), s.ConfirmTTL)
+	// в ответе ID перевода, поэтому тело строится после создания
+	body := func() []byte {
+		response, _ := json.Marshal(map[string]any{
+			"message":          "Transfer requires confirmation",
+			"confirm_with":     confirmWith,
+			"pending_transfer": t,
+		})
+		return response
+	}
+	if idem != nil {
+		idem.Body = body
+	}
+
+	stored, err := s.store.CreatePendingTransfer(t, idem)
+	if err != nil {
+		writeOperationError(w, "Failed to create pending transfer: ", err)
+		return
+	}
+	if stored != nil {
+		writeStoredResponse(w, stored)
+		return
+	}
+	if confirmWith == account.TwoFactorSMS {
+		acc, err := s.store.GetAccount(userID)
+		if err == nil {
+			err = s.sendCode(acc, "transfer confirmation")
+		}
+		if err != nil {
+			http.Error(w, "Failed to send code", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(body())
+}
+
+func (s *Server) handleGetPendingTransfer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	t, ok := s.ownPendingTransfer(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// подтверждение перевода PIN ({"pin"}) или кодом второго фактора
+// ({"code"}); после MaxAttempts неверных подтверждений перевод отменяется.
+// Неверные подтверждения считаются и в счётчике неудачных входов, так что
+// новый перевод не даёт новых попыток подобрать PIN или код
+func (s *Server) handleConfirmPendingTransfer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		PIN  string `json:"pin"`
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.ownPendingTransfer(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := r.Context().Value("user_id").(string)
+	if err := t.CheckPending(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if !s.beginLogin(w, r, userID, http.Error) {
+		return
+	}
+
+	// проверка и её учёт идут под блокировкой перевода: параллельные
+	// подтверждения не получат лишних попыток. Статус меняется до
+	// исполнения, поэтому повторное подтверждение не исполнит перевод
+	// второй раз
+	verified, cancelled := false, false
+	errVerify := errors.New("verification failed")
+	err := s.store.UpdatePendingTransfer(t.ID, func(t *pending.Transfer) error {
+		if err := t.CheckPending(time.Now()); err != nil {
+			return err
+		}
+		ok, err := s.verifyStepUp(userID, t.Method, req.PIN, req.Code)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errVerify, err)
+		}
+		if !ok {
+			t.Fail(time.Now())
+			cancelled = t.Status == pending.StatusCancelled
+			return nil
+		}
+		verified = true
+		return t.Confirm(time.Now())
+	})
+	if errors.Is(err, errVerify) {
+		http.Error(w, "Failed to verify confirmation", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if !verified {
+		locked := s.failLogin(r, userID, "step_up")
+		switch {
+		case locked:
+			http.Error(w, "Invalid PIN or code, too many failed attempts", http.StatusUnauthorized)
+		case cancelled:
+			http.Error(w, "Invalid PIN or code, transfer cancelled", http.StatusUnauthorized)
+		default:
+			http.Error(w, "Invalid PIN or code", http.StatusUnauthorized)
+		}
+		return
+	}
+	s.passLogin(userID, true)
+
+	if _, err := s.store.Transfer(t.AccountID, t.ToAccount, t.Amount, t.QuoteID, nil); err != nil {
+		s.store.UpdatePendingTransfer(t.ID, func(t *pending.Transfer) error {
+			t.SetFailed(err.Error(), time.Now())
+			return nil
+		})
+		writeTransferError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":             "Transfer successful",
+		"from":                t.AccountID,
+		"to":                  t.ToAccount,
+		"amount":              t.Amount.String(),
+		"currency":            t.Amount.Currency,
+		"pending_transfer_id": strconv.FormatInt(t.ID, 10),
+	})
+}
+
+func (s *Server) handleCancelPendingTransfer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	t, ok := s.ownPendingTransfer(w, r)
+	if !ok {
+		return
+	}
+	err := s.store.UpdatePendingTransfer(t.ID, func(t *pending.Transfer) error {
+		return t.Cancel(time.Now())
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Transfer cancelled"})
+}
+
+// проверка PIN входа или кода второго фактора владельца счёта
+func (s *Server) verifyStepUp(userID, method, pin, code string) (bool, error) {
+	switch method {
+	case pending.MethodPIN:
+		acc, err := s.store.GetAccount(userID)
+		if err != nil {
+			return false, err
+		}
+		return pin != "" && account.CheckPasswordHash(pin, acc.Password), nil
+	case pending.MethodOTP:
+		err := s.store.UpdateTwoFactor(userID, func(tf *account.TwoFactor) error {
+			_, err := tf.Verify(code, time.Now())
+			return err
+		})
+		if errors.Is(err, account.ErrInvalidCode) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, fmt.Errorf("unknown confirmation method %s", method)
+}
+
+// перевод клиента по ID из пути; чужие переводы не отличаются
+// от несуществующих
+func (s *Server) ownPendingTransfer(w http.ResponseWriter, r *http.Request) (*pending.Transfer, bool) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid pending transfer ID", http.StatusBadRequest)
+		return nil, false
+	}
+	t, err := s.store.GetPendingTransfer(id)
+	if errors.Is(err, pending.ErrNotFound) {
+		http.Error(w, "Pending transfer not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get pending transfer", http.StatusInternalServerError)
+		return nil, false
+	}
+	ids, err := s.ownAccountIDs(userID)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !slices.Contains(ids, t.AccountID) {
+		http.Error(w, "Pending transfer not found", http.StatusNotFound)
+		return nil, false
+	}
+	return t, true
+}
+
+// порог подтверждения своего счёта; ?currency= выбирает валютный счёт
+func (s *Server) handleMyConfirmThreshold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	acc, ok := s.myAccount(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(s.thresholdResponse(acc))
+}
+
+// клиент может только понизить порог: повышение снимает защиту
+// и делается сотрудником банка
+func (s *Server) handleLowerConfirmThreshold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Threshold string `json:"threshold"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	acc, ok := s.myAccount(w, r)
+	if !ok {
+		return
+	}
+	threshold, err := money.Parse(req.Threshold, acc.Currency())
+	if err != nil {
+		http.Error(w, "Valid threshold required: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if current := s.confirmThreshold(acc); current != nil && threshold.Amount > current.Amount {
+		http.Error(w, "Only the bank can raise the confirmation threshold", http.StatusForbidden)
+		return
+	}
+
+	if !s.setConfirmThreshold(w, acc.ID, &threshold) {
+		return
+	}
+	acc.ConfirmThreshold = &threshold
+	json.NewEncoder(w).Encode(s.thresholdResponse(acc))
+}
+
+// порог подтверждения любого счёта; {"threshold": null} возвращает
+// порог по умолчанию
+func (s *Server) handleSetConfirmThreshold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Threshold *string `json:"threshold"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	acc, err := s.store.GetAccount(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	var threshold *money.Money
+	details := "default"
+	if req.Threshold != nil {
+		parsed, err := money.Parse(*req.Threshold, acc.Currency())
+		if err != nil {
+			http.Error(w, "Valid threshold required: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		threshold, details = &parsed, parsed.String()
+	}
+
+	if !s.setConfirmThreshold(w, acc.ID, threshold) {
+		return
+	}
+	s.audit(r, "set_confirm_threshold", acc.ID, "threshold="+details)
+
+	acc.ConfirmThreshold = threshold
+	json.NewEncoder(w).Encode(s.thresholdResponse(acc))
+}
+
+func (s *Server) setConfirmThreshold(w http.ResponseWriter, accountID string, threshold *money.Money) bool {
+	if err := s.store.SetConfirmThreshold(accountID, threshold); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return false
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (s *Server) thresholdResponse(acc *account.Account) map[string]any {
+	return map[string]any{
+		"account_id":        acc.ID,
+		"confirm_threshold": s.confirmThreshold(acc),
+		"default":           acc.ConfirmThreshold == nil,
+	}
+}
+
+// свой счёт по ?currency=
+func (s *Server) myAccount(w http.ResponseWriter, r *http.Request) (*account.Account, bool) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	acc, err := s.store.GetAccount(accountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return nil, false
+	}
+	return acc, true
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"log"
+	"mfp/account"
+	"mfp/storage"
+	"net/http"
+	"slices"
+)
+
+// доступ только для перечисленных ролей; роль кладёт в контекст authMiddleware
+func (s *Server) requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value("role").(string)
+			if !slices.Contains(roles, role) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// аудитор может только читать: любые изменяющие запросы запрещены
+func readOnlyForAuditors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("role").(string)
+		if role == account.RoleAuditor && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Auditors have read-only access", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// запись в журнал аудита от имени текущего пользователя
+func (s *Server) audit(r *http.Request, action, targetID, details string) {
+	actorID, _ := r.Context().Value("user_id").(string)
+	actorRole, _ := r.Context().Value("role").(string)
+
+	err := s.store.RecordAudit(&storage.AuditEvent{
+		ActorID:   actorID,
+		ActorRole: actorRole,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+	})
+	if err != nil {
+		log.Printf("Failed to record audit event %s by %s: %v", action, actorID, err)
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mfp/money"
+	"mfp/pending"
+	"mfp/schedule"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ID основного аккаунта клиента и его валютных счетов
+func (s *Server) ownAccountIDs(userID string) ([]string, error) {
+	accounts, err := s.store.GetOwnedAccounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(accounts))
+	for _, acc := range accounts {
+		ids = append(ids, acc.ID)
+	}
+	return ids, nil
+}
+
+// создание разового или регулярного перевода;
+// валюта суммы, как и при обычном переводе, выбирает счёт списания
+func (s *Server) handleCreateScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		To        string      `json:"to"`
+		Amount    money.Money `json:"amount"`
+		Frequency string      `json:"frequency"` // once, daily, weekly, monthly
+		StartAt   string      `json:"start_at"`  // дата или RFC 3339
+		EndAt     string      `json:"end_at"`
+		MaxRuns   int         `json:"max_runs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	startAt, _, err := parseDate(req.StartAt)
+	if err != nil {
+		http.Error(w, "Invalid start_at: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if startAt.Before(time.Now().Add(-time.Minute)) {
+		http.Error(w, "start_at must not be in the past", http.StatusBadRequest)
+		return
+	}
+	var endAt *time.Time
+	if req.EndAt != "" {
+		end, dateOnly, err := parseDate(req.EndAt)
+		if err != nil {
+			http.Error(w, "Invalid end_at: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if dateOnly {
+			end = end.AddDate(0, 0, 1).Add(-time.Nanosecond) // дата включается целиком
+		}
+		endAt = &end
+	}
+
+	fromID, err := s.ownAccount(userID, req.Amount.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// планировщик исполняет переводы без подтверждения, поэтому перевод
+	// больше порога запланировать нельзя: иначе разовый перевод с началом
+	// «сейчас» обходил бы подтверждение PIN или кодом
+	from, err := s.store.GetAccount(fromID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if pending.RequiresConfirmation(req.Amount, s.confirmThreshold(from)) {
+		http.Error(w, "Amount exceeds the confirmation threshold; scheduled transfers cannot be confirmed", http.StatusForbidden)
+		return
+	}
+	if _, err := s.store.GetAccount(req.To); err != nil {
+		http.Error(w, "Destination account not found", http.StatusNotFound)
+		return
+	}
+
+	t, err := schedule.New(fromID, req.To, req.Amount, req.Frequency, startAt, endAt, req.MaxRuns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.CreateScheduledTransfer(t); err != nil {
+		http.Error(w, "Failed to schedule transfer", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// запланированные переводы со всех счетов клиента
+func (s *Server) handleMyScheduledTransfers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ids, err := s.ownAccountIDs(userID)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return
+	}
+	transfers, err := s.store.GetScheduledTransfers(ids)
+	if err != nil {
+		http.Error(w, "Failed to get scheduled transfers", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(transfers)
+}
+
+func (s *Server) handlePauseScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	s.setScheduledTransferStatus(w, r, schedule.StatusPaused)
+}
+
+func (s *Server) handleResumeScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	s.setScheduledTransferStatus(w, r, schedule.StatusActive)
+}
+
+func (s *Server) handleCancelScheduledTransfer(w http.ResponseWriter, r *http.Request) {
+	s.setScheduledTransferStatus(w, r, schedule.StatusCancelled)
+}
+
+// смена статуса своего запланированного перевода
+func (s *Server) setScheduledTransferStatus(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid scheduled transfer ID", http.StatusBadRequest)
+		return
+	}
+
+	t, err := s.store.GetScheduledTransfer(id)
+	if errors.Is(err, schedule.ErrNotFound) {
+		http.Error(w, "Scheduled transfer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get scheduled transfer", http.StatusInternalServerError)
+		return
+	}
+
+	// чужие переводы не отличаются от несуществующих
+	ids, err := s.ownAccountIDs(userID)
+	if err != nil {
+		http.Error(w, "Failed to get accounts", http.StatusInternalServerError)
+		return
+	}
+	owned := false
+	for _, accID := range ids {
+		owned = owned || accID == t.AccountID
+	}
+	if !owned {
+		http.Error(w, "Scheduled transfer not found", http.StatusNotFound)
+		return
+	}
+
+	if err := schedule.CheckStatusChange(t.Status, status); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	// порог могли снизить, пока перевод стоял на паузе
+	if status == schedule.StatusActive {
+		from, err := s.store.GetAccount(t.AccountID)
+		if err != nil {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		if pending.RequiresConfirmation(t.Amount, s.confirmThreshold(from)) {
+			http.Error(w, "Amount exceeds the confirmation threshold; scheduled transfers cannot be confirmed", http.StatusForbidden)
+			return
+		}
+	}
+	if err := s.store.SetScheduledTransferStatus(t.ID, t.Status, status); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": fmt.Sprintf("Scheduled transfer %d is %s", t.ID, status),
+		"status":  status,
+	})
+}
+
+// уведомления клиента, новые сверху; ?limit= от 1 до 200
+func (s *Server) handleMyNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultPageSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := s.store.GetNotifications(userID, limit)
+	if err != nil {
+		http.Error(w, "Failed to get notifications", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(notifications)
+}
@@ -3,12 +3,19 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"mfp/account"
-	"mfp/database"
+	"mfp/fx"
+	"mfp/money"
+	"mfp/otp"
+	"mfp/pending"
 	"mfp/session"
+	"mfp/storage"
+	"mfp/token"
+	"net"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -17,23 +24,54 @@ import (
 
 // структура сервера API
 type Server struct {
-	repo           *database.Repository
+	store          storage.Store
 	SessionManager *session.SessionManager
 	RateLimiter    *RateLimiter
+	QuoteTTL       time.Duration // срок действия котировки обмена
+	LimitCooling   time.Duration // через сколько вступает в силу повышение лимитов без одобрения
+	ProcessingKey  string        // ключ процессинга карт; пустой выключает авторизации
+	HoldTTL        time.Duration // срок блокировки по карте без списания
+
+	TwoFactorRequired bool          // вход только со вторым фактором
+	TOTPIssuer        string        // название банка в приложении-аутентификаторе
+	OTPSender         otp.Sender    // доставка одноразовых кодов
+	OTPCodeTTL        time.Duration // срок одноразового кода и незавершённого входа
+
+	ConfirmThreshold string        // порог подтверждения переводов по умолчанию в валюте счёта; пусто — без подтверждения
+	ConfirmTTL       time.Duration // сколько перевод ждёт подтверждения
+
+	Tokens            *token.Manager      // вход по токенам; nil — только cookie
+	LoginPolicy       account.LoginPolicy // паузы и блокировка после неудачных входов
+	CardPolicy        account.LoginPolicy // блокировка карты после неверных реквизитов подряд
+	TrustForwardedFor bool                // сервер за прокси, который передаёт адрес клиента в X-Forwarded-For
 }
 
 // создание нового сервера API
-func NewServer(repo *database.Repository, sessionManager *session.SessionManager) *Server {
+func NewServer(store storage.Store, sessionManager *session.SessionManager, rateLimiter *RateLimiter) *Server {
 	return &Server{
-		repo:           repo,
+		store:          store,
 		SessionManager: sessionManager,
-		RateLimiter:    NewRateLimiter(3, 10*time.Second),
+		RateLimiter:    rateLimiter,
+		QuoteTTL:       time.Minute,
+		LimitCooling:   24 * time.Hour,
+		HoldTTL:        7 * 24 * time.Hour,
+		CardPolicy:     account.LoginPolicy{MaxFailures: 3},
+		TOTPIssuer:     "MFP Bank",
+		OTPSender:      otp.LogSender{},
+		OTPCodeTTL:     5 * time.Minute,
+		ConfirmTTL:     10 * time.Minute,
+		LoginPolicy: account.LoginPolicy{
+			MaxFailures: 5,
+			BaseDelay:   time.Second,
+			MaxDelay:    30 * time.Second,
+			Lockout:     15 * time.Minute,
+		},
 	}
 }
 
 func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getIPAddress(r)
+		ip := s.clientIP(r)
 		fmt.Printf("Rate limit check for IP: %s\n", ip)
 
 		if !s.RateLimiter.Allow(ip) {
@@ -47,34 +85,104 @@ func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func getIPAddress(r *http.Request) string {
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = r.RemoteAddr
+// адрес клиента. X-Forwarded-For может прислать сам клиент, поэтому
+// он учитывается только за доверенным прокси, и берётся последний
+// адрес — его добавил прокси
+func (s *Server) clientIP(r *http.Request) string {
+	if s.TrustForwardedFor {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-	return ip
+	return host
 }
 
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return s.authenticate(next, false)
+}
+
+// пускает и вход, ожидающий второй фактор: с ним можно только
+// подключить второй фактор, если он обязателен
+func (s *Server) pendingAuthMiddleware(next http.Handler) http.Handler {
+	return s.authenticate(next, true)
+}
+
+// вход по cookie session_id или по токену доступа в заголовке
+// Authorization: Bearer; токен, если он есть, важнее cookie
+func (s *Server) authenticate(next http.Handler, allowPending bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if raw, ok := bearerToken(r); ok {
+			s.authenticateBearer(next, w, r, raw)
+			return
+		}
+
 		cookie, err := r.Cookie("session_id")
 		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
+			unauthorized(w, r, "Authentication required")
 			return
 		}
 
 		sess, err := s.SessionManager.GetSession(cookie.Value)
 		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
+			unauthorized(w, r, "Session expired")
+			return
+		}
+		if sess.Pending && !allowPending {
+			errorWriterFor(r)(w, "Second factor required", http.StatusUnauthorized)
+			return
+		}
+
+		acc, err := s.store.GetAccount(sess.UserID)
+		if err != nil || account.CheckLogin(acc.Status) != nil {
+			s.SessionManager.DeleteSession(sess.ID)
+			unauthorized(w, r, "Login is not allowed")
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), "user_id", sess.UserID)
+		ctx = context.WithValue(ctx, "role", acc.EffectiveRole())
+		ctx = context.WithValue(ctx, "session_id", sess.ID)
+		ctx = context.WithValue(ctx, "pending", sess.Pending)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// токен доступа проверяется по подписи и сроку без обращения к хранилищу,
+// но статус аккаунта проверяется при каждом запросе, как и для сессий
+func (s *Server) authenticateBearer(next http.Handler, w http.ResponseWriter, r *http.Request, raw string) {
+	if s.Tokens == nil {
+		unauthorized(w, r, "Token authentication is disabled")
+		return
+	}
+	claims, err := s.Tokens.Authenticate(raw)
+	if errors.Is(err, token.ErrExpired) {
+		unauthorized(w, r, "Access token expired")
+		return
+	}
+	if err != nil {
+		unauthorized(w, r, "Invalid access token")
+		return
+	}
+
+	acc, err := s.store.GetAccount(claims.Subject)
+	if err != nil || account.CheckLogin(acc.Status) != nil {
+		unauthorized(w, r, "Login is not allowed")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "user_id", acc.ID)
+	ctx = context.WithValue(ctx, "role", acc.EffectiveRole())
+	ctx = context.WithValue(ctx, "pending", false)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // обработчик создания аккаунта
 func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	// start := time.Now()
@@ -95,65 +203,120 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 
 	acc := account.NewAccount(req.Password, req.FirstName, req.Phone, req.Age)
 	// log.Printf("✅ Account object created in %v", time.Since(start))
+	if req.Currency != "" {
+		if err := money.ValidateCurrency(req.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		acc.Balance = money.Zero(req.Currency)
+	}
 
-	if err := s.repo.CreateAccount(acc); err != nil {
+	// первая карта выпускается сразу, если клиент задал её PIN
+	var card *account.Card
+	if req.PIN != "" {
+		var err error
+		if card, err = account.NewCard(acc.ID, req.PIN); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.store.CreateAccountWithCard(acc, card); err != nil {
 		// log.Printf("❌ AddAccount error: %v", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	// log.Printf("✅ Account added to list in %v", time.Since(start))
 
+	response := struct {
+		AccountResponse
+		Card *CardResponse `json:"card,omitempty"`
+	}{AccountResponse: AccountToResponse(acc)}
+	if card != nil {
+		issued := CardToResponse(card, true)
+		response.Card = &issued
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(AccountToResponse(acc))
+	json.NewEncoder(w).Encode(response)
 	// log.Printf("🎉 Total account creation time: %v", time.Since(start))
 }
 
-// обработчик получения всех аккаунтов
-func (s *Server) handleGetAccounts(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleMyDeposit(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	accounts, err := s.repo.GetAccounts()
-	if err != nil {
-		http.Error(w, "Couldnt get accounts from database", http.StatusBadRequest)
-	}
-	response := make([]AccountResponse, 0, len(accounts))
-	for _, acc := range accounts {
-		response = append(response, AccountToResponse(acc))
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	json.NewEncoder(w).Encode(response)
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.deposit(w, r, accountID)
 }
 
-// обработчик получения аккаунта по ID
-func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// пополнение счёта accountID в его валюте; возвращает true при успехе
+func (s *Server) deposit(w http.ResponseWriter, r *http.Request, accountID string) bool {
+	acc, err := s.store.GetAccount(accountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return false
+	}
+	amount, err := parseAmount(r.URL.Query().Get("amount"), acc.Currency())
+	if err != nil {
+		http.Error(w, "Valid amount required: "+err.Error(), http.StatusBadRequest)
+		return false
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing account ID", http.StatusBadRequest)
-		return
+	response, _ := json.Marshal(map[string]string{
+		"message":  "Deposit successful",
+		"id":       accountID,
+		"amount":   amount.String(),
+		"currency": amount.Currency,
+	})
+	idem, err := idempotencyFromRequest(r, "deposit", http.StatusOK, response, amount.Format())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
 	}
-	acc, err := s.repo.GetAccount(id)
+
+	stored, err := s.store.Deposit(accountID, amount, idem)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		writeOperationError(w, "Error depositing amount:\n", err)
+		return false
+	}
+	if stored != nil {
+		writeStoredResponse(w, stored)
+		return false
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(AccountToResponse(acc))
+	w.Write(response)
+	return true
 }
 
-func (s *Server) handleMyDeposit(w http.ResponseWriter, r *http.Request) {
+// разбор суммы из запроса: строго не более двух знаков после запятой
+func parseAmount(raw, currency string) (money.Money, error) {
+	amount, err := money.Parse(raw, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if !amount.IsPositive() {
+		return money.Money{}, fmt.Errorf("amount must be positive")
+	}
+	return amount, nil
+}
+
+func (s *Server) handleMyWithdraw(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
@@ -167,58 +330,55 @@ func (s *Server) handleMyDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
-	if err != nil || amount <= 0 {
-		http.Error(w, "Valid amount required", http.StatusBadRequest)
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	s.withdraw(w, r, accountID)
+}
 
-	if err := s.repo.Deposit(userID, amount); err != nil {
-		http.Error(w, "Error depositing amount:\n"+err.Error(), http.StatusInternalServerError)
-		return
+// снятие со счёта accountID в его валюте; возвращает true при успехе
+func (s *Server) withdraw(w http.ResponseWriter, r *http.Request, accountID string) bool {
+	acc, err := s.store.GetAccount(accountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return false
+	}
+	amount, err := parseAmount(r.URL.Query().Get("amount"), acc.Currency())
+	if err != nil {
+		http.Error(w, "Valid amount required: "+err.Error(), http.StatusBadRequest)
+		return false
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Deposit successful",
-		"id":      userID,
-		"amount":  fmt.Sprintf("%.2f", amount),
+	response, _ := json.Marshal(map[string]string{
+		"message":  "Withdrawal successful",
+		"id":       accountID,
+		"amount":   amount.String(),
+		"currency": amount.Currency,
 	})
-}
-
-func (s *Server) handleMyWithdraw(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	idem, err := idempotencyFromRequest(r, "withdraw", http.StatusOK, response, amount.Format())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
 	}
 
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
-	if err != nil || amount <= 0 {
-		http.Error(w, "Valid amount required", http.StatusBadRequest)
-		return
+	stored, err := s.store.Withdraw(accountID, amount, idem)
+	if err != nil {
+		writeOperationError(w, "Error withdrawing amount:\n", err)
+		return false
 	}
-
-	if err := s.repo.Withdraw(userID, amount); err != nil {
-		http.Error(w, "Error withdrawing amount:\n"+err.Error(), http.StatusInternalServerError)
-		return
+	if stored != nil {
+		writeStoredResponse(w, stored)
+		return false
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Withdrawal successful",
-		"id":      userID,
-		"amount":  fmt.Sprintf("%.2f", amount),
-	})
+	w.Write(response)
+	return true
 }
 
-// обработчик удаления аккаунта
+// закрытие аккаунта клиентом; строка и история операций сохраняются
 func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -231,12 +391,17 @@ func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if err := s.repo.DeleteAccount(userID); err != nil {
-		http.Error(w, "Error removing account:\n"+err.Error(), http.StatusInternalServerError)
+	if err := s.store.SetStatus(userID, account.StatusClosed, "closed by customer"); err != nil {
+		http.Error(w, "Error closing account:\n"+err.Error(), http.StatusBadRequest)
 		return
 	}
+	s.audit(r, "close_account", userID, "")
+
+	if cookie, err := r.Cookie("session_id"); err == nil {
+		s.SessionManager.DeleteSession(cookie.Value)
+	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Account successfully deleted"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account successfully closed"})
 }
 
 // обработчик перевода средств между аккаунтами
@@ -248,19 +413,20 @@ func (s *Server) handleMyTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fromID, ok := r.Context().Value("user_id").(string)
+	userID, ok := r.Context().Value("user_id").(string)
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	var req struct {
-		To     string  `json:"to"`
-		Amount float64 `json:"amount"`
+		To      string      `json:"to"`
+		Amount  money.Money `json:"amount"`
+		QuoteID string      `json:"quote_id"` // котировка для перевода в другую валюту
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -269,25 +435,72 @@ func (s *Server) handleMyTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Amount <= 0 {
+	if !req.Amount.IsPositive() {
 		http.Error(w, "Amount must be positive", http.StatusBadRequest)
 		return
 	}
 
-	if err := s.repo.Transfer(fromID, req.To, req.Amount); err != nil {
-		http.Error(w, "Error transferring amount:\n"+err.Error(), http.StatusInternalServerError)
+	// валюта суммы выбирает счёт клиента, с которого идёт списание
+	fromID, err := s.ownAccount(userID, req.Amount.Currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// перевод больше порога счёта исполняется только после подтверждения
+	from, err := s.store.GetAccount(fromID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if pending.RequiresConfirmation(req.Amount, s.confirmThreshold(from)) {
+		// тот же ключ и хеш, что у перевода без подтверждения: повтор
+		// запроса получает сохранённый ответ 202 и второй перевод не создаёт
+		idem, err := idempotencyFromRequest(r, "transfer", http.StatusAccepted, nil, req.To, req.Amount.Format(), req.QuoteID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.createPendingTransfer(w, userID, fromID, req.To, req.Amount, req.QuoteID, idem)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Transfer successful",
-		"from":    fromID,
-		"to":      req.To,
-		"amount":  fmt.Sprintf("%.2f", req.Amount),
+	response, _ := json.Marshal(map[string]string{
+		"message":  "Transfer successful",
+		"from":     fromID,
+		"to":       req.To,
+		"amount":   req.Amount.String(),
+		"currency": req.Amount.Currency,
 	})
+	idem, err := idempotencyFromRequest(r, "transfer", http.StatusOK, response, req.To, req.Amount.Format(), req.QuoteID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := s.store.Transfer(fromID, req.To, req.Amount, req.QuoteID, idem)
+	if err != nil {
+		writeTransferError(w, err)
+		return
+	}
+	if stored != nil {
+		writeStoredResponse(w, stored)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+func writeTransferError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fx.ErrQuoteNotFound) {
+		http.Error(w, "Quote not found", http.StatusNotFound)
+		return
+	}
+	writeOperationError(w, "Error transferring amount:\n", err)
 }
 
+// история операций своего счёта; ?currency= выбирает валютный счёт
 func (s *Server) handleMyTransactions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -297,12 +510,12 @@ func (s *Server) handleMyTransactions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transactions, err := s.repo.GetTransactions(userID)
+	accountID, err := s.ownAccount(userID, r.URL.Query().Get("currency"))
 	if err != nil {
-		http.Error(w, "Failed to get transactions", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	json.NewEncoder(w).Encode(transactions)
+	s.transactions(w, r, accountID)
 }
 
 func (s *Server) handleGetMyAccount(w http.ResponseWriter, r *http.Request) {
@@ -319,7 +532,7 @@ func (s *Server) handleGetMyAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	acc, err := s.repo.GetAccount(userID)
+	acc, err := s.store.GetAccount(userID)
 	if err != nil {
 		http.Error(w, "Account not found", http.StatusNotFound)
 		return
@@ -344,19 +557,50 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	acc, err := s.repo.GetAccountByPhone(loginReq.Phone)
+	acc, ok := s.checkCredentials(w, r, loginReq.Phone, loginReq.Password, http.Error)
+	if !ok {
+		return
+	}
+
+	// после пароля нужен второй фактор: выдаётся сессия, которая
+	// открывает только его проверку или подключение
+	secondFactor, err := s.secondFactor(acc)
 	if err != nil {
-		http.Error(w, "Invalid phone", http.StatusUnauthorized)
+		http.Error(w, "Failed to check second factor", http.StatusInternalServerError)
+		return
+	}
+	s.passLogin(acc.ID, secondFactor == "")
+	if secondFactor != "" {
+		sessionID, err := s.SessionManager.CreatePendingSession(acc.ID, r.RemoteAddr, r.UserAgent(), s.OTPCodeTTL)
+		if err != nil {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		setSessionCookie(w, sessionID)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message":       "Second factor required",
+			"second_factor": secondFactor,
+		})
 		return
 	}
 
-	if !account.CheckPasswordHash(loginReq.Password, acc.Password) {
-		http.Error(w, "Invalid Password", http.StatusUnauthorized)
+	sessionID, err := s.SessionManager.CreateSession(acc.ID, r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
+	setSessionCookie(w, sessionID)
 
-	sessionID := s.SessionManager.CreateSession(acc.ID, r.RemoteAddr, r.UserAgent())
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Login successful",
+		"user_id": acc.ID,
+	})
+}
 
+func setSessionCookie(w http.ResponseWriter, sessionID string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_id",
 		Value:    sessionID,
@@ -365,12 +609,6 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Secure:   false,
 		Path:     "/",
 	})
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Login successful",
-		"user_id": acc.ID,
-	})
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -393,29 +631,137 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
-func (s *Server) Start() {
+func (s *Server) Start(addr string) error {
+	fmt.Printf("Server started at %s\n", addr)
+	return http.ListenAndServe(addr, s.routes())
+}
+
+// маршруты API вместе с промежуточными обработчиками
+func (s *Server) routes() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(s.rateLimitMiddleware)
 
 	r.Post("/login", s.handleLogin)
+	r.Post("/login/verify", s.handleLoginVerify)
 	r.Post("/logout", s.handleLogout)
 	r.Post("/register", s.handleCreateAccount)
+	if s.Tokens != nil {
+		r.Post("/auth/token", s.handleIssueToken)
+		r.Post("/auth/refresh", s.handleRefreshToken)
+		r.Post("/auth/revoke", s.handleRevokeToken)
+	}
 
+	// процессинг карт входит по своему ключу, а не по сессии клиента
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireProcessingKey)
+
+		r.Post("/card-payments/authorize", s.handleAuthorizeCard)
+		r.Get("/card-payments/{id}", s.handleGetHold)
+		r.Post("/card-payments/{id}/capture", s.handleCaptureHold)
+		r.Post("/card-payments/{id}/reverse", s.handleReverseHold)
+	})
+
+	// подключение второго фактора доступно и входу, который ждёт его
+	r.Group(func(r chi.Router) {
+		r.Use(s.pendingAuthMiddleware)
+
+		r.Post("/accounts/me/2fa", s.handleEnrollTwoFactor)
+		r.Post("/accounts/me/2fa/confirm", s.handleConfirmTwoFactor)
+	})
+
+	// второй фактор настраивают и аудиторы, поэтому без readOnlyForAuditors
 	r.Group(func(r chi.Router) {
 		r.Use(s.authMiddleware)
 
+		r.Get("/accounts/me/2fa", s.handleMyTwoFactor)
+		r.Post("/accounts/me/2fa/code", s.handleSendTwoFactorCode)
+		r.Post("/accounts/me/2fa/recovery-codes", s.handleRegenerateRecoveryCodes)
+		r.Delete("/accounts/me/2fa", s.handleDisableTwoFactor)
+		r.Delete("/accounts/me/tokens", s.handleRevokeMyTokens)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.authMiddleware)
+		r.Use(readOnlyForAuditors)
+
 		r.Get("/accounts/me", s.handleGetMyAccount)
 		r.Get("/accounts/me/transactions", s.handleMyTransactions)
+		r.Get("/accounts/me/statements", s.handleMyStatement)
 		r.Post("/accounts/me/deposit", s.handleMyDeposit)
 		r.Post("/accounts/me/withdraw", s.handleMyWithdraw)
 		r.Post("/accounts/me/transfer", s.handleMyTransfer)
+		r.Get("/accounts/me/pending-transfers/{id}", s.handleGetPendingTransfer)
+		r.Post("/accounts/me/pending-transfers/{id}/confirm", s.handleConfirmPendingTransfer)
+		r.Delete("/accounts/me/pending-transfers/{id}", s.handleCancelPendingTransfer)
+		r.Get("/accounts/me/confirm-threshold", s.handleMyConfirmThreshold)
+		r.Put("/accounts/me/confirm-threshold", s.handleLowerConfirmThreshold)
 		r.Delete("/accounts/me", s.handleDeleteAccount)
+		r.Get("/accounts/me/currencies", s.handleMyCurrencyAccounts)
+		r.Post("/accounts/me/currencies", s.handleOpenCurrencyAccount)
+		r.Get("/accounts/me/scheduled-transfers", s.handleMyScheduledTransfers)
+		r.Post("/accounts/me/scheduled-transfers", s.handleCreateScheduledTransfer)
+		r.Post("/accounts/me/scheduled-transfers/{id}/pause", s.handlePauseScheduledTransfer)
+		r.Post("/accounts/me/scheduled-transfers/{id}/resume", s.handleResumeScheduledTransfer)
+		r.Delete("/accounts/me/scheduled-transfers/{id}", s.handleCancelScheduledTransfer)
+		r.Get("/accounts/me/notifications", s.handleMyNotifications)
+		r.Get("/accounts/me/limits", s.handleMyLimits)
+		r.Put("/accounts/me/limits", s.handleRequestLimits)
+		r.Get("/accounts/me/cards", s.handleMyCards)
+		r.Post("/accounts/me/cards", s.handleIssueCard)
+		r.Post("/accounts/me/cards/{id}/block", s.handleBlockCard)
+		r.Post("/accounts/me/cards/{id}/unblock", s.handleUnblockCard)
+		r.Post("/accounts/me/cards/{id}/reissue", s.handleReissueCard)
+		r.Put("/accounts/me/cards/{id}/pin", s.handleChangeCardPIN)
+
+		r.Get("/fx/rates", s.handleGetRates)
+		r.Post("/fx/quotes", s.handleCreateQuote)
+		r.Get("/fees/rules", s.handleGetFeeRules)
+		r.Post("/fees/quote", s.handleFeeQuote)
+
+		// просмотр чужих аккаунтов: администратор и аудитор
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireRole(account.RoleAdmin, account.RoleAuditor))
+
+			r.Get("/accounts", s.handleGetAccounts)
+			r.Get("/audit", s.handleAuditLog)
+		})
+
+		// карточка и история клиента нужны и операционисту
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireRole(account.RoleAdmin, account.RoleAuditor, account.RoleTeller))
+
+			r.Get("/accounts/{id}", s.handleGetAccount)
+			r.Get("/accounts/{id}/transactions", s.handleGetAccountTransactions)
+			r.Get("/accounts/{id}/cards", s.handleGetAccountCards)
+			r.Get("/accounts/{id}/login-attempts", s.handleGetLoginAttempts)
+		})
+
+		// операции за клиента: операционист и администратор
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireRole(account.RoleAdmin, account.RoleTeller))
+
+			r.Post("/accounts/{id}/deposit", s.handleAccountDeposit)
+			r.Post("/accounts/{id}/withdraw", s.handleAccountWithdraw)
+			r.Put("/cards/{id}/status", s.handleSetCardStatus)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireRole(account.RoleAdmin))
+
+			r.Put("/accounts/{id}/role", s.handleSetRole)
+			r.Put("/accounts/{id}/status", s.handleSetStatus)
+			r.Put("/accounts/{id}/overdraft", s.handleSetOverdraft)
+			r.Put("/accounts/{id}/product", s.handleSetProduct)
+			r.Put("/accounts/{id}/limits", s.handleSetLimits)
+			r.Put("/accounts/{id}/confirm-threshold", s.handleSetConfirmThreshold)
+			r.Delete("/accounts/{id}/2fa", s.handleResetTwoFactor)
+			r.Post("/accounts/{id}/unlock", s.handleUnlockLogin)
+			r.Post("/accounts/{id}/limits/approve", s.handleApproveLimits)
+			r.Put("/fx/rates", s.handleSetRates)
+			r.Put("/fees/rules", s.handleSetFeeRules)
+		})
 	})
 
-	r.Get("/accounts", s.handleGetAccounts)
-	r.Get("/accounts/{id}", s.handleGetAccount)
-
-	fmt.Println("Server started at http://localhost:8080")
-	http.ListenAndServe(":8080", r)
+	return r
 }
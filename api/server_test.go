@@ -0,0 +1,239 @@
+package api
+
+import (
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"mfp/session"
+	"mfp/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "123456"
+
+// сервер на хранилище в памяти с двумя клиентами по 1000.00
+func newTestServer(t *testing.T) (*Server, storage.Store, http.Handler) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.NewMemoryStore()
+	for n := 1; n <= 2; n++ {
+		err := store.CreateAccount(&account.Account{
+			ID:           fmt.Sprintf("KZ%014d", n),
+			Password:     string(hash),
+			Balance:      money.FromMinor(100000, money.DefaultCurrency),
+			Name:         "Test",
+			Phone:        fmt.Sprintf("7700000%04d", n),
+			Age:          30,
+			Role:         account.RoleCustomer,
+			Status:       account.StatusActive,
+			CreatedAt:    time.Now(),
+			ExpiredAt:    time.Now().AddDate(5, 0, 0),
+			Transactions: []account.Transaction{},
+		})
+		if err != nil {
+			t.Fatalf("CreateAccount: %v", err)
+		}
+	}
+
+	sessions := session.NewSessionManager(store.Sessions(), time.Hour, 5)
+	s := NewServer(store, sessions, NewRateLimiter(1000, time.Minute))
+	return s, store, s.routes()
+}
+
+// запрос от имени клиента accountID с новой сессией
+func do(t *testing.T, s *Server, handler http.Handler, accountID, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	if accountID != "" {
+		sessionID, err := s.SessionManager.CreateSession(accountID, "127.0.0.1", "test")
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		r.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func balance(t *testing.T, store storage.Store, accountID string) int64 {
+	t.Helper()
+	acc, err := store.GetAccount(accountID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	return acc.Balance.Amount
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		phone      string
+		password   string
+		wantStatus int
+		wantCookie bool
+	}{
+		{name: "success", phone: "77000000001", password: testPassword, wantStatus: http.StatusOK, wantCookie: true},
+		{name: "wrong password", phone: "77000000001", password: "654321", wantStatus: http.StatusUnauthorized},
+		{name: "unknown phone", phone: "77000009999", password: testPassword, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, handler := newTestServer(t)
+			body := fmt.Sprintf(`{"phone":%q,"password":%q}`, tt.phone, tt.password)
+			w := do(t, s, handler, "", http.MethodPost, "/login", body, nil)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			hasCookie := strings.Contains(w.Header().Get("Set-Cookie"), "session_id=")
+			if hasCookie != tt.wantCookie {
+				t.Errorf("session cookie set = %v, want %v", hasCookie, tt.wantCookie)
+			}
+		})
+	}
+}
+
+// сразу после неудачи следующая попытка ждёт паузу, в том числе
+// для незарегистрированного телефона
+func TestLoginDelayAfterFailure(t *testing.T) {
+	for _, phone := range []string{"77000000001", "77000009999"} {
+		t.Run(phone, func(t *testing.T) {
+			s, _, handler := newTestServer(t)
+			body := fmt.Sprintf(`{"phone":%q,"password":"654321"}`, phone)
+			if w := do(t, s, handler, "", http.MethodPost, "/login", body, nil); w.Code != http.StatusUnauthorized {
+				t.Fatalf("first attempt status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+
+			body = fmt.Sprintf(`{"phone":%q,"password":%q}`, phone, testPassword)
+			w := do(t, s, handler, "", http.MethodPost, "/login", body, nil)
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("second attempt status = %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After header is missing")
+			}
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	const from, to = "KZ00000000000001", "KZ00000000000002"
+
+	tests := []struct {
+		name       string
+		threshold  string
+		body       string
+		wantStatus int
+		wantFrom   int64
+		wantTo     int64
+	}{
+		{
+			name:       "success",
+			body:       `{"to":"KZ00000000000002","amount":{"amount":"100.00","currency":"KZT"}}`,
+			wantStatus: http.StatusOK,
+			wantFrom:   90000,
+			wantTo:     110000,
+		},
+		{
+			name:       "no destination",
+			body:       `{"amount":{"amount":"100.00","currency":"KZT"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantFrom:   100000,
+			wantTo:     100000,
+		},
+		{
+			name:       "zero amount",
+			body:       `{"to":"KZ00000000000002","amount":{"amount":"0","currency":"KZT"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantFrom:   100000,
+			wantTo:     100000,
+		},
+		{
+			name:       "no account in currency",
+			body:       `{"to":"KZ00000000000002","amount":{"amount":"100.00","currency":"USD"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantFrom:   100000,
+			wantTo:     100000,
+		},
+		{
+			name:       "above confirmation threshold",
+			threshold:  "50",
+			body:       `{"to":"KZ00000000000002","amount":{"amount":"100.00","currency":"KZT"}}`,
+			wantStatus: http.StatusAccepted,
+			wantFrom:   100000,
+			wantTo:     100000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store, handler := newTestServer(t)
+			s.ConfirmThreshold = tt.threshold
+			w := do(t, s, handler, from, http.MethodPost, "/accounts/me/transfer", tt.body, nil)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := balance(t, store, from); got != tt.wantFrom {
+				t.Errorf("source balance = %d, want %d", got, tt.wantFrom)
+			}
+			if got := balance(t, store, to); got != tt.wantTo {
+				t.Errorf("destination balance = %d, want %d", got, tt.wantTo)
+			}
+		})
+	}
+}
+
+// повтор запроса с тем же ключом получает сохранённый ответ и деньги
+// второй раз не списывает; тот же ключ с другим телом отклоняется
+func TestTransferIdempotency(t *testing.T) {
+	const from, to = "KZ00000000000001", "KZ00000000000002"
+	s, store, handler := newTestServer(t)
+	body := `{"to":"KZ00000000000002","amount":{"amount":"100.00","currency":"KZT"}}`
+	key := map[string]string{idempotencyHeader: "transfer-1"}
+
+	first := do(t, s, handler, from, http.MethodPost, "/accounts/me/transfer", body, key)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first status = %d: %s", first.Code, first.Body)
+	}
+
+	retry := do(t, s, handler, from, http.MethodPost, "/accounts/me/transfer", body, key)
+	if retry.Code != http.StatusOK || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d replayed %q, want 200 replayed", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry body = %s, want %s", retry.Body, first.Body)
+	}
+
+	other := `{"to":"KZ00000000000002","amount":{"amount":"200.00","currency":"KZT"}}`
+	if w := do(t, s, handler, from, http.MethodPost, "/accounts/me/transfer", other, key); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	if w := do(t, s, handler, from, http.MethodPost, "/accounts/me/transfer", body, map[string]string{idempotencyHeader: "transfer-2"}); w.Code != http.StatusOK {
+		t.Errorf("new key status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if got := balance(t, store, from); got != 80000 {
+		t.Errorf("source balance = %d, want 80000", got)
+	}
+	if got := balance(t, store, to); got != 120000 {
+		t.Errorf("destination balance = %d, want 120000", got)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mfp/statements"
+	"mfp/storage"
+	"net/http"
+	"time"
+)
+
+// выписка по своему счёту за период:
+// ?from=2024-01-01&to=2024-01-31&format=csv|pdf|ofx|camt053|json&currency=USD;
+// без периода — текущий месяц
+func (s *Server) handleMyStatement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	accountID, err := s.ownAccount(userID, query.Get("currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, 1, 0)
+	if raw := query.Get("from"); raw != "" {
+		if from, _, err = parseDate(raw); err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if raw := query.Get("to"); raw != "" {
+		var dateOnly bool
+		if to, dateOnly, err = parseDate(raw); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	st, err := statements.Generate(s.store, accountID, from, to)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to generate statement: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" || format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+		return
+	}
+
+	f, err := statements.Lookup(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s-%s-%s.%s"`,
+		st.AccountID, st.From.Format("20060102"), st.LastDay().Format("20060102"), f.Extension))
+	if err := f.Write(w, st); err != nil {
+		http.Error(w, "Failed to write statement", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mfp/account"
+	"mfp/token"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ошибка в JSON для мобильных и серверных клиентов, которые не умеют
+// переходить на страницу входа
+func writeJSONError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// клиент API, а не браузер: пришёл с токеном или ждёт JSON
+func isAPIClient(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// ошибки клиентам API — в JSON, браузеру — текстом
+func errorWriterFor(r *http.Request) errorWriter {
+	if isAPIClient(r) {
+		return writeJSONError
+	}
+	return http.Error
+}
+
+// токен из заголовка Authorization: Bearer <token>
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, raw, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	raw = strings.TrimSpace(raw)
+	return raw, raw != ""
+}
+
+// отказ в доступе: браузер отправляется на страницу входа,
+// клиент API получает 401 в JSON
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	if !isAPIClient(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if _, ok := bearerToken(r); ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	writeJSONError(w, message, http.StatusUnauthorized)
+}
+
+// вход по телефону и паролю с выдачей пары токенов; при включённом
+// втором факторе код передаётся в том же запросе полем "code"
+func (s *Server) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Phone    string `json:"phone"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	acc, ok := s.checkCredentials(w, r, req.Phone, req.Password, writeJSONError)
+	if !ok {
+		return
+	}
+	if !s.checkTokenSecondFactor(w, r, acc, req.Code) {
+		return
+	}
+	s.passLogin(acc.ID, true)
+
+	pair, err := s.Tokens.Issue(acc.ID)
+	if err != nil {
+		writeJSONError(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+	writeTokenPair(w, pair)
+}
+
+// второй фактор при входе по токенам: без кода клиент получает 401
+// с нужным способом (для sms код отправляется), подключить второй
+// фактор можно только после входа через cookie
+func (s *Server) checkTokenSecondFactor(w http.ResponseWriter, r *http.Request, acc *account.Account, code string) bool {
+	tf, err := s.store.GetTwoFactor(acc.ID)
+	if err != nil && !errors.Is(err, account.ErrTwoFactorNotFound) {
+		writeJSONError(w, "Failed to check second factor", http.StatusInternalServerError)
+		return false
+	}
+	if tf == nil || !tf.Confirmed {
+		if s.TwoFactorRequired {
+			s.passLogin(acc.ID, false)
+			writeJSONError(w, "Second factor enrollment required", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	if code == "" {
+		s.passLogin(acc.ID, false)
+		method, err := s.secondFactor(acc)
+		if err != nil {
+			writeJSONError(w, "Failed to send code", http.StatusInternalServerError)
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":         "Second factor required",
+			"second_factor": method,
+		})
+		return false
+	}
+
+	err = s.store.UpdateTwoFactor(acc.ID, func(tf *account.TwoFactor) error {
+		_, err := tf.Verify(code, time.Now())
+		return err
+	})
+	if errors.Is(err, account.ErrInvalidCode) {
+		s.failLogin(r, acc.ID, "second_factor")
+		writeJSONError(w, "Invalid code", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		writeJSONError(w, "Failed to verify code", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// обмен токена обновления на новую пару. Прежний токен больше не
+// принимается, а его повторное предъявление отзывает всю цепочку
+func (s *Server) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeJSONError(w, "refresh_token required", http.StatusBadRequest)
+		return
+	}
+
+	pair, err := s.Tokens.Refresh(req.RefreshToken)
+	if errors.Is(err, token.ErrReused) {
+		log.Printf("Reused refresh token from %s, token family revoked", s.clientIP(r))
+	}
+	switch {
+	case errors.Is(err, token.ErrNotFound), errors.Is(err, token.ErrRevoked),
+		errors.Is(err, token.ErrReused), errors.Is(err, token.ErrExpired):
+		writeJSONError(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	case err != nil:
+		writeJSONError(w, "Failed to refresh tokens", http.StatusInternalServerError)
+		return
+	}
+	writeTokenPair(w, pair)
+}
+
+// отзыв токена обновления вместе с его цепочкой; неизвестный токен
+// не считается ошибкой, чтобы по ответу нельзя было проверять токены
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeJSONError(w, "refresh_token required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Tokens.Revoke(req.RefreshToken); err != nil && !errors.Is(err, token.ErrNotFound) {
+		writeJSONError(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Token revoked"})
+}
+
+// отзыв всех токенов обновления клиента, например после потери телефона
+func (s *Server) handleRevokeMyTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if s.Tokens == nil {
+		http.Error(w, "Token authentication is disabled", http.StatusNotFound)
+		return
+	}
+	if err := s.Tokens.RevokeUser(userID); err != nil {
+		http.Error(w, "Failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "All refresh tokens revoked"})
+}
+
+func writeTokenPair(w http.ResponseWriter, pair *token.Pair) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(pair)
+}
@@ -0,0 +1,415 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mfp/account"
+	"mfp/otp"
+	"mfp/storage"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// второй фактор, который нужен после пароля: totp, sms, enroll —
+// сначала подключить, или пусто, если вход завершается паролем
+func (s *Server) secondFactor(acc *account.Account) (string, error) {
+	tf, err := s.store.GetTwoFactor(acc.ID)
+	if err != nil && !errors.Is(err, account.ErrTwoFactorNotFound) {
+		return "", err
+	}
+	if tf == nil || !tf.Confirmed {
+		if s.TwoFactorRequired {
+			return "enroll", nil
+		}
+		return "", nil
+	}
+	if tf.Method == account.TwoFactorSMS {
+		if err := s.sendCode(acc, "login"); err != nil {
+			return "", err
+		}
+	}
+	return tf.Method, nil
+}
+
+// подтверждение входа кодом второго фактора или кодом восстановления
+func (s *Server) handleLoginVerify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		http.Error(w, "No login waiting for a second factor", http.StatusUnauthorized)
+		return
+	}
+	sess, err := s.SessionManager.GetSession(cookie.Value)
+	if err != nil || !sess.Pending {
+		http.Error(w, "No login waiting for a second factor", http.StatusUnauthorized)
+		return
+	}
+	acc, err := s.store.GetAccount(sess.UserID)
+	if err != nil || account.CheckLogin(acc.Status) != nil {
+		s.SessionManager.DeleteSession(sess.ID)
+		http.Error(w, "Login is not allowed", http.StatusForbidden)
+		return
+	}
+
+	// неверные коды считаются вместе с неверными паролями
+	if !s.beginLogin(w, r, acc.ID, http.Error) {
+		return
+	}
+	var recovery bool
+	left := 0
+	err = s.store.UpdateTwoFactor(acc.ID, func(tf *account.TwoFactor) error {
+		if !tf.Confirmed {
+			return account.ErrTwoFactorNotFound
+		}
+		var err error
+		recovery, err = tf.Verify(req.Code, time.Now())
+		left = len(tf.RecoveryCodes)
+		return err
+	})
+	if errors.Is(err, account.ErrInvalidCode) && s.failLogin(r, acc.ID, "second_factor") {
+		s.SessionManager.DeleteSession(sess.ID) // после блокировки вход начинается с пароля
+	}
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	response := map[string]any{"message": "Login successful", "user_id": acc.ID}
+	if recovery {
+		response["recovery_codes_left"] = left
+	}
+	s.completeLogin(w, r, sess.ID, acc.ID, response)
+}
+
+// замена незавершённой сессии полной: у сессии после второго фактора
+// новый идентификатор
+func (s *Server) completeLogin(w http.ResponseWriter, r *http.Request, pendingID, userID string, response map[string]any) {
+	s.SessionManager.DeleteSession(pendingID)
+	s.passLogin(userID, true)
+	sessionID, err := s.SessionManager.CreateSession(userID, r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	setSessionCookie(w, sessionID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// состояние второго фактора клиента
+func (s *Server) handleMyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tf, err := s.store.GetTwoFactor(userID)
+	if errors.Is(err, account.ErrTwoFactorNotFound) {
+		json.NewEncoder(w).Encode(map[string]any{"enabled": false, "required": s.TwoFactorRequired})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get two-factor settings", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"enabled":             tf.Confirmed,
+		"required":            s.TwoFactorRequired,
+		"method":              tf.Method,
+		"recovery_codes_left": len(tf.RecoveryCodes),
+	})
+}
+
+// подключение второго фактора; до подтверждения первым кодом он не
+// действует. для TOTP возвращаются секрет и URI для QR-кода приложения,
+// для SMS отправляется код. доступно и при входе, ожидающем подключения
+func (s *Server) handleEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Method string `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Method == "" {
+		req.Method = account.TwoFactorTOTP
+	}
+
+	acc, err := s.store.GetAccount(userID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	current, err := s.store.GetTwoFactor(userID)
+	if err != nil && !errors.Is(err, account.ErrTwoFactorNotFound) {
+		http.Error(w, "Failed to get two-factor settings", http.StatusInternalServerError)
+		return
+	}
+	// заменить подтверждённый фактор можно только отключив его кодом
+	if current != nil && current.Confirmed {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	tf, err := account.NewTwoFactor(req.Method, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.SetTwoFactor(userID, tf); err != nil {
+		http.Error(w, "Failed to save two-factor settings", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]string{"method": tf.Method}
+	switch tf.Method {
+	case account.TwoFactorTOTP:
+		response["secret"] = tf.Secret
+		response["provisioning_uri"] = otp.ProvisioningURI(tf.Secret, s.TOTPIssuer, acc.Phone)
+		response["message"] = "Scan the provisioning URI and confirm with a code from the app"
+	case account.TwoFactorSMS:
+		if err := s.sendCode(acc, "confirmation"); err != nil {
+			http.Error(w, "Failed to send code", http.StatusInternalServerError)
+			return
+		}
+		response["message"] = "Code sent, confirm it to enable two-factor authentication"
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// подтверждение подключения первым кодом; коды восстановления
+// показываются только в этом ответе. вход, ожидавший подключения,
+// на этом завершается
+func (s *Server) handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err := s.store.UpdateTwoFactor(userID, func(tf *account.TwoFactor) error {
+		var err error
+		codes, err = tf.Confirm(req.Code, time.Now())
+		return err
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	s.audit(r, "enable_two_factor", userID, "")
+
+	response := map[string]any{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	}
+	if pending, _ := r.Context().Value("pending").(bool); pending {
+		sessionID, _ := r.Context().Value("session_id").(string)
+		response["user_id"] = userID
+		s.completeLogin(w, r, sessionID, userID, response)
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// отправка одноразового кода клиенту со способом sms, например перед
+// отключением второго фактора
+func (s *Server) handleSendTwoFactorCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	acc, err := s.store.GetAccount(userID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	tf, err := s.store.GetTwoFactor(userID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	if tf.Method != account.TwoFactorSMS {
+		http.Error(w, "Codes are sent only for the sms method", http.StatusConflict)
+		return
+	}
+	if err := s.sendCode(acc, "confirmation"); err != nil {
+		http.Error(w, "Failed to send code", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Code sent"})
+}
+
+// новый набор кодов восстановления по текущему коду второго фактора
+func (s *Server) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var codes []string
+	err := s.store.UpdateTwoFactor(userID, func(tf *account.TwoFactor) error {
+		if !tf.Confirmed {
+			return account.ErrTwoFactorNotFound
+		}
+		if _, err := tf.Verify(req.Code, time.Now()); err != nil {
+			return err
+		}
+		codes = tf.NewRecoveryCodes()
+		return nil
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	s.audit(r, "regenerate_recovery_codes", userID, "")
+
+	json.NewEncoder(w).Encode(map[string]any{"recovery_codes": codes})
+}
+
+// отключение второго фактора по текущему коду; невозможно, если
+// второй фактор обязателен
+func (s *Server) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if s.TwoFactorRequired {
+		http.Error(w, "Two-factor authentication is required and cannot be disabled", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := s.store.UpdateTwoFactor(userID, func(tf *account.TwoFactor) error {
+		if !tf.Confirmed {
+			return nil
+		}
+		_, err := tf.Verify(req.Code, time.Now())
+		return err
+	})
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	if err := s.store.SetTwoFactor(userID, nil); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, "disable_two_factor", userID, "")
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// сброс второго фактора администратором, например при потере телефона
+// вместе с кодами восстановления; клиент подключает его заново
+func (s *Server) handleResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := chi.URLParam(r, "id")
+	if _, err := s.store.GetTwoFactor(id); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+	if err := s.store.SetTwoFactor(id, nil); err != nil {
+		http.Error(w, "Failed to reset two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, "reset_two_factor", id, "")
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication reset"})
+}
+
+// новый одноразовый код клиенту; код действует OTPCodeTTL,
+// прежний отправленный код перестаёт действовать
+func (s *Server) sendCode(acc *account.Account, purpose string) error {
+	var code string
+	err := s.store.UpdateTwoFactor(acc.ID, func(tf *account.TwoFactor) error {
+		code = tf.IssueCode(time.Now(), s.OTPCodeTTL)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Your %s code: %s. It expires in %s.", purpose, code, s.OTPCodeTTL)
+	if err := s.OTPSender.Send(acc.Phone, message); err != nil {
+		log.Printf("Failed to send code to %s: %v", acc.ID, err)
+		return err
+	}
+	return nil
+}
+
+// ошибки второго фактора: неверный код — 401, не подключён — 409
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, account.ErrInvalidCode):
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+	case errors.Is(err, account.ErrTwoFactorNotFound):
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+	case errors.Is(err, storage.ErrAccountNotFound):
+		http.Error(w, "Account not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
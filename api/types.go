@@ -1,6 +1,10 @@
 package api
 
-import "mfp/account"
+import (
+	"mfp/account"
+	"mfp/money"
+	"time"
+)
 
 // запрос на создание аккаунта
 type CreateAccountRequest struct {
@@ -8,26 +12,88 @@ type CreateAccountRequest struct {
 	Age       int    `json:"age"`
 	Phone     string `json:"phone"`
 	Password  string `json:"password"`
+	Currency  string `json:"currency"` // валюта основного счёта, по умолчанию KZT
+	PIN       string `json:"pin"`      // PIN первой карты; без него карта не выпускается
 }
 
 // ответ с информацией об аккаунте
 type AccountResponse struct {
-	ID        string  `json:"id"`
-	Name      string  `json:"name"`
-	Age       int     `json:"age"`
-	Phone     string  `json:"phone"`
-	Balance   float64 `json:"balance"`
-	CreatedAt string  `json:"created_at"`
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Age       int         `json:"age"`
+	Phone     string      `json:"phone"`
+	OwnerID   string      `json:"owner_id,omitempty"`
+	Role      string      `json:"role"`
+	Status    string      `json:"status"`
+	Balance   money.Money `json:"balance"`
+	Available money.Money `json:"available_balance"` // баланс без блокировок по картам
+	CreatedAt string      `json:"created_at"`
+
+	OverdraftLimit money.Money `json:"overdraft_limit"`
+	OverdraftRate  string      `json:"overdraft_rate,omitempty"`
+
+	Product         string `json:"product"`
+	InterestRate    string `json:"interest_rate,omitempty"`
+	MaturityDate    string `json:"maturity_date,omitempty"`
+	AccruedInterest string `json:"accrued_interest,omitempty"` // начислено, но ещё не зачислено
 }
 
 // преобразование аккаунта в ответ API
 func AccountToResponse(acc *account.Account) AccountResponse {
-	return AccountResponse{
+	resp := AccountResponse{
 		ID:        acc.ID,
 		Name:      acc.Name,
 		Age:       acc.Age,
 		Phone:     acc.Phone,
+		OwnerID:   acc.OwnerID,
+		Role:      acc.EffectiveRole(),
+		Status:    acc.EffectiveStatus(),
 		Balance:   acc.Balance,
+		Available: acc.Available(),
 		CreatedAt: acc.CreatedAt.Format("2006-01-02 15:04:05"),
+
+		OverdraftLimit: acc.Overdraft(),
+		OverdraftRate:  acc.OverdraftRate,
+
+		Product:      acc.EffectiveProduct(),
+		InterestRate: acc.InterestRate,
+	}
+	if acc.MaturityDate != nil {
+		resp.MaturityDate = acc.MaturityDate.Format("2006-01-02")
+	}
+	if acc.Accrual != nil {
+		resp.AccruedInterest = acc.Accrual.Interest
+	}
+	return resp
+}
+
+// ответ с информацией о карте; номер маскируется, кроме ответа на выпуск
+type CardResponse struct {
+	ID           string `json:"id"`
+	AccountID    string `json:"account_id"`
+	PAN          string `json:"pan"`
+	CVC2         string `json:"cvc2,omitempty"` // только при выпуске и перевыпуске
+	Expiry       string `json:"expiry"`         // MM/YY
+	Status       string `json:"status"`
+	StatusReason string `json:"status_reason,omitempty"`
+	ReplacedBy   string `json:"replaced_by,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// преобразование карты в ответ API; reveal показывает полный номер и CVC2
+func CardToResponse(c *account.Card, reveal bool) CardResponse {
+	resp := CardResponse{
+		ID:           c.ID,
+		AccountID:    c.AccountID,
+		PAN:          account.MaskPAN(c.PAN),
+		Expiry:       c.Expiry(),
+		Status:       c.EffectiveStatus(time.Now()),
+		StatusReason: c.StatusReason,
+		ReplacedBy:   c.ReplacedBy,
+		CreatedAt:    c.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if reveal {
+		resp.PAN, resp.CVC2 = c.PAN, c.CVC2
 	}
+	return resp
 }
@@ -0,0 +1,419 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"mfp/money"
+	"os"
+	"strings"
+	"time"
+)
+
+// префикс переменных окружения: server.addr -> BANK_SERVER_ADDR
+const envPrefix = "BANK_"
+
+// настройки HTTP-сервера
+type ServerConfig struct {
+	Addr string
+}
+
+// параметры подключения к PostgreSQL
+type DatabaseConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	AutoMigrate bool // применять миграции при запуске сервера
+}
+
+// выбор хранилища данных
+type StorageConfig struct {
+	Backend  string // postgres, memory или file
+	DataFile string
+}
+
+// ограничение частоты запросов с одного адреса
+type RateLimitConfig struct {
+	Requests          int
+	Window            time.Duration
+	TrustForwardedFor bool // сервер за прокси: адрес клиента берётся из X-Forwarded-For
+}
+
+// защита входа от перебора пароля
+type LoginConfig struct {
+	MaxFailures int           // неудач подряд до блокировки
+	BaseDelay   time.Duration // пауза после первой неудачи, дальше удваивается
+	MaxDelay    time.Duration
+	Lockout     time.Duration // первая блокировка, каждая следующая вдвое дольше
+}
+
+// параметры сессий
+type SessionConfig struct {
+	Store      string // пусто — хранилище выбранного storage.backend, memory — память процесса
+	TTL        time.Duration
+	MaxPerUser int
+}
+
+// курсы обмена валют
+type FXConfig struct {
+	RatesFile string        // JSON-файл с курсами, загружается при запуске
+	QuoteTTL  time.Duration // срок действия котировки
+}
+
+// тарифы комиссий
+type FeesConfig struct {
+	RulesFile string // JSON-файл с тарифами, загружается при запуске
+}
+
+// лимиты расходов
+type LimitsConfig struct {
+	CoolingPeriod time.Duration // повышение лимитов клиентом вступает в силу через этот срок
+}
+
+// подтверждение крупных переводов
+type TransfersConfig struct {
+	ConfirmThreshold string        // порог по умолчанию в валюте счёта; пусто — без подтверждения
+	ConfirmTTL       time.Duration // сколько перевод ждёт подтверждения
+}
+
+// приём платежей по картам от процессинга
+type CardsConfig struct {
+	ProcessingKey string        // ключ в заголовке X-Processing-Key; пустой выключает API авторизаций
+	HoldTTL       time.Duration // срок блокировки без списания
+	MaxFailures   int           // неверных сроков и CVC2 подряд до блокировки карты
+}
+
+// шифрование телефонов и кодов CVC2 в файле данных и PostgreSQL
+type EncryptionConfig struct {
+	MasterKeyFile string // мастер-ключ, шифрующий ключи данных; создаётся при первом запуске
+	KeyringFile   string // ключи данных по версиям, зашифрованные мастер-ключом
+}
+
+// второй фактор входа
+type TwoFactorConfig struct {
+	Required   bool          // вход без второго фактора невозможен: клиент без него сначала подключает его
+	Issuer     string        // название банка в приложении-аутентификаторе
+	Sender     string        // доставка одноразовых кодов: log или file
+	SenderFile string        // файл для sender = file
+	CodeTTL    time.Duration // срок одноразового кода и незавершённого входа
+}
+
+// вход по токенам для мобильных и серверных клиентов
+type TokensConfig struct {
+	SigningKeyFile string        // ключ подписи токенов доступа; создаётся при первом запуске
+	AccessTTL      time.Duration // срок токена доступа
+	RefreshTTL     time.Duration // срок токена обновления
+}
+
+// фоновые задания: запланированные переводы и ежедневные начисления
+type SchedulerConfig struct {
+	Enabled     bool          // запускать фоновые задания в этом экземпляре сервера
+	Interval    time.Duration // период проверки наступивших переводов
+	RetryDelay  time.Duration // пауза перед повтором неудавшегося перевода
+	MaxAttempts int           // попыток на один повтор перевода
+
+	DailyCheck time.Duration // период проверки ежедневных заданий, например процентов по овердрафту
+}
+
+// конфигурация приложения
+type Config struct {
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Storage    StorageConfig
+	RateLimit  RateLimitConfig
+	Login      LoginConfig
+	Session    SessionConfig
+	FX         FXConfig
+	Scheduler  SchedulerConfig
+	Limits     LimitsConfig
+	Transfers  TransfersConfig
+	Fees       FeesConfig
+	Cards      CardsConfig
+	Encryption EncryptionConfig
+	TwoFactor  TwoFactorConfig
+	Tokens     TokensConfig
+}
+
+// значения по умолчанию совпадают с прежними захардкоженными
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{Addr: ":8080"},
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Password: "password",
+			Name:     "mybank",
+			SSLMode:  "disable",
+		},
+		Storage:    StorageConfig{Backend: "postgres", DataFile: "accounts.json"},
+		RateLimit:  RateLimitConfig{Requests: 3, Window: 10 * time.Second},
+		Login:      LoginConfig{MaxFailures: 5, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Lockout: 15 * time.Minute},
+		Session:    SessionConfig{TTL: 15 * time.Minute, MaxPerUser: 3},
+		FX:         FXConfig{QuoteTTL: time.Minute},
+		Scheduler:  SchedulerConfig{Enabled: true, Interval: time.Minute, RetryDelay: time.Hour, MaxAttempts: 3, DailyCheck: time.Hour},
+		Limits:     LimitsConfig{CoolingPeriod: 24 * time.Hour},
+		Transfers:  TransfersConfig{ConfirmTTL: 10 * time.Minute},
+		Cards:      CardsConfig{HoldTTL: 7 * 24 * time.Hour, MaxFailures: 3},
+		Encryption: EncryptionConfig{MasterKeyFile: "master.key", KeyringFile: "keyring.json"},
+		TwoFactor:  TwoFactorConfig{Issuer: "MFP Bank", Sender: "log", SenderFile: "otp.log", CodeTTL: 5 * time.Minute},
+		Tokens:     TokensConfig{SigningKeyFile: "jwt.key", AccessTTL: 5 * time.Minute, RefreshTTL: 30 * 24 * time.Hour},
+	}
+}
+
+// описание одного параметра: ключ в файле, флаг и переменная окружения
+type field struct {
+	key   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{"server.addr", "HTTP listen address", (*stringValue)(&c.Server.Addr)},
+		{"database.host", "PostgreSQL host", (*stringValue)(&c.Database.Host)},
+		{"database.port", "PostgreSQL port", (*intValue)(&c.Database.Port)},
+		{"database.user", "PostgreSQL user", (*stringValue)(&c.Database.User)},
+		{"database.password", "PostgreSQL password", (*stringValue)(&c.Database.Password)},
+		{"database.name", "PostgreSQL database name", (*stringValue)(&c.Database.Name)},
+		{"database.sslmode", "PostgreSQL sslmode", (*stringValue)(&c.Database.SSLMode)},
+		{"database.auto_migrate", "apply pending migrations on server startup", (*boolValue)(&c.Database.AutoMigrate)},
+		{"storage.backend", "storage backend: postgres, memory or file", (*stringValue)(&c.Storage.Backend)},
+		{"storage.data_file", "data file for the file storage backend", (*stringValue)(&c.Storage.DataFile)},
+		{"rate_limit.requests", "requests allowed per IP within the window", (*intValue)(&c.RateLimit.Requests)},
+		{"rate_limit.window", "rate limit window, e.g. 10s", (*durationValue)(&c.RateLimit.Window)},
+		{"rate_limit.trust_forwarded_for", "take the client address from X-Forwarded-For; enable only behind a proxy that sets it", (*boolValue)(&c.RateLimit.TrustForwardedFor)},
+		{"login.max_failures", "consecutive failed logins before the account is locked", (*intValue)(&c.Login.MaxFailures)},
+		{"login.base_delay", "wait after the first failed login, doubled after each next one, e.g. 1s", (*durationValue)(&c.Login.BaseDelay)},
+		{"login.max_delay", "longest wait between failed logins, e.g. 30s", (*durationValue)(&c.Login.MaxDelay)},
+		{"login.lockout", "first lockout duration, doubled for each consecutive lockout up to 24h, e.g. 15m", (*durationValue)(&c.Login.Lockout)},
+		{"session.store", "session store: empty for the storage backend's own, postgres or memory", (*stringValue)(&c.Session.Store)},
+		{"session.ttl", "session idle timeout, e.g. 15m", (*durationValue)(&c.Session.TTL)},
+		{"session.max_per_user", "maximum concurrent sessions per user", (*intValue)(&c.Session.MaxPerUser)},
+		{"fx.rates_file", "JSON file with exchange rates loaded on startup", (*stringValue)(&c.FX.RatesFile)},
+		{"fx.quote_ttl", "how long an exchange quote can be executed, e.g. 1m", (*durationValue)(&c.FX.QuoteTTL)},
+		{"scheduler.enabled", "run scheduled transfers and daily jobs in this server instance", (*boolValue)(&c.Scheduler.Enabled)},
+		{"scheduler.interval", "how often due scheduled transfers are checked, e.g. 1m", (*durationValue)(&c.Scheduler.Interval)},
+		{"scheduler.retry_delay", "delay before retrying a failed scheduled transfer, e.g. 1h", (*durationValue)(&c.Scheduler.RetryDelay)},
+		{"scheduler.max_attempts", "attempts per scheduled transfer occurrence before giving up", (*intValue)(&c.Scheduler.MaxAttempts)},
+		{"scheduler.daily_check", "how often daily jobs such as overdraft interest check whether today is done, e.g. 1h", (*durationValue)(&c.Scheduler.DailyCheck)},
+		{"limits.cooling_period", "delay before a customer's limit increase takes effect without admin approval, e.g. 24h", (*durationValue)(&c.Limits.CoolingPeriod)},
+		{"transfers.confirm_threshold", "default amount in the account currency above which transfers need PIN or OTP confirmation; empty disables", (*stringValue)(&c.Transfers.ConfirmThreshold)},
+		{"transfers.confirm_ttl", "how long a transfer waits for confirmation before it expires", (*durationValue)(&c.Transfers.ConfirmTTL)},
+		{"fees.rules_file", "JSON file with fee rules loaded on startup", (*stringValue)(&c.Fees.RulesFile)},
+		{"cards.processing_key", "shared key the card processor sends in X-Processing-Key; empty disables card authorizations", (*stringValue)(&c.Cards.ProcessingKey)},
+		{"encryption.master_key_file", "file with the hex-encoded master key that wraps data keys; created on first start", (*stringValue)(&c.Encryption.MasterKeyFile)},
+		{"encryption.keyring_file", "file with versioned data keys wrapped by the master key", (*stringValue)(&c.Encryption.KeyringFile)},
+		{"cards.hold_ttl", "how long an authorized card payment stays on hold without capture, e.g. 168h", (*durationValue)(&c.Cards.HoldTTL)},
+		{"cards.max_failures", "consecutive invalid expiry or CVC2 attempts before the card is blocked", (*intValue)(&c.Cards.MaxFailures)},
+		{"two_factor.required", "require a second factor for every login; customers without one must enroll first", (*boolValue)(&c.TwoFactor.Required)},
+		{"two_factor.issuer", "issuer name shown in authenticator apps", (*stringValue)(&c.TwoFactor.Issuer)},
+		{"two_factor.sender", "one-time code delivery: log or file (stand-ins for an SMS gateway)", (*stringValue)(&c.TwoFactor.Sender)},
+		{"two_factor.sender_file", "file that receives one-time codes when sender is file", (*stringValue)(&c.TwoFactor.SenderFile)},
+		{"tokens.signing_key_file", "file with the hex-encoded key that signs access tokens; created on first start and shared by all server instances", (*stringValue)(&c.Tokens.SigningKeyFile)},
+		{"tokens.access_ttl", "access token lifetime, e.g. 5m", (*durationValue)(&c.Tokens.AccessTTL)},
+		{"tokens.refresh_ttl", "refresh token lifetime, e.g. 720h", (*durationValue)(&c.Tokens.RefreshTTL)},
+		{"two_factor.code_ttl", "lifetime of one-time codes and of a login waiting for the second factor", (*durationValue)(&c.TwoFactor.CodeTTL)},
+	}
+}
+
+// загрузка конфигурации; приоритет: флаги > окружение > файл > значения по умолчанию.
+// путь к файлу задаётся флагом -config или переменной BANK_CONFIG.
+// возвращает также аргументы после флагов (подкоманду)
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	// флаги разбираются в отдельную копию, чтобы применить их последними
+	flagged := Default()
+	fs := flag.NewFlagSet("bank", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to TOML config file")
+	for _, f := range flagged.fields() {
+		fs.Var(f.value, f.key, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	fields := make(map[string]flag.Value)
+	for _, f := range cfg.fields() {
+		fields[f.key] = f.value
+	}
+
+	if *configPath != "" {
+		values, err := parseFile(*configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, raw := range values {
+			value, known := fields[key]
+			if !known {
+				return nil, nil, fmt.Errorf("%s: unknown key %q", *configPath, key)
+			}
+			if err := value.Set(raw); err != nil {
+				return nil, nil, fmt.Errorf("%s: invalid %s: %v", *configPath, key, err)
+			}
+		}
+	}
+
+	for _, f := range cfg.fields() {
+		name := envName(f.key)
+		if raw, ok := os.LookupEnv(name); ok {
+			if err := f.value.Set(raw); err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: %v", name, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		if value, known := fields[fl.Name]; known && flagErr == nil {
+			flagErr = value.Set(fl.Value.String())
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// проверка значений конфигурации
+func (c *Config) Validate() error {
+	if c.Server.Addr == "" {
+		return fmt.Errorf("server.addr is required")
+	}
+
+	switch c.Storage.Backend {
+	case "postgres":
+		if c.Database.Host == "" || c.Database.User == "" || c.Database.Name == "" {
+			return fmt.Errorf("database.host, database.user and database.name are required")
+		}
+		if c.Database.Port < 1 || c.Database.Port > 65535 {
+			return fmt.Errorf("database.port must be between 1 and 65535")
+		}
+		switch c.Database.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			return fmt.Errorf("database.sslmode %q is not supported", c.Database.SSLMode)
+		}
+	case "memory":
+	case "file":
+		if c.Storage.DataFile == "" {
+			return fmt.Errorf("storage.data_file is required for the file backend")
+		}
+	default:
+		return fmt.Errorf("storage.backend must be postgres, memory or file, got %q", c.Storage.Backend)
+	}
+
+	if c.RateLimit.Requests < 1 {
+		return fmt.Errorf("rate_limit.requests must be at least 1")
+	}
+	if c.RateLimit.Window <= 0 {
+		return fmt.Errorf("rate_limit.window must be positive")
+	}
+	if c.Login.MaxFailures < 1 {
+		return fmt.Errorf("login.max_failures must be at least 1")
+	}
+	if c.Login.BaseDelay < 0 || c.Login.MaxDelay < c.Login.BaseDelay {
+		return fmt.Errorf("login.base_delay must not be negative or exceed login.max_delay")
+	}
+	if c.Login.Lockout <= 0 {
+		return fmt.Errorf("login.lockout must be positive")
+	}
+	switch c.Session.Store {
+	case "", "memory":
+	case "postgres":
+		if c.Storage.Backend != "postgres" {
+			return fmt.Errorf("session.store postgres requires storage.backend postgres")
+		}
+	default:
+		return fmt.Errorf("session.store must be postgres or memory, got %q", c.Session.Store)
+	}
+	if c.Session.TTL <= 0 {
+		return fmt.Errorf("session.ttl must be positive")
+	}
+	if c.Session.MaxPerUser < 1 {
+		return fmt.Errorf("session.max_per_user must be at least 1")
+	}
+	if c.FX.QuoteTTL <= 0 {
+		return fmt.Errorf("fx.quote_ttl must be positive")
+	}
+	if c.Scheduler.Interval <= 0 {
+		return fmt.Errorf("scheduler.interval must be positive")
+	}
+	if c.Scheduler.RetryDelay <= 0 {
+		return fmt.Errorf("scheduler.retry_delay must be positive")
+	}
+	if c.Scheduler.MaxAttempts < 1 {
+		return fmt.Errorf("scheduler.max_attempts must be at least 1")
+	}
+	if c.Scheduler.DailyCheck <= 0 {
+		return fmt.Errorf("scheduler.daily_check must be positive")
+	}
+	if c.Limits.CoolingPeriod < 0 {
+		return fmt.Errorf("limits.cooling_period must not be negative")
+	}
+	if c.Transfers.ConfirmThreshold != "" {
+		threshold, err := money.Parse(c.Transfers.ConfirmThreshold, money.DefaultCurrency)
+		if err != nil || threshold.IsNegative() {
+			return fmt.Errorf("transfers.confirm_threshold must be a non-negative amount, got %q", c.Transfers.ConfirmThreshold)
+		}
+	}
+	if c.Transfers.ConfirmTTL <= 0 {
+		return fmt.Errorf("transfers.confirm_ttl must be positive")
+	}
+	if c.Encryption.MasterKeyFile == "" || c.Encryption.KeyringFile == "" {
+		return fmt.Errorf("encryption.master_key_file and encryption.keyring_file are required")
+	}
+	if c.Cards.HoldTTL <= 0 {
+		return fmt.Errorf("cards.hold_ttl must be positive")
+	}
+	if c.Cards.MaxFailures < 1 {
+		return fmt.Errorf("cards.max_failures must be at least 1")
+	}
+	if c.TwoFactor.Sender != "log" && c.TwoFactor.Sender != "file" {
+		return fmt.Errorf("two_factor.sender must be log or file, got %q", c.TwoFactor.Sender)
+	}
+	if c.TwoFactor.Sender == "file" && c.TwoFactor.SenderFile == "" {
+		return fmt.Errorf("two_factor.sender_file is required for the file sender")
+	}
+	if c.TwoFactor.CodeTTL <= 0 {
+		return fmt.Errorf("two_factor.code_ttl must be positive")
+	}
+	if c.Tokens.SigningKeyFile == "" && c.Storage.Backend != "memory" {
+		return fmt.Errorf("tokens.signing_key_file is required")
+	}
+	if c.Tokens.AccessTTL <= 0 || c.Tokens.RefreshTTL <= 0 {
+		return fmt.Errorf("tokens.access_ttl and tokens.refresh_ttl must be positive")
+	}
+	if c.Tokens.RefreshTTL < c.Tokens.AccessTTL {
+		return fmt.Errorf("tokens.refresh_ttl must not be shorter than tokens.access_ttl")
+	}
+	return nil
+}
+
+// строка подключения к PostgreSQL в формате key=value
+func (d DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(d.Host), d.Port, quoteDSN(d.User), quoteDSN(d.Password), quoteDSN(d.Name), quoteDSN(d.SSLMode))
+}
+
+// экранирование значения для строки подключения libpq
+func quoteDSN(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// разбор подмножества TOML: секции [name], пары key = value,
+// строки в кавычках, числа и комментарии #.
+// результат — плоская карта вида "section.key" -> значение
+func parseFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config: %v", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || strings.TrimSpace(stripComment(line[end+1:])) != "" {
+				return nil, fmt.Errorf("%s:%d: invalid section header", path, lineNo)
+			}
+			section = strings.TrimSpace(line[1:end])
+			if section == "" {
+				return nil, fmt.Errorf("%s:%d: empty section name", path, lineNo)
+			}
+			continue
+		}
+
+		key, raw, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, duplicate := values[key]; duplicate {
+			return nil, fmt.Errorf("%s:%d: duplicate key %q", path, lineNo, key)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	return values, nil
+}
+
+func parseValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, `"`) {
+		quoted, err := strconv.QuotedPrefix(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid string value")
+		}
+		if strings.TrimSpace(stripComment(raw[len(quoted):])) != "" {
+			return "", fmt.Errorf("unexpected text after string value")
+		}
+		return strconv.Unquote(quoted)
+	}
+
+	value := strings.TrimSpace(stripComment(raw))
+	if value == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return value, nil
+}
+
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -2,289 +2,535 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"mfp/account"
+	"mfp/fees"
+	"mfp/ledger"
+	"mfp/money"
+	"mfp/storage"
+	"sort"
+	"strings"
 	"time"
 )
 
+// колонки accounts в порядке, который ожидает scanAccount
+const accountColumns = `id, password, balance, held, currency, owner_id, name, phone, phone_enc, key_version, age, role,
+	status, status_reason, status_changed_at, created_at, expired_at, overdraft_limit, overdraft_rate,
+	` + limitColumns + `, ` + interestColumns + `, confirm_threshold, overdraft_through`
+
+// телефон записывается только зашифрованным, открытое поле phone остаётся
+// для строк, созданных до шифрования
 func (r *Repository) CreateAccount(acc *account.Account) error {
-	query := `INSERT INTO accounts (id, password, cvc2, balance, name, phone, age, created_at, expired_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	return r.runInTx(func(tx *sql.Tx) error {
+		return r.insertAccount(tx, acc)
+	})
+}
+
+func (r *Repository) CreateAccountWithCard(acc *account.Account, c *account.Card) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		if err := r.insertAccount(tx, acc); err != nil {
+			return err
+		}
+		if c == nil {
+			return nil
+		}
+		if err := account.CheckCardIssue(acc.Status, nil, time.Now()); err != nil {
+			return err
+		}
+		return insertCard(tx, r.keys, c)
+	})
+}
+
+// уникальный индекс по phone_index не видит строки, которые ещё не
+// перешифрованы, поэтому телефон проверяется по обоим полям
+func (r *Repository) insertAccount(tx *sql.Tx, acc *account.Account) error {
+	phone, version, err := r.keys.Encrypt(acc.Phone, acc.ID)
+	if err != nil {
+		return err
+	}
+	index := r.keys.BlindIndex(acc.Phone)
 
-	_, err := r.db.Exec(query, acc.ID, acc.Password, acc.CVC2, acc.Balance, acc.Name, acc.Phone, acc.Age, acc.CreatedAt, acc.ExpiredAt)
+	if acc.OwnerID == "" {
+		var exists bool
+		err := tx.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM accounts WHERE (phone_index = $1 OR phone = $2) AND owner_id IS NULL)`,
+			index, acc.Phone,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check phone: %v", err)
+		}
+		if exists {
+			return fmt.Errorf("account with phone %s number already exists", acc.Phone)
+		}
+	}
+
+	query := `INSERT INTO accounts (` + accountColumns + `, phone_index) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24,
+		$25, $26, $27, $28, $29, $30, $31, $32, $33, $34)`
+
+	_, err = tx.Exec(query, acc.ID, acc.Password, acc.Balance, acc.Held, acc.Currency(), nullString(acc.OwnerID),
+		acc.Name, nil, phone, version, acc.Age, acc.EffectiveRole(),
+		acc.EffectiveStatus(), acc.StatusReason, acc.CreatedAt, acc.CreatedAt, acc.ExpiredAt,
+		acc.Overdraft(), numericOrZero(acc.OverdraftRate),
+		acc.Limits.PerTransaction, acc.Limits.Daily, acc.Limits.Monthly, nil, nil, nil, nil,
+		acc.EffectiveProduct(), numericOrZero(acc.InterestRate), dateOrNull(acc.MaturityDate), "0", nil,
+		confirmThreshold(acc.ConfirmThreshold), dateOrNull(acc.OverdraftThrough), index)
 	return err
 }
 
 func (r *Repository) GetAccount(id string) (*account.Account, error) {
-	query := `SELECT id, password, cvc2, balance, name, phone, age, created_at, expired_at FROM accounts WHERE id = $1`
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1`
 
 	row := r.db.QueryRow(query, id)
-	return scanAccount(row)
+	return r.scanAccount(row)
 }
 
+// поиск по слепому индексу, а у ещё не перешифрованных строк — по открытому телефону
 func (r *Repository) GetAccountByPhone(phone string) (*account.Account, error) {
 	query := `
-		SELECT id, password, cvc2, balance, name, phone, age, created_at, expired_at
-		FROM accounts WHERE phone = $1`
+		SELECT ` + accountColumns + `
+		FROM accounts WHERE (phone_index = $1 OR phone = $2) AND owner_id IS NULL`
 
-	row := r.db.QueryRow(query, phone)
-	return scanAccount(row)
+	row := r.db.QueryRow(query, r.keys.BlindIndex(phone), phone)
+	return r.scanAccount(row)
+}
+
+func (r *Repository) GetOwnedAccounts(ownerID string) ([]*account.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1 OR owner_id = $1 ORDER BY created_at`
+
+	return r.queryAccounts(query, ownerID)
 }
 
 func (r *Repository) GetAccounts() ([]*account.Account, error) {
-	query := `SELECT id, password, cvc2, balance, name, phone, age, created_at, expired_at FROM accounts`
+	query := `SELECT ` + accountColumns + ` FROM accounts ORDER BY created_at`
 
-	rows, err := r.db.Query(query)
+	return r.queryAccounts(query)
+}
+
+// поиск по части ID или имени; зашифрованный телефон ищется только
+// целиком, по части — лишь у ещё не перешифрованных строк
+func (r *Repository) SearchAccounts(search string) ([]*account.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE id LIKE $1 OR phone LIKE $1 OR name ILIKE $1 OR phone_index = $2
+		ORDER BY created_at`
+
+	return r.queryAccounts(query, "%"+escapeLike(search)+"%", r.keys.BlindIndex(search))
+}
+
+func (r *Repository) SetRole(accountID, role string) error {
+	if err := account.ValidateRole(role); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(`UPDATE accounts SET role = $1 WHERE id = $2`, role, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return storage.ErrAccountNotFound
+	}
+	return nil
+}
+
+func (r *Repository) queryAccounts(query string, args ...any) ([]*account.Account, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var accounts []*account.Account
+	accounts := []*account.Account{}
 	for rows.Next() {
-		acc, err := scanAccountFromRows(rows)
+		acc, err := r.scanAccount(rows)
 		if err != nil {
 			return nil, err
 		}
 		accounts = append(accounts, acc)
 	}
-	return accounts, nil
+	return accounts, rows.Err()
 }
 
-func (r *Repository) Deposit(accountID string, amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("deposit amount must be positive")
+func (r *Repository) Deposit(accountID string, amount money.Money, idem *storage.Idempotency) (*storage.StoredResponse, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("deposit amount must be positive")
 	}
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	var stored *storage.StoredResponse
+	err := r.runInTx(func(tx *sql.Tx) error {
+		var err error
+		if stored, err = claimIdempotencyKey(tx, accountID, idem); err != nil || stored != nil {
+			return err
+		}
 
-	query := `UPDATE accounts SET balance = balance + $1 WHERE id = $2`
-	result, err := tx.Exec(query, amount, accountID)
-	if err != nil {
-		return fmt.Errorf("deposit failed: %v", err)
-	}
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := account.CheckCredit(acc.Status); err != nil {
+			return err
+		}
+		if err := acc.checkCurrency(amount); err != nil {
+			return err
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		return fmt.Errorf("account not found")
-	}
+		if err := ledger.Post(tx, ledger.Deposit(accountID, amount)); err != nil {
+			return fmt.Errorf("deposit failed: %w", err)
+		}
 
-	_, err = tx.Exec(`
-        INSERT INTO transactions (type, from_account, to_account, amount, timestamp, status, account_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		"deposit", "", accountID, amount, time.Now(), "completed", accountID,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to record transaction: %v", err)
+		return storeIdempotentResponse(tx, accountID, idem)
+	})
+	return stored, err
+}
+
+func (r *Repository) Withdraw(accountID string, amount money.Money, idem *storage.Idempotency) (*storage.StoredResponse, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("withdraw amount must be positive")
 	}
 
-	return tx.Commit()
+	var stored *storage.StoredResponse
+	err := r.runInTx(func(tx *sql.Tx) error {
+		var err error
+		if stored, err = claimIdempotencyKey(tx, accountID, idem); err != nil || stored != nil {
+			return err
+		}
+
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := account.CheckDebit(acc.Status); err != nil {
+			return err
+		}
+		if err := account.CheckTerm(acc.Product, acc.MaturityDate, time.Now()); err != nil {
+			return err
+		}
+		if err := acc.checkCurrency(amount); err != nil {
+			return err
+		}
+
+		fee, err := operationFee(tx, fees.OpWithdrawal, acc.ID, acc.ClientID, acc.Product, "", amount)
+		if err != nil {
+			return err
+		}
+		if err := acc.checkFunds(amount, fee); err != nil {
+			return err
+		}
+		if err := acc.checkSpending(tx, amount); err != nil {
+			return err
+		}
+
+		if err := ledger.Post(tx, ledger.Withdrawal(accountID, amount)); err != nil {
+			return fmt.Errorf("withdraw failed: %w", err)
+		}
+		if err := postFee(tx, accountID, fee); err != nil {
+			return err
+		}
+
+		return storeIdempotentResponse(tx, accountID, idem)
+	})
+	return stored, err
 }
 
-func (r *Repository) Withdraw(accountID string, amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("withdraw amount must be positive")
+func (r *Repository) Transfer(fromAccount, toAccount string, amount money.Money, quoteID string, idem *storage.Idempotency) (*storage.StoredResponse, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("transfer amount must be positive")
 	}
 
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+	if fromAccount == toAccount {
+		return nil, fmt.Errorf("cannot transfer to the same account")
 	}
-	defer tx.Rollback()
 
-	var currentBalance float64
-	err = tx.QueryRow("SELECT balance FROM accounts WHERE id = $1", accountID).Scan(&currentBalance)
-	if err != nil {
-		return fmt.Errorf("account not found: %v", err)
-	}
+	var stored *storage.StoredResponse
+	err := r.runInTx(func(tx *sql.Tx) error {
+		var err error
+		if stored, err = claimIdempotencyKey(tx, fromAccount, idem); err != nil || stored != nil {
+			return err
+		}
 
-	if currentBalance < amount {
-		return fmt.Errorf("insufficient funds: have %.2f, need %.2f", currentBalance, amount)
-	}
+		locked, err := lockAccountsInOrder(tx, fromAccount, toAccount)
+		if err != nil {
+			return err
+		}
 
-	query := `UPDATE accounts SET balance = balance - $1 WHERE id = $2`
-	result, err := tx.Exec(query, amount, accountID)
-	if err != nil {
-		return fmt.Errorf("withdraw failed: %v", err)
-	}
+		from, ok := locked[fromAccount]
+		if !ok {
+			return fmt.Errorf("sender account not found")
+		}
+		to, ok := locked[toAccount]
+		if !ok {
+			return fmt.Errorf("receiver account not found")
+		}
+		if err := account.CheckDebit(from.Status); err != nil {
+			return fmt.Errorf("sender %v", err)
+		}
+		if err := account.CheckTerm(from.Product, from.MaturityDate, time.Now()); err != nil {
+			return err
+		}
+		if err := account.CheckCredit(to.Status); err != nil {
+			return fmt.Errorf("receiver %v", err)
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
-		return fmt.Errorf("account not found")
-	}
+		if err := from.checkCurrency(amount); err != nil {
+			return err
+		}
 
-	_, err = tx.Exec(`
-        INSERT INTO transactions (type, from_account, to_account, amount, timestamp, status, account_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		"withdraw", accountID, "", amount, time.Now(), "completed", accountID,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to record transaction: %v", err)
-	}
+		fee, err := operationFee(tx, fees.OpTransfer, from.ID, from.ClientID, from.Product, to.ClientID, amount)
+		if err != nil {
+			return err
+		}
+		if err := from.checkFunds(amount, fee); err != nil {
+			return err
+		}
+		if err := from.checkSpending(tx, amount); err != nil {
+			return err
+		}
 
-	return tx.Commit()
+		entry, err := r.transferEntry(tx, from, to, amount, quoteID)
+		if err != nil {
+			return err
+		}
+		if err := ledger.Post(tx, entry); err != nil {
+			return fmt.Errorf("transfer failed: %w", err)
+		}
+		if err := postFee(tx, fromAccount, fee); err != nil {
+			return err
+		}
+
+		return storeIdempotentResponse(tx, fromAccount, idem)
+	})
+	return stored, err
 }
 
-func (r *Repository) Transfer(fromAccount, toAccount string, amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("transfer amount must be positive")
-	}
+// состояние счёта, заблокированного до конца транзакции
+type lockedAccount struct {
+	ID             string
+	ClientID       string // основной аккаунт клиента
+	Balance        money.Money
+	Held           money.Money // заблокировано по картам
+	Status         string
+	OverdraftLimit money.Money
+	OverdraftRate  string
+	Limits         account.SpendingLimits
+	PendingLimits  *account.PendingLimits
+	Product        string
+	InterestRate   string
+	MaturityDate   *time.Time
+	Accrual        *account.Accrual
+
+	OverdraftThrough *time.Time // последний день, за который начислены проценты за овердрафт
+}
 
-	if fromAccount == toAccount {
-		return fmt.Errorf("cannot transfer to the same account")
+// блокировка строки счёта до конца транзакции и чтение баланса и статуса
+func lockAccount(tx *sql.Tx, accountID string) (*lockedAccount, error) {
+	acc := &lockedAccount{ID: accountID}
+	var (
+		limits           limitsRow
+		interest         interestRow
+		overdraftThrough sql.NullTime
+	)
+	dest := []any{&acc.ClientID, &acc.Balance, &acc.Held, &acc.Balance.Currency, &acc.Status, &acc.OverdraftLimit, &acc.OverdraftRate}
+	dest = append(append(dest, limits.dest()...), interest.dest()...)
+	dest = append(dest, &overdraftThrough)
+	err := tx.QueryRow(`
+        SELECT COALESCE(owner_id, id), balance, held, currency, status, overdraft_limit, overdraft_rate,
+               `+limitColumns+`, `+interestColumns+`, overdraft_through
+        FROM accounts WHERE id = $1 FOR UPDATE`, accountID).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrAccountNotFound
 	}
-
-	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to lock account: %w", err)
 	}
-	defer tx.Rollback()
-
-	var fromBalance float64
-	err = tx.QueryRow("SELECT balance FROM accounts WHERE id = $1", fromAccount).Scan(&fromBalance)
-	if err != nil {
-		return fmt.Errorf("sender account not found: %v", err)
+	acc.Held.Currency = acc.Balance.Currency
+	acc.OverdraftLimit.Currency = acc.Balance.Currency
+	acc.OverdraftRate = trimZeros(acc.OverdraftRate)
+	if acc.Limits, acc.PendingLimits, err = limits.parse(acc.Balance.Currency); err != nil {
+		return nil, err
 	}
+	acc.Product, acc.InterestRate, acc.MaturityDate, acc.Accrual = interest.parse()
+	acc.OverdraftThrough = nullDate(overdraftThrough)
+	return acc, nil
+}
 
-	if fromBalance < amount {
-		return fmt.Errorf("insufficient funds: have %.2f, need %.2f", fromBalance, amount)
+// доступного остатка с овердрафтом должно хватить на сумму вместе с комиссией
+func (acc *lockedAccount) checkFunds(amount, fee money.Money) error {
+	available := money.FromMinor(acc.Balance.Amount-acc.Held.Amount, acc.Balance.Currency)
+	if err := account.CheckFunds(available, acc.OverdraftLimit, amount); err != nil {
+		return err
 	}
-
-	var toAccountExists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM accounts WHERE id = $1)", toAccount).Scan(&toAccountExists)
-	if err != nil || !toAccountExists {
-		return fmt.Errorf("receiver account not found")
+	if fee.IsZero() {
+		return nil
 	}
-
-	queryDeduct := `UPDATE accounts SET balance = balance - $1 WHERE id = $2`
-	resultDeduct, err := tx.Exec(queryDeduct, amount, fromAccount)
+	total, err := amount.Add(fee)
 	if err != nil {
-		return fmt.Errorf("transfer deduction failed: %v", err)
+		return err
 	}
+	return account.CheckFunds(available, acc.OverdraftLimit, total)
+}
 
-	queryAdd := `UPDATE accounts SET balance = balance + $1 WHERE id = $2`
-	resultAdd, err := tx.Exec(queryAdd, amount, toAccount)
-	if err != nil {
-		return fmt.Errorf("transfer addition failed: %v", err)
+// сумма операции должна быть в валюте счёта
+func (acc *lockedAccount) checkCurrency(amount money.Money) error {
+	if amount.Currency != acc.Balance.Currency {
+		return fmt.Errorf("account %s is in %s, amount is in %s", acc.ID, acc.Balance.Currency, amount.Currency)
 	}
+	return nil
+}
 
-	if rows, _ := resultDeduct.RowsAffected(); rows == 0 {
-		return fmt.Errorf("sender account not found")
-	}
-	if rows, _ := resultAdd.RowsAffected(); rows == 0 {
-		return fmt.Errorf("receiver account not found")
+// блокировка нескольких счетов в порядке возрастания ID,
+// чтобы встречные переводы не приводили к взаимоблокировке;
+// отсутствующие счета в результат не попадают
+func lockAccountsInOrder(tx *sql.Tx, accountIDs ...string) (map[string]*lockedAccount, error) {
+	ids := append([]string(nil), accountIDs...)
+	sort.Strings(ids)
+
+	locked := make(map[string]*lockedAccount, len(ids))
+	for _, id := range ids {
+		if _, done := locked[id]; done {
+			continue
+		}
+		acc, err := lockAccount(tx, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrAccountNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		locked[id] = acc
 	}
+	return locked, nil
+}
 
-	_, err = tx.Exec(`
-        INSERT INTO transactions (type, from_account, to_account, amount, timestamp, status, account_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		"transfer", fromAccount, toAccount, amount, time.Now(), "completed", fromAccount,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to record sender transaction: %v", err)
-	}
+// смена статуса аккаунта; закрытие не удаляет строку и историю операций
+func (r *Repository) SetStatus(accountID, status, reason string) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := account.CheckStatusTransition(acc.Status, status, acc.Balance.IsZero()); err != nil {
+			return err
+		}
 
-	_, err = tx.Exec(`
-        INSERT INTO transactions (type, from_account, to_account, amount, timestamp, status, account_id)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		"transfer", fromAccount, toAccount, amount, time.Now(), "completed", toAccount,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to record receiver transaction: %v", err)
-	}
+		_, err = tx.Exec(`
+            UPDATE accounts SET status = $1, status_reason = $2, status_changed_at = $3
+            WHERE id = $4`,
+			status, reason, time.Now(), accountID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update status: %w", err)
+		}
 
-	return tx.Commit()
+		if account.CheckLogin(status) != nil {
+			if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = $1", accountID); err != nil {
+				return fmt.Errorf("failed to delete sessions: %w", err)
+			}
+			if err := revokeUserTokens(tx, accountID, time.Now()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (r *Repository) GetTransactions(accountID string) ([]*account.Transaction, error) {
-	query := `
-        SELECT id, type, from_account, to_account, amount, timestamp, status 
-        FROM transactions 
-        WHERE account_id = $1 
-        ORDER BY timestamp DESC`
+// общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	rows, err := r.db.Query(query, accountID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get transactions: %v", err)
+func (r *Repository) scanAccount(row rowScanner) (*account.Account, error) {
+	var (
+		acc        account.Account
+		ownerID    sql.NullString
+		phone      sql.NullString
+		phoneEnc   sql.NullString
+		keyVersion sql.NullInt64
+		limits     limitsRow
+		interest   interestRow
+		threshold  sql.NullString
+		through    sql.NullTime
+	)
+	dest := []any{
+		&acc.ID, &acc.Password, &acc.Balance, &acc.Held, &acc.Balance.Currency, &ownerID, &acc.Name,
+		&phone, &phoneEnc, &keyVersion, &acc.Age, &acc.Role,
+		&acc.Status, &acc.StatusReason, &acc.StatusChangedAt, &acc.CreatedAt, &acc.ExpiredAt,
+		&acc.OverdraftLimit, &acc.OverdraftRate,
+	}
+	dest = append(append(dest, limits.dest()...), interest.dest()...)
+	dest = append(dest, &threshold, &through)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
 	}
-	defer rows.Close()
-
-	var transactions []*account.Transaction
-	for rows.Next() {
-		tx, err := scanTransaction(rows)
-		if err != nil {
+	acc.OwnerID = ownerID.String
+	acc.Phone = phone.String
+	if phoneEnc.Valid {
+		var err error
+		if acc.Phone, err = r.keys.Decrypt(phoneEnc.String, int(keyVersion.Int64), acc.ID); err != nil {
 			return nil, err
 		}
-		transactions = append(transactions, tx)
 	}
+	acc.Held.Currency = acc.Balance.Currency
+	acc.OverdraftLimit.Currency = acc.Balance.Currency
+	acc.OverdraftRate = trimZeros(acc.OverdraftRate)
 
-	if err := rows.Err(); err != nil {
+	var err error
+	if acc.Limits, acc.PendingLimits, err = limits.parse(acc.Currency()); err != nil {
 		return nil, err
 	}
-
-	return transactions, nil
+	acc.Product, acc.InterestRate, acc.MaturityDate, acc.Accrual = interest.parse()
+	if threshold.Valid {
+		parsed, err := money.Parse(threshold.String, acc.Currency())
+		if err != nil {
+			return nil, fmt.Errorf("invalid confirmation threshold: %v", err)
+		}
+		acc.ConfirmThreshold = &parsed
+	}
+	acc.OverdraftThrough = nullDate(through)
+	return &acc, nil
 }
 
-func (r *Repository) DeleteAccount(accountID string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+// порог подтверждения; NULL — порог по умолчанию
+func confirmThreshold(threshold *money.Money) any {
+	if threshold == nil {
+		return nil
 	}
-	defer tx.Rollback()
+	return *threshold
+}
 
-	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = $1", accountID)
-	if err != nil {
-		return fmt.Errorf("failed to delete sessions: %v", err)
+// пустая строка записывается как NULL
+func nullString(s string) any {
+	if s == "" {
+		return nil
 	}
+	return s
+}
 
-	_, err = tx.Exec("DELETE FROM accounts WHERE id = $1", accountID)
-	if err != nil {
-		return fmt.Errorf("failed to delete account: %v", err)
+// ноль записывается как NULL
+func nullInt(n int) any {
+	if n == 0 {
+		return nil
 	}
-
-	return tx.Commit()
+	return n
 }
 
-func scanAccount(row *sql.Row) (*account.Account, error) {
-	var acc account.Account
-	err := row.Scan(
-		&acc.ID, &acc.Password, &acc.CVC2, &acc.Balance, &acc.Name,
-		&acc.Phone, &acc.Age, &acc.CreatedAt, &acc.ExpiredAt,
-	)
-	if err != nil {
-		return nil, err
+// пустая ставка записывается как ноль
+func numericOrZero(s string) string {
+	if s == "" {
+		return "0"
 	}
-	return &acc, nil
+	return s
 }
 
-func scanTransaction(rows *sql.Rows) (*account.Transaction, error) {
-	var tx account.Transaction
-	err := rows.Scan(
-		&tx.ID,
-		&tx.Type,
-		&tx.FromAccount,
-		&tx.ToAccount,
-		&tx.Amount,
-		&tx.Timestamp,
-		&tx.Status,
-	)
-	if err != nil {
-		return nil, err
+// NUMERIC читается с нулями до масштаба колонки: "24.5000" -> "24.5"
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
 	}
-	return &tx, nil
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
 }
 
-func scanAccountFromRows(rows *sql.Rows) (*account.Account, error) {
-	var acc account.Account
-	err := rows.Scan(
-		&acc.ID, &acc.Password, &acc.CVC2, &acc.Balance, &acc.Name,
-		&acc.Phone, &acc.Age, &acc.CreatedAt, &acc.ExpiredAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return &acc, nil
+// экранирование спецсимволов шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
 }
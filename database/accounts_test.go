@@ -0,0 +1,220 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"mfp/account"
+	"mfp/keyring"
+	"mfp/migrations"
+	"mfp/money"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// тесты с PostgreSQL запускаются, только если задана строка подключения
+// к отдельной тестовой базе: BANK_TEST_DATABASE_URL
+func testRepository(t *testing.T) *Repository {
+	t.Helper()
+	connStr := os.Getenv("BANK_TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("BANK_TEST_DATABASE_URL is not set")
+	}
+
+	db, err := Open(connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return NewRepository(db, keyring.NewEphemeral())
+}
+
+// параллельные переводы во встречных направлениях блокируют строки
+// в порядке ID и повторяются при конфликтах: ни один баланс не уходит
+// в минус, сумма сохраняется, журнал сходится с балансами
+func TestConcurrentTransfers(t *testing.T) {
+	const (
+		accounts  = 5
+		workers   = 20
+		transfers = 25
+		initial   = 100000 // 1000.00
+	)
+
+	r := testRepository(t)
+	run := time.Now().UnixNano() % 1e9
+	ids := make([]string, accounts)
+	for i := range ids {
+		acc := &account.Account{
+			ID:        fmt.Sprintf("KZTEST%09d%d", run, i),
+			Password:  "-",
+			Balance:   money.Zero(money.DefaultCurrency),
+			Name:      "Test",
+			Phone:     fmt.Sprintf("77%09d", (run*accounts+int64(i))%1e9),
+			Age:       30,
+			Role:      account.RoleCustomer,
+			Status:    account.StatusActive,
+			CreatedAt: time.Now(),
+			ExpiredAt: time.Now().AddDate(5, 0, 0),
+		}
+		if err := r.CreateAccount(acc); err != nil {
+			t.Fatalf("CreateAccount: %v", err)
+		}
+		if _, err := r.Deposit(acc.ID, money.FromMinor(initial, money.DefaultCurrency), nil); err != nil {
+			t.Fatalf("Deposit: %v", err)
+		}
+		ids[i] = acc.ID
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*transfers)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for range transfers {
+				from := rnd.Intn(accounts)
+				to := (from + 1 + rnd.Intn(accounts-1)) % accounts
+				amount := money.FromMinor(int64(1+rnd.Intn(50000)), money.DefaultCurrency)
+				_, err := r.Transfer(ids[from], ids[to], amount, "", nil)
+				if err != nil && !errors.Is(err, account.ErrInsufficientFunds) {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Transfer: %v", err)
+	}
+
+	var total int64
+	for _, id := range ids {
+		acc, err := r.GetAccount(id)
+		if err != nil {
+			t.Fatalf("GetAccount: %v", err)
+		}
+		if acc.Balance.IsNegative() {
+			t.Errorf("account %s balance is negative: %s", id, acc.Balance)
+		}
+		total += acc.Balance.Amount
+	}
+	if total != accounts*initial {
+		t.Errorf("total balance = %s, want %s", money.FromMinor(total, money.DefaultCurrency),
+			money.FromMinor(accounts*initial, money.DefaultCurrency))
+	}
+
+	report, err := r.VerifyLedger()
+	if err != nil {
+		t.Fatalf("VerifyLedger: %v", err)
+	}
+	for _, m := range report.Mismatches {
+		for _, id := range ids {
+			if m.AccountID == id {
+				t.Errorf("account %s balance %s does not match postings %s", id, m.Balance, m.Postings)
+			}
+		}
+	}
+}
+
+// телефон занят и у перешифрованной строки, и у строки, созданной до
+// шифрования, где phone_index ещё пуст
+func TestCreateAccountDuplicatePhone(t *testing.T) {
+	r := testRepository(t)
+	run := time.Now().UnixNano() % 1e8
+
+	newAccount := func(id, phone string) *account.Account {
+		return &account.Account{
+			ID:        id,
+			Password:  "-",
+			Balance:   money.Zero(money.DefaultCurrency),
+			Name:      "Test",
+			Phone:     phone,
+			Age:       30,
+			Role:      account.RoleCustomer,
+			Status:    account.StatusActive,
+			CreatedAt: time.Now(),
+			ExpiredAt: time.Now().AddDate(5, 0, 0),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		legacy bool
+	}{
+		{name: "encrypted", legacy: false},
+		{name: "not reencrypted", legacy: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			phone := fmt.Sprintf("77%09d", run*10+int64(i))
+			first := newAccount(fmt.Sprintf("KZDUP%010d%d", run, i), phone)
+			if err := r.CreateAccount(first); err != nil {
+				t.Fatalf("CreateAccount: %v", err)
+			}
+			if tt.legacy {
+				if _, err := r.db.Exec(`UPDATE accounts SET phone = $2, phone_enc = NULL, phone_index = NULL WHERE id = $1`,
+					first.ID, phone); err != nil {
+					t.Fatalf("failed to make legacy row: %v", err)
+				}
+			}
+
+			second := newAccount(first.ID+"X", phone)
+			if err := r.CreateAccount(second); err == nil {
+				t.Fatal("CreateAccount with a taken phone succeeded")
+			}
+			if _, err := r.GetAccount(second.ID); err == nil {
+				t.Error("duplicate account was stored")
+			}
+		})
+	}
+}
+
+// если первую карту записать не удалось, аккаунт тоже не сохраняется
+func TestCreateAccountWithCardRollback(t *testing.T) {
+	r := testRepository(t)
+	run := time.Now().UnixNano() % 1e8
+
+	newAccount := func(n int) *account.Account {
+		return &account.Account{
+			ID:        fmt.Sprintf("KZCARD%09d%d", run, n),
+			Password:  "-",
+			Balance:   money.Zero(money.DefaultCurrency),
+			Name:      "Test",
+			Phone:     fmt.Sprintf("78%08d%d", run, n),
+			Age:       30,
+			Role:      account.RoleCustomer,
+			Status:    account.StatusActive,
+			CreatedAt: time.Now(),
+			ExpiredAt: time.Now().AddDate(5, 0, 0),
+		}
+	}
+	newCard := func(acc *account.Account, pan string) *account.Card {
+		return &account.Card{ID: "card-" + acc.ID, AccountID: acc.ID, PAN: pan, CVC2: "123",
+			ExpiryMonth: 1, ExpiryYear: time.Now().Year() + 3, Status: account.CardActive,
+			CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	}
+
+	pan := fmt.Sprintf("40%014d", run)
+	first := newAccount(1)
+	if err := r.CreateAccountWithCard(first, newCard(first, pan)); err != nil {
+		t.Fatalf("CreateAccountWithCard: %v", err)
+	}
+	if cards, err := r.GetCards([]string{first.ID}); err != nil || len(cards) != 1 {
+		t.Fatalf("GetCards = %d cards, %v, want 1", len(cards), err)
+	}
+
+	second := newAccount(2)
+	if err := r.CreateAccountWithCard(second, newCard(second, pan)); err == nil {
+		t.Fatal("CreateAccountWithCard with a taken card number succeeded")
+	}
+	if _, err := r.GetAccount(second.ID); err == nil {
+		t.Error("account was stored without its card")
+	}
+}
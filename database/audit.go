@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"mfp/storage"
+	"time"
+)
+
+func (r *Repository) RecordAudit(event *storage.AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	err := r.db.QueryRow(`
+        INSERT INTO audit_log (actor_id, actor_role, action, target_id, details, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		event.ActorID, event.ActorRole, event.Action, event.TargetID, event.Details, event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %v", err)
+	}
+	return nil
+}
+
+// последние записи журнала аудита, новые сверху
+func (r *Repository) GetAuditLog(limit int) ([]*storage.AuditEvent, error) {
+	rows, err := r.db.Query(`
+        SELECT id, actor_id, actor_role, action, target_id, details, created_at
+        FROM audit_log
+        ORDER BY id DESC
+        LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %v", err)
+	}
+	defer rows.Close()
+
+	events := []*storage.AuditEvent{}
+	for rows.Next() {
+		var e storage.AuditEvent
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorRole, &e.Action, &e.TargetID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/keyring"
+	"mfp/ledger"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// колонки cards в порядке, который ожидает scanCard
+const cardColumns = `id, account_id, pan, cvc2, cvc2_mac, key_version, pin_hash, expiry_month, expiry_year,
+    status, status_reason, replaced_by, created_at, updated_at`
+
+// выпуск идёт под блокировкой строки счёта, поэтому параллельные
+// запросы не превысят число карт на счёте
+func (r *Repository) IssueCard(c *account.Card) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, c.AccountID)
+		if err != nil {
+			return err
+		}
+		cards, err := queryCards(tx, `SELECT `+cardColumns+` FROM cards WHERE account_id = $1`, c.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := account.CheckCardIssue(acc.Status, cards, time.Now()); err != nil {
+			return err
+		}
+		return insertCard(tx, r.keys, c)
+	})
+}
+
+func (r *Repository) GetCard(id string) (*account.Card, error) {
+	return getCard(r.db, `SELECT `+cardColumns+` FROM cards WHERE id = $1`, id)
+}
+
+func (r *Repository) GetCards(accountIDs []string) ([]*account.Card, error) {
+	return queryCards(r.db, `
+        SELECT `+cardColumns+` FROM cards
+        WHERE account_id = ANY($1)
+        ORDER BY created_at DESC`, pq.Array(accountIDs))
+}
+
+func (r *Repository) SetCardStatus(id, status, reason string) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		c, err := lockCard(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := c.SetStatus(status, reason, time.Now()); err != nil {
+			return err
+		}
+		if err := updateCard(tx, c); err != nil {
+			return err
+		}
+		if c.Status == account.CardActive {
+			return resetCardAttempts(tx, c.ID)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) SetCardPIN(id, pinHash string) error {
+	result, err := r.db.Exec(`UPDATE cards SET pin_hash = $2, updated_at = $3 WHERE id = $1`, id, pinHash, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update PIN: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return account.ErrCardNotFound
+	}
+	return nil
+}
+
+func (r *Repository) ReissueCard(id string) (*account.Card, error) {
+	var replacement *account.Card
+	err := r.runInTx(func(tx *sql.Tx) error {
+		c, err := lockCard(tx, id)
+		if err != nil {
+			return err
+		}
+		acc, err := lockAccount(tx, c.AccountID)
+		if err != nil {
+			return err
+		}
+		if acc.Status != account.StatusActive {
+			return fmt.Errorf("account is %s", acc.Status)
+		}
+
+		if replacement, err = c.Reissue(time.Now()); err != nil {
+			return err
+		}
+		if err := insertCard(tx, r.keys, replacement); err != nil {
+			return err
+		}
+		return updateCard(tx, c)
+	})
+	return replacement, err
+}
+
+func lockCard(tx *sql.Tx, id string) (*account.Card, error) {
+	return getCard(tx, `SELECT `+cardColumns+` FROM cards WHERE id = $1 FOR UPDATE`, id)
+}
+
+// открытый CVC2 не записывается, сохраняется только код проверки
+func insertCard(tx *sql.Tx, keys *keyring.Keyring, c *account.Card) error {
+	c.SealCVC2(keys)
+	_, err := tx.Exec(`
+        INSERT INTO cards (`+cardColumns+`)
+        VALUES ($1, $2, $3, NULL, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		c.ID, c.AccountID, c.PAN, c.CVC2MAC, c.KeyVersion, c.PINHash, c.ExpiryMonth, c.ExpiryYear,
+		c.Status, c.StatusReason, nullString(c.ReplacedBy), c.CreatedAt, c.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return fmt.Errorf("card number collision, try again")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create card: %v", err)
+	}
+	return nil
+}
+
+func updateCard(tx *sql.Tx, c *account.Card) error {
+	_, err := tx.Exec(`
+        UPDATE cards SET status = $2, status_reason = $3, replaced_by = $4, updated_at = $5
+        WHERE id = $1`, c.ID, c.Status, c.StatusReason, nullString(c.ReplacedBy), c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update card: %v", err)
+	}
+	return nil
+}
+
+// неверные реквизиты считаются в card_attempts под блокировкой строки
+// карты; после последней допустимой неудачи карта блокируется
+func failCardDetails(tx *sql.Tx, c *account.Card, p account.LoginPolicy, now time.Time) error {
+	attempts, err := scanLoginAttempts(tx.QueryRow(`SELECT `+loginAttemptColumns+` FROM card_attempts WHERE card_id = $1`, c.ID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	c.Attempts = attempts
+	blocked := c.FailDetails(p, now)
+	if c.Attempts == nil {
+		return nil
+	}
+
+	a := c.Attempts
+	_, err = tx.Exec(`
+        INSERT INTO card_attempts (card_id, `+loginAttemptColumns+`) VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (card_id) DO UPDATE SET failures = $2, lockouts = $3, last_failure_at = $4,
+            next_attempt_at = $5, locked_until = $6`,
+		c.ID, a.Failures, a.Lockouts, a.LastFailureAt, a.NextAttemptAt, a.LockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to save card attempts: %v", err)
+	}
+	if blocked {
+		return updateCard(tx, c)
+	}
+	return nil
+}
+
+// успешная авторизация и снятие блокировки сбрасывают счётчик
+func resetCardAttempts(tx *sql.Tx, cardID string) error {
+	if _, err := tx.Exec(`DELETE FROM card_attempts WHERE card_id = $1`, cardID); err != nil {
+		return fmt.Errorf("failed to reset card attempts: %v", err)
+	}
+	return nil
+}
+
+func getCard(q ledger.Querier, query string, args ...any) (*account.Card, error) {
+	c, err := scanCard(q.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, account.ErrCardNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card: %v", err)
+	}
+	return c, nil
+}
+
+func queryCards(q ledger.Querier, query string, args ...any) ([]*account.Card, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards: %v", err)
+	}
+	defer rows.Close()
+
+	cards := []*account.Card{}
+	for rows.Next() {
+		c, err := scanCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
+}
+
+func scanCard(row rowScanner) (*account.Card, error) {
+	var (
+		c          account.Card
+		cvc2       sql.NullString
+		mac        sql.NullString
+		keyVersion sql.NullInt64
+		replacedBy sql.NullString
+	)
+	err := row.Scan(&c.ID, &c.AccountID, &c.PAN, &cvc2, &mac, &keyVersion, &c.PINHash, &c.ExpiryMonth, &c.ExpiryYear,
+		&c.Status, &c.StatusReason, &replacedBy, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.CVC2, c.CVC2MAC, c.KeyVersion = cvc2.String, mac.String, int(keyVersion.Int64)
+	c.ReplacedBy = replacedBy.String
+	return &c, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// перешифрование пачкой в одной транзакции; строки, занятые другими
+// транзакциями, пропускаются и попадут в следующую пачку. CVC2 на прежнем
+// ключе перешифровать нельзя: открытого кода нет, такие карты остаются
+// на своей версии ключа до перевыпуска
+func (r *Repository) Reencrypt(limit int) (int, error) {
+	done := 0
+	err := r.runInTx(func(tx *sql.Tx) error {
+		current := r.keys.Current()
+
+		rows, err := tx.Query(`
+            SELECT id, phone, phone_enc, key_version FROM accounts
+            WHERE phone IS NOT NULL OR key_version IS DISTINCT FROM $1
+            ORDER BY id LIMIT $2
+            FOR UPDATE SKIP LOCKED`, current, limit)
+		if err != nil {
+			return fmt.Errorf("failed to select accounts: %w", err)
+		}
+		type stalePhone struct {
+			id, phone string
+		}
+		var phones []stalePhone
+		for rows.Next() {
+			var (
+				id         string
+				phone      sql.NullString
+				phoneEnc   sql.NullString
+				keyVersion sql.NullInt64
+			)
+			if err := rows.Scan(&id, &phone, &phoneEnc, &keyVersion); err != nil {
+				rows.Close()
+				return err
+			}
+			plain := phone.String
+			if !phone.Valid {
+				if plain, err = r.keys.Decrypt(phoneEnc.String, int(keyVersion.Int64), id); err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			phones = append(phones, stalePhone{id, plain})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, p := range phones {
+			sealed, version, err := r.keys.Encrypt(p.phone, p.id)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`
+                UPDATE accounts SET phone = NULL, phone_enc = $2, phone_index = $3, key_version = $4
+                WHERE id = $1`, p.id, sealed, r.keys.BlindIndex(p.phone), version)
+			if err != nil {
+				return fmt.Errorf("failed to update account %s: %w", p.id, err)
+			}
+		}
+		done += len(phones)
+
+		cards, err := queryCards(tx, `
+            SELECT `+cardColumns+` FROM cards
+            WHERE cvc2 IS NOT NULL
+            ORDER BY id LIMIT $1
+            FOR UPDATE SKIP LOCKED`, limit-done)
+		if err != nil {
+			return err
+		}
+		for _, c := range cards {
+			c.SealCVC2(r.keys)
+			_, err := tx.Exec(`UPDATE cards SET cvc2 = NULL, cvc2_mac = $2, key_version = $3 WHERE id = $1`,
+				c.ID, c.CVC2MAC, c.KeyVersion)
+			if err != nil {
+				return fmt.Errorf("failed to update card %s: %w", c.ID, err)
+			}
+		}
+		done += len(cards)
+
+		// секреты TOTP на прежнем ключе
+		rows, err = tx.Query(`
+            SELECT account_id FROM two_factor
+            WHERE secret_enc IS NOT NULL AND key_version IS DISTINCT FROM $1
+            ORDER BY account_id LIMIT $2
+            FOR UPDATE SKIP LOCKED`, current, limit-done)
+		if err != nil {
+			return fmt.Errorf("failed to select two-factor settings: %w", err)
+		}
+		var accountIDs []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			accountIDs = append(accountIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, id := range accountIDs {
+			tf, err := r.getTwoFactor(tx, id, "")
+			if err != nil {
+				return err
+			}
+			if err := r.saveTwoFactor(tx, id, tf, true); err != nil {
+				return err
+			}
+		}
+		done += len(accountIDs)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return done, nil
+}
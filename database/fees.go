@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mfp/fees"
+	"mfp/ledger"
+	"mfp/money"
+	"mfp/storage"
+	"time"
+)
+
+// замена всего набора тарифов в одной транзакции
+func (r *Repository) SetFeeRules(rules []fees.Rule) error {
+	if err := fees.ValidateRules(rules); err != nil {
+		return err
+	}
+
+	return r.runInTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM fee_rules`); err != nil {
+			return fmt.Errorf("failed to clear fee rules: %w", err)
+		}
+		now := time.Now()
+		for _, rule := range rules {
+			data, err := json.Marshal(rule)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`
+                INSERT INTO fee_rules (operation, currency, rule, updated_at)
+                VALUES ($1, $2, $3, $4)`,
+				rule.Operation, rule.Currency, data, now,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save fee rule %s/%s: %w", rule.Operation, rule.Currency, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) GetFeeRules() ([]fees.Rule, error) {
+	return queryFeeRules(r.db)
+}
+
+// оценка без блокировок: перед самой операцией комиссия считается заново
+func (r *Repository) EstimateFee(accountID, kind, toAccount string, amount money.Money) (money.Money, error) {
+	from, err := r.GetAccount(accountID)
+	if err == sql.ErrNoRows {
+		return money.Money{}, storage.ErrAccountNotFound
+	}
+	if err != nil {
+		return money.Money{}, err
+	}
+	if amount.Currency != from.Currency() {
+		return money.Money{}, fmt.Errorf("account is in %s, amount is in %s", from.Currency(), amount.Currency)
+	}
+
+	var toClient string
+	if kind == fees.OpTransfer {
+		to, err := r.GetAccount(toAccount)
+		if err != nil {
+			return money.Money{}, fmt.Errorf("destination account not found")
+		}
+		toClient = to.ClientID()
+	}
+	return operationFee(r.db, kind, from.ID, from.ClientID(), from.EffectiveProduct(), toClient, amount)
+}
+
+// комиссия за операцию со счёта fromID; toClient — клиент получателя
+// перевода, пустой для снятия. оборот за месяц читается, только если
+// тариф ступенчатый
+func operationFee(q ledger.Querier, kind, fromID, fromClient, product, toClient string, amount money.Money) (money.Money, error) {
+	rules, err := queryFeeRules(q)
+	if err != nil {
+		return money.Money{}, err
+	}
+	rule := fees.Find(rules, kind, amount.Currency)
+	if rule == nil {
+		return money.Zero(amount.Currency), nil
+	}
+
+	op := fees.Operation{Kind: kind, Amount: amount, Product: product, Internal: toClient == fromClient}
+	if rule.UsesVolume() {
+		if _, op.MonthlyVolume, err = spent(q, fromID, amount.Currency, time.Now()); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return rule.Fee(op)
+}
+
+// комиссия отдельной проводкой в той же транзакции, что и операция
+func postFee(tx *sql.Tx, accountID string, fee money.Money) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+	if err := ledger.Post(tx, ledger.Fee(accountID, fee)); err != nil {
+		return fmt.Errorf("fee failed: %w", err)
+	}
+	return nil
+}
+
+func queryFeeRules(q ledger.Querier) ([]fees.Rule, error) {
+	rows, err := q.Query(`SELECT rule FROM fee_rules ORDER BY operation, currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []fees.Rule{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var rule fees.Rule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return nil, fmt.Errorf("invalid fee rule: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/fx"
+	"mfp/ledger"
+	"mfp/money"
+	"time"
+)
+
+// добавление или замена курсов переданных пар
+func (r *Repository) SetRates(rates []fx.Rate) error {
+	for i := range rates {
+		if err := rates[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	return r.runInTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		for _, rate := range rates {
+			_, err := tx.Exec(`
+                INSERT INTO fx_rates (from_currency, to_currency, rate, updated_at)
+                VALUES ($1, $2, $3, $4)
+                ON CONFLICT (from_currency, to_currency)
+                DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at`,
+				rate.From, rate.To, rate.Rate, now,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save rate %s/%s: %w", rate.From, rate.To, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) GetRates() ([]fx.Rate, error) {
+	return queryRates(r.db)
+}
+
+func (r *Repository) CreateQuote(quote *fx.Quote) error {
+	_, err := r.db.Exec(`
+        INSERT INTO fx_quotes (id, account_id, sell_amount, sell_currency, buy_amount, buy_currency, rate, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		quote.ID, quote.AccountID, quote.Sell, quote.Sell.Currency, quote.Buy, quote.Buy.Currency,
+		quote.Rate, quote.CreatedAt, quote.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save quote: %v", err)
+	}
+
+	// истёкшие котировки больше не нужны
+	r.db.Exec(`DELETE FROM fx_quotes WHERE expires_at < $1 AND used_at IS NULL`, time.Now().Add(-24*time.Hour))
+	return nil
+}
+
+// проводка перевода: в одной валюте — простое перемещение,
+// в разных — обмен по котировке или по текущему курсу
+func (r *Repository) transferEntry(tx *sql.Tx, from, to *lockedAccount, amount money.Money, quoteID string) (*ledger.Entry, error) {
+	if from.Balance.Currency == to.Balance.Currency {
+		if quoteID != "" {
+			return nil, fmt.Errorf("quote is only used for transfers between currencies")
+		}
+		return ledger.Transfer(from.ID, to.ID, amount), nil
+	}
+
+	if quoteID == "" {
+		rates, err := queryRates(tx)
+		if err != nil {
+			return nil, err
+		}
+		rate, buy, err := fx.Exchange(rates, amount, to.Balance.Currency)
+		if err != nil {
+			return nil, err
+		}
+		return ledger.Exchange(from.ID, to.ID, amount, buy, rate, ""), nil
+	}
+
+	quote, err := lockQuote(tx, quoteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := quote.Check(from.ID, amount, to.Balance.Currency, time.Now()); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE fx_quotes SET used_at = $1 WHERE id = $2`, time.Now(), quote.ID); err != nil {
+		return nil, fmt.Errorf("failed to mark quote as used: %w", err)
+	}
+	return ledger.Exchange(from.ID, to.ID, quote.Sell, quote.Buy, quote.Rate, quote.ID), nil
+}
+
+// котировка, заблокированная до конца транзакции, чтобы исполнить её один раз
+func lockQuote(tx *sql.Tx, quoteID string) (*fx.Quote, error) {
+	var (
+		q      fx.Quote
+		usedAt sql.NullTime
+	)
+	err := tx.QueryRow(`
+        SELECT id, account_id, sell_amount, sell_currency, buy_amount, buy_currency, rate::TEXT,
+               created_at, expires_at, used_at
+        FROM fx_quotes WHERE id = $1 FOR UPDATE`, quoteID,
+	).Scan(&q.ID, &q.AccountID, &q.Sell, &q.Sell.Currency, &q.Buy, &q.Buy.Currency, &q.Rate,
+		&q.CreatedAt, &q.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, fx.ErrQuoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quote: %w", err)
+	}
+	q.Rate = fx.CanonicalRate(q.Rate)
+	if usedAt.Valid {
+		q.UsedAt = &usedAt.Time
+	}
+	return &q, nil
+}
+
+func queryRates(q ledger.Querier) ([]fx.Rate, error) {
+	rows, err := q.Query(`SELECT from_currency, to_currency, rate::TEXT, updated_at FROM fx_rates ORDER BY from_currency, to_currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rates: %v", err)
+	}
+	defer rows.Close()
+
+	rates := []fx.Rate{}
+	for rows.Next() {
+		var rate fx.Rate
+		if err := rows.Scan(&rate.From, &rate.To, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rate.Rate = fx.CanonicalRate(rate.Rate)
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/account"
+	"mfp/fx"
+	"mfp/money"
+	"mfp/storage"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// история операций счёта: одна строка на движение по счёту;
+// отправитель и получатель восстанавливаются по встречным движениям проводки,
+// валютная позиция банка при обмене в них не участвует. получатель оплаты
+// картой — продавец из блокировки. несписанные блокировки добавляются
+// строками с отрицательным ID и нулевой суммой со знаком, чтобы не
+// попадать в итоги
+const historyQuery = `
+    SELECT e.id,
+           CASE
+               WHEN e.type = 'transfer' AND p.amount < 0 THEN 'transfer_out'
+               WHEN e.type = 'transfer' THEN 'transfer_in'
+               WHEN e.type = 'withdraw' THEN 'withdrawal'
+               ELSE e.type
+           END AS type,
+           COALESCE(src.account_id, '') AS from_account,
+           COALESCE(ch.merchant, dst.account_id, '') AS to_account,
+           ABS(p.amount) AS amount,
+           p.amount AS signed_amount,
+           p.currency,
+           e.created_at,
+           e.status,
+           COALESCE(e.fx_rate::TEXT, '') AS rate,
+           CASE WHEN e.fx_rate IS NOT NULL AND p.amount < 0 THEN dst.amount END AS converted_amount,
+           CASE WHEN e.fx_rate IS NOT NULL AND p.amount < 0 THEN dst.currency END AS converted_currency,
+           CASE WHEN p.amount < 0 THEN COALESCE(ch.merchant, dst.account_id, '') ELSE COALESCE(src.account_id, '') END AS counterparty,
+           COALESCE(e.hold_id, 0) AS hold_id
+    FROM postings p
+    JOIN journal_entries e ON e.id = p.entry_id
+    LEFT JOIN card_holds ch ON ch.id = e.hold_id
+    LEFT JOIN LATERAL (
+        SELECT c.account_id FROM postings c
+        WHERE c.entry_id = e.id AND c.amount < 0 AND c.account_id <> 'system:fx'
+        ORDER BY c.id LIMIT 1
+    ) src ON TRUE
+    LEFT JOIN LATERAL (
+        SELECT c.account_id, c.amount, c.currency FROM postings c
+        WHERE c.entry_id = e.id AND c.amount > 0 AND c.account_id <> 'system:fx'
+        ORDER BY c.id LIMIT 1
+    ) dst ON TRUE
+    WHERE p.account_id = $1
+    UNION ALL
+    SELECT -h.id, 'card_payment', h.account_id, h.merchant, h.amount, 0, h.currency,
+           h.created_at, h.status, '', NULL, NULL, h.merchant, h.id
+    FROM card_holds h
+    WHERE h.account_id = $1 AND h.status <> 'captured'`
+
+const historyColumns = `id, type, from_account, to_account, amount, currency, created_at, status,
+    rate, converted_amount, converted_currency, hold_id`
+
+// порядок строк и условие «после курсора» для каждой сортировки
+var historyOrder = map[string]struct{ orderBy, after string }{
+	storage.SortDateDesc:   {"created_at DESC, id DESC", "(created_at, id) < (%s, %s)"},
+	storage.SortDateAsc:    {"created_at ASC, id ASC", "(created_at, id) > (%s, %s)"},
+	storage.SortAmountDesc: {"amount DESC, id DESC", "(amount, id) < (%s, %s)"},
+	storage.SortAmountAsc:  {"amount ASC, id ASC", "(amount, id) > (%s, %s)"},
+}
+
+func (r *Repository) QueryTransactions(accountID string, filter storage.TransactionFilter) (*storage.TransactionPage, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	var currency string
+	err := r.db.QueryRow(`SELECT currency FROM accounts WHERE id = $1`, accountID).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %v", err)
+	}
+
+	args := []any{accountID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conditions []string
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.To))
+	}
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "type = ANY("+arg(pq.Array(filter.Types))+")")
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "amount >= "+arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "amount <= "+arg(*filter.MaxAmount))
+	}
+	if filter.Counterparty != "" {
+		conditions = append(conditions, "counterparty = "+arg(filter.Counterparty))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &storage.TransactionPage{
+		Transactions: []*account.Transaction{},
+		Summary:      storage.TransactionSummary{In: money.Zero(currency), Out: money.Zero(currency)},
+	}
+
+	err = r.db.QueryRow(`
+        SELECT COUNT(*),
+               COALESCE(SUM(amount) FILTER (WHERE signed_amount > 0), 0),
+               COALESCE(SUM(amount) FILTER (WHERE signed_amount < 0), 0)
+        FROM (`+historyQuery+`) h`+where, args...,
+	).Scan(&page.Summary.Count, &page.Summary.In, &page.Summary.Out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize transactions: %v", err)
+	}
+
+	order := historyOrder[filter.Sort]
+	if filter.Cursor != "" {
+		cursor, _ := storage.DecodeCursor(filter.Cursor)
+		key := any(cursor.Time)
+		if strings.HasPrefix(filter.Sort, "amount") {
+			key = money.FromMinor(cursor.Amount, currency)
+		}
+		conditions = append(conditions, fmt.Sprintf(order.after, arg(key), arg(cursor.ID)))
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `SELECT ` + historyColumns + ` FROM (` + historyQuery + `) h` + where + ` ORDER BY ` + order.orderBy
+	if filter.Limit > 0 {
+		// лишняя строка показывает, что есть следующая страница
+		query += " LIMIT " + arg(filter.Limit+1)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Transactions = append(page.Transactions, tx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(page.Transactions) > filter.Limit {
+		page.Transactions = page.Transactions[:filter.Limit]
+		page.NextCursor = storage.NextCursor(page.Transactions[filter.Limit-1])
+	}
+	return page, nil
+}
+
+func scanTransaction(rows *sql.Rows) (*account.Transaction, error) {
+	var (
+		tx                account.Transaction
+		converted         sql.NullString
+		convertedCurrency sql.NullString
+	)
+	err := rows.Scan(
+		&tx.ID,
+		&tx.Type,
+		&tx.FromAccount,
+		&tx.ToAccount,
+		&tx.Amount,
+		&tx.Amount.Currency,
+		&tx.Timestamp,
+		&tx.Status,
+		&tx.Rate,
+		&converted,
+		&convertedCurrency,
+		&tx.HoldID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Rate != "" {
+		tx.Rate = fx.CanonicalRate(tx.Rate)
+	}
+	if converted.Valid {
+		amount, err := money.Parse(converted.String, convertedCurrency.String)
+		if err != nil {
+			return nil, err
+		}
+		tx.ConvertedAmount = &amount
+	}
+	return &tx, nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/money"
+	"time"
+)
+
+// колонки card_holds в порядке, который ожидает scanHold
+const holdColumns = `id, card_id, account_id, merchant, amount, captured, currency,
+    status, created_at, expires_at, updated_at`
+
+// авторизация идёт под блокировкой строки счёта, поэтому параллельные
+// авторизации не превысят доступный остаток и лимиты
+func (r *Repository) AuthorizeCard(a *account.Authorization) (*account.Hold, error) {
+	var hold *account.Hold
+	var declined error
+	err := r.runInTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		declined = nil
+		card, err := getCard(tx, `SELECT `+cardColumns+` FROM cards WHERE pan = $1 FOR UPDATE`, a.PAN)
+		if errors.Is(err, account.ErrCardNotFound) {
+			return fmt.Errorf("invalid card details")
+		}
+		if err != nil {
+			return err
+		}
+		if err := card.Verify(r.keys, a.ExpiryMonth, a.ExpiryYear, a.CVC2, now); err != nil {
+			if !errors.Is(err, account.ErrInvalidCardDetails) {
+				return err
+			}
+			// неудача должна сохраниться, поэтому транзакция не откатывается
+			declined = err
+			return failCardDetails(tx, card, a.Policy, now)
+		}
+
+		acc, err := lockAccount(tx, card.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := a.Validate(acc.Status, acc.Product, acc.MaturityDate, acc.Balance.Currency, now); err != nil {
+			return err
+		}
+		if err := acc.checkFunds(a.Amount, money.Zero(a.Amount.Currency)); err != nil {
+			return err
+		}
+		if err := acc.checkSpending(tx, a.Amount); err != nil {
+			return err
+		}
+
+		if err := resetCardAttempts(tx, card.ID); err != nil {
+			return err
+		}
+		hold = account.NewHold(card, a, now)
+		err = tx.QueryRow(`
+            INSERT INTO card_holds (card_id, account_id, merchant, amount, captured, currency,
+                                    status, created_at, expires_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+			hold.CardID, hold.AccountID, hold.Merchant, hold.Amount, hold.Captured, hold.Amount.Currency,
+			hold.Status, hold.CreatedAt, hold.ExpiresAt, hold.UpdatedAt,
+		).Scan(&hold.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create hold: %w", err)
+		}
+		return changeHeld(tx, acc.ID, hold.Amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, declined
+}
+
+func (r *Repository) GetHold(id int64) (*account.Hold, error) {
+	return getHold(r.db, `SELECT `+holdColumns+` FROM card_holds WHERE id = $1`, id)
+}
+
+func (r *Repository) CaptureHold(id int64, amount *money.Money) (*account.Hold, error) {
+	return r.releaseHold(id, func(tx *sql.Tx, hold *account.Hold, now time.Time) error {
+		if amount == nil {
+			amount = &hold.Amount
+		}
+		if err := hold.Capture(*amount, now); err != nil {
+			return err
+		}
+		entry := ledger.CardPayment(hold.AccountID, hold.Captured, hold.ID)
+		entry.CreatedAt = now
+		if err := ledger.Post(tx, entry); err != nil {
+			return fmt.Errorf("capture failed: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) ReverseHold(id int64) (*account.Hold, error) {
+	return r.releaseHold(id, func(tx *sql.Tx, hold *account.Hold, now time.Time) error {
+		return hold.Reverse(now)
+	})
+}
+
+// каждая блокировка снимается в своей транзакции; блокировку,
+// списанную или отменённую между выборкой и снятием, пропускаем
+func (r *Repository) ExpireHolds(now time.Time) (int, error) {
+	rows, err := r.db.Query(`SELECT id FROM card_holds WHERE status = 'pending' AND expires_at <= $1 ORDER BY id`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get expired holds: %v", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		_, err := r.releaseHold(id, func(tx *sql.Tx, hold *account.Hold, _ time.Time) error {
+			if !hold.Expire(now) {
+				return errHoldNotDue
+			}
+			return nil
+		})
+		if errors.Is(err, errHoldNotDue) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// блокировка уже не ждёт снятия по сроку
+var errHoldNotDue = errors.New("hold is not due")
+
+// смена статуса блокировки под блокировками её строки и строки счёта
+// со снятием заблокированной суммы со счёта
+func (r *Repository) releaseHold(id int64, change func(tx *sql.Tx, hold *account.Hold, now time.Time) error) (*account.Hold, error) {
+	var hold *account.Hold
+	err := r.runInTx(func(tx *sql.Tx) error {
+		var err error
+		if hold, err = getHold(tx, `SELECT `+holdColumns+` FROM card_holds WHERE id = $1 FOR UPDATE`, id); err != nil {
+			return err
+		}
+		if _, err := lockAccount(tx, hold.AccountID); err != nil {
+			return err
+		}
+		if err := change(tx, hold, time.Now()); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE card_holds SET captured = $2, status = $3, updated_at = $4 WHERE id = $1`,
+			hold.ID, hold.Captured, hold.Status, hold.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update hold: %w", err)
+		}
+		return changeHeld(tx, hold.AccountID, hold.Amount.Neg())
+	})
+	return hold, err
+}
+
+// изменение суммы блокировок счёта на delta
+func changeHeld(tx *sql.Tx, accountID string, delta money.Money) error {
+	if _, err := tx.Exec(`UPDATE accounts SET held = held + $1 WHERE id = $2`, delta, accountID); err != nil {
+		return fmt.Errorf("failed to update held amount: %w", err)
+	}
+	return nil
+}
+
+func getHold(q ledger.Querier, query string, args ...any) (*account.Hold, error) {
+	var h account.Hold
+	err := q.QueryRow(query, args...).Scan(&h.ID, &h.CardID, &h.AccountID, &h.Merchant,
+		&h.Amount, &h.Captured, &h.Amount.Currency, &h.Status, &h.CreatedAt, &h.ExpiresAt, &h.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, account.ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %v", err)
+	}
+	h.Captured.Currency = h.Amount.Currency
+	return &h, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/storage"
+	"time"
+)
+
+// захват ключа в рамках транзакции;
+// если ключ уже был использован, возвращается сохранённый ответ
+func claimIdempotencyKey(tx *sql.Tx, accountID string, idem *storage.Idempotency) (*storage.StoredResponse, error) {
+	if idem == nil {
+		return nil, nil
+	}
+
+	// параллельный запрос с тем же ключом ждёт здесь фиксации первой транзакции
+	result, err := tx.Exec(`
+        INSERT INTO idempotency_keys (key, account_id, endpoint, request_hash, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (account_id, endpoint, key) DO NOTHING`,
+		idem.Key, accountID, idem.Endpoint, idem.RequestHash, time.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %v", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 1 {
+		return nil, nil
+	}
+
+	var (
+		requestHash string
+		status      sql.NullInt64
+		body        []byte
+	)
+	err = tx.QueryRow(`
+        SELECT request_hash, response_status, response_body
+        FROM idempotency_keys
+        WHERE account_id = $1 AND endpoint = $2 AND key = $3`,
+		accountID, idem.Endpoint, idem.Key,
+	).Scan(&requestHash, &status, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %v", err)
+	}
+
+	if requestHash != idem.RequestHash {
+		return nil, storage.ErrIdempotencyKeyReused
+	}
+	if !status.Valid {
+		return nil, fmt.Errorf("request with this idempotency key is still in progress")
+	}
+	return &storage.StoredResponse{StatusCode: int(status.Int64), Body: body}, nil
+}
+
+// сохранение ответа для ключа в той же транзакции, что и сама операция
+func storeIdempotentResponse(tx *sql.Tx, accountID string, idem *storage.Idempotency) error {
+	if idem == nil {
+		return nil
+	}
+
+	response := idem.Result()
+	_, err := tx.Exec(`
+        UPDATE idempotency_keys SET response_status = $1, response_body = $2
+        WHERE account_id = $3 AND endpoint = $4 AND key = $5`,
+		response.StatusCode, response.Body, accountID, idem.Endpoint, idem.Key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %v", err)
+	}
+	return nil
+}
+
+// удаление ключей старше указанного срока
+func (r *Repository) CleanupIdempotencyKeys(olderThan time.Duration) error {
+	_, err := r.db.Exec("DELETE FROM idempotency_keys WHERE created_at < $1", time.Now().Add(-olderThan))
+	return err
+}
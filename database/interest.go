@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/money"
+	"time"
+)
+
+// колонки процентного продукта в порядке, который ожидает interestRow
+const interestColumns = `product, interest_rate, maturity_date, accrued_interest, accrued_through`
+
+// продукт и начисление в том виде, в каком они читаются из строки accounts
+type interestRow struct {
+	product, rate, accrued string
+	maturity, through      sql.NullTime
+}
+
+func (i *interestRow) dest() []any {
+	return []any{&i.product, &i.rate, &i.maturity, &i.accrued, &i.through}
+}
+
+// DATE читается как полночь в зоне соединения, поэтому дата берётся по полям
+func (i *interestRow) parse() (product, rate string, maturity *time.Time, accrual *account.Accrual) {
+	product, rate = i.product, trimZeros(i.rate)
+	if i.maturity.Valid {
+		date := sqlDate(i.maturity.Time)
+		maturity = &date
+	}
+	if i.through.Valid {
+		accrual = &account.Accrual{Interest: trimZeros(i.accrued), Through: sqlDate(i.through.Time)}
+	}
+	return product, rate, maturity, accrual
+}
+
+func sqlDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DATE, который может быть NULL
+func nullDate(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	date := sqlDate(t.Time)
+	return &date
+}
+
+// дата как строка YYYY-MM-DD, чтобы PostgreSQL не пересчитывал её по зоне
+func dateOrNull(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+func (r *Repository) SetProduct(accountID, product, annualRate string, maturity *time.Time) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := account.ValidateProduct(product, annualRate, maturity, now); err != nil {
+			return err
+		}
+
+		yesterday := account.UTCDate(now).AddDate(0, 0, -1)
+		if _, err := acc.accrueInterest(tx, yesterday); err != nil {
+			return err
+		}
+		if acc.Accrual == nil && product != account.ProductCurrent {
+			acc.Accrual = &account.Accrual{Interest: "0", Through: yesterday}
+		}
+		if err := saveAccrual(tx, acc); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE accounts SET product = $1, interest_rate = $2, maturity_date = $3 WHERE id = $4`,
+			product, numericOrZero(annualRate), dateOrNull(maturity), accountID)
+		if err != nil {
+			return fmt.Errorf("failed to set product: %w", err)
+		}
+		return nil
+	})
+}
+
+// начисление идёт под блокировкой строки счёта, а дата последнего
+// начисленного дня сохраняется в той же транзакции, поэтому задание
+// на нескольких экземплярах сервера не начислит день дважды
+func (r *Repository) AccrueInterest(accountID string, through time.Time) (money.Money, error) {
+	var credited money.Money
+	err := r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if credited, err = acc.accrueInterest(tx, through); err != nil {
+			return err
+		}
+		return saveAccrual(tx, acc)
+	})
+	return credited, err
+}
+
+// начисление по дням с остатком на конец каждого дня, восстановленным
+// по проводкам; капитализация проводится в начале следующего дня
+func (acc *lockedAccount) accrueInterest(tx *sql.Tx, through time.Time) (money.Money, error) {
+	credited := money.Zero(acc.Balance.Currency)
+	if acc.Accrual == nil {
+		return credited, nil
+	}
+
+	through = account.UTCDate(through)
+	for day := acc.Accrual.Through.AddDate(0, 0, 1); !day.After(through); day = day.AddDate(0, 0, 1) {
+		balance, err := acc.balanceAt(tx, day)
+		if err != nil {
+			return credited, err
+		}
+		accrued, capitalized, err := account.AccrueDay(acc.Accrual.Interest, balance, acc.InterestRate, acc.MaturityDate, day)
+		if err != nil {
+			return credited, err
+		}
+		if capitalized.IsPositive() {
+			entry := ledger.Interest(acc.ID, capitalized)
+			entry.CreatedAt = day.AddDate(0, 0, 1)
+			if err := ledger.Post(tx, entry); err != nil {
+				return credited, fmt.Errorf("interest capitalization failed: %w", err)
+			}
+			acc.Balance.Amount += capitalized.Amount
+			credited.Amount += capitalized.Amount
+		}
+		acc.Accrual = &account.Accrual{Interest: accrued, Through: day}
+	}
+	return credited, nil
+}
+
+// остаток на конец дня day: текущий баланс без движений, проведённых позже.
+// created_at хранится с часовым поясом, граница дня — полночь UTC
+func (acc *lockedAccount) balanceAt(tx *sql.Tx, day time.Time) (money.Money, error) {
+	later := money.Zero(acc.Balance.Currency)
+	err := tx.QueryRow(`
+        SELECT COALESCE(SUM(p.amount), 0)
+        FROM postings p
+        JOIN journal_entries e ON e.id = p.entry_id
+        WHERE p.account_id = $1 AND e.created_at >= $2`, acc.ID, account.UTCDate(day).AddDate(0, 0, 1)).Scan(&later)
+	if err != nil {
+		return later, fmt.Errorf("failed to compute end-of-day balance: %w", err)
+	}
+	return acc.Balance.Sub(later)
+}
+
+func saveAccrual(tx *sql.Tx, acc *lockedAccount) error {
+	if acc.Accrual == nil {
+		return nil
+	}
+	_, err := tx.Exec(`UPDATE accounts SET accrued_interest = $1, accrued_through = $2 WHERE id = $3`,
+		numericOrZero(acc.Accrual.Interest), dateOrNull(&acc.Accrual.Through), acc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save accrued interest: %w", err)
+	}
+	return nil
+}
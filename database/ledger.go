@@ -0,0 +1,8 @@
+package database
+
+import "mfp/ledger"
+
+// сверка журнала проводок с балансами счетов
+func (r *Repository) VerifyLedger() (*ledger.Report, error) {
+	return ledger.Verify(r.db)
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/money"
+	"time"
+)
+
+// колонки лимитов расходов в порядке, который ожидает limitsRow
+const limitColumns = `limit_per_transaction, limit_daily, limit_monthly,
+	pending_limit_per_transaction, pending_limit_daily, pending_limit_monthly, pending_limits_effective_at`
+
+// лимиты в том виде, в каком они читаются из строки accounts
+type limitsRow struct {
+	perTransaction, daily, monthly                      string
+	pendingPerTransaction, pendingDaily, pendingMonthly sql.NullString
+	pendingEffectiveAt                                  sql.NullTime
+}
+
+func (l *limitsRow) dest() []any {
+	return []any{
+		&l.perTransaction, &l.daily, &l.monthly,
+		&l.pendingPerTransaction, &l.pendingDaily, &l.pendingMonthly, &l.pendingEffectiveAt,
+	}
+}
+
+func (l *limitsRow) parse(currency string) (account.SpendingLimits, *account.PendingLimits, error) {
+	limits, err := parseLimits(currency, l.perTransaction, l.daily, l.monthly)
+	if err != nil || !l.pendingEffectiveAt.Valid {
+		return limits, nil, err
+	}
+
+	pending, err := parseLimits(currency, l.pendingPerTransaction.String, l.pendingDaily.String, l.pendingMonthly.String)
+	if err != nil {
+		return limits, nil, err
+	}
+	return limits, &account.PendingLimits{SpendingLimits: pending, EffectiveAt: l.pendingEffectiveAt.Time}, nil
+}
+
+func parseLimits(currency, perTransaction, daily, monthly string) (account.SpendingLimits, error) {
+	var (
+		limits account.SpendingLimits
+		err    error
+	)
+	if limits.PerTransaction, err = money.Parse(perTransaction, currency); err != nil {
+		return limits, fmt.Errorf("invalid per-transaction limit: %v", err)
+	}
+	if limits.Daily, err = money.Parse(daily, currency); err != nil {
+		return limits, fmt.Errorf("invalid daily limit: %v", err)
+	}
+	if limits.Monthly, err = money.Parse(monthly, currency); err != nil {
+		return limits, fmt.Errorf("invalid monthly limit: %v", err)
+	}
+	return limits, nil
+}
+
+// проверка списания по лимитам; вызывается под блокировкой строки счёта,
+// поэтому параллельные списания не могут вместе превысить лимит
+func (acc *lockedAccount) checkSpending(tx *sql.Tx, amount money.Money) error {
+	now := time.Now()
+	limits := account.EffectiveLimits(acc.Limits, acc.PendingLimits, now)
+	if limits.IsZero() {
+		return nil
+	}
+
+	today, month, err := spent(tx, acc.ID, acc.Balance.Currency, now)
+	if err != nil {
+		return err
+	}
+	return account.CheckSpending(limits, amount, today, month)
+}
+
+// сумма снятий, исходящих переводов и оплат картой, включая ещё
+// не списанные блокировки, с начала дня и месяца (UTC)
+func spent(q ledger.Querier, accountID, currency string, now time.Time) (today, month money.Money, err error) {
+	dayStart, monthStart := account.SpendingPeriods(now)
+	today, month = money.Zero(currency), money.Zero(currency)
+	err = q.QueryRow(`
+        SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $2), 0),
+               COALESCE(SUM(amount), 0)
+        FROM (
+            SELECT -p.amount AS amount, e.created_at
+            FROM postings p
+            JOIN journal_entries e ON e.id = p.entry_id
+            WHERE p.account_id = $1 AND p.amount < 0
+              AND e.type IN ('withdraw', 'transfer', 'card_payment')
+              AND e.created_at >= $3
+            UNION ALL
+            SELECT amount, created_at FROM card_holds
+            WHERE account_id = $1 AND status = 'pending' AND created_at >= $3
+        ) s`, accountID, dayStart, monthStart).Scan(&today, &month)
+	if err != nil {
+		return today, month, fmt.Errorf("failed to sum spending: %w", err)
+	}
+	return today, month, nil
+}
+
+func (r *Repository) RequestLimits(accountID string, requested account.SpendingLimits, cooling time.Duration) (account.SpendingLimits, *account.PendingLimits, error) {
+	var (
+		limits  account.SpendingLimits
+		pending *account.PendingLimits
+	)
+	err := r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := requested.Validate(acc.Balance.Currency); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		current := account.EffectiveLimits(acc.Limits, acc.PendingLimits, now)
+		limits, pending = account.RequestLimits(current, requested, now, cooling)
+		return saveLimits(tx, accountID, limits, pending)
+	})
+	return limits, pending, err
+}
+
+func (r *Repository) SetLimits(accountID string, limits account.SpendingLimits) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := limits.Validate(acc.Balance.Currency); err != nil {
+			return err
+		}
+		return saveLimits(tx, accountID, limits, nil)
+	})
+}
+
+func saveLimits(tx *sql.Tx, accountID string, limits account.SpendingLimits, pending *account.PendingLimits) error {
+	args := []any{accountID, limits.PerTransaction, limits.Daily, limits.Monthly, nil, nil, nil, nil}
+	if pending != nil {
+		args[4], args[5], args[6], args[7] = pending.PerTransaction, pending.Daily, pending.Monthly, pending.EffectiveAt
+	}
+
+	_, err := tx.Exec(`
+        UPDATE accounts SET
+            limit_per_transaction = $2, limit_daily = $3, limit_monthly = $4,
+            pending_limit_per_transaction = $5, pending_limit_daily = $6, pending_limit_monthly = $7,
+            pending_limits_effective_at = $8
+        WHERE id = $1`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to save limits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/storage"
+	"time"
+)
+
+// колонки login_attempts в порядке, который ожидает getLoginAttempts
+const loginAttemptColumns = `failures, lockouts, last_failure_at, next_attempt_at, locked_until`
+
+// у клиента без неудачных входов строки нет, счётчик пустой
+func (r *Repository) GetLoginAttempts(accountID string) (*account.LoginAttempts, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1)`, accountID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account: %v", err)
+	}
+	if !exists {
+		return nil, storage.ErrAccountNotFound
+	}
+	attempts, err := getLoginAttempts(r.db, accountID, "")
+	if errors.Is(err, sql.ErrNoRows) {
+		return &account.LoginAttempts{}, nil
+	}
+	return attempts, err
+}
+
+// строка создаётся при первой неудаче и блокируется на время изменения,
+// так что параллельные попытки входа одного клиента идут по очереди
+func (r *Repository) UpdateLoginAttempts(accountID string, change func(a *account.LoginAttempts) error) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+            INSERT INTO login_attempts (account_id)
+            SELECT id FROM accounts WHERE id = $1
+            ON CONFLICT (account_id) DO NOTHING`, accountID)
+		if err != nil {
+			return fmt.Errorf("failed to create login attempts: %v", err)
+		}
+		attempts, err := getLoginAttempts(tx, accountID, "FOR UPDATE")
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrAccountNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := change(attempts); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            UPDATE login_attempts SET failures = $2, lockouts = $3, last_failure_at = $4,
+                next_attempt_at = $5, locked_until = $6
+            WHERE account_id = $1`,
+			accountID, attempts.Failures, attempts.Lockouts, attempts.LastFailureAt,
+			attempts.NextAttemptAt, attempts.LockedUntil)
+		if err != nil {
+			return fmt.Errorf("failed to save login attempts: %v", err)
+		}
+		return nil
+	})
+}
+
+// счётчик ищется по слепому индексу, открытый телефон не хранится
+func (r *Repository) UpdateUnknownLoginAttempts(phone string, change func(a *account.LoginAttempts) error) error {
+	index := r.keys.BlindIndex(phone)
+	return r.runInTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+            INSERT INTO unknown_login_attempts (phone_index) VALUES ($1)
+            ON CONFLICT (phone_index) DO NOTHING`, index)
+		if err != nil {
+			return fmt.Errorf("failed to create login attempts: %v", err)
+		}
+		attempts, err := scanLoginAttempts(tx.QueryRow(
+			`SELECT `+loginAttemptColumns+` FROM unknown_login_attempts WHERE phone_index = $1 FOR UPDATE`, index))
+		if err != nil {
+			return err
+		}
+		if err := change(attempts); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            UPDATE unknown_login_attempts SET failures = $2, lockouts = $3, last_failure_at = $4,
+                next_attempt_at = $5, locked_until = $6
+            WHERE phone_index = $1`,
+			index, attempts.Failures, attempts.Lockouts, attempts.LastFailureAt,
+			attempts.NextAttemptAt, attempts.LockedUntil)
+		if err != nil {
+			return fmt.Errorf("failed to save login attempts: %v", err)
+		}
+		return nil
+	})
+}
+
+// удаление счётчиков незарегистрированных телефонов без действующей
+// блокировки и без неудач за olderThan
+func (r *Repository) CleanupUnknownLoginAttempts(olderThan time.Duration) error {
+	now := time.Now()
+	_, err := r.db.Exec(`
+        DELETE FROM unknown_login_attempts
+        WHERE (locked_until IS NULL OR locked_until < $1)
+          AND (last_failure_at IS NULL OR last_failure_at < $2)`, now, now.Add(-olderThan))
+	return err
+}
+
+// sql.ErrNoRows возвращается как есть: отсутствие строки значит разное
+// для чтения и изменения
+func getLoginAttempts(q ledger.Querier, accountID, lock string) (*account.LoginAttempts, error) {
+	return scanLoginAttempts(q.QueryRow(`SELECT `+loginAttemptColumns+` FROM login_attempts WHERE account_id = $1 `+lock, accountID))
+}
+
+func scanLoginAttempts(row *sql.Row) (*account.LoginAttempts, error) {
+	var (
+		attempts                   account.LoginAttempts
+		lastFailure, next, lockEnd sql.NullTime
+	)
+	err := row.Scan(&attempts.Failures, &attempts.Lockouts, &lastFailure, &next, &lockEnd)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login attempts: %v", err)
+	}
+	for _, t := range []struct {
+		src sql.NullTime
+		dst **time.Time
+	}{
+		{lastFailure, &attempts.LastFailureAt},
+		{next, &attempts.NextAttemptAt},
+		{lockEnd, &attempts.LockedUntil},
+	} {
+		if t.src.Valid {
+			v := t.src.Time
+			*t.dst = &v
+		}
+	}
+	return &attempts, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/money"
+	"time"
+)
+
+// дни до вчерашнего включительно начисляются по прежней ставке
+func (r *Repository) SetOverdraft(accountID string, limit money.Money, annualRate string) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := account.ValidateOverdraft(acc.Balance.Currency, limit, annualRate); err != nil {
+			return err
+		}
+		if _, err := acc.chargeOverdraftInterest(tx, account.UTCDate(time.Now()).AddDate(0, 0, -1)); err != nil {
+			return err
+		}
+		if err := saveOverdraftThrough(tx, acc); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE accounts SET overdraft_limit = $1, overdraft_rate = $2 WHERE id = $3`,
+			limit, numericOrZero(annualRate), accountID)
+		if err != nil {
+			return fmt.Errorf("failed to set overdraft: %w", err)
+		}
+		return nil
+	})
+}
+
+// списание идёт под блокировкой строки счёта, а последний обработанный
+// день сохраняется в той же транзакции, поэтому задание на нескольких
+// экземплярах сервера не спишет проценты за день дважды
+func (r *Repository) ChargeOverdraftInterest(accountID string, through time.Time) (money.Money, error) {
+	var charged money.Money
+	err := r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if charged, err = acc.chargeOverdraftInterest(tx, through); err != nil {
+			return err
+		}
+		return saveOverdraftThrough(tx, acc)
+	})
+	return charged, err
+}
+
+// списание за дни после последнего обработанного по through включительно
+// с остатком на конец каждого дня, восстановленным по проводкам;
+// проценты за день проводятся в начале следующего дня
+func (acc *lockedAccount) chargeOverdraftInterest(tx *sql.Tx, through time.Time) (money.Money, error) {
+	charged := money.Zero(acc.Balance.Currency)
+	through = account.UTCDate(through)
+
+	var lastCharge *time.Time
+	if acc.OverdraftThrough == nil {
+		var last sql.NullTime
+		err := tx.QueryRow(`
+            SELECT MAX(e.created_at)
+            FROM journal_entries e
+            JOIN postings p ON p.entry_id = e.id
+            WHERE p.account_id = $1 AND e.type = $2`, acc.ID, ledger.TypeOverdraftInterest).Scan(&last)
+		if err != nil {
+			return charged, fmt.Errorf("failed to find last overdraft interest: %w", err)
+		}
+		if last.Valid {
+			lastCharge = &last.Time
+		}
+	}
+
+	for day := account.OverdraftFrom(acc.OverdraftThrough, lastCharge, through); !day.After(through); day = day.AddDate(0, 0, 1) {
+		balance, err := acc.balanceAt(tx, day)
+		if err != nil {
+			return charged, err
+		}
+		interest, err := account.OverdraftInterest(balance, acc.OverdraftRate)
+		if err != nil {
+			return charged, err
+		}
+		if !interest.IsZero() {
+			entry := ledger.OverdraftInterest(acc.ID, interest)
+			entry.CreatedAt = day.AddDate(0, 0, 1)
+			if err := ledger.Post(tx, entry); err != nil {
+				return charged, fmt.Errorf("overdraft interest failed: %w", err)
+			}
+			acc.Balance.Amount -= interest.Amount
+			charged.Amount += interest.Amount
+		}
+		chargedThrough := day
+		acc.OverdraftThrough = &chargedThrough
+	}
+	return charged, nil
+}
+
+func saveOverdraftThrough(tx *sql.Tx, acc *lockedAccount) error {
+	if acc.OverdraftThrough == nil {
+		return nil
+	}
+	_, err := tx.Exec(`UPDATE accounts SET overdraft_through = $1 WHERE id = $2`,
+		dateOrNull(acc.OverdraftThrough), acc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save overdraft interest date: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/money"
+	"testing"
+	"time"
+)
+
+// проценты за овердрафт считаются по остатку на конец каждого дня
+// и догоняют пропущенные дни; ставка 36.5% даёт тысячную долю долга
+func TestChargeOverdraftInterest(t *testing.T) {
+	r := testRepository(t)
+	run := time.Now().UnixNano() % 1e8
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	kzt := func(amount int64) money.Money { return money.FromMinor(amount, money.DefaultCurrency) }
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name        string
+		through     *time.Time // сохранённый последний обработанный день
+		oldCharge   *time.Time // списание до появления overdraft_through
+		wantCharged int64
+		wantBalance int64
+	}{
+		{
+			// 1 марта: −1000.00 → 1.00; 2 марта: −1001.00 → 1.00;
+			// 3 марта: +998.00 → 0; 4 марта: −502.00 → 0.50
+			name:        "catch up from saved day",
+			through:     ptr(day(0)),
+			wantCharged: 250,
+			wantBalance: -50250,
+		},
+		{
+			// 2 марта уже списано днём по старой схеме, догоняются 3 и 4 марта
+			name:        "catch up from last charge",
+			oldCharge:   ptr(day(2).Add(10 * time.Hour)),
+			wantCharged: 50,
+			wantBalance: -50150,
+		},
+		{
+			name:        "first run",
+			wantCharged: 50,
+			wantBalance: -50050,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := &account.Account{
+				ID:        fmt.Sprintf("KZOVD%010d%d", run, i),
+				Password:  "-",
+				Balance:   money.Zero(money.DefaultCurrency),
+				Name:      "Test",
+				Phone:     fmt.Sprintf("77%09d", run*10+int64(i)),
+				Age:       30,
+				Role:      account.RoleCustomer,
+				Status:    account.StatusActive,
+				CreatedAt: time.Now(),
+				ExpiredAt: time.Now().AddDate(5, 0, 0),
+			}
+			if err := r.CreateAccount(acc); err != nil {
+				t.Fatalf("CreateAccount: %v", err)
+			}
+
+			post := func(e *ledger.Entry, at time.Time) {
+				e.CreatedAt = at
+				if err := ledger.Post(r.db, e); err != nil {
+					t.Fatalf("Post: %v", err)
+				}
+			}
+			post(ledger.Withdrawal(acc.ID, kzt(100000)), day(1).Add(10*time.Hour))
+			post(ledger.Deposit(acc.ID, kzt(200000)), day(3).Add(12*time.Hour))
+			post(ledger.Withdrawal(acc.ID, kzt(150000)), day(4).Add(23*time.Hour))
+			if tt.oldCharge != nil {
+				post(ledger.OverdraftInterest(acc.ID, kzt(100)), *tt.oldCharge)
+			}
+			_, err := r.db.Exec(`UPDATE accounts SET overdraft_limit = 2000, overdraft_rate = 36.5, overdraft_through = $2 WHERE id = $1`,
+				acc.ID, dateOrNull(tt.through))
+			if err != nil {
+				t.Fatalf("failed to set overdraft: %v", err)
+			}
+
+			charged, err := r.ChargeOverdraftInterest(acc.ID, day(4))
+			if err != nil {
+				t.Fatalf("ChargeOverdraftInterest: %v", err)
+			}
+			if charged.Amount != tt.wantCharged {
+				t.Errorf("charged = %d, want %d", charged.Amount, tt.wantCharged)
+			}
+			if again, err := r.ChargeOverdraftInterest(acc.ID, day(4)); err != nil || !again.IsZero() {
+				t.Errorf("repeated ChargeOverdraftInterest = %s, %v, want zero", again, err)
+			}
+
+			got, err := r.GetAccount(acc.ID)
+			if err != nil {
+				t.Fatalf("GetAccount: %v", err)
+			}
+			if got.Balance.Amount != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", got.Balance.Amount, tt.wantBalance)
+			}
+			if got.OverdraftThrough == nil || !got.OverdraftThrough.Equal(day(4)) {
+				t.Errorf("OverdraftThrough = %v, want %v", got.OverdraftThrough, day(4))
+			}
+		})
+	}
+}
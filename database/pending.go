@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+	"mfp/money"
+	"mfp/pending"
+	"mfp/storage"
+	"time"
+)
+
+const pendingColumns = `id, account_id, to_account, amount, currency, quote_id, method, attempts,
+    status, last_error, created_at, expires_at, updated_at`
+
+// ключ идемпотентности захватывается в той же транзакции, что и вставка,
+// поэтому повтор запроса не создаст второй перевод
+func (r *Repository) CreatePendingTransfer(t *pending.Transfer, idem *storage.Idempotency) (*storage.StoredResponse, error) {
+	var stored *storage.StoredResponse
+	err := r.runInTx(func(tx *sql.Tx) error {
+		var err error
+		if stored, err = claimIdempotencyKey(tx, t.AccountID, idem); err != nil || stored != nil {
+			return err
+		}
+
+		err = tx.QueryRow(`
+            INSERT INTO pending_transfers (account_id, to_account, amount, currency, quote_id, method,
+                status, created_at, expires_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+			t.AccountID, t.ToAccount, t.Amount, t.Amount.Currency, t.QuoteID, t.Method,
+			t.Status, t.CreatedAt, t.ExpiresAt, t.UpdatedAt,
+		).Scan(&t.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create pending transfer: %v", err)
+		}
+		return storeIdempotentResponse(tx, t.AccountID, idem)
+	})
+	return stored, err
+}
+
+func (r *Repository) GetPendingTransfer(id int64) (*pending.Transfer, error) {
+	return getPendingTransfer(r.db, `SELECT `+pendingColumns+` FROM pending_transfers WHERE id = $1`, id)
+}
+
+// изменение под блокировкой строки: два параллельных подтверждения
+// не исполнят перевод дважды
+func (r *Repository) UpdatePendingTransfer(id int64, change func(t *pending.Transfer) error) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		t, err := getPendingTransfer(tx, `SELECT `+pendingColumns+` FROM pending_transfers WHERE id = $1 FOR UPDATE`, id)
+		if err != nil {
+			return err
+		}
+		if err := change(t); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            UPDATE pending_transfers SET attempts = $2, status = $3, last_error = $4, updated_at = $5
+            WHERE id = $1`, t.ID, t.Attempts, t.Status, t.LastError, t.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update pending transfer: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) ExpirePendingTransfers(now time.Time) (int, error) {
+	result, err := r.db.Exec(`
+        UPDATE pending_transfers SET status = 'expired', updated_at = $1
+        WHERE status = 'pending' AND expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire pending transfers: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+func (r *Repository) SetConfirmThreshold(accountID string, threshold *money.Money) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		acc, err := lockAccount(tx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := account.ValidateConfirmThreshold(acc.Balance.Currency, threshold); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE accounts SET confirm_threshold = $1 WHERE id = $2`, confirmThreshold(threshold), accountID); err != nil {
+			return fmt.Errorf("failed to set confirmation threshold: %w", err)
+		}
+		return nil
+	})
+}
+
+func getPendingTransfer(q ledger.Querier, query string, args ...any) (*pending.Transfer, error) {
+	var t pending.Transfer
+	err := q.QueryRow(query, args...).Scan(
+		&t.ID, &t.AccountID, &t.ToAccount, &t.Amount, &t.Amount.Currency, &t.QuoteID, &t.Method, &t.Attempts,
+		&t.Status, &t.LastError, &t.CreatedAt, &t.ExpiresAt, &t.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, pending.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending transfer: %v", err)
+	}
+	return &t, nil
+}
@@ -4,21 +4,35 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"mfp/keyring"
+	"mfp/session"
+	"mfp/storage"
+	"mfp/token"
 
 	_ "github.com/lib/pq"
 )
 
 type Repository struct {
-	db *sql.DB
+	db       *sql.DB
+	sessions session.Store
+	tokens   token.Store
+	keys     *keyring.Keyring // шифрование телефонов и коды CVC2
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, keys *keyring.Keyring) *Repository {
+	return &Repository{db: db, sessions: NewSessionStore(db), tokens: NewRefreshTokenStore(db), keys: keys}
 }
 
-func Connect() (*Repository, error) {
-	connStr := "host=localhost port=5432 user=postgres password=password dbname=mybank sslmode=disable"
+func Connect(connStr string, keys *keyring.Keyring) (*Repository, error) {
+	db, err := Open(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return NewRepository(db, keys), nil
+}
 
+// открытие и проверка подключения к PostgreSQL
+func Open(connStr string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
@@ -28,5 +42,17 @@ func Connect() (*Repository, error) {
 	}
 
 	log.Println("Connected to postgreSql succesfully")
-	return NewRepository(db), nil
+	return db, nil
+}
+
+// хранилище сессий в таблице sessions
+func (r *Repository) Sessions() session.Store {
+	return r.sessions
+}
+
+// токены обновления в таблице refresh_tokens
+func (r *Repository) RefreshTokens() token.Store {
+	return r.tokens
 }
+
+var _ storage.Store = (*Repository)(nil)
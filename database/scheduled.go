@@ -0,0 +1,214 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/schedule"
+	"mfp/storage"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const scheduledColumns = `id, account_id, to_account, amount, currency, frequency, start_at, end_at,
+    max_runs, occurrence, run_count, attempts, next_run_at, status, last_error, created_at, updated_at`
+
+func (r *Repository) CreateScheduledTransfer(t *schedule.Transfer) error {
+	err := r.db.QueryRow(`
+        INSERT INTO scheduled_transfers (account_id, to_account, amount, currency, frequency, start_at, end_at,
+            max_runs, next_run_at, status, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		t.AccountID, t.ToAccount, t.Amount, t.Amount.Currency, t.Frequency, t.StartAt, t.EndAt,
+		t.MaxRuns, t.NextRunAt, t.Status, t.CreatedAt, t.UpdatedAt,
+	).Scan(&t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduled transfer: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetScheduledTransfer(id int64) (*schedule.Transfer, error) {
+	t, err := scanScheduledTransfer(r.db.QueryRow(`SELECT `+scheduledColumns+` FROM scheduled_transfers WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, schedule.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled transfer: %v", err)
+	}
+	return t, nil
+}
+
+func (r *Repository) GetScheduledTransfers(accountIDs []string) ([]*schedule.Transfer, error) {
+	rows, err := r.db.Query(`
+        SELECT `+scheduledColumns+` FROM scheduled_transfers
+        WHERE account_id = ANY($1)
+        ORDER BY id DESC`, pq.Array(accountIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled transfers: %v", err)
+	}
+	return scanScheduledTransfers(rows)
+}
+
+func (r *Repository) SetScheduledTransferStatus(id int64, from, to string) error {
+	if from == schedule.StatusPaused && to == schedule.StatusActive {
+		return r.resumeScheduledTransfer(id)
+	}
+
+	result, err := r.db.Exec(`
+        UPDATE scheduled_transfers SET status = $3, updated_at = $4
+        WHERE id = $1 AND status = $2`, id, from, to, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update scheduled transfer: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := r.GetScheduledTransfer(id); err != nil {
+			return err
+		}
+		return fmt.Errorf("scheduled transfer is not %s", from)
+	}
+	return nil
+}
+
+// при возобновлении next_run_at пересчитывается, чтобы пропущенные
+// за время паузы повторы не выполнились подряд
+func (r *Repository) resumeScheduledTransfer(id int64) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		t, err := scanScheduledTransfer(tx.QueryRow(`
+            SELECT `+scheduledColumns+` FROM scheduled_transfers WHERE id = $1 FOR UPDATE`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return schedule.ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get scheduled transfer: %v", err)
+		}
+		if t.Status != schedule.StatusPaused {
+			return fmt.Errorf("scheduled transfer is not %s", schedule.StatusPaused)
+		}
+
+		t.Resume(time.Now())
+		_, err = tx.Exec(`
+            UPDATE scheduled_transfers SET
+                occurrence = $2, attempts = $3, next_run_at = $4, status = $5, updated_at = $6
+            WHERE id = $1`,
+			t.ID, t.Occurrence, t.Attempts, t.NextRunAt, t.Status, t.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update scheduled transfer: %v", err)
+		}
+		return nil
+	})
+}
+
+// SKIP LOCKED не даёт двум экземплярам выбрать одни и те же строки,
+// а locked_until — повторно выдать их, пока первый запуск не закончился
+func (r *Repository) ClaimDueTransfers(now time.Time, limit int, lease time.Duration) ([]*schedule.Transfer, error) {
+	var transfers []*schedule.Transfer
+	err := r.runInTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+            UPDATE scheduled_transfers SET locked_until = $3
+            WHERE id IN (
+                SELECT id FROM scheduled_transfers
+                WHERE status = 'active' AND next_run_at <= $1
+                  AND (locked_until IS NULL OR locked_until <= $1)
+                ORDER BY next_run_at
+                LIMIT $2
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING `+scheduledColumns, now, limit, now.Add(lease))
+		if err != nil {
+			return fmt.Errorf("failed to claim scheduled transfers: %v", err)
+		}
+		transfers, err = scanScheduledTransfers(rows)
+		return err
+	})
+	return transfers, err
+}
+
+func (r *Repository) SaveScheduledRun(t *schedule.Transfer) error {
+	_, err := r.db.Exec(`
+        UPDATE scheduled_transfers SET
+            occurrence = $2, run_count = $3, attempts = $4, next_run_at = $5, last_error = $6,
+            status = CASE WHEN status = 'active' THEN $7 ELSE status END,
+            locked_until = NULL, updated_at = $8
+        WHERE id = $1`,
+		t.ID, t.Occurrence, t.RunCount, t.Attempts, t.NextRunAt, t.LastError, t.Status, t.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save scheduled transfer run: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) AddNotification(n *storage.Notification) error {
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+
+	err := r.db.QueryRow(`
+        INSERT INTO notifications (account_id, kind, message, created_at)
+        VALUES ($1, $2, $3, $4) RETURNING id`,
+		n.AccountID, n.Kind, n.Message, n.CreatedAt,
+	).Scan(&n.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add notification: %v", err)
+	}
+	return nil
+}
+
+// последние уведомления счёта, новые сверху
+func (r *Repository) GetNotifications(accountID string, limit int) ([]*storage.Notification, error) {
+	rows, err := r.db.Query(`
+        SELECT id, account_id, kind, message, created_at
+        FROM notifications
+        WHERE account_id = $1
+        ORDER BY id DESC
+        LIMIT $2`, accountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []*storage.Notification{}
+	for rows.Next() {
+		var n storage.Notification
+		if err := rows.Scan(&n.ID, &n.AccountID, &n.Kind, &n.Message, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+func scanScheduledTransfers(rows *sql.Rows) ([]*schedule.Transfer, error) {
+	defer rows.Close()
+
+	transfers := []*schedule.Transfer{}
+	for rows.Next() {
+		t, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+func scanScheduledTransfer(row rowScanner) (*schedule.Transfer, error) {
+	var (
+		t     schedule.Transfer
+		endAt sql.NullTime
+	)
+	err := row.Scan(
+		&t.ID, &t.AccountID, &t.ToAccount, &t.Amount, &t.Amount.Currency, &t.Frequency, &t.StartAt, &endAt,
+		&t.MaxRuns, &t.Occurrence, &t.RunCount, &t.Attempts, &t.NextRunAt, &t.Status, &t.LastError,
+		&t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if endAt.Valid {
+		t.EndAt = &endAt.Time
+	}
+	return &t, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/session"
+	"time"
+)
+
+// хранилище сессий в таблице sessions: переживает перезапуск
+// и позволяет нескольким копиям сервера работать с одними сессиями
+type SessionStore struct {
+	db *sql.DB
+}
+
+func NewSessionStore(db *sql.DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+const sessionColumns = `id, user_id, created_at, last_activity, expires_at, user_agent, ip, pending`
+
+func (ss *SessionStore) Create(sess *session.Session, maxPerUser int) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// сериализуем создание сессий одного пользователя,
+	// иначе параллельные входы могут превысить лимит
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, sess.UserID); err != nil {
+		return fmt.Errorf("failed to lock user sessions: %v", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = $1 AND expires_at < $2`, sess.UserID, sess.CreatedAt); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %v", err)
+	}
+
+	_, err = tx.Exec(`
+        DELETE FROM sessions WHERE id IN (
+            SELECT id FROM sessions WHERE user_id = $1
+            ORDER BY created_at DESC
+            OFFSET $2
+        )`,
+		sess.UserID, maxPerUser-1,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to evict old sessions: %v", err)
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO sessions (`+sessionColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		sess.ID, sess.UserID, sess.CreatedAt, sess.LastActivity, sess.ExpiresAt, sess.UserAgent, sess.IP, sess.Pending,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert session: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (ss *SessionStore) Get(sessionID string) (*session.Session, error) {
+	row := ss.db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, sessionID)
+	return scanSession(row)
+}
+
+// скользящее продление: обновляются last_activity и expires_at,
+// если сессия ещё не истекла к моменту обращения
+func (ss *SessionStore) Touch(sessionID string, lastActivity, expiresAt time.Time) (*session.Session, error) {
+	row := ss.db.QueryRow(`
+        UPDATE sessions SET last_activity = $2, expires_at = $3
+        WHERE id = $1 AND expires_at >= $2
+        RETURNING `+sessionColumns,
+		sessionID, lastActivity, expiresAt,
+	)
+	return scanSession(row)
+}
+
+func (ss *SessionStore) Delete(sessionID string) error {
+	_, err := ss.db.Exec(`DELETE FROM sessions WHERE id = $1`, sessionID)
+	return err
+}
+
+func (ss *SessionStore) ListByUser(userID string) ([]*session.Session, error) {
+	rows, err := ss.db.Query(`SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*session.Session{}
+	for rows.Next() {
+		var sess session.Session
+		if err := rows.Scan(&sess.ID, &sess.UserID, &sess.CreatedAt, &sess.LastActivity,
+			&sess.ExpiresAt, &sess.UserAgent, &sess.IP, &sess.Pending); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &sess)
+	}
+	return sessions, rows.Err()
+}
+
+func (ss *SessionStore) DeleteExpired(now time.Time) error {
+	_, err := ss.db.Exec(`DELETE FROM sessions WHERE expires_at < $1`, now)
+	return err
+}
+
+func scanSession(row *sql.Row) (*session.Session, error) {
+	var sess session.Session
+	err := row.Scan(&sess.ID, &sess.UserID, &sess.CreatedAt, &sess.LastActivity,
+		&sess.ExpiresAt, &sess.UserAgent, &sess.IP, &sess.Pending)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+var _ session.Store = (*SessionStore)(nil)
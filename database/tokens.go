@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/ledger"
+	"mfp/token"
+	"time"
+)
+
+// токены обновления в таблице refresh_tokens: общие для всех копий
+// сервера, хранятся только хеши
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenStore(db *sql.DB) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db}
+}
+
+const refreshTokenColumns = `token_hash, family_id, user_id, created_at, expires_at, rotated_at, revoked_at`
+
+func (ts *RefreshTokenStore) Create(t *token.RefreshToken) error {
+	return insertRefreshToken(ts.db, t)
+}
+
+// строка токена блокируется, поэтому один токен не обменяют дважды
+// параллельными запросами
+func (ts *RefreshTokenStore) Rotate(hash string, now time.Time, next *token.RefreshToken) (*token.RefreshToken, error) {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		t                token.RefreshToken
+		rotated, revoked sql.NullTime
+	)
+	err = tx.QueryRow(`SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, hash).
+		Scan(&t.Hash, &t.FamilyID, &t.UserID, &t.CreatedAt, &t.ExpiresAt, &rotated, &revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, token.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %v", err)
+	}
+	if rotated.Valid {
+		t.RotatedAt = &rotated.Time
+	}
+	if revoked.Valid {
+		t.RevokedAt = &revoked.Time
+	}
+
+	if checkErr := t.Check(now); checkErr != nil {
+		if !errors.Is(checkErr, token.ErrReused) {
+			return nil, checkErr
+		}
+		if err := revokeFamily(tx, t.FamilyID, now); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, checkErr
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET rotated_at = $2 WHERE token_hash = $1`, hash, now); err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	t.RotatedAt = &now
+	next.FamilyID, next.UserID = t.FamilyID, t.UserID
+	if err := insertRefreshToken(tx, next); err != nil {
+		return nil, err
+	}
+	return &t, tx.Commit()
+}
+
+func (ts *RefreshTokenStore) Revoke(hash string, now time.Time) error {
+	var familyID string
+	err := ts.db.QueryRow(`SELECT family_id FROM refresh_tokens WHERE token_hash = $1`, hash).Scan(&familyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get refresh token: %v", err)
+	}
+	return revokeFamily(ts.db, familyID, now)
+}
+
+func (ts *RefreshTokenStore) RevokeUser(userID string, now time.Time) error {
+	return revokeUserTokens(ts.db, userID, now)
+}
+
+func (ts *RefreshTokenStore) DeleteExpired(now time.Time) error {
+	if _, err := ts.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %v", err)
+	}
+	return nil
+}
+
+func insertRefreshToken(q ledger.Querier, t *token.RefreshToken) error {
+	_, err := q.Exec(`
+        INSERT INTO refresh_tokens (`+refreshTokenColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		t.Hash, t.FamilyID, t.UserID, t.CreatedAt, t.ExpiresAt, t.RotatedAt, t.RevokedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %v", err)
+	}
+	return nil
+}
+
+func revokeFamily(q ledger.Querier, familyID string, now time.Time) error {
+	_, err := q.Exec(`UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL`, familyID, now)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return nil
+}
+
+func revokeUserTokens(q ledger.Querier, userID string, now time.Time) error {
+	_, err := q.Exec(`UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`, userID, now)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/ledger"
+
+	"github.com/lib/pq"
+)
+
+// колонки two_factor в порядке, который ожидает scanTwoFactor
+const twoFactorColumns = `method, secret_enc, key_version, confirmed, recovery_codes, last_step,
+    code_hash, code_expires_at, created_at`
+
+func (r *Repository) GetTwoFactor(accountID string) (*account.TwoFactor, error) {
+	return r.getTwoFactor(r.db, accountID, "")
+}
+
+// новый второй фактор заменяет прежний вместе с кодами восстановления
+func (r *Repository) SetTwoFactor(accountID string, tf *account.TwoFactor) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		if _, err := lockAccount(tx, accountID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM two_factor WHERE account_id = $1`, accountID); err != nil {
+			return fmt.Errorf("failed to delete two-factor settings: %v", err)
+		}
+		if tf == nil {
+			return nil
+		}
+		return r.saveTwoFactor(tx, accountID, tf, false)
+	})
+}
+
+// изменение под блокировкой строки, чтобы одноразовый код
+// нельзя было принять дважды параллельными запросами
+func (r *Repository) UpdateTwoFactor(accountID string, change func(tf *account.TwoFactor) error) error {
+	return r.runInTx(func(tx *sql.Tx) error {
+		tf, err := r.getTwoFactor(tx, accountID, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := change(tf); err != nil {
+			return err
+		}
+		return r.saveTwoFactor(tx, accountID, tf, true)
+	})
+}
+
+// секрет шифруется на копии, в tf он остаётся открытым
+func (r *Repository) saveTwoFactor(tx *sql.Tx, accountID string, tf *account.TwoFactor, update bool) error {
+	stored := *tf
+	if err := stored.SealSecret(r.keys, accountID); err != nil {
+		return err
+	}
+	query := `
+        INSERT INTO two_factor (account_id, ` + twoFactorColumns + `)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if update {
+		query = `
+            UPDATE two_factor SET method = $2, secret_enc = $3, key_version = $4, confirmed = $5,
+                recovery_codes = $6, last_step = $7, code_hash = $8, code_expires_at = $9, created_at = $10
+            WHERE account_id = $1`
+	}
+	_, err := tx.Exec(query, accountID, stored.Method, nullString(stored.SecretEnc), nullInt(stored.KeyVersion),
+		stored.Confirmed, pq.Array(stored.RecoveryCodes), stored.LastStep, nullString(stored.CodeHash),
+		stored.CodeExpiresAt, stored.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor settings: %v", err)
+	}
+	return nil
+}
+
+func (r *Repository) getTwoFactor(q ledger.Querier, accountID, lock string) (*account.TwoFactor, error) {
+	var (
+		tf         account.TwoFactor
+		secretEnc  sql.NullString
+		keyVersion sql.NullInt64
+		codeHash   sql.NullString
+		codes      []string
+		expires    sql.NullTime
+	)
+	err := q.QueryRow(`SELECT `+twoFactorColumns+` FROM two_factor WHERE account_id = $1 `+lock, accountID).Scan(&tf.Method, &secretEnc, &keyVersion, &tf.Confirmed, pq.Array(&codes),
+		&tf.LastStep, &codeHash, &expires, &tf.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, account.ErrTwoFactorNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor settings: %v", err)
+	}
+	tf.SecretEnc, tf.KeyVersion, tf.CodeHash, tf.RecoveryCodes = secretEnc.String, int(keyVersion.Int64), codeHash.String, codes
+	if expires.Valid {
+		tf.CodeExpiresAt = &expires.Time
+	}
+	if err := tf.OpenSecret(r.keys, accountID); err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
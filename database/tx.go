@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// максимальное число попыток транзакции при конфликте сериализации или взаимоблокировке
+const maxTxAttempts = 5
+
+// базовая задержка перед повтором транзакции
+const txRetryDelay = 10 * time.Millisecond
+
+// выполнение функции в транзакции с автоматическим повтором
+// при ошибках сериализации (40001) и взаимоблокировках (40P01)
+func (r *Repository) runInTx(fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = r.runInTxOnce(fn)
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * txRetryDelay)
+	}
+	return err
+}
+
+func (r *Repository) runInTxOnce(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// проверка, можно ли повторить транзакцию целиком
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return true
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package fees
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mfp/account"
+	"mfp/money"
+	"os"
+	"strings"
+)
+
+// платные операции
+const (
+	OpTransfer   = "transfer"
+	OpWithdrawal = "withdrawal"
+)
+
+// ступень тарифа: процент для клиентов с месячным оборотом от Volume
+type Tier struct {
+	Volume  string `json:"volume"`
+	Percent string `json:"percent"`
+}
+
+// тариф на операцию в одной валюте: фиксированная часть плюс процент
+// от суммы, ограниченные снизу Min и сверху Max. суммы — десятичные строки
+// в валюте тарифа, пустая строка — ноль (для Max — без ограничения)
+type Rule struct {
+	Operation string `json:"operation"`
+	Currency  string `json:"currency"`
+	Flat      string `json:"flat,omitempty"`
+	Percent   string `json:"percent,omitempty"`
+	Min       string `json:"min,omitempty"`
+	Max       string `json:"max,omitempty"`
+	// процент по обороту за месяц заменяет Percent, берётся наибольшая
+	// достигнутая ступень
+	Tiers []Tier `json:"tiers,omitempty"`
+	// переводы между своими счетами бесплатны, если продукт счёта
+	// списания не ниже указанного (current < savings < deposit)
+	FreeInternalFrom string `json:"free_internal_from,omitempty"`
+}
+
+// операция, за которую считается комиссия
+type Operation struct {
+	Kind          string
+	Amount        money.Money
+	MonthlyVolume money.Money // снятия и исходящие переводы с начала месяца
+	Internal      bool        // перевод между счетами одного клиента
+	Product       string      // продукт счёта списания
+}
+
+// уровни продуктов для бесплатных внутренних переводов
+var productLevels = map[string]int{
+	account.ProductCurrent: 0,
+	account.ProductSavings: 1,
+	account.ProductDeposit: 2,
+}
+
+// проверка и нормализация тарифа перед сохранением
+func (r *Rule) Validate() error {
+	if r.Operation != OpTransfer && r.Operation != OpWithdrawal {
+		return fmt.Errorf("unknown operation %q, expected %s or %s", r.Operation, OpTransfer, OpWithdrawal)
+	}
+	r.Currency = money.NormalizeCurrency(r.Currency)
+	if err := money.ValidateCurrency(r.Currency); err != nil {
+		return err
+	}
+
+	for _, amount := range []struct{ name, value string }{{"flat", r.Flat}, {"min", r.Min}, {"max", r.Max}} {
+		if _, err := parseAmount(amount.value, r.Currency); err != nil {
+			return fmt.Errorf("%s %s: invalid %s: %v", r.Operation, r.Currency, amount.name, err)
+		}
+	}
+	if _, err := parsePercent(r.Percent); err != nil {
+		return fmt.Errorf("%s %s: %v", r.Operation, r.Currency, err)
+	}
+	min, _ := parseAmount(r.Min, r.Currency)
+	max, _ := parseAmount(r.Max, r.Currency)
+	if !max.IsZero() && min.Amount > max.Amount {
+		return fmt.Errorf("%s %s: min must not exceed max", r.Operation, r.Currency)
+	}
+
+	for i, tier := range r.Tiers {
+		volume, err := parseAmount(tier.Volume, r.Currency)
+		if err != nil {
+			return fmt.Errorf("%s %s: tier %d: invalid volume: %v", r.Operation, r.Currency, i+1, err)
+		}
+		if _, err := parsePercent(tier.Percent); err != nil {
+			return fmt.Errorf("%s %s: tier %d: %v", r.Operation, r.Currency, i+1, err)
+		}
+		if i > 0 {
+			previous, _ := parseAmount(r.Tiers[i-1].Volume, r.Currency)
+			if volume.Amount <= previous.Amount {
+				return fmt.Errorf("%s %s: tier volumes must increase", r.Operation, r.Currency)
+			}
+		}
+	}
+
+	if r.FreeInternalFrom != "" {
+		if _, ok := productLevels[r.FreeInternalFrom]; !ok {
+			return fmt.Errorf("%s %s: unknown product %q", r.Operation, r.Currency, r.FreeInternalFrom)
+		}
+		if r.Operation != OpTransfer {
+			return fmt.Errorf("%s %s: free_internal_from applies to transfers only", r.Operation, r.Currency)
+		}
+	}
+	return nil
+}
+
+// проверка набора тарифов: не больше одного на операцию и валюту
+func ValidateRules(rules []Rule) error {
+	seen := make(map[[2]string]bool)
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return err
+		}
+		key := [2]string{rules[i].Operation, rules[i].Currency}
+		if seen[key] {
+			return fmt.Errorf("duplicate rule for %s in %s", key[0], key[1])
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// тариф на операцию kind в валюте currency; nil — операция бесплатна
+func Find(rules []Rule, kind, currency string) *Rule {
+	for i := range rules {
+		if rules[i].Operation == kind && rules[i].Currency == currency {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// нужен ли тарифу месячный оборот клиента
+func (r *Rule) UsesVolume() bool {
+	return len(r.Tiers) > 0
+}
+
+// комиссия за операцию в валюте её суммы; процентная часть округляется
+// до минимальной единицы половиной вверх. общая для всех хранилищ
+func (r *Rule) Fee(op Operation) (money.Money, error) {
+	zero := money.Zero(op.Amount.Currency)
+	if op.Amount.Currency != r.Currency {
+		return zero, fmt.Errorf("fee rule is in %s, amount is in %s", r.Currency, op.Amount.Currency)
+	}
+	if op.Internal && r.FreeInternalFrom != "" && productLevel(op.Product) >= productLevels[r.FreeInternalFrom] {
+		return zero, nil
+	}
+
+	percent, _ := parsePercent(r.Percent)
+	for _, tier := range r.Tiers {
+		volume, _ := parseAmount(tier.Volume, r.Currency)
+		if op.MonthlyVolume.Amount >= volume.Amount {
+			percent, _ = parsePercent(tier.Percent)
+		}
+	}
+
+	flat, _ := parseAmount(r.Flat, r.Currency)
+	min, _ := parseAmount(r.Min, r.Currency)
+	max, _ := parseAmount(r.Max, r.Currency)
+
+	fee := flat
+	fee.Amount += percentOf(op.Amount.Amount, percent)
+	if fee.Amount < min.Amount {
+		fee.Amount = min.Amount
+	}
+	if !max.IsZero() && fee.Amount > max.Amount {
+		fee.Amount = max.Amount
+	}
+	return fee, nil
+}
+
+// amount × percent / 100 в минимальных единицах, половина вверх
+func percentOf(amount int64, percent *big.Rat) int64 {
+	value := new(big.Rat).Mul(big.NewRat(amount, 100), percent)
+	minor := new(big.Int).Quo(
+		new(big.Int).Add(new(big.Int).Mul(value.Num(), big.NewInt(2)), value.Denom()),
+		new(big.Int).Mul(value.Denom(), big.NewInt(2)),
+	)
+	return minor.Int64()
+}
+
+// продукт без названия — расчётный счёт
+func productLevel(product string) int {
+	return productLevels[product]
+}
+
+// неотрицательная сумма; пустая строка — ноль
+func parseAmount(s, currency string) (money.Money, error) {
+	if strings.TrimSpace(s) == "" {
+		return money.Zero(currency), nil
+	}
+	amount, err := money.Parse(s, currency)
+	if err != nil {
+		return amount, err
+	}
+	if amount.IsNegative() {
+		return amount, fmt.Errorf("must not be negative")
+	}
+	return amount, nil
+}
+
+// процент от 0 до 100; пустая строка — ноль
+func parsePercent(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return new(big.Rat), nil
+	}
+	percent, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "eE/") {
+		return nil, fmt.Errorf("invalid percent %q", s)
+	}
+	if percent.Sign() < 0 || percent.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("percent must be between 0 and 100")
+	}
+	return percent, nil
+}
+
+// загрузка тарифов из JSON-файла: [{"operation": "transfer", "currency": "KZT", "percent": "0.5"}]
+func LoadFile(filename string) ([]Rule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fee rules file: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse fee rules file: %v", err)
+	}
+	if err := ValidateRules(rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,184 @@
+package fees
+
+import (
+	"mfp/account"
+	"mfp/money"
+	"testing"
+)
+
+func kzt(amount int64) money.Money { return money.FromMinor(amount, "KZT") }
+
+func TestRuleFee(t *testing.T) {
+	tiered := Rule{
+		Operation: OpTransfer,
+		Currency:  "KZT",
+		Percent:   "1",
+		Tiers: []Tier{
+			{Volume: "100000", Percent: "0.5"},
+			{Volume: "1000000", Percent: "0.1"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		op      Operation
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "flat only",
+			rule: Rule{Operation: OpWithdrawal, Currency: "KZT", Flat: "150"},
+			op:   Operation{Kind: OpWithdrawal, Amount: kzt(100000)},
+			want: 15000,
+		},
+		{
+			name: "flat plus percent",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Flat: "1", Percent: "0.5"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000)},
+			want: 600,
+		},
+		{
+			name: "percent rounds half up",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "0.5"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100)}, // 0.5 минорной единицы
+			want: 1,
+		},
+		{
+			name: "percent rounds down below half",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "0.4"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100)},
+			want: 0,
+		},
+		{
+			name: "min cap",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "1", Min: "50"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000)},
+			want: 5000,
+		},
+		{
+			name: "max cap",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "1", Max: "500"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(10000000)},
+			want: 50000,
+		},
+		{
+			name: "between min and max",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "1", Min: "50", Max: "500"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(2000000)},
+			want: 20000,
+		},
+		{
+			name: "empty max is unlimited",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "1"},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(1000000000)},
+			want: 10000000,
+		},
+		{
+			name: "below first tier",
+			rule: tiered,
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000), MonthlyVolume: kzt(9999999)},
+			want: 1000,
+		},
+		{
+			name: "first tier reached exactly",
+			rule: tiered,
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000), MonthlyVolume: kzt(10000000)},
+			want: 500,
+		},
+		{
+			name: "highest tier reached",
+			rule: tiered,
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000), MonthlyVolume: kzt(500000000)},
+			want: 100,
+		},
+		{
+			name: "internal transfer from eligible product is free",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Flat: "100", FreeInternalFrom: account.ProductSavings},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000), Internal: true, Product: account.ProductDeposit},
+			want: 0,
+		},
+		{
+			name: "internal transfer from lower product is charged",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Flat: "100", FreeInternalFrom: account.ProductSavings},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000), Internal: true, Product: account.ProductCurrent},
+			want: 10000,
+		},
+		{
+			name: "external transfer is charged",
+			rule: Rule{Operation: OpTransfer, Currency: "KZT", Flat: "100", FreeInternalFrom: account.ProductCurrent},
+			op:   Operation{Kind: OpTransfer, Amount: kzt(100000), Product: account.ProductDeposit},
+			want: 10000,
+		},
+		{
+			name:    "currency mismatch",
+			rule:    Rule{Operation: OpTransfer, Currency: "USD", Flat: "1"},
+			op:      Operation{Kind: OpTransfer, Amount: kzt(100000)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+			got, err := tt.rule.Fee(tt.op)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Fee() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fee() unexpected error: %v", err)
+			}
+			if got.Amount != tt.want || got.Currency != tt.op.Amount.Currency {
+				t.Errorf("Fee() = %s, want %s", got.Format(), kzt(tt.want).Format())
+			}
+		})
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{name: "valid", rule: Rule{Operation: OpTransfer, Currency: "kzt", Percent: "0.5", Min: "1", Max: "10"}},
+		{name: "unknown operation", rule: Rule{Operation: "deposit", Currency: "KZT"}, wantErr: true},
+		{name: "unsupported currency", rule: Rule{Operation: OpTransfer, Currency: "GBP"}, wantErr: true},
+		{name: "negative flat", rule: Rule{Operation: OpTransfer, Currency: "KZT", Flat: "-1"}, wantErr: true},
+		{name: "percent above 100", rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "101"}, wantErr: true},
+		{name: "fractional percent notation", rule: Rule{Operation: OpTransfer, Currency: "KZT", Percent: "1/2"}, wantErr: true},
+		{name: "min above max", rule: Rule{Operation: OpTransfer, Currency: "KZT", Min: "10", Max: "5"}, wantErr: true},
+		{
+			name:    "tiers not increasing",
+			rule:    Rule{Operation: OpTransfer, Currency: "KZT", Tiers: []Tier{{Volume: "10", Percent: "1"}, {Volume: "10", Percent: "0.5"}}},
+			wantErr: true,
+		},
+		{
+			name:    "free internal on withdrawal",
+			rule:    Rule{Operation: OpWithdrawal, Currency: "KZT", FreeInternalFrom: account.ProductCurrent},
+			wantErr: true,
+		},
+		{
+			name:    "unknown product",
+			rule:    Rule{Operation: OpTransfer, Currency: "KZT", FreeInternalFrom: "gold"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatal("Validate() = nil, want error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+		})
+	}
+}
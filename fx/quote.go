@@ -0,0 +1,88 @@
+package fx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mfp/money"
+	"time"
+)
+
+// котировка не найдена
+var ErrQuoteNotFound = errors.New("quote not found")
+
+// котировка обмена: фиксирует курс на время действия
+type Quote struct {
+	ID        string      `json:"id"`
+	AccountID string      `json:"account_id"` // счёт, с которого будет списана сумма
+	Sell      money.Money `json:"sell"`       // списывается в валюте отправителя
+	Buy       money.Money `json:"buy"`        // зачисляется в валюте получателя
+	Rate      string      `json:"rate"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	UsedAt    *time.Time  `json:"used_at,omitempty"`
+}
+
+// расчёт обмена по текущим курсам: курс и сумма в валюте to
+func Exchange(rates []Rate, sell money.Money, to string) (string, money.Money, error) {
+	rate, err := Find(rates, sell.Currency, to)
+	if err != nil {
+		return "", money.Money{}, err
+	}
+	buy, err := Convert(sell, to, rate)
+	if err != nil {
+		return "", money.Money{}, err
+	}
+	if !buy.IsPositive() {
+		return "", money.Money{}, fmt.Errorf("amount is too small to exchange")
+	}
+	return rate, buy, nil
+}
+
+// новая котировка, действующая ttl
+func NewQuote(rates []Rate, accountID string, sell money.Money, to string, ttl time.Duration) (*Quote, error) {
+	if !sell.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	rate, buy, err := Exchange(rates, sell, to)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Quote{
+		ID:        generateQuoteID(),
+		AccountID: accountID,
+		Sell:      sell,
+		Buy:       buy,
+		Rate:      rate,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// проверка, что котировку можно исполнить этим переводом
+func (q *Quote) Check(accountID string, sell money.Money, to string, now time.Time) error {
+	switch {
+	case q.UsedAt != nil:
+		return fmt.Errorf("quote %s has already been used", q.ID)
+	case now.After(q.ExpiresAt):
+		return fmt.Errorf("quote %s has expired", q.ID)
+	case q.AccountID != accountID:
+		return fmt.Errorf("quote %s was issued for another account", q.ID)
+	case q.Sell != sell:
+		return fmt.Errorf("quote %s is for %s, not %s", q.ID, q.Sell.Format(), sell.Format())
+	case q.Buy.Currency != money.NormalizeCurrency(to):
+		return fmt.Errorf("quote %s buys %s, receiver account is in %s", q.ID, q.Buy.Currency, to)
+	}
+	return nil
+}
+
+func generateQuoteID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,134 @@
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mfp/money"
+	"os"
+	"strings"
+	"time"
+)
+
+// максимальное число знаков после запятой в курсе
+const rateDigits = 10
+
+// курс обмена: сколько единиц To дают за одну единицу From
+type Rate struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// разбор курса: положительное десятичное число
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if _, frac, _ := strings.Cut(s, "."); len(frac) > rateDigits {
+		return nil, fmt.Errorf("rate must have at most %d fractional digits", rateDigits)
+	}
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return nil, fmt.Errorf("invalid rate %q", s)
+	}
+	if rate.Sign() <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	return rate, nil
+}
+
+// проверка и нормализация курса перед сохранением
+func (r *Rate) Validate() error {
+	r.From = money.NormalizeCurrency(r.From)
+	r.To = money.NormalizeCurrency(r.To)
+	if err := money.ValidateCurrency(r.From); err != nil {
+		return err
+	}
+	if err := money.ValidateCurrency(r.To); err != nil {
+		return err
+	}
+	if r.From == r.To {
+		return fmt.Errorf("rate %s/%s converts a currency to itself", r.From, r.To)
+	}
+	if _, err := ParseRate(r.Rate); err != nil {
+		return fmt.Errorf("%s/%s: %v", r.From, r.To, err)
+	}
+	return nil
+}
+
+// курс для пары валют; при отсутствии прямого берётся обратный
+func Find(rates []Rate, from, to string) (string, error) {
+	from, to = money.NormalizeCurrency(from), money.NormalizeCurrency(to)
+	for _, r := range rates {
+		if r.From == from && r.To == to {
+			return r.Rate, nil
+		}
+	}
+	for _, r := range rates {
+		if r.From == to && r.To == from {
+			rate, err := ParseRate(r.Rate)
+			if err != nil {
+				return "", err
+			}
+			return formatRate(new(big.Rat).Inv(rate)), nil
+		}
+	}
+	return "", fmt.Errorf("no exchange rate for %s/%s", from, to)
+}
+
+// конвертация суммы по курсу с округлением до минорных единиц
+// (половина округляется от нуля)
+func Convert(amount money.Money, to, rate string) (money.Money, error) {
+	r, err := ParseRate(rate)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(amount.Amount), r)
+	num := new(big.Int).Abs(product.Num())
+	quo, rem := new(big.Int).QuoRem(num, product.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(product.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if product.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	if !quo.IsInt64() {
+		return money.Money{}, fmt.Errorf("amount overflow")
+	}
+	return money.FromMinor(quo.Int64(), to), nil
+}
+
+// загрузка курсов из JSON-файла: [{"from": "USD", "to": "KZT", "rate": "470.50"}]
+func LoadFile(filename string) ([]Rate, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var rates []Rate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file: %v", err)
+	}
+	for i := range rates {
+		if err := rates[i].Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return rates, nil
+}
+
+// каноническая запись курса, прочитанного из NUMERIC: без лишних нулей
+func CanonicalRate(s string) string {
+	rate, err := ParseRate(s)
+	if err != nil {
+		return s
+	}
+	return formatRate(rate)
+}
+
+// десятичная запись курса без лишних нулей в конце
+func formatRate(rate *big.Rat) string {
+	s := strings.TrimRight(rate.FloatString(rateDigits), "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"mfp/storage"
+	"time"
+)
+
+// снятие блокировок по картам, которые продавец не списал в срок
+type HoldExpiry struct {
+	store    storage.Store
+	interval time.Duration
+}
+
+func NewHoldExpiry(store storage.Store, interval time.Duration) *HoldExpiry {
+	return &HoldExpiry{store: store, interval: interval}
+}
+
+func (j *HoldExpiry) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := j.store.ExpireHolds(time.Now())
+		if err != nil {
+			log.Printf("Hold expiry: %v", err)
+		} else if expired > 0 {
+			log.Printf("Released %d expired card hold(s)", expired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
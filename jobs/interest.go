@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"mfp/account"
+	"mfp/storage"
+	"time"
+)
+
+// ежедневное начисление процентов по накопительным счетам и вкладам
+// за каждый завершившийся день; пропущенные дни после простоя
+// начисляются при следующем запуске по остаткам на конец тех дней
+type InterestAccrual struct {
+	store    storage.Store
+	interval time.Duration
+}
+
+func NewInterestAccrual(store storage.Store, interval time.Duration) *InterestAccrual {
+	return &InterestAccrual{store: store, interval: interval}
+}
+
+func (j *InterestAccrual) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.AccrueThrough(account.UTCDate(time.Now()).AddDate(0, 0, -1)); err != nil {
+			log.Printf("Interest accrual: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// начисление по день day включительно по всем подключённым счетам;
+// возвращает число счетов, на которые зачислены проценты
+func (j *InterestAccrual) AccrueThrough(day time.Time) (int, error) {
+	accounts, err := j.store.GetAccounts()
+	if err != nil {
+		return 0, err
+	}
+
+	day = account.UTCDate(day)
+	credited := 0
+	for _, acc := range accounts {
+		if acc.Accrual == nil || !acc.Accrual.Through.Before(day) || acc.EffectiveStatus() == account.StatusClosed {
+			continue
+		}
+		interest, err := j.store.AccrueInterest(acc.ID, day)
+		if err != nil {
+			log.Printf("Interest accrual for %s: %v", acc.ID, err)
+			continue
+		}
+		if interest.IsPositive() {
+			credited++
+		}
+	}
+	if credited > 0 {
+		log.Printf("Interest capitalized on %d account(s) through %s", credited, day.Format("2006-01-02"))
+	}
+	return credited, nil
+}
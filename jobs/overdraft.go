@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"mfp/account"
+	"mfp/storage"
+	"time"
+)
+
+// ежедневное начисление процентов на отрицательные остатки на конец
+// каждого завершившегося дня; пропущенные дни после простоя начисляются
+// при следующем запуске, а хранилище помнит последний обработанный день
+// и не списывает проценты за день дважды
+type OverdraftInterest struct {
+	store    storage.Store
+	interval time.Duration
+}
+
+func NewOverdraftInterest(store storage.Store, interval time.Duration) *OverdraftInterest {
+	return &OverdraftInterest{store: store, interval: interval}
+}
+
+func (j *OverdraftInterest) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.ChargeThrough(account.UTCDate(time.Now()).AddDate(0, 0, -1)); err != nil {
+			log.Printf("Overdraft interest: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// начисление по день day включительно по всем счетам; возвращает
+// число счетов, с которых списаны проценты
+func (j *OverdraftInterest) ChargeThrough(day time.Time) (int, error) {
+	accounts, err := j.store.GetAccounts()
+	if err != nil {
+		return 0, err
+	}
+
+	day = account.UTCDate(day)
+	charged := 0
+	for _, acc := range accounts {
+		if acc.OverdraftThrough != nil && !acc.OverdraftThrough.Before(day) {
+			continue
+		}
+		interest, err := j.store.ChargeOverdraftInterest(acc.ID, day)
+		if err != nil {
+			log.Printf("Overdraft interest for %s: %v", acc.ID, err)
+			continue
+		}
+		if !interest.IsZero() {
+			charged++
+		}
+	}
+	if charged > 0 {
+		log.Printf("Overdraft interest charged on %d account(s) through %s", charged, day.Format("2006-01-02"))
+	}
+	return charged, nil
+}
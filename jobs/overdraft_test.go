@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"mfp/account"
+	"mfp/storage"
+	"testing"
+	"time"
+)
+
+// счёт с историей за 1–4 марта: ставка 36.5% даёт в день тысячную долю долга.
+// 1 марта — минус 1000.00; 3 марта долг погашен днём, к концу дня остаток
+// положительный; 4 марта снова минус
+func overdraftAccount() *account.Account {
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC) }
+	through := at(0, 0) // 28 февраля
+
+	acc := testAccount(1, kzt(-50000))
+	acc.OverdraftLimit = kzt(200000)
+	acc.OverdraftRate = "36.5"
+	acc.OverdraftThrough = &through
+	acc.Transactions = []account.Transaction{
+		{ID: 1, Type: account.TypeWithdrawal, FromAccount: acc.ID, Amount: kzt(100000), Timestamp: at(1, 10), Status: "completed"},
+		{ID: 2, Type: account.TypeDeposit, ToAccount: acc.ID, Amount: kzt(200000), Timestamp: at(3, 12), Status: "completed"},
+		{ID: 3, Type: account.TypeWithdrawal, FromAccount: acc.ID, Amount: kzt(150000), Timestamp: at(4, 23), Status: "completed"},
+	}
+	return acc
+}
+
+func TestOverdraftInterestChargeThrough(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	// 1 марта: −1000.00 → 1.00; 2 марта: −1001.00 → 1.00;
+	// 3 марта: +998.00 → 0; 4 марта: −502.00 → 0.50
+	const wantBalance = -50250
+
+	tests := []struct {
+		name string
+		runs []time.Time
+	}{
+		{name: "daily", runs: []time.Time{day(1), day(2), day(3), day(4)}},
+		{name: "after downtime", runs: []time.Time{day(4)}},
+		{name: "repeated", runs: []time.Time{day(2), day(4), day(4), day(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			acc := overdraftAccount()
+			if err := store.CreateAccount(acc); err != nil {
+				t.Fatalf("CreateAccount: %v", err)
+			}
+
+			job := NewOverdraftInterest(store, time.Hour)
+			for _, run := range tt.runs {
+				if _, err := job.ChargeThrough(run); err != nil {
+					t.Fatalf("ChargeThrough(%s): %v", run.Format("2006-01-02"), err)
+				}
+			}
+
+			got, err := store.GetAccount(acc.ID)
+			if err != nil {
+				t.Fatalf("GetAccount: %v", err)
+			}
+			if got.Balance.Amount != wantBalance {
+				t.Errorf("balance = %d, want %d", got.Balance.Amount, wantBalance)
+			}
+
+			var charges []time.Time
+			for _, tx := range got.Transactions {
+				if tx.Type == account.TypeOverdraftInterest {
+					charges = append(charges, tx.Timestamp)
+				}
+			}
+			want := []time.Time{day(2), day(3), day(5)} // за день списывается в начале следующего
+			if len(charges) != len(want) {
+				t.Fatalf("charges at %v, want %v", charges, want)
+			}
+			for i := range want {
+				if !charges[i].Equal(want[i]) {
+					t.Errorf("charge %d at %v, want %v", i, charges[i], want[i])
+				}
+			}
+			if got.OverdraftThrough == nil || !got.OverdraftThrough.Equal(day(4)) {
+				t.Errorf("OverdraftThrough = %v, want %v", got.OverdraftThrough, day(4))
+			}
+		})
+	}
+}
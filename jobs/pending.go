@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"mfp/storage"
+	"time"
+)
+
+// отметка крупных переводов, которые клиент не подтвердил в срок
+type PendingTransferExpiry struct {
+	store    storage.Store
+	interval time.Duration
+}
+
+func NewPendingTransferExpiry(store storage.Store, interval time.Duration) *PendingTransferExpiry {
+	return &PendingTransferExpiry{store: store, interval: interval}
+}
+
+func (j *PendingTransferExpiry) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := j.store.ExpirePendingTransfers(time.Now())
+		if err != nil {
+			log.Printf("Pending transfer expiry: %v", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d unconfirmed transfer(s)", expired)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
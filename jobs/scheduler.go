@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mfp/pending"
+	"mfp/schedule"
+	"mfp/storage"
+	"time"
+)
+
+// сколько переводов берётся за один проход
+const batchSize = 100
+
+// исполнитель запланированных переводов; несколько экземпляров
+// сервера могут работать одновременно — хранилище выдаёт каждый
+// перевод одному из них, а ключ идемпотентности повтора защищает
+// от двойного списания, если блокировка истекла посреди запуска
+type Scheduler struct {
+	store    storage.Store
+	interval time.Duration
+	retry    schedule.RetryPolicy
+	lease    time.Duration
+
+	ConfirmThreshold string // порог подтверждения по умолчанию, как у сервера
+}
+
+func NewScheduler(store storage.Store, interval time.Duration, retry schedule.RetryPolicy) *Scheduler {
+	lease := 5 * time.Minute
+	if interval > lease {
+		lease = interval
+	}
+	return &Scheduler{store: store, interval: interval, retry: retry, lease: lease}
+}
+
+// проходы с интервалом до отмены контекста
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunDue(time.Now()); err != nil {
+			log.Printf("Scheduled transfers: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// исполнение переводов, срок которых наступил; возвращает число обработанных
+func (s *Scheduler) RunDue(now time.Time) (int, error) {
+	transfers, err := s.store.ClaimDueTransfers(now, batchSize, s.lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range transfers {
+		s.execute(t, now)
+		if err := s.store.SaveScheduledRun(t); err != nil {
+			log.Printf("Scheduled transfer %d: %v", t.ID, err)
+		}
+	}
+	return len(transfers), nil
+}
+
+func (s *Scheduler) execute(t *schedule.Transfer, now time.Time) {
+	// порог могли снизить после создания перевода: подтвердить платёж
+	// некому, поэтому перевод встаёт на паузу, а деньги не списываются
+	if acc, err := s.store.GetAccount(t.AccountID); err == nil &&
+		pending.RequiresConfirmation(t.Amount, pending.Threshold(acc, s.ConfirmThreshold)) {
+		t.Paused("amount exceeds the confirmation threshold", now)
+		s.notify(t, "scheduled_transfer_paused",
+			fmt.Sprintf("Scheduled transfer #%d of %s to %s is paused: the amount exceeds the confirmation threshold",
+				t.ID, t.Amount.Format(), t.ToAccount))
+		return
+	}
+
+	idem, err := idempotencyFor(t)
+	if err != nil {
+		log.Printf("Scheduled transfer %d: %v", t.ID, err)
+		return
+	}
+
+	_, err = s.store.Transfer(t.AccountID, t.ToAccount, t.Amount, "", idem)
+	if err == nil {
+		t.Succeeded(now)
+		if t.Status == schedule.StatusCompleted {
+			s.notify(t, "scheduled_transfer_completed",
+				fmt.Sprintf("Scheduled transfer #%d of %s to %s is completed", t.ID, t.Amount.Format(), t.ToAccount))
+		}
+		return
+	}
+
+	if !t.Failed(err, s.retry, now) {
+		log.Printf("Scheduled transfer %d failed, retry at %s: %v", t.ID, t.NextRunAt.Format(time.RFC3339), err)
+		return
+	}
+
+	message := fmt.Sprintf("Scheduled transfer #%d of %s to %s failed after %d attempt(s): %v",
+		t.ID, t.Amount.Format(), t.ToAccount, s.retry.MaxAttempts, err)
+	if t.Status == schedule.StatusActive {
+		message += "; the next payment is due " + t.NextRunAt.Format("2006-01-02 15:04")
+	}
+	s.notify(t, "scheduled_transfer_failed", message)
+}
+
+// уведомление получает основной аккаунт, даже если списание с валютного счёта
+func (s *Scheduler) notify(t *schedule.Transfer, kind, message string) {
+	recipient := t.AccountID
+	if acc, err := s.store.GetAccount(t.AccountID); err == nil && acc.OwnerID != "" {
+		recipient = acc.OwnerID
+	}
+
+	if err := s.store.AddNotification(&storage.Notification{
+		AccountID: recipient,
+		Kind:      kind,
+		Message:   message,
+	}); err != nil {
+		log.Printf("Scheduled transfer %d: failed to notify: %v", t.ID, err)
+	}
+}
+
+// ключ привязан к повтору, а хеш — к параметрам перевода
+func idempotencyFor(t *schedule.Transfer) (*storage.Idempotency, error) {
+	payload, err := json.Marshal(map[string]any{"id": t.ID, "to": t.ToAccount, "amount": t.Amount})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]any{"scheduled_transfer_id": t.ID, "occurrence": t.Occurrence})
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.Idempotency{
+		Key:         t.IdempotencyKey(),
+		Endpoint:    "scheduled_transfer",
+		RequestHash: hex.EncodeToString(sum[:]),
+		Response:    storage.StoredResponse{StatusCode: 200, Body: body},
+	}, nil
+}
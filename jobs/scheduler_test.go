@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"mfp/schedule"
+	"mfp/storage"
+	"testing"
+	"time"
+)
+
+func kzt(amount int64) money.Money { return money.FromMinor(amount, money.DefaultCurrency) }
+
+func testAccount(n int, balance money.Money) *account.Account {
+	return &account.Account{
+		ID:           fmt.Sprintf("KZ%014d", n),
+		Password:     "-",
+		Balance:      balance,
+		Name:         "Test",
+		Phone:        fmt.Sprintf("7700000%04d", n),
+		Age:          30,
+		Role:         account.RoleCustomer,
+		Status:       account.StatusActive,
+		CreatedAt:    time.Now(),
+		ExpiredAt:    time.Now().AddDate(5, 0, 0),
+		Transactions: []account.Transaction{},
+	}
+}
+
+func createAccount(t *testing.T, store storage.Store, n int, balance money.Money) *account.Account {
+	t.Helper()
+	acc := testAccount(n, balance)
+	if err := store.CreateAccount(acc); err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	return acc
+}
+
+// перевод больше порога, сниженного после создания, встаёт на паузу
+// с уведомлением и денег не списывает
+func TestSchedulerConfirmThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		threshold   string       // порог по умолчанию
+		own         *money.Money // порог счёта
+		wantStatus  string
+		wantBalance int64
+		wantNotice  string
+	}{
+		{
+			name:        "no threshold",
+			wantStatus:  schedule.StatusCompleted,
+			wantBalance: 90000,
+			wantNotice:  "scheduled_transfer_completed",
+		},
+		{
+			name:        "below default threshold",
+			threshold:   "500",
+			wantStatus:  schedule.StatusCompleted,
+			wantBalance: 90000,
+			wantNotice:  "scheduled_transfer_completed",
+		},
+		{
+			name:        "above default threshold",
+			threshold:   "50",
+			wantStatus:  schedule.StatusPaused,
+			wantBalance: 100000,
+			wantNotice:  "scheduled_transfer_paused",
+		},
+		{
+			name:        "above own threshold",
+			threshold:   "500",
+			own:         &money.Money{Amount: 5000, Currency: money.DefaultCurrency},
+			wantStatus:  schedule.StatusPaused,
+			wantBalance: 100000,
+			wantNotice:  "scheduled_transfer_paused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			from := createAccount(t, store, 1, kzt(100000))
+			to := createAccount(t, store, 2, kzt(0))
+			if tt.own != nil {
+				if err := store.SetConfirmThreshold(from.ID, tt.own); err != nil {
+					t.Fatalf("SetConfirmThreshold: %v", err)
+				}
+			}
+
+			now := time.Now()
+			transfer, err := schedule.New(from.ID, to.ID, kzt(10000), schedule.Once, now.Add(-time.Minute), nil, 0)
+			if err != nil {
+				t.Fatalf("schedule.New: %v", err)
+			}
+			if err := store.CreateScheduledTransfer(transfer); err != nil {
+				t.Fatalf("CreateScheduledTransfer: %v", err)
+			}
+
+			scheduler := NewScheduler(store, time.Minute, schedule.RetryPolicy{MaxAttempts: 1})
+			scheduler.ConfirmThreshold = tt.threshold
+			if n, err := scheduler.RunDue(now); err != nil || n != 1 {
+				t.Fatalf("RunDue = %d, %v", n, err)
+			}
+
+			got, err := store.GetScheduledTransfer(transfer.ID)
+			if err != nil {
+				t.Fatalf("GetScheduledTransfer: %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			acc, err := store.GetAccount(from.ID)
+			if err != nil {
+				t.Fatalf("GetAccount: %v", err)
+			}
+			if acc.Balance.Amount != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", acc.Balance.Amount, tt.wantBalance)
+			}
+			notifications, err := store.GetNotifications(from.ID, 10)
+			if err != nil {
+				t.Fatalf("GetNotifications: %v", err)
+			}
+			if len(notifications) != 1 || notifications[0].Kind != tt.wantNotice {
+				t.Errorf("notifications = %+v, want one %s", notifications, tt.wantNotice)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// длина мастер-ключа и ключей данных (AES-256)
+const keySize = 32
+
+// ключ данных с такой версией в связке не найден
+var ErrUnknownVersion = errors.New("unknown data key version")
+
+// связка ключей для шифрования полей: ключи данных AES-GCM, каждый под
+// своей версией, хранятся в файле зашифрованными мастер-ключом. новые
+// значения шифруются текущей версией, старые версии нужны для чтения
+// до перешифрования
+type Keyring struct {
+	mu      sync.RWMutex
+	master  cipher.AEAD // шифрует ключи данных; nil — связка только в памяти
+	file    string
+	keys    map[int][]byte
+	current int
+	index   []byte // ключ слепого индекса для поиска по зашифрованным полям; не ротируется
+}
+
+// файл связки: ключи данных, зашифрованные мастер-ключом
+type keyringFile struct {
+	Current  int          `json:"current"`
+	IndexKey string       `json:"index_key"`
+	Keys     []wrappedKey `json:"keys"`
+}
+
+type wrappedKey struct {
+	Version int    `json:"version"`
+	Key     string `json:"key"`
+}
+
+// открытие связки по файлу мастер-ключа и файлу ключей данных;
+// отсутствующие файлы создаются с новыми ключами
+func Open(masterKeyFile, keyringFile string) (*Keyring, error) {
+	master, err := LoadKeyFile(masterKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(master)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{master: aead, file: keyringFile}
+	err = k.load()
+	if errors.Is(err, os.ErrNotExist) {
+		k.keys = map[int][]byte{1: randomKey()}
+		k.current = 1
+		k.index = randomKey()
+		err = k.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// связка со случайными ключами только в памяти процесса, для хранилища
+// без данных на диске
+func NewEphemeral() *Keyring {
+	return &Keyring{keys: map[int][]byte{1: randomKey()}, current: 1, index: randomKey()}
+}
+
+// текущая версия ключа данных
+func (k *Keyring) Current() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// новый ключ данных становится текущим; прежние остаются для чтения
+func (k *Keyring) Rotate() (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.master == nil {
+		return 0, fmt.Errorf("in-memory keyring cannot be rotated")
+	}
+	// другой процесс мог повернуть ключ после загрузки связки
+	if err := k.loadLocked(); err != nil {
+		return 0, err
+	}
+	k.current++
+	k.keys[k.current] = randomKey()
+	if err := k.saveLocked(); err != nil {
+		return 0, err
+	}
+	return k.current, nil
+}
+
+// шифрование значения текущим ключом; context (например, ID записи)
+// привязывает шифротекст к записи, чтобы его нельзя было перенести в другую
+func (k *Keyring) Encrypt(plaintext, context string) (string, int, error) {
+	k.mu.RLock()
+	version, key := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", 0, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", 0, err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), additionalData(version, context))
+	return base64.StdEncoding.EncodeToString(sealed), version, nil
+}
+
+func (k *Keyring) Decrypt(ciphertext string, version int, context string) (string, error) {
+	key, err := k.key(version)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %v", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid ciphertext")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData(version, context))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt with key version %d: %v", version, err)
+	}
+	return string(plaintext), nil
+}
+
+// необратимый код значения для проверки без хранения, например CVC2:
+// HMAC-SHA256 текущим ключом данных
+func (k *Keyring) Digest(value, context string) (string, int) {
+	k.mu.RLock()
+	version, key := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+	return digest(key, value, context), version
+}
+
+// совпадает ли значение с кодом, полученным Digest
+func (k *Keyring) Verify(mac string, version int, value, context string) bool {
+	key, err := k.key(version)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(digest(key, value, context)))
+}
+
+// слепой индекс для точного поиска по зашифрованному значению
+func (k *Keyring) BlindIndex(value string) string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return digest(k.index, value, "index")
+}
+
+// ключ данных по версии; неизвестную версию мог добавить другой
+// процесс, поэтому файл связки перечитывается
+func (k *Keyring) key(version int) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.keys[version]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.master != nil {
+		if err := k.loadLocked(); err != nil {
+			return nil, err
+		}
+	}
+	if key, ok = k.keys[version]; !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+	return key, nil
+}
+
+func (k *Keyring) load() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.loadLocked()
+}
+
+func (k *Keyring) loadLocked() error {
+	data, err := os.ReadFile(k.file)
+	if err != nil {
+		return err
+	}
+	var f keyringFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("failed to parse keyring %s: %v", k.file, err)
+	}
+
+	keys := make(map[int][]byte, len(f.Keys))
+	for _, wrapped := range f.Keys {
+		if keys[wrapped.Version], err = k.unwrap(wrapped.Key, "data:"+strconv.Itoa(wrapped.Version)); err != nil {
+			return fmt.Errorf("failed to unwrap data key %d: %v", wrapped.Version, err)
+		}
+	}
+	if _, ok := keys[f.Current]; !ok {
+		return fmt.Errorf("keyring %s has no current key %d", k.file, f.Current)
+	}
+	index, err := k.unwrap(f.IndexKey, "index")
+	if err != nil {
+		return fmt.Errorf("failed to unwrap index key: %v", err)
+	}
+	k.keys, k.current, k.index = keys, f.Current, index
+	return nil
+}
+
+func (k *Keyring) save() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.saveLocked()
+}
+
+// запись во временный файл и атомарная замена, как у файла данных
+func (k *Keyring) saveLocked() error {
+	f := keyringFile{Current: k.current, IndexKey: k.wrap(k.index, "index")}
+	for version := 1; version <= k.current; version++ {
+		if key, ok := k.keys[version]; ok {
+			f.Keys = append(f.Keys, wrappedKey{Version: version, Key: k.wrap(key, "data:"+strconv.Itoa(version))})
+		}
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(k.file, data)
+}
+
+// шифрование ключа мастер-ключом; label не даёт подменить ключ одной
+// версии ключом другой
+func (k *Keyring) wrap(key []byte, label string) string {
+	nonce := make([]byte, k.master.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("Nonce generation failed: %v", err))
+	}
+	return base64.StdEncoding.EncodeToString(k.master.Seal(nonce, nonce, key, []byte(label)))
+}
+
+func (k *Keyring) unwrap(wrapped, label string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < k.master.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	return k.master.Open(nil, data[:k.master.NonceSize()], data[k.master.NonceSize():], []byte(label))
+}
+
+// ключ в файле, например мастер-ключ: 32 байта в hex; при первом
+// запуске создаётся новый
+func LoadKeyFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		key := randomKey()
+		if err := writeFile(filename, []byte(hex.EncodeToString(key)+"\n")); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("key file %s must contain %d hex-encoded bytes", filename, keySize)
+	}
+	return key, nil
+}
+
+func writeFile(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to set file permissions: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to replace file: %v", err)
+	}
+	return nil
+}
+
+func randomKey() []byte {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("Key generation failed: %v", err))
+	}
+	return key
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(version int, context string) []byte {
+	return []byte(strconv.Itoa(version) + ":" + context)
+}
+
+func digest(key []byte, value, context string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(context + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
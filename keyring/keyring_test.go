@@ -0,0 +1,248 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// связка в отдельном каталоге теста
+func openTemp(t *testing.T) (*Keyring, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	master, file := filepath.Join(dir, "master.key"), filepath.Join(dir, "keyring.json")
+	k, err := Open(master, file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return k, master, file
+}
+
+// испорченный шифротекст: последний байт тега инвертирован
+func tamper(t *testing.T, ciphertext string) string {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := NewEphemeral()
+	ciphertext, version, err := k.Encrypt("77001234567", "KZ01")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("version = %d, want 1", version)
+	}
+
+	tests := []struct {
+		name       string
+		keyring    *Keyring
+		ciphertext string
+		version    int
+		context    string
+		want       string
+		wantErr    bool
+	}{
+		{name: "round trip", keyring: k, ciphertext: ciphertext, version: 1, context: "KZ01", want: "77001234567"},
+		{name: "other record", keyring: k, ciphertext: ciphertext, version: 1, context: "KZ02", wantErr: true},
+		{name: "tampered", keyring: k, ciphertext: tamper(t, ciphertext), version: 1, context: "KZ01", wantErr: true},
+		{name: "unknown version", keyring: k, ciphertext: ciphertext, version: 2, context: "KZ01", wantErr: true},
+		{name: "wrong key", keyring: NewEphemeral(), ciphertext: ciphertext, version: 1, context: "KZ01", wantErr: true},
+		{name: "not base64", keyring: k, ciphertext: "%%%", version: 1, context: "KZ01", wantErr: true},
+		{name: "too short", keyring: k, ciphertext: "AAAA", version: 1, context: "KZ01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(tt.ciphertext, tt.version, tt.context)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Decrypt = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// после ротации новые значения шифруются новой версией, старые читаются,
+// а связка, открытая заново из файла, знает обе версии
+func TestRotate(t *testing.T) {
+	k, master, file := openTemp(t)
+
+	old, oldVersion, err := k.Encrypt("old", "ctx")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	version, err := k.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if version != 2 || k.Current() != 2 {
+		t.Fatalf("Rotate = %d, current %d, want 2", version, k.Current())
+	}
+	fresh, freshVersion, err := k.Encrypt("new", "ctx")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if freshVersion != 2 {
+		t.Errorf("Encrypt after rotation used version %d, want 2", freshVersion)
+	}
+
+	reopened, err := Open(master, file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		keyring    *Keyring
+		ciphertext string
+		version    int
+		want       string
+	}{
+		{name: "old version", keyring: k, ciphertext: old, version: oldVersion, want: "old"},
+		{name: "new version", keyring: k, ciphertext: fresh, version: freshVersion, want: "new"},
+		{name: "old version reopened", keyring: reopened, ciphertext: old, version: oldVersion, want: "old"},
+		{name: "new version reopened", keyring: reopened, ciphertext: fresh, version: freshVersion, want: "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(tt.ciphertext, tt.version, "ctx")
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// версию, добавленную другим процессом, связка подхватывает из файла
+func TestRotateByOtherProcess(t *testing.T) {
+	k, master, file := openTemp(t)
+	other, err := Open(master, file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := other.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	ciphertext, version, err := other.Encrypt("value", "ctx")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	got, err := k.Decrypt(ciphertext, version, "ctx")
+	if err != nil || got != "value" {
+		t.Errorf("Decrypt = %q, %v, want %q", got, err, "value")
+	}
+	if _, err := k.Decrypt(ciphertext, version+1, "ctx"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Decrypt with missing version = %v, want %v", err, ErrUnknownVersion)
+	}
+}
+
+func TestOpenWrongMasterKey(t *testing.T) {
+	_, _, file := openTemp(t)
+	other := filepath.Join(t.TempDir(), "other.key")
+	if _, err := Open(other, file); err == nil {
+		t.Fatal("Open with another master key succeeded")
+	}
+}
+
+func TestRotateEphemeral(t *testing.T) {
+	if _, err := NewEphemeral().Rotate(); err == nil {
+		t.Fatal("Rotate of an in-memory keyring succeeded")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k, master, file := openTemp(t)
+	index := k.BlindIndex("77001234567")
+	if _, err := k.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	reopened, err := Open(master, file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		got   string
+		equal bool
+	}{
+		{name: "same value", got: k.BlindIndex("77001234567"), equal: true},
+		{name: "after rotation and reopen", got: reopened.BlindIndex("77001234567"), equal: true},
+		{name: "other value", got: k.BlindIndex("77001234568"), equal: false},
+		{name: "other keyring", got: NewEphemeral().BlindIndex("77001234567"), equal: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.got == index) != tt.equal {
+				t.Errorf("BlindIndex = %s, base %s, want equal %v", tt.got, index, tt.equal)
+			}
+		})
+	}
+}
+
+func TestDigestVerify(t *testing.T) {
+	k := NewEphemeral()
+	mac, version := k.Digest("123", "card:1")
+
+	tests := []struct {
+		name    string
+		mac     string
+		version int
+		value   string
+		context string
+		want    bool
+	}{
+		{name: "match", mac: mac, version: version, value: "123", context: "card:1", want: true},
+		{name: "wrong value", mac: mac, version: version, value: "124", context: "card:1"},
+		{name: "other card", mac: mac, version: version, value: "123", context: "card:2"},
+		{name: "unknown version", mac: mac, version: version + 1, value: "123", context: "card:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.Verify(tt.mac, tt.version, tt.value, tt.context); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	created := filepath.Join(dir, "created.key")
+	key, err := LoadKeyFile(created)
+	if err != nil {
+		t.Fatalf("LoadKeyFile: %v", err)
+	}
+	again, err := LoadKeyFile(created)
+	if err != nil || string(again) != string(key) {
+		t.Fatalf("second LoadKeyFile = %x, %v, want %x", again, err, key)
+	}
+
+	invalid := filepath.Join(dir, "invalid.key")
+	if err := os.WriteFile(invalid, []byte("abcd\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyFile(invalid); err == nil {
+		t.Error("LoadKeyFile accepted a short key")
+	}
+}
@@ -0,0 +1,203 @@
+package ledger
+
+import (
+	"database/sql"
+	"fmt"
+	"mfp/money"
+	"strings"
+	"time"
+)
+
+// системные счета банка; в таблице accounts их нет
+const (
+	CashInAccount   = "system:cash_in"  // внесение наличных
+	CashOutAccount  = "system:cash_out" // выдача наличных
+	FeesAccount     = "system:fees"     // комиссионный доход
+	FXAccount       = "system:fx"       // валютная позиция банка при обмене
+	InterestAccount = "system:interest" // процентные доходы и расходы банка
+	CardsAccount    = "system:cards"    // расчёты с продавцами по картам
+)
+
+// типы проводок
+const (
+	TypeDeposit  = "deposit"
+	TypeWithdraw = "withdraw"
+	TypeTransfer = "transfer"
+
+	TypeOverdraftInterest = "overdraft_interest"
+	TypeInterest          = "interest"
+	TypeFee               = "fee"
+	TypeCardPayment       = "card_payment"
+)
+
+// проверка, является ли счёт системным
+func IsSystemAccount(accountID string) bool {
+	return strings.HasPrefix(accountID, "system:")
+}
+
+// движение по одному счёту внутри проводки:
+// положительная сумма увеличивает баланс счёта, отрицательная уменьшает
+type Posting struct {
+	AccountID string
+	Amount    money.Money
+}
+
+// журнальная проводка из сбалансированных движений
+type Entry struct {
+	ID        int64
+	Type      string
+	Status    string
+	CreatedAt time.Time
+	Postings  []Posting
+
+	Rate    string // курс обмена, если проводка затрагивает две валюты
+	QuoteID string // исполненная котировка
+	HoldID  int64  // блокировка по карте, по которой проведено списание
+}
+
+// интерфейс, которому удовлетворяют *sql.DB и *sql.Tx
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// создание новой проводки
+func NewEntry(entryType string) *Entry {
+	return &Entry{Type: entryType, Status: "completed", CreatedAt: time.Now()}
+}
+
+// добавление движения по счёту
+func (e *Entry) Add(accountID string, amount money.Money) *Entry {
+	e.Postings = append(e.Postings, Posting{AccountID: accountID, Amount: amount})
+	return e
+}
+
+// перемещение суммы с одного счёта на другой
+func (e *Entry) Move(from, to string, amount money.Money) *Entry {
+	return e.Add(from, amount.Neg()).Add(to, amount)
+}
+
+// пополнение: деньги приходят с системного счёта внесения наличных
+func Deposit(accountID string, amount money.Money) *Entry {
+	return NewEntry(TypeDeposit).Move(CashInAccount, accountID, amount)
+}
+
+// снятие: деньги уходят на системный счёт выдачи наличных
+func Withdrawal(accountID string, amount money.Money) *Entry {
+	return NewEntry(TypeWithdraw).Move(accountID, CashOutAccount, amount)
+}
+
+// перевод между клиентскими счетами
+func Transfer(from, to string, amount money.Money) *Entry {
+	return NewEntry(TypeTransfer).Move(from, to, amount)
+}
+
+// проценты за овердрафт: списываются в процентный доход банка
+func OverdraftInterest(accountID string, amount money.Money) *Entry {
+	return NewEntry(TypeOverdraftInterest).Move(accountID, InterestAccount, amount)
+}
+
+// комиссия за операцию: списывается в комиссионный доход банка
+func Fee(accountID string, amount money.Money) *Entry {
+	return NewEntry(TypeFee).Move(accountID, FeesAccount, amount)
+}
+
+// капитализация процентов по вкладу: выплачивается из процентных расходов банка
+func Interest(accountID string, amount money.Money) *Entry {
+	return NewEntry(TypeInterest).Move(InterestAccount, accountID, amount)
+}
+
+// списание по блокировке карты: деньги уходят на расчёты с продавцами
+func CardPayment(accountID string, amount money.Money, holdID int64) *Entry {
+	e := NewEntry(TypeCardPayment).Move(accountID, CardsAccount, amount)
+	e.HoldID = holdID
+	return e
+}
+
+// перевод с конвертацией: в каждой валюте проводка сбалансирована
+// через валютную позицию банка
+func Exchange(from, to string, sell, buy money.Money, rate, quoteID string) *Entry {
+	e := NewEntry(TypeTransfer).Move(from, FXAccount, sell).Move(FXAccount, to, buy)
+	e.Rate = rate
+	e.QuoteID = quoteID
+	return e
+}
+
+// проверка проводки: не менее двух движений, нулевая сумма в каждой валюте
+func (e *Entry) Validate() error {
+	if e.Type == "" {
+		return fmt.Errorf("entry type is required")
+	}
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("entry must have at least two postings")
+	}
+
+	totals := make(map[string]money.Money)
+	for _, p := range e.Postings {
+		if p.AccountID == "" {
+			return fmt.Errorf("posting account is required")
+		}
+		if p.Amount.IsZero() {
+			return fmt.Errorf("posting amount must not be zero")
+		}
+		total, ok := totals[p.Amount.Currency]
+		if !ok {
+			total = money.Zero(p.Amount.Currency)
+		}
+		sum, err := total.Add(p.Amount)
+		if err != nil {
+			return fmt.Errorf("unbalanced entry: %v", err)
+		}
+		totals[p.Amount.Currency] = sum
+	}
+	for _, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("unbalanced entry: postings sum to %s", total.Format())
+		}
+	}
+	if len(totals) > 1 && e.Rate == "" {
+		return fmt.Errorf("multi-currency entry requires an exchange rate")
+	}
+	return nil
+}
+
+// запись проводки и обновление кэшированных балансов клиентских счетов;
+// вызывающий код должен заранее заблокировать затрагиваемые строки accounts
+func Post(q Querier, e *Entry) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	err := q.QueryRow(`
+        INSERT INTO journal_entries (type, status, created_at, fx_rate, quote_id, hold_id)
+        VALUES ($1, $2, $3, NULLIF($4, '')::NUMERIC, NULLIF($5, ''), NULLIF($6, 0)) RETURNING id`,
+		e.Type, e.Status, e.CreatedAt, e.Rate, e.QuoteID, e.HoldID,
+	).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record journal entry: %w", err)
+	}
+
+	for _, p := range e.Postings {
+		_, err := q.Exec(`
+            INSERT INTO postings (entry_id, account_id, amount, currency)
+            VALUES ($1, $2, $3, $4)`,
+			e.ID, p.AccountID, p.Amount, p.Amount.Currency,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record posting: %w", err)
+		}
+
+		if IsSystemAccount(p.AccountID) {
+			continue
+		}
+		result, err := q.Exec(`UPDATE accounts SET balance = balance + $1 WHERE id = $2`, p.Amount, p.AccountID)
+		if err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fmt.Errorf("account %s not found", p.AccountID)
+		}
+	}
+	return nil
+}
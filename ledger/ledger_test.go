@@ -0,0 +1,55 @@
+package ledger
+
+import (
+	"mfp/money"
+	"testing"
+)
+
+func kzt(amount int64) money.Money { return money.FromMinor(amount, "KZT") }
+func usd(amount int64) money.Money { return money.FromMinor(amount, "USD") }
+
+func TestEntryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   *Entry
+		wantErr bool
+	}{
+		{name: "deposit", entry: Deposit("A1", kzt(1000))},
+		{name: "withdrawal", entry: Withdrawal("A1", kzt(1000))},
+		{name: "transfer", entry: Transfer("A1", "A2", kzt(1))},
+		{name: "card payment", entry: CardPayment("A1", kzt(500), 7)},
+		{name: "exchange with rate", entry: Exchange("A1", "A2", kzt(50000), usd(100), "500", "q1")},
+		{
+			name:  "three postings",
+			entry: NewEntry(TypeTransfer).Add("A1", kzt(-300)).Add("A2", kzt(200)).Add(FeesAccount, kzt(100)),
+		},
+		{name: "missing type", entry: (&Entry{}).Move("A1", "A2", kzt(1)), wantErr: true},
+		{name: "no postings", entry: NewEntry(TypeDeposit), wantErr: true},
+		{name: "single posting", entry: NewEntry(TypeDeposit).Add("A1", kzt(100)), wantErr: true},
+		{name: "empty account", entry: NewEntry(TypeTransfer).Move("", "A2", kzt(100)), wantErr: true},
+		{name: "zero amount", entry: Transfer("A1", "A2", kzt(0)), wantErr: true},
+		{
+			name:    "unbalanced",
+			entry:   NewEntry(TypeTransfer).Add("A1", kzt(-100)).Add("A2", kzt(99)),
+			wantErr: true,
+		},
+		{
+			name:    "balanced overall but not per currency",
+			entry:   NewEntry(TypeTransfer).Add("A1", kzt(-100)).Add("A2", usd(100)),
+			wantErr: true,
+		},
+		{name: "exchange without rate", entry: Exchange("A1", "A2", kzt(50000), usd(100), "", ""), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatal("Validate() = nil, want error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"fmt"
+	"mfp/money"
+)
+
+// расхождение кэшированного баланса счёта с суммой его движений
+type BalanceMismatch struct {
+	AccountID string
+	Balance   money.Money
+	Postings  money.Money
+}
+
+// результат сверки журнала
+type Report struct {
+	Entries           int
+	Total             map[string]money.Money // сумма всех движений по валютам, должна быть нулевой
+	UnbalancedEntries []int64
+	Mismatches        []BalanceMismatch
+}
+
+// журнал согласован: все суммы нулевые, расхождений нет
+func (r *Report) OK() bool {
+	for _, total := range r.Total {
+		if !total.IsZero() {
+			return false
+		}
+	}
+	return len(r.UnbalancedEntries) == 0 && len(r.Mismatches) == 0
+}
+
+// сверка журнала: сумма всех движений равна нулю, каждая проводка
+// сбалансирована, а балансы клиентских счетов совпадают с их движениями
+func Verify(q Querier) (*Report, error) {
+	report := &Report{Total: make(map[string]money.Money)}
+
+	if err := q.QueryRow(`SELECT COUNT(*) FROM journal_entries`).Scan(&report.Entries); err != nil {
+		return nil, fmt.Errorf("failed to count journal entries: %v", err)
+	}
+
+	rows, err := q.Query(`SELECT currency, COALESCE(SUM(amount), 0) FROM postings GROUP BY currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum postings: %v", err)
+	}
+	for rows.Next() {
+		var (
+			currency string
+			total    money.Money
+		)
+		if err := rows.Scan(&currency, &total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Total[currency] = money.FromMinor(total.Amount, currency)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to sum postings: %v", err)
+	}
+	rows.Close()
+
+	rows, err = q.Query(`
+        SELECT entry_id FROM postings
+        GROUP BY entry_id, currency
+        HAVING SUM(amount) <> 0
+        ORDER BY entry_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check journal entries: %v", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.UnbalancedEntries = append(report.UnbalancedEntries, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to check journal entries: %v", err)
+	}
+	rows.Close()
+
+	rows, err = q.Query(`
+        SELECT a.id, a.balance, COALESCE(SUM(p.amount), 0)
+        FROM accounts a
+        LEFT JOIN postings p ON p.account_id = a.id
+        GROUP BY a.id, a.balance
+        HAVING a.balance <> COALESCE(SUM(p.amount), 0)
+        ORDER BY a.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account balances: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m BalanceMismatch
+		if err := rows.Scan(&m.AccountID, &m.Balance, &m.Postings); err != nil {
+			return nil, err
+		}
+		report.Mismatches = append(report.Mismatches, m)
+	}
+
+	return report, rows.Err()
+}
@@ -0,0 +1,237 @@
+package ledger
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"mfp/money"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ответ фиктивной базы на запрос, содержащий match
+type fakeResult struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+	err     error // ошибка при выполнении запроса
+	iterErr error // ошибка после выдачи всех строк
+}
+
+var (
+	fakeMu      sync.Mutex
+	fakeResults = map[string][]fakeResult{}
+)
+
+func init() {
+	sql.Register("ledgerfake", fakeDriver{})
+}
+
+// открытие фиктивной базы с заранее заданными ответами
+func openFake(t *testing.T, results []fakeResult) *sql.DB {
+	t.Helper()
+	fakeMu.Lock()
+	fakeResults[t.Name()] = results
+	fakeMu.Unlock()
+
+	db, err := sql.Open("ledgerfake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeMu.Lock()
+		delete(fakeResults, t.Name())
+		fakeMu.Unlock()
+	})
+	return db
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{name: name}, nil }
+
+type fakeConn struct{ name string }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	for _, r := range fakeResults[s.conn.name] {
+		if strings.Contains(s.query, r.match) {
+			if r.err != nil {
+				return nil, r.err
+			}
+			return &fakeRows{result: r}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+type fakeRows struct {
+	result fakeResult
+	pos    int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.result.rows) {
+		if r.result.iterErr != nil {
+			return r.result.iterErr
+		}
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// ответы для согласованного журнала; override заменяет ответ с тем же match
+func verifyResults(overrides ...fakeResult) []fakeResult {
+	results := []fakeResult{
+		{match: "COUNT(*) FROM journal_entries", columns: []string{"count"}, rows: [][]driver.Value{{int64(3)}}},
+		{match: "GROUP BY currency", columns: []string{"currency", "sum"}, rows: [][]driver.Value{{"KZT", "0.00"}, {"USD", "0.00"}}},
+		{match: "HAVING SUM(amount) <> 0", columns: []string{"entry_id"}},
+		{match: "LEFT JOIN postings", columns: []string{"id", "balance", "sum"}},
+	}
+	for _, o := range overrides {
+		for i := range results {
+			if results[i].match == o.match {
+				results[i] = o
+			}
+		}
+	}
+	return results
+}
+
+func TestVerify(t *testing.T) {
+	queryErr := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		overrides  []fakeResult
+		wantErr    bool
+		wantOK     bool
+		wantTotal  map[string]int64
+		unbalanced []int64
+		mismatches []string
+	}{
+		{
+			name:      "consistent",
+			wantOK:    true,
+			wantTotal: map[string]int64{"KZT": 0, "USD": 0},
+		},
+		{
+			name: "non-zero total",
+			overrides: []fakeResult{
+				{match: "GROUP BY currency", columns: []string{"currency", "sum"}, rows: [][]driver.Value{{"KZT", "0.01"}}},
+			},
+			wantTotal: map[string]int64{"KZT": 1},
+		},
+		{
+			name: "unbalanced entries",
+			overrides: []fakeResult{
+				{match: "HAVING SUM(amount) <> 0", columns: []string{"entry_id"}, rows: [][]driver.Value{{int64(4)}, {int64(9)}}},
+			},
+			wantTotal:  map[string]int64{"KZT": 0, "USD": 0},
+			unbalanced: []int64{4, 9},
+		},
+		{
+			name: "balance mismatch",
+			overrides: []fakeResult{
+				{match: "LEFT JOIN postings", columns: []string{"id", "balance", "sum"}, rows: [][]driver.Value{{"A1", "10.00", "9.50"}}},
+			},
+			wantTotal:  map[string]int64{"KZT": 0, "USD": 0},
+			mismatches: []string{"A1"},
+		},
+		{
+			name:      "count query fails",
+			overrides: []fakeResult{{match: "COUNT(*) FROM journal_entries", err: queryErr}},
+			wantErr:   true,
+		},
+		{
+			name: "totals interrupted",
+			overrides: []fakeResult{
+				{match: "GROUP BY currency", columns: []string{"currency", "sum"}, rows: [][]driver.Value{{"KZT", "0.00"}}, iterErr: queryErr},
+			},
+			wantErr: true,
+		},
+		{
+			name: "entry check interrupted",
+			overrides: []fakeResult{
+				{match: "HAVING SUM(amount) <> 0", columns: []string{"entry_id"}, iterErr: queryErr},
+			},
+			wantErr: true,
+		},
+		{
+			name: "balance check interrupted",
+			overrides: []fakeResult{
+				{match: "LEFT JOIN postings", columns: []string{"id", "balance", "sum"}, iterErr: queryErr},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openFake(t, verifyResults(tt.overrides...))
+
+			report, err := Verify(db)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Verify() = %+v, want error", report)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() unexpected error: %v", err)
+			}
+
+			if report.Entries != 3 {
+				t.Errorf("Entries = %d, want 3", report.Entries)
+			}
+			if got := report.OK(); got != tt.wantOK {
+				t.Errorf("OK() = %v, want %v", got, tt.wantOK)
+			}
+			if len(report.Total) != len(tt.wantTotal) {
+				t.Errorf("Total = %v, want %v", report.Total, tt.wantTotal)
+			}
+			for currency, amount := range tt.wantTotal {
+				if got := report.Total[currency]; got != money.FromMinor(amount, currency) {
+					t.Errorf("Total[%s] = %v, want %d", currency, got, amount)
+				}
+			}
+			if fmt.Sprint(report.UnbalancedEntries) != fmt.Sprint(tt.unbalanced) {
+				t.Errorf("UnbalancedEntries = %v, want %v", report.UnbalancedEntries, tt.unbalanced)
+			}
+			var mismatches []string
+			for _, m := range report.Mismatches {
+				mismatches = append(mismatches, m.AccountID)
+			}
+			if fmt.Sprint(mismatches) != fmt.Sprint(tt.mismatches) {
+				t.Errorf("Mismatches = %v, want %v", mismatches, tt.mismatches)
+			}
+		})
+	}
+}
@@ -1,22 +1,357 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"mfp/account"
 	"mfp/api"
+	"mfp/config"
 	"mfp/database"
+	"mfp/fees"
+	"mfp/fx"
+	"mfp/jobs"
+	"mfp/keyring"
+	"mfp/migrations"
+	"mfp/otp"
+	"mfp/schedule"
 	"mfp/session"
+	"mfp/storage"
+	"mfp/token"
+	"os"
+	"strconv"
+	"time"
 )
 
 func main() {
-	repo, err := database.Connect()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Configuration error: ", err)
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrate(cfg, args[1:]); err != nil {
+				log.Fatal("Migration failed: ", err)
+			}
+		case "role":
+			if err := runSetRole(cfg, args[1:]); err != nil {
+				log.Fatal("Role change failed: ", err)
+			}
+		case "keys":
+			if err := runKeys(cfg, args[1:]); err != nil {
+				log.Fatal("Key command failed: ", err)
+			}
+		case "reencrypt":
+			if err := runReencrypt(cfg, args[1:]); err != nil {
+				log.Fatal("Re-encryption failed: ", err)
+			}
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		return
+	}
+
+	if cfg.Storage.Backend == "postgres" && cfg.Database.AutoMigrate {
+		if err := runMigrate(cfg, nil); err != nil {
+			log.Fatal("Auto-migration failed: ", err)
+		}
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatal("Storage initialization failed: ", err)
+	}
+	if repo, ok := store.(*database.Repository); ok {
+		maintainDatabase(repo)
+	}
+
+	if cfg.FX.RatesFile != "" {
+		rates, err := fx.LoadFile(cfg.FX.RatesFile)
+		if err != nil {
+			log.Fatal("Failed to load exchange rates: ", err)
+		}
+		if err := store.SetRates(rates); err != nil {
+			log.Fatal("Failed to save exchange rates: ", err)
+		}
+		log.Printf("Loaded %d exchange rate(s) from %s", len(rates), cfg.FX.RatesFile)
+	}
+
+	if cfg.Fees.RulesFile != "" {
+		rules, err := fees.LoadFile(cfg.Fees.RulesFile)
+		if err != nil {
+			log.Fatal("Failed to load fee rules: ", err)
+		}
+		if err := store.SetFeeRules(rules); err != nil {
+			log.Fatal("Failed to save fee rules: ", err)
+		}
+		log.Printf("Loaded %d fee rule(s) from %s", len(rules), cfg.Fees.RulesFile)
+	}
+
+	sessionStore := store.Sessions()
+	if cfg.Session.Store == "memory" {
+		sessionStore = session.NewMemoryStore()
+	}
+	sessionManager := session.NewSessionManager(sessionStore, cfg.Session.TTL, cfg.Session.MaxPerUser)
+	rateLimiter := api.NewRateLimiter(cfg.RateLimit.Requests, cfg.RateLimit.Window)
+
+	if cfg.Scheduler.Enabled {
+		scheduler := jobs.NewScheduler(store, cfg.Scheduler.Interval, schedule.RetryPolicy{
+			MaxAttempts: cfg.Scheduler.MaxAttempts,
+			Delay:       cfg.Scheduler.RetryDelay,
+		})
+		scheduler.ConfirmThreshold = cfg.Transfers.ConfirmThreshold
+		go scheduler.Run(context.Background())
+		go jobs.NewOverdraftInterest(store, cfg.Scheduler.DailyCheck).Run(context.Background())
+		go jobs.NewInterestAccrual(store, cfg.Scheduler.DailyCheck).Run(context.Background())
+		go jobs.NewHoldExpiry(store, cfg.Scheduler.Interval).Run(context.Background())
+		go jobs.NewPendingTransferExpiry(store, cfg.Scheduler.Interval).Run(context.Background())
+	}
+
+	server := api.NewServer(store, sessionManager, rateLimiter)
+	server.QuoteTTL = cfg.FX.QuoteTTL
+	server.LimitCooling = cfg.Limits.CoolingPeriod
+	server.ProcessingKey = cfg.Cards.ProcessingKey
+	server.HoldTTL = cfg.Cards.HoldTTL
+	server.CardPolicy = account.LoginPolicy{MaxFailures: cfg.Cards.MaxFailures}
+	server.ConfirmThreshold = cfg.Transfers.ConfirmThreshold
+	server.ConfirmTTL = cfg.Transfers.ConfirmTTL
+	server.TrustForwardedFor = cfg.RateLimit.TrustForwardedFor
+	server.LoginPolicy = account.LoginPolicy{
+		MaxFailures: cfg.Login.MaxFailures,
+		BaseDelay:   cfg.Login.BaseDelay,
+		MaxDelay:    cfg.Login.MaxDelay,
+		Lockout:     cfg.Login.Lockout,
+	}
+	server.TwoFactorRequired = cfg.TwoFactor.Required
+	server.TOTPIssuer = cfg.TwoFactor.Issuer
+	server.OTPCodeTTL = cfg.TwoFactor.CodeTTL
+	if cfg.TwoFactor.Sender == "file" {
+		server.OTPSender = otp.NewFileSender(cfg.TwoFactor.SenderFile)
+	}
+	signer, err := tokenSigner(cfg)
+	if err != nil {
+		log.Fatal("Token signing key failed: ", err)
+	}
+	server.Tokens = token.NewManager(signer, store.RefreshTokens(), cfg.Tokens.AccessTTL, cfg.Tokens.RefreshTTL)
+	log.Fatal(server.Start(cfg.Server.Addr))
+}
+
+// издатель токенов доступа; токены с другим издателем не принимаются
+const tokenIssuer = "mfp"
+
+// ключ подписи токенов доступа общий для всех копий сервера, поэтому
+// хранится в файле; хранилищу в памяти хватает случайного ключа
+func tokenSigner(cfg *config.Config) (*token.Signer, error) {
+	if cfg.Storage.Backend == "memory" {
+		return token.NewEphemeralSigner(tokenIssuer), nil
+	}
+	key, err := keyring.LoadKeyFile(cfg.Tokens.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewSigner(key, tokenIssuer), nil
+}
+
+// выбор хранилища; без побочных действий, поэтому подходит и для
+// разовых подкоманд. миграции и обслуживание базы запускает только сервер
+func openStore(cfg *config.Config) (storage.Store, error) {
+	switch cfg.Storage.Backend {
+	case "memory":
+		log.Println("Using in-memory storage, data will be lost on restart")
+		return storage.NewMemoryStore(), nil
+	case "file", "postgres":
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+
+	keys, err := keyring.Open(cfg.Encryption.MasterKeyFile, cfg.Encryption.KeyringFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyring: %v", err)
+	}
+	log.Printf("Encryption keyring %s, current key version %d", cfg.Encryption.KeyringFile, keys.Current())
+
+	if cfg.Storage.Backend == "file" {
+		log.Printf("Using file storage: %s", cfg.Storage.DataFile)
+		return storage.NewFileStore(cfg.Storage.DataFile, keys)
+	}
+
+	db, err := database.Open(cfg.Database.DSN())
 	if err != nil {
-		log.Fatal("Database connection failed: ", err)
+		return nil, err
 	}
 	log.Println("Database connected successsfully!")
 
-	sessionManager := session.NewSessionManager()
+	return database.NewRepository(db, keys), nil
+}
+
+// при запуске сервера: сверка журнала и периодическая очистка
+// ключей идемпотентности, которые хранятся сутки, и счётчиков входа
+// незарегистрированных телефонов
+func maintainDatabase(repo *database.Repository) {
+	report, err := repo.VerifyLedger()
+	if err != nil {
+		log.Printf("Ledger verification failed: %v", err)
+	} else if !report.OK() {
+		log.Printf("Ledger is inconsistent: totals=%v unbalanced=%v mismatches=%d",
+			report.Total, report.UnbalancedEntries, len(report.Mismatches))
+	} else {
+		log.Printf("Ledger verified: %d entries, all postings sum to zero", report.Entries)
+	}
+
+	go func() {
+		for {
+			time.Sleep(1 * time.Hour)
+			if err := repo.CleanupIdempotencyKeys(24 * time.Hour); err != nil {
+				log.Printf("Failed to clean up idempotency keys: %v", err)
+			}
+			if err := repo.CleanupUnknownLoginAttempts(account.MaxLockout); err != nil {
+				log.Printf("Failed to clean up login attempts: %v", err)
+			}
+		}
+	}()
+}
+
+// подкоманда migrate: up, down [N], status
+func runMigrate(cfg *config.Config, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	db, err := database.Open(cfg.Database.DSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		applied, err := migrations.Up(db)
+		if err != nil {
+			return err
+		}
+		log.Printf("Applied %d migration(s)", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrations.Down(db, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+	case "status":
+		statuses, err := migrations.GetStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.AppliedAt != nil {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%-28s %s\n", st.Version, st.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+	}
+	return nil
+}
+
+// подкоманда role <id или телефон> <роль>: назначение роли,
+// в том числе первого администратора
+func runSetRole(cfg *config.Config, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: role <account id or phone> <customer|teller|admin|auditor>")
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	acc, err := store.GetAccount(args[0])
+	if err != nil {
+		if acc, err = store.GetAccountByPhone(args[0]); err != nil {
+			return fmt.Errorf("account %s not found", args[0])
+		}
+	}
+
+	if err := store.SetRole(acc.ID, args[1]); err != nil {
+		return err
+	}
+	if err := store.RecordAudit(&storage.AuditEvent{
+		ActorID:   "cli",
+		ActorRole: account.RoleAdmin,
+		Action:    "set_role",
+		TargetID:  acc.ID,
+		Details:   "role=" + args[1],
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("Account %s now has role %s", acc.ID, args[1])
+	return nil
+}
+
+// подкоманда keys rotate: новый ключ данных становится текущим. работающие
+// серверы читают данные на новом ключе сразу, а шифруют им после перезапуска
+func runKeys(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "rotate" {
+		return fmt.Errorf("usage: keys rotate")
+	}
+
+	keys, err := keyring.Open(cfg.Encryption.MasterKeyFile, cfg.Encryption.KeyringFile)
+	if err != nil {
+		return err
+	}
+	version, err := keys.Rotate()
+	if err != nil {
+		return err
+	}
+	log.Printf("Data key version %d is now current; run reencrypt to move existing data to it", version)
+	return nil
+}
+
+// подкоманда reencrypt [размер пачки]: перешифрование телефонов и CVC2
+// текущим ключом пачками с паузой, чтобы не мешать работающему серверу
+func runReencrypt(cfg *config.Config, args []string) error {
+	batch := 100
+	if len(args) > 0 {
+		var err error
+		if batch, err = strconv.Atoi(args[0]); err != nil || batch < 1 {
+			return fmt.Errorf("invalid batch size %q", args[0])
+		}
+	}
 
-	// СТАНОВИТСЯ:
-	server := api.NewServer(repo, sessionManager) // ← Передаем repo вместо accountList
-	server.Start()
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for {
+		n, err := store.Reencrypt(batch)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+		log.Printf("Re-encrypted %d record(s), %d in total", n, total)
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Printf("Re-encryption finished: %d record(s)", total)
+	return nil
 }
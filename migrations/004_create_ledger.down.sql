@@ -0,0 +1,33 @@
+-- восстановление таблицы transactions из журнала: по строке на каждое
+-- движение по клиентскому счёту, как это было до перехода на журнал
+CREATE TABLE IF NOT EXISTS transactions (
+    id SERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    from_account TEXT,
+    to_account TEXT,
+    amount DECIMAL(15,2) NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    account_id TEXT NOT NULL,
+    FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_account_id ON transactions(account_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_timestamp ON transactions(timestamp);
+
+INSERT INTO transactions (type, from_account, to_account, amount, timestamp, status, account_id)
+SELECT e.type,
+       COALESCE((SELECT c.account_id FROM postings c
+                 WHERE c.entry_id = e.id AND c.amount < 0 AND c.account_id NOT LIKE 'system:%'
+                 ORDER BY c.id LIMIT 1), ''),
+       COALESCE((SELECT c.account_id FROM postings c
+                 WHERE c.entry_id = e.id AND c.amount > 0 AND c.account_id NOT LIKE 'system:%'
+                 ORDER BY c.id LIMIT 1), ''),
+       ABS(p.amount), e.created_at, e.status, p.account_id
+FROM postings p
+JOIN journal_entries e ON e.id = p.entry_id
+WHERE p.account_id IN (SELECT id FROM accounts)
+ORDER BY e.id, p.id;
+
+DROP TABLE IF EXISTS postings;
+DROP TABLE IF EXISTS journal_entries;
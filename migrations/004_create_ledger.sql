@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    legacy_transaction_id INTEGER UNIQUE
+);
+
+-- движения не ссылаются на accounts: системные счета там не хранятся,
+-- а история закрытых счетов должна сохраняться
+CREATE TABLE IF NOT EXISTS postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES journal_entries(id),
+    account_id TEXT NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount <> 0),
+    currency TEXT NOT NULL DEFAULT 'KZT'
+);
+
+CREATE INDEX IF NOT EXISTS idx_postings_entry_id ON postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings(account_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_created_at ON journal_entries(created_at);
+
+-- перенос истории из таблицы transactions: у перевода две строки,
+-- берём только строку отправителя
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'transactions') THEN
+        INSERT INTO journal_entries (type, status, created_at, legacy_transaction_id)
+        SELECT t.type, t.status, t.timestamp, t.id
+        FROM transactions t
+        WHERE (t.type IN ('deposit', 'withdraw') OR t.account_id = t.from_account)
+          AND NOT EXISTS (SELECT 1 FROM journal_entries e WHERE e.legacy_transaction_id = t.id);
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT e.id,
+               CASE WHEN t.type = 'deposit' THEN 'system:cash_in' ELSE t.from_account END,
+               -t.amount
+        FROM journal_entries e
+        JOIN transactions t ON t.id = e.legacy_transaction_id
+        WHERE NOT EXISTS (SELECT 1 FROM postings p WHERE p.entry_id = e.id);
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT e.id,
+               CASE WHEN t.type = 'withdraw' THEN 'system:cash_out' ELSE t.to_account END,
+               t.amount
+        FROM journal_entries e
+        JOIN transactions t ON t.id = e.legacy_transaction_id
+        WHERE (SELECT COUNT(*) FROM postings p WHERE p.entry_id = e.id) = 1;
+
+        DROP TABLE transactions;
+    END IF;
+END $$;
@@ -0,0 +1,7 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'frozen', 'blocked', 'closed'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- закрытый аккаунт остаётся в базе, поэтому номер телефона у него не освобождается
+CREATE INDEX IF NOT EXISTS idx_accounts_status ON accounts(status);
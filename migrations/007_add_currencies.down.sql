@@ -0,0 +1,10 @@
+-- откат не пройдёт, пока существуют валютные счета с общим телефоном
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS quote_id;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS fx_rate;
+DROP TABLE IF EXISTS fx_quotes;
+DROP TABLE IF EXISTS fx_rates;
+DROP INDEX IF EXISTS idx_accounts_owner_id;
+DROP INDEX IF EXISTS idx_accounts_phone_owner;
+ALTER TABLE accounts ADD CONSTRAINT accounts_phone_key UNIQUE (phone);
+ALTER TABLE accounts DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS currency;
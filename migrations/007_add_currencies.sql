@@ -0,0 +1,34 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'KZT';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS owner_id TEXT REFERENCES accounts(id);
+
+-- валютные счета делят телефон с основным аккаунтом,
+-- уникальность телефона остаётся только среди основных
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_phone_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_phone_owner ON accounts(phone) WHERE owner_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_accounts_owner_id ON accounts(owner_id);
+
+CREATE TABLE IF NOT EXISTS fx_rates (
+    from_currency TEXT NOT NULL,
+    to_currency TEXT NOT NULL,
+    rate NUMERIC(30,10) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (from_currency, to_currency)
+);
+
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id TEXT PRIMARY KEY,
+    account_id TEXT NOT NULL REFERENCES accounts(id),
+    sell_amount DECIMAL(15,2) NOT NULL,
+    sell_currency TEXT NOT NULL,
+    buy_amount DECIMAL(15,2) NOT NULL,
+    buy_currency TEXT NOT NULL,
+    rate NUMERIC(30,10) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fx_quotes_expires_at ON fx_quotes(expires_at);
+
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(30,10);
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS quote_id TEXT;
@@ -0,0 +1,5 @@
+-- лимит в валюте счёта и годовая ставка в процентах
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(15,2) NOT NULL DEFAULT 0
+    CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_rate NUMERIC(7,4) NOT NULL DEFAULT 0
+    CHECK (overdraft_rate >= 0 AND overdraft_rate <= 100);
@@ -0,0 +1,7 @@
+ALTER TABLE accounts DROP COLUMN IF EXISTS pending_limits_effective_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS pending_limit_monthly;
+ALTER TABLE accounts DROP COLUMN IF EXISTS pending_limit_daily;
+ALTER TABLE accounts DROP COLUMN IF EXISTS pending_limit_per_transaction;
+ALTER TABLE accounts DROP COLUMN IF EXISTS limit_monthly;
+ALTER TABLE accounts DROP COLUMN IF EXISTS limit_daily;
+ALTER TABLE accounts DROP COLUMN IF EXISTS limit_per_transaction;
@@ -0,0 +1,14 @@
+-- лимиты на снятие и исходящие переводы в валюте счёта; 0 — без лимита
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS limit_per_transaction DECIMAL(15,2) NOT NULL DEFAULT 0
+    CHECK (limit_per_transaction >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS limit_daily DECIMAL(15,2) NOT NULL DEFAULT 0
+    CHECK (limit_daily >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS limit_monthly DECIMAL(15,2) NOT NULL DEFAULT 0
+    CHECK (limit_monthly >= 0);
+
+-- повышение, запрошенное клиентом; вступает в силу в pending_limits_effective_at
+-- или раньше, если его одобрит администратор
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS pending_limit_per_transaction DECIMAL(15,2);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS pending_limit_daily DECIMAL(15,2);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS pending_limit_monthly DECIMAL(15,2);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS pending_limits_effective_at TIMESTAMP;
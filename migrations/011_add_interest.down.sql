@@ -0,0 +1,5 @@
+ALTER TABLE accounts DROP COLUMN IF EXISTS accrued_through;
+ALTER TABLE accounts DROP COLUMN IF EXISTS accrued_interest;
+ALTER TABLE accounts DROP COLUMN IF EXISTS maturity_date;
+ALTER TABLE accounts DROP COLUMN IF EXISTS interest_rate;
+ALTER TABLE accounts DROP COLUMN IF EXISTS product;
@@ -0,0 +1,12 @@
+-- счётный продукт и годовая ставка в процентах
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS product VARCHAR(16) NOT NULL DEFAULT 'current'
+    CHECK (product IN ('current', 'savings', 'deposit'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS interest_rate NUMERIC(7,4) NOT NULL DEFAULT 0
+    CHECK (interest_rate >= 0 AND interest_rate <= 100);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS maturity_date DATE;
+
+-- начисленные и ещё не капитализированные проценты и последний день
+-- начисления; NULL — счёт не подключён к начислению
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS accrued_interest NUMERIC(24,8) NOT NULL DEFAULT 0
+    CHECK (accrued_interest >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS accrued_through DATE;
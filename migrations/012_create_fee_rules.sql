@@ -0,0 +1,8 @@
+-- тарифы комиссий: одно правило на операцию и валюту, параметры в JSON
+CREATE TABLE IF NOT EXISTS fee_rules (
+    operation VARCHAR(16) NOT NULL CHECK (operation IN ('transfer', 'withdrawal')),
+    currency VARCHAR(3) NOT NULL,
+    rule JSONB NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (operation, currency)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS cvc2 TEXT NOT NULL DEFAULT '';
+UPDATE accounts a SET cvc2 = c.cvc2 FROM cards c WHERE c.pan = a.id;
+DROP TABLE IF EXISTS cards;
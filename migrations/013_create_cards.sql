@@ -0,0 +1,30 @@
+-- карты как отдельная сущность: несколько карт на счёт, номер карты
+-- не совпадает с номером счёта; статус expired не хранится, он
+-- вычисляется по сроку действия
+CREATE TABLE IF NOT EXISTS cards (
+    id TEXT PRIMARY KEY,
+    account_id TEXT NOT NULL REFERENCES accounts(id),
+    pan TEXT UNIQUE NOT NULL,
+    cvc2 TEXT NOT NULL,
+    pin_hash TEXT NOT NULL,
+    expiry_month INTEGER NOT NULL CHECK (expiry_month BETWEEN 1 AND 12),
+    expiry_year INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'blocked', 'lost')),
+    status_reason TEXT NOT NULL DEFAULT '',
+    replaced_by TEXT REFERENCES cards(id),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_cards_account_id ON cards(account_id);
+
+-- раньше номер счёта был номером карты: такие карты переносятся в таблицу,
+-- их PIN до смены совпадает с паролем входа
+INSERT INTO cards (id, account_id, pan, cvc2, pin_hash, expiry_month, expiry_year, status, created_at, updated_at)
+SELECT left(md5(id), 16), id, id, cvc2, password,
+       EXTRACT(MONTH FROM expired_at), EXTRACT(YEAR FROM expired_at), 'active', created_at, CURRENT_TIMESTAMP
+FROM accounts
+WHERE cvc2 <> ''
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS cvc2;
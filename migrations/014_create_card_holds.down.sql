@@ -0,0 +1,3 @@
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS hold_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS held;
+DROP TABLE IF EXISTS card_holds;
@@ -0,0 +1,24 @@
+-- блокировки сумм по авторизациям карт: доступный остаток уменьшается
+-- сразу, баланс — при списании проводкой card_payment
+CREATE TABLE IF NOT EXISTS card_holds (
+    id BIGSERIAL PRIMARY KEY,
+    card_id TEXT NOT NULL REFERENCES cards(id),
+    account_id TEXT NOT NULL REFERENCES accounts(id),
+    merchant TEXT NOT NULL DEFAULT '',
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    captured DECIMAL(15,2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'captured', 'reversed', 'expired')),
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_holds_account_id ON card_holds(account_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_card_holds_pending ON card_holds(expires_at) WHERE status = 'pending';
+
+-- сумма действующих блокировок счёта; доступный остаток — balance - held
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held DECIMAL(15,2) NOT NULL DEFAULT 0;
+
+-- блокировка, по которой проведено списание
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS hold_id BIGINT REFERENCES card_holds(id);
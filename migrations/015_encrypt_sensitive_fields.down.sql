@@ -0,0 +1,18 @@
+-- расшифровать данные в SQL нельзя, а CVC2 не восстанавливается вовсе,
+-- поэтому откат возможен только до перешифрования
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM accounts WHERE phone IS NULL) OR EXISTS (SELECT 1 FROM cards WHERE cvc2 IS NULL) THEN
+        RAISE EXCEPTION 'encrypted phones or CVC2 codes exist and cannot be restored';
+    END IF;
+END $$;
+
+ALTER TABLE cards DROP COLUMN IF EXISTS key_version;
+ALTER TABLE cards DROP COLUMN IF EXISTS cvc2_mac;
+ALTER TABLE cards ALTER COLUMN cvc2 SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_accounts_phone_index_owner;
+ALTER TABLE accounts DROP COLUMN IF EXISTS key_version;
+ALTER TABLE accounts DROP COLUMN IF EXISTS phone_index;
+ALTER TABLE accounts DROP COLUMN IF EXISTS phone_enc;
+ALTER TABLE accounts ALTER COLUMN phone SET NOT NULL;
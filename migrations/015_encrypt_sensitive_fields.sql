@@ -0,0 +1,14 @@
+-- телефоны шифруются ключом данных версии key_version, поиск по телефону
+-- идёт через слепой индекс phone_index; вместо CVC2 хранится HMAC.
+-- существующие открытые значения переносит команда reencrypt, после
+-- неё phone и cvc2 остаются пустыми
+ALTER TABLE accounts ALTER COLUMN phone DROP NOT NULL;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS phone_enc TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS phone_index TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS key_version INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_phone_index_owner ON accounts(phone_index) WHERE owner_id IS NULL;
+
+ALTER TABLE cards ALTER COLUMN cvc2 DROP NOT NULL;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS cvc2_mac TEXT;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS key_version INTEGER;
@@ -0,0 +1,3 @@
+DELETE FROM sessions WHERE pending;
+ALTER TABLE sessions DROP COLUMN IF EXISTS pending;
+DROP TABLE IF EXISTS two_factor;
@@ -0,0 +1,17 @@
+-- второй фактор входа: секрет TOTP зашифрован ключом данных версии
+-- key_version, одноразовые коды и коды восстановления хранятся хешами
+CREATE TABLE IF NOT EXISTS two_factor (
+    account_id TEXT PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    method VARCHAR(8) NOT NULL CHECK (method IN ('totp', 'sms')),
+    secret_enc TEXT,
+    key_version INTEGER,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    recovery_codes TEXT[] NOT NULL DEFAULT '{}',
+    last_step BIGINT NOT NULL DEFAULT 0,
+    code_hash TEXT,
+    code_expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- сессия после пароля, ожидающая второй фактор
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS pending BOOLEAN NOT NULL DEFAULT FALSE;
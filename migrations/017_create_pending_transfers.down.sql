@@ -0,0 +1,2 @@
+ALTER TABLE accounts DROP COLUMN IF EXISTS confirm_threshold;
+DROP TABLE IF EXISTS pending_transfers;
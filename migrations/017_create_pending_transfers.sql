@@ -0,0 +1,23 @@
+-- крупные переводы исполняются только после подтверждения PIN или кодом
+CREATE TABLE IF NOT EXISTS pending_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    account_id TEXT NOT NULL REFERENCES accounts(id),
+    to_account TEXT NOT NULL,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    currency TEXT NOT NULL,
+    quote_id TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL CHECK (method IN ('pin', 'otp')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'confirmed', 'failed', 'cancelled', 'expired')),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_transfers_account_id ON pending_transfers(account_id);
+CREATE INDEX IF NOT EXISTS idx_pending_transfers_expiry ON pending_transfers(expires_at) WHERE status = 'pending';
+
+-- порог подтверждения переводов счёта; NULL — порог банка по умолчанию
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS confirm_threshold DECIMAL(15,2);
@@ -0,0 +1,10 @@
+-- неудачные попытки входа: пауза между попытками и временная блокировка
+-- против перебора PIN; строка появляется при первой неудаче
+CREATE TABLE IF NOT EXISTS login_attempts (
+    account_id TEXT PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP,
+    next_attempt_at TIMESTAMP,
+    locked_until TIMESTAMP
+);
@@ -0,0 +1,2 @@
+ALTER TABLE journal_entries ALTER COLUMN created_at TYPE TIMESTAMP
+    USING created_at AT TIME ZONE current_setting('TimeZone');
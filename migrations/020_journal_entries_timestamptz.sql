@@ -0,0 +1,6 @@
+-- время проводки как момент, а не как время на часах сервера: остаток
+-- на конец дня UTC считается по сравнению с полуночью UTC и не зависит
+-- от часового пояса сервера. существующие значения записаны по часам
+-- сервера и переводятся по часовому поясу сессии
+ALTER TABLE journal_entries ALTER COLUMN created_at TYPE TIMESTAMPTZ
+    USING created_at AT TIME ZONE current_setting('TimeZone');
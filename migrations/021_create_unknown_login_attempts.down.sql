@@ -0,0 +1 @@
+DROP TABLE IF EXISTS unknown_login_attempts;
@@ -0,0 +1,13 @@
+-- неудачные попытки входа с незарегистрированным телефоном по слепому
+-- индексу номера: паузы и блокировки такие же, как у клиентов, чтобы
+-- по ответу нельзя было узнать, зарегистрирован ли номер
+CREATE TABLE IF NOT EXISTS unknown_login_attempts (
+    phone_index TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP,
+    next_attempt_at TIMESTAMP,
+    locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_unknown_login_attempts_last_failure_at ON unknown_login_attempts(last_failure_at);
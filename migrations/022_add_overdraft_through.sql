@@ -0,0 +1,4 @@
+-- последний день, за который начислены проценты за овердрафт; после
+-- простоя задание начисляет пропущенные дни по остаткам на их конец.
+-- NULL — дни ещё не отмечались, отсчёт идёт от последнего списания
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_through DATE;
@@ -0,0 +1,10 @@
+-- неверные срок и CVC2 подряд при авторизации по карте: после
+-- нескольких неудач карта блокируется; строка появляется при первой неудаче
+CREATE TABLE IF NOT EXISTS card_attempts (
+    card_id TEXT PRIMARY KEY REFERENCES cards(id) ON DELETE CASCADE,
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP,
+    next_attempt_at TIMESTAMP,
+    locked_until TIMESTAMP
+);
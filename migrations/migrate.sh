@@ -1,18 +1,10 @@
 #!/bin/bash
 
-# Скрипт для применения миграций
+# Скрипт для применения миграций: обёртка над встроенным мигратором.
+# Использование: migrations/migrate.sh [up|down [N]|status]
+# Параметры подключения берутся из переменных окружения BANK_DATABASE_*
 set -e
 
-DB_NAME="mybank"
-DB_USER="postgres"
-DB_HOST="localhost"
-DB_PORT="5432"
+cd "$(dirname "$0")/.."
 
-echo "Applying database migrations..."
-
-for migration_file in migrations/*.sql; do
-    echo "Applying: $migration_file"
-    psql -h $DB_HOST -p $DB_PORT -U $DB_USER -d $DB_NAME -f "$migration_file"
-done
-
-echo "All migrations applied successfully!"
\ No newline at end of file
+go run . migrate "${@:-up}"
@@ -0,0 +1,254 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// валюта по умолчанию для всех счетов
+const DefaultCurrency = "KZT"
+
+// валюты, в которых открываются счета
+var SupportedCurrencies = []string{"KZT", "USD", "EUR"}
+
+// проверка, что счёт можно открыть в этой валюте
+func ValidateCurrency(currency string) error {
+	for _, supported := range SupportedCurrencies {
+		if normalizeCurrency(currency) == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported currency %q, expected one of %s", currency, strings.Join(SupportedCurrencies, ", "))
+}
+
+// код валюты в верхнем регистре; пустой код — валюта по умолчанию
+func NormalizeCurrency(currency string) string {
+	return normalizeCurrency(currency)
+}
+
+// количество знаков после запятой (минорные единицы: тиыны, центы)
+const fractionDigits = 2
+
+const minorPerMajor = 100
+
+// денежная сумма в минорных единицах с кодом валюты
+type Money struct {
+	Amount   int64  // сумма в минорных единицах
+	Currency string // код валюты ISO 4217
+}
+
+// создание суммы из минорных единиц
+func FromMinor(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: normalizeCurrency(currency)}
+}
+
+// нулевая сумма в указанной валюте
+func Zero(currency string) Money {
+	return FromMinor(0, currency)
+}
+
+// разбор десятичной строки вида "123", "123.4", "123.45", "-0.50"
+// суммы с более чем двумя знаками после запятой отклоняются
+func Parse(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, fmt.Errorf("amount is empty")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || (hasDot && fracPart == "") {
+		return Money{}, fmt.Errorf("invalid amount format")
+	}
+	if len(fracPart) > fractionDigits {
+		return Money{}, fmt.Errorf("amount must have at most %d fractional digits", fractionDigits)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, fmt.Errorf("invalid amount format")
+	}
+
+	major, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || major > math.MaxInt64/minorPerMajor-1 {
+		return Money{}, fmt.Errorf("amount is too large")
+	}
+
+	fracPart += strings.Repeat("0", fractionDigits-len(fracPart))
+	minor, _ := strconv.ParseInt(fracPart, 10, 64)
+
+	amount := major*minorPerMajor + minor
+	if negative {
+		amount = -amount
+	}
+	return FromMinor(amount, currency), nil
+}
+
+// строковое представление суммы без валюты, например "123.45"
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorPerMajor, amount%minorPerMajor)
+}
+
+// сумма с кодом валюты, например "123.45 KZT"
+func (m Money) Format() string {
+	return m.String() + " " + normalizeCurrency(m.Currency)
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// сложение сумм одной валюты
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("amount overflow")
+	}
+	return FromMinor(sum, m.Currency), nil
+}
+
+// вычитание сумм одной валюты
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+// сумма с противоположным знаком
+func (m Money) Neg() Money {
+	return FromMinor(-m.Amount, m.Currency)
+}
+
+// сравнение сумм одной валюты: -1, 0 или 1
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// проверка, что суммы в одной валюте
+func (m Money) sameCurrency(other Money) error {
+	if normalizeCurrency(m.Currency) != normalizeCurrency(other.Currency) {
+		return fmt.Errorf("currency mismatch: %s and %s", normalizeCurrency(m.Currency), normalizeCurrency(other.Currency))
+	}
+	return nil
+}
+
+// JSON-представление: {"amount": "123.45", "currency": "KZT"}
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: normalizeCurrency(m.Currency)})
+}
+
+// принимает объект {"amount","currency"}, строку "123.45" или число 123.45;
+// число разбирается по исходному тексту, без преобразования во float
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(raw, "{"):
+		var obj moneyJSON
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return fmt.Errorf("invalid money object: %v", err)
+		}
+		parsed, err := Parse(obj.Amount, obj.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	case strings.HasPrefix(raw, `"`):
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := Parse(s, m.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	default:
+		parsed, err := Parse(raw, m.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	}
+	return nil
+}
+
+// чтение значения DECIMAL из базы данных; валюта сохраняется, если уже задана
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case nil:
+		return fmt.Errorf("cannot scan NULL into money")
+	default:
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+
+	parsed, err := Parse(s, m.Currency)
+	if err != nil {
+		return fmt.Errorf("invalid money value %q: %v", s, err)
+	}
+	*m = parsed
+	return nil
+}
+
+// запись в базу данных в виде десятичной строки для DECIMAL(15,2)
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func normalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+func isDigits(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package money
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{name: "integer", in: "123", want: 12300},
+		{name: "one fractional digit is padded", in: "123.4", want: 12340},
+		{name: "two fractional digits", in: "123.45", want: 12345},
+		{name: "leading zeros in fraction", in: "0.05", want: 5},
+		{name: "surrounding spaces", in: "  7.10 ", want: 710},
+		{name: "explicit plus", in: "+1.01", want: 101},
+		{name: "negative", in: "-0.50", want: -50},
+		{name: "negative integer", in: "-12", want: -1200},
+		{name: "negative zero", in: "-0", want: 0},
+		{name: "no rounding of third digit", in: "1.005", wantErr: true},
+		{name: "too many decimals", in: "0.999", wantErr: true},
+		{name: "empty", in: "", wantErr: true},
+		{name: "only sign", in: "-", wantErr: true},
+		{name: "trailing dot", in: "1.", wantErr: true},
+		{name: "leading dot", in: ".5", wantErr: true},
+		{name: "letters", in: "12a", wantErr: true},
+		{name: "double sign", in: "--1", wantErr: true},
+		{name: "exponent", in: "1e3", wantErr: true},
+		{name: "largest allowed", in: strconv.FormatInt(math.MaxInt64/minorPerMajor-1, 10) + ".99", want: (math.MaxInt64/minorPerMajor-1)*minorPerMajor + 99},
+		{name: "overflow after scaling", in: strconv.FormatInt(math.MaxInt64/minorPerMajor, 10), wantErr: true},
+		{name: "overflow of int64", in: "99999999999999999999", wantErr: true},
+		{name: "negative overflow", in: "-99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in, tt.currency)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) unexpected error: %v", tt.in, err)
+			}
+			if got.Amount != tt.want {
+				t.Errorf("Parse(%q).Amount = %d, want %d", tt.in, got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     string
+	}{
+		{currency: "", want: DefaultCurrency},
+		{currency: "usd", want: "USD"},
+		{currency: " EUR ", want: "EUR"},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse("1", tt.currency)
+		if err != nil {
+			t.Fatalf("Parse with currency %q: %v", tt.currency, err)
+		}
+		if got.Currency != tt.want {
+			t.Errorf("Parse with currency %q: Currency = %q, want %q", tt.currency, got.Currency, tt.want)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, in := range []string{"0.00", "0.05", "-0.50", "123.45", "-1000000.01"} {
+		m, err := Parse(in, "")
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if got := m.String(); got != in {
+			t.Errorf("Parse(%q).String() = %q", in, got)
+		}
+	}
+}
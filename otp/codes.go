@@ -0,0 +1,51 @@
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// число кодов восстановления в наборе
+const RecoveryCodeCount = 10
+
+// алфавит кодов восстановления без похожих символов (0/o, 1/l)
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// набор кодов восстановления: открытые коды показываются клиенту один раз,
+// хранятся только их хеши
+func GenerateRecoveryCodes() (codes, hashes []string) {
+	for range RecoveryCodeCount {
+		code := randomString(recoveryAlphabet, 5) + "-" + randomString(recoveryAlphabet, 5)
+		codes = append(codes, code)
+		hashes = append(hashes, HashCode(code))
+	}
+	return codes, hashes
+}
+
+// одноразовый числовой код для отправки по SMS
+func NumericCode() string {
+	return randomString("0123456789", Digits)
+}
+
+// хеш одноразового кода; регистр и дефисы при вводе не важны
+func HashCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			panic(fmt.Sprintf("Code generation failed: %v", err))
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b)
+}
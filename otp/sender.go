@@ -0,0 +1,49 @@
+package otp
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// доставка одноразовых кодов клиенту; настоящий SMS-шлюз подключается
+// реализацией этого интерфейса
+type Sender interface {
+	Send(phone, message string) error
+}
+
+// вместо SMS код пишется в журнал сервера; только для разработки
+type LogSender struct{}
+
+func (LogSender) Send(phone, message string) error {
+	log.Printf("OTP to %s: %s", phone, message)
+	return nil
+}
+
+// вместо SMS сообщения дописываются в файл, например для тестов
+type FileSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSender(path string) *FileSender {
+	return &FileSender{path: path}
+}
+
+func (fs *FileSender) Send(phone, message string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open OTP file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, message); err != nil {
+		return fmt.Errorf("failed to write OTP: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// параметры TOTP по RFC 6238, которые понимают распространённые
+// приложения-аутентификаторы
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	secretSize = 20 // 160 бит, как рекомендует RFC 4226
+	skew       = 1  // допустимое расхождение часов в шагах
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// новый секрет TOTP в base32
+func GenerateSecret() string {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("TOTP secret generation failed: %v", err))
+	}
+	return encoding.EncodeToString(b)
+}
+
+// номер 30-секундного шага для момента t
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// код для шага step
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// проверка кода с допуском на расхождение часов; коды шагов не позже
+// lastStep уже использованы и не принимаются повторно. возвращает
+// принятый шаг
+func Validate(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI для QR-кода, который сканирует приложение-аутентификатор
+func ProvisioningURI(secret, issuer, accountName string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
@@ -0,0 +1,110 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+// секрет из тестовых векторов RFC 6238 ("12345678901234567890" в base32)
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "previous step within skew", code: codeAt(current - 1), wantStep: current - 1, wantOK: true},
+		{name: "next step within skew", code: codeAt(current + 1), wantStep: current + 1, wantOK: true},
+		{name: "two steps behind", code: codeAt(current - 2)},
+		{name: "two steps ahead", code: codeAt(current + 2)},
+		{name: "wrong code", code: "000000"},
+		{name: "too short", code: codeAt(current)[:Digits-1]},
+		{name: "too long", code: codeAt(current) + "0"},
+		{name: "replay of accepted step", code: codeAt(current), lastStep: current},
+		{name: "older step after newer was used", code: codeAt(current - 1), lastStep: current},
+		{
+			name:     "newer step after older was used",
+			code:     codeAt(current + 1),
+			lastStep: current,
+			wantStep: current + 1,
+			wantOK:   true,
+		},
+		{
+			name:     "current step after previous was used",
+			code:     codeAt(current),
+			lastStep: current - 1,
+			wantStep: current,
+			wantOK:   true,
+		},
+		{name: "invalid secret", secret: "not base32!", code: "123456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := tt.secret
+			if secret == "" {
+				secret = rfcSecret
+			}
+			step, ok := Validate(secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK {
+				t.Fatalf("Validate() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if step != tt.wantStep {
+				t.Errorf("Validate() step = %d, want %d", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestHashCode(t *testing.T) {
+	codes, hashes := GenerateRecoveryCodes()
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), RecoveryCodeCount)
+	}
+	for i, code := range codes {
+		if HashCode(code) != hashes[i] {
+			t.Errorf("hash of code %d does not match", i)
+		}
+	}
+
+	if HashCode("ABCDE-FGHJK") != HashCode(" abcdefghjk ") {
+		t.Error("HashCode must ignore case, dashes and spaces")
+	}
+}
@@ -0,0 +1,143 @@
+package pending
+
+import (
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"time"
+)
+
+// перевод, ожидающий подтверждения, не найден
+var ErrNotFound = errors.New("pending transfer not found")
+
+// статусы перевода, ожидающего подтверждения
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed" // подтверждён и исполнен
+	StatusFailed    = "failed"    // подтверждён, но исполнить не удалось
+	StatusCancelled = "cancelled" // отменён клиентом или после неверных подтверждений
+	StatusExpired   = "expired"   // не подтверждён в срок
+)
+
+// способы подтверждения
+const (
+	MethodPIN = "pin" // PIN входа
+	MethodOTP = "otp" // код второго фактора
+)
+
+// неверных подтверждений, после которых перевод отменяется
+const MaxAttempts = 3
+
+// крупный перевод: создаётся запросом на перевод и исполняется только
+// после подтверждения PIN или кодом
+type Transfer struct {
+	ID        int64       `json:"id"`
+	AccountID string      `json:"account_id"` // счёт списания
+	ToAccount string      `json:"to_account"`
+	Amount    money.Money `json:"amount"`
+	QuoteID   string      `json:"quote_id,omitempty"`
+	Method    string      `json:"method"`
+	Attempts  int         `json:"attempts"` // неверных подтверждений
+	Status    string      `json:"status"`
+	LastError string      `json:"last_error,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// новый перевод, ожидающий подтверждения в течение ttl
+func New(accountID, toAccount string, amount money.Money, quoteID, method string, now time.Time, ttl time.Duration) *Transfer {
+	return &Transfer{
+		AccountID: accountID,
+		ToAccount: toAccount,
+		Amount:    amount,
+		QuoteID:   quoteID,
+		Method:    method,
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		UpdatedAt: now,
+	}
+}
+
+// можно ли ещё подтвердить перевод
+func (t *Transfer) CheckPending(now time.Time) error {
+	if t.Status != StatusPending {
+		return fmt.Errorf("transfer is %s", t.Status)
+	}
+	if !now.Before(t.ExpiresAt) {
+		return fmt.Errorf("transfer has expired")
+	}
+	return nil
+}
+
+// неверное подтверждение; после MaxAttempts перевод отменяется
+func (t *Transfer) Fail(now time.Time) {
+	t.Attempts++
+	if t.Attempts >= MaxAttempts {
+		t.Status = StatusCancelled
+		t.LastError = "too many failed confirmations"
+	}
+	t.UpdatedAt = now
+}
+
+// подтверждение перед исполнением: статус меняется сразу, чтобы
+// параллельное подтверждение не исполнило перевод второй раз
+func (t *Transfer) Confirm(now time.Time) error {
+	if err := t.CheckPending(now); err != nil {
+		return err
+	}
+	t.Status = StatusConfirmed
+	t.UpdatedAt = now
+	return nil
+}
+
+// перевод подтверждён, но не исполнен, например из-за нехватки средств
+func (t *Transfer) SetFailed(reason string, now time.Time) {
+	t.Status = StatusFailed
+	t.LastError = reason
+	t.UpdatedAt = now
+}
+
+// отмена клиентом
+func (t *Transfer) Cancel(now time.Time) error {
+	if t.Status != StatusPending {
+		return fmt.Errorf("transfer is %s", t.Status)
+	}
+	t.Status = StatusCancelled
+	t.UpdatedAt = now
+	return nil
+}
+
+// отметка неподтверждённого в срок перевода; false — срок не вышел
+func (t *Transfer) Expire(now time.Time) bool {
+	if t.Status != StatusPending || now.Before(t.ExpiresAt) {
+		return false
+	}
+	t.Status = StatusExpired
+	t.UpdatedAt = now
+	return true
+}
+
+// нужен ли переводу amount подтверждение при пороге threshold;
+// nil — порог не задан
+func RequiresConfirmation(amount money.Money, threshold *money.Money) bool {
+	return threshold != nil && amount.Currency == threshold.Currency && amount.Amount > threshold.Amount
+}
+
+// порог подтверждения переводов счёта: свой или банка по умолчанию
+// defaultThreshold в валюте счёта; nil — переводы не подтверждаются
+func Threshold(acc *account.Account, defaultThreshold string) *money.Money {
+	if acc.ConfirmThreshold != nil {
+		return acc.ConfirmThreshold
+	}
+	if defaultThreshold == "" {
+		return nil
+	}
+	threshold, err := money.Parse(defaultThreshold, acc.Currency())
+	if err != nil {
+		return nil // порог проверяется при загрузке настроек
+	}
+	return &threshold
+}
@@ -0,0 +1,216 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"mfp/money"
+	"strconv"
+	"time"
+)
+
+// запланированный перевод не найден
+var ErrNotFound = errors.New("scheduled transfer not found")
+
+// периодичность перевода
+const (
+	Once    = "once"
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// статусы запланированного перевода
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+	StatusCompleted = "completed" // все повторы выполнены или дата окончания прошла
+	StatusFailed    = "failed"    // разовый перевод не удался после всех попыток
+)
+
+// запланированный разовый или регулярный перевод
+type Transfer struct {
+	ID         int64       `json:"id"`
+	AccountID  string      `json:"account_id"` // счёт списания
+	ToAccount  string      `json:"to_account"`
+	Amount     money.Money `json:"amount"`
+	Frequency  string      `json:"frequency"`
+	StartAt    time.Time   `json:"start_at"`
+	EndAt      *time.Time  `json:"end_at,omitempty"` // последний допустимый запуск
+	MaxRuns    int         `json:"max_runs"`         // 0 — без ограничения числа повторов
+	Occurrence int         `json:"occurrence"`       // номер текущего повтора от нуля
+	RunCount   int         `json:"run_count"`        // выполнено успешно
+	Attempts   int         `json:"attempts"`         // неудачных попыток текущего повтора
+	NextRunAt  time.Time   `json:"next_run_at"`
+	Status     string      `json:"status"`
+	LastError  string      `json:"last_error,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// политика повторов при ошибке исполнения
+type RetryPolicy struct {
+	MaxAttempts int           // попыток на один повтор
+	Delay       time.Duration // пауза между попытками
+}
+
+// новый перевод; первый запуск в startAt
+func New(accountID, toAccount string, amount money.Money, frequency string, startAt time.Time, endAt *time.Time, maxRuns int) (*Transfer, error) {
+	t := &Transfer{
+		AccountID: accountID,
+		ToAccount: toAccount,
+		Amount:    amount,
+		Frequency: frequency,
+		StartAt:   startAt,
+		EndAt:     endAt,
+		MaxRuns:   maxRuns,
+		NextRunAt: startAt,
+		Status:    StatusActive,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if frequency == Once {
+		t.MaxRuns = 1
+	}
+	return t, t.Validate()
+}
+
+// проверка параметров перевода
+func (t *Transfer) Validate() error {
+	switch t.Frequency {
+	case Once, Daily, Weekly, Monthly:
+	default:
+		return fmt.Errorf("frequency must be once, daily, weekly or monthly")
+	}
+	if t.ToAccount == "" {
+		return fmt.Errorf("destination account required")
+	}
+	if t.ToAccount == t.AccountID {
+		return fmt.Errorf("cannot transfer to the same account")
+	}
+	if !t.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	if t.StartAt.IsZero() {
+		return fmt.Errorf("start_at is required")
+	}
+	if t.MaxRuns < 0 {
+		return fmt.Errorf("max_runs must not be negative")
+	}
+	if t.EndAt != nil && t.EndAt.Before(t.StartAt) {
+		return fmt.Errorf("end_at must not be before start_at")
+	}
+	return nil
+}
+
+// время повтора с номером n; ежемесячный перевод с 31-го числа
+// в коротком месяце выполняется в последний день месяца
+func (t *Transfer) occurrenceAt(n int) time.Time {
+	switch t.Frequency {
+	case Daily:
+		return t.StartAt.AddDate(0, 0, n)
+	case Weekly:
+		return t.StartAt.AddDate(0, 0, 7*n)
+	case Monthly:
+		start := t.StartAt
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		day := start.Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return t.StartAt
+}
+
+// ключ идемпотентности текущего повтора: перевод не выполнится дважды,
+// даже если два экземпляра сервера возьмут его одновременно
+func (t *Transfer) IdempotencyKey() string {
+	return "schedule:" + strconv.FormatInt(t.ID, 10) + ":" + strconv.Itoa(t.Occurrence)
+}
+
+// переход к следующему повтору или завершение; повторы, время которых
+// уже прошло, пропускаются, чтобы после простоя не выполнять их подряд
+func (t *Transfer) advance(now time.Time) {
+	t.Attempts = 0
+	for {
+		t.Occurrence++
+		t.NextRunAt = t.occurrenceAt(t.Occurrence)
+
+		if t.Frequency == Once || t.MaxRuns > 0 && t.Occurrence >= t.MaxRuns {
+			t.Status = StatusCompleted
+			return
+		}
+		if t.EndAt != nil && t.NextRunAt.After(*t.EndAt) {
+			t.Status = StatusCompleted
+			return
+		}
+		if !t.NextRunAt.Before(now) {
+			return
+		}
+	}
+}
+
+// возобновление после паузы: пропущенные за время паузы повторы
+// не выполняются, разовый перевод с прошедшей датой выполняется сразу
+func (t *Transfer) Resume(now time.Time) {
+	t.Status = StatusActive
+	t.UpdatedAt = now
+	if !t.NextRunAt.Before(now) {
+		return
+	}
+	if t.Frequency == Once {
+		t.NextRunAt = now
+		return
+	}
+	t.advance(now)
+}
+
+// успешное исполнение текущего повтора
+func (t *Transfer) Succeeded(now time.Time) {
+	t.RunCount++
+	t.LastError = ""
+	t.UpdatedAt = now
+	t.advance(now)
+}
+
+// неудачная попытка; возвращает true, если попытки исчерпаны:
+// разовый перевод отмечается неудавшимся, у регулярного пропускается повтор
+func (t *Transfer) Failed(err error, policy RetryPolicy, now time.Time) bool {
+	t.Attempts++
+	t.LastError = err.Error()
+	t.UpdatedAt = now
+
+	if t.Attempts < policy.MaxAttempts {
+		t.NextRunAt = now.Add(policy.Delay)
+		return false
+	}
+
+	if t.Frequency == Once {
+		t.Status = StatusFailed
+		return true
+	}
+	t.advance(now)
+	return true
+}
+
+// перевод ставится на паузу, если исполнять его без клиента больше нельзя
+func (t *Transfer) Paused(reason string, now time.Time) {
+	t.Status = StatusPaused
+	t.LastError = reason
+	t.UpdatedAt = now
+}
+
+// проверка перехода при ручной смене статуса клиентом
+func CheckStatusChange(from, to string) error {
+	switch {
+	case to == StatusPaused && from == StatusActive:
+	case to == StatusActive && from == StatusPaused:
+	case to == StatusCancelled && (from == StatusActive || from == StatusPaused):
+	default:
+		return fmt.Errorf("cannot change scheduled transfer from %s to %s", from, to)
+	}
+	return nil
+}
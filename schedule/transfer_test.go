@@ -0,0 +1,213 @@
+package schedule
+
+import (
+	"errors"
+	"mfp/money"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)
+
+func newTransfer(t *testing.T, frequency string, endAt *time.Time, maxRuns int) *Transfer {
+	t.Helper()
+	tr, err := New("7700000001", "7700000002", money.FromMinor(10000, "KZT"), frequency, start, endAt, maxRuns)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return tr
+}
+
+func TestSucceededSkipsMissedOccurrences(t *testing.T) {
+	end := start.AddDate(0, 0, 5)
+
+	tests := []struct {
+		name           string
+		frequency      string
+		endAt          *time.Time
+		maxRuns        int
+		now            time.Time
+		wantOccurrence int
+		wantNext       time.Time
+		wantStatus     string
+	}{
+		{
+			name:           "on time",
+			frequency:      Daily,
+			now:            start.Add(time.Minute),
+			wantOccurrence: 1,
+			wantNext:       start.AddDate(0, 0, 1),
+			wantStatus:     StatusActive,
+		},
+		{
+			name:           "after three day gap",
+			frequency:      Daily,
+			now:            start.AddDate(0, 0, 3).Add(time.Hour),
+			wantOccurrence: 4,
+			wantNext:       start.AddDate(0, 0, 4),
+			wantStatus:     StatusActive,
+		},
+		{
+			name:           "exactly at next occurrence",
+			frequency:      Weekly,
+			now:            start.AddDate(0, 0, 7),
+			wantOccurrence: 1,
+			wantNext:       start.AddDate(0, 0, 7),
+			wantStatus:     StatusActive,
+		},
+		{
+			name:           "gap past max runs",
+			frequency:      Daily,
+			maxRuns:        3,
+			now:            start.AddDate(0, 0, 10),
+			wantOccurrence: 3,
+			wantNext:       start.AddDate(0, 0, 3),
+			wantStatus:     StatusCompleted,
+		},
+		{
+			name:           "gap past end date",
+			frequency:      Daily,
+			endAt:          &end,
+			now:            start.AddDate(0, 0, 10),
+			wantOccurrence: 6,
+			wantNext:       start.AddDate(0, 0, 6),
+			wantStatus:     StatusCompleted,
+		},
+		{
+			name:           "once",
+			frequency:      Once,
+			now:            start.AddDate(0, 0, 2),
+			wantOccurrence: 1,
+			wantNext:       start,
+			wantStatus:     StatusCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTransfer(t, tt.frequency, tt.endAt, tt.maxRuns)
+			tr.Succeeded(tt.now)
+
+			if tr.Occurrence != tt.wantOccurrence {
+				t.Errorf("Occurrence = %d, want %d", tr.Occurrence, tt.wantOccurrence)
+			}
+			if !tr.NextRunAt.Equal(tt.wantNext) {
+				t.Errorf("NextRunAt = %v, want %v", tr.NextRunAt, tt.wantNext)
+			}
+			if tr.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", tr.Status, tt.wantStatus)
+			}
+			if tr.RunCount != 1 {
+				t.Errorf("RunCount = %d, want 1", tr.RunCount)
+			}
+		})
+	}
+}
+
+func TestFailedSkipsMissedOccurrences(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, Delay: time.Minute}
+	tr := newTransfer(t, Daily, nil, 0)
+
+	now := start.AddDate(0, 0, 2)
+	if tr.Failed(errors.New("insufficient funds"), policy, now) {
+		t.Fatal("first failure exhausted attempts")
+	}
+	if want := now.Add(policy.Delay); !tr.NextRunAt.Equal(want) {
+		t.Fatalf("retry NextRunAt = %v, want %v", tr.NextRunAt, want)
+	}
+
+	now = now.Add(policy.Delay)
+	if !tr.Failed(errors.New("insufficient funds"), policy, now) {
+		t.Fatal("second failure did not exhaust attempts")
+	}
+	if tr.Occurrence != 3 || !tr.NextRunAt.Equal(start.AddDate(0, 0, 3)) {
+		t.Errorf("after failure: occurrence %d at %v, want 3 at %v", tr.Occurrence, tr.NextRunAt, start.AddDate(0, 0, 3))
+	}
+	if tr.Attempts != 0 {
+		t.Errorf("Attempts = %d, want 0", tr.Attempts)
+	}
+}
+
+func TestResume(t *testing.T) {
+	end := start.AddDate(0, 2, 0)
+
+	tests := []struct {
+		name           string
+		frequency      string
+		endAt          *time.Time
+		maxRuns        int
+		now            time.Time
+		wantOccurrence int
+		wantNext       time.Time
+		wantStatus     string
+	}{
+		{
+			name:           "before next run",
+			frequency:      Monthly,
+			now:            start.Add(-time.Hour),
+			wantOccurrence: 0,
+			wantNext:       start,
+			wantStatus:     StatusActive,
+		},
+		{
+			name:           "monthly after gap",
+			frequency:      Monthly,
+			now:            start.AddDate(0, 3, 1),
+			wantOccurrence: 4,
+			wantNext:       start.AddDate(0, 4, 0),
+			wantStatus:     StatusActive,
+		},
+		{
+			name:           "weekly after gap",
+			frequency:      Weekly,
+			now:            start.AddDate(0, 0, 20),
+			wantOccurrence: 3,
+			wantNext:       start.AddDate(0, 0, 21),
+			wantStatus:     StatusActive,
+		},
+		{
+			name:           "gap past end date",
+			frequency:      Monthly,
+			endAt:          &end,
+			now:            start.AddDate(0, 5, 0),
+			wantOccurrence: 3,
+			wantNext:       start.AddDate(0, 3, 0),
+			wantStatus:     StatusCompleted,
+		},
+		{
+			name:           "gap past max runs",
+			frequency:      Weekly,
+			maxRuns:        2,
+			now:            start.AddDate(0, 1, 0),
+			wantOccurrence: 2,
+			wantNext:       start.AddDate(0, 0, 14),
+			wantStatus:     StatusCompleted,
+		},
+		{
+			name:           "once runs immediately",
+			frequency:      Once,
+			now:            start.AddDate(0, 0, 3),
+			wantOccurrence: 0,
+			wantNext:       start.AddDate(0, 0, 3),
+			wantStatus:     StatusActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTransfer(t, tt.frequency, tt.endAt, tt.maxRuns)
+			tr.Status = StatusPaused
+			tr.Resume(tt.now)
+
+			if tr.Occurrence != tt.wantOccurrence {
+				t.Errorf("Occurrence = %d, want %d", tr.Occurrence, tt.wantOccurrence)
+			}
+			if !tr.NextRunAt.Equal(tt.wantNext) {
+				t.Errorf("NextRunAt = %v, want %v", tr.NextRunAt, tt.wantNext)
+			}
+			if tr.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", tr.Status, tt.wantStatus)
+			}
+		})
+	}
+}
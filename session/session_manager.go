@@ -4,7 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"sync"
+	"log"
 	"time"
 )
 
@@ -16,16 +16,22 @@ type Session struct {
 	ExpiresAt    time.Time `json:"expires_at"`
 	UserAgent    string    `json:"user_agent"`
 	IP           string    `json:"ip"`
+	// вход не завершён: пароль проверен, второй фактор ещё нет.
+	// такая сессия не продлевается и открывает только подтверждение входа
+	Pending bool `json:"pending"`
 }
 
 type SessionManager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
+	store       Store
+	ttl         time.Duration // время жизни сессии без активности
+	maxSessions int           // максимум одновременных сессий пользователя
 }
 
-func NewSessionManager() *SessionManager {
+func NewSessionManager(store Store, ttl time.Duration, maxSessions int) *SessionManager {
 	sm := &SessionManager{
-		sessions: make(map[string]*Session),
+		store:       store,
+		ttl:         ttl,
+		maxSessions: maxSessions,
 	}
 
 	go func() {
@@ -37,18 +43,16 @@ func NewSessionManager() *SessionManager {
 	return sm
 }
 
-func (sm *SessionManager) CreateSession(userID, ip, userAgent string) string {
-	sessions := sm.GetUserSessions(userID)
-	if len(sessions) > 2 {
-		oldestSession := sessions[0]
-		for _, sess := range sessions[1:] {
-			if sess.CreatedAt.Before(oldestSession.CreatedAt) {
-				oldestSession = sess
-			}
-		}
-		sm.DeleteSession(oldestSession.ID)
-	}
+func (sm *SessionManager) CreateSession(userID, ip, userAgent string) (string, error) {
+	return sm.create(userID, ip, userAgent, sm.ttl, false)
+}
+
+// сессия до проверки второго фактора с коротким сроком ttl
+func (sm *SessionManager) CreatePendingSession(userID, ip, userAgent string, ttl time.Duration) (string, error) {
+	return sm.create(userID, ip, userAgent, ttl, true)
+}
 
+func (sm *SessionManager) create(userID, ip, userAgent string, ttl time.Duration, pending bool) (string, error) {
 	sessionID := generateSessionID()
 	timestamp := time.Now()
 
@@ -57,24 +61,23 @@ func (sm *SessionManager) CreateSession(userID, ip, userAgent string) string {
 		UserID:       userID,
 		CreatedAt:    timestamp,
 		LastActivity: timestamp,
-		ExpiresAt:    timestamp.Add(15 * time.Minute),
+		ExpiresAt:    timestamp.Add(ttl),
 		UserAgent:    userAgent,
 		IP:           ip,
+		Pending:      pending,
 	}
 
-	sm.mu.Lock()
-	sm.sessions[sessionID] = session
-	sm.mu.Unlock()
+	// хранилище атомарно вытесняет самые старые сессии сверх лимита
+	if err := sm.store.Create(session, sm.maxSessions); err != nil {
+		return "", fmt.Errorf("failed to save session: %v", err)
+	}
 
-	return sessionID
+	return sessionID, nil
 }
 
 func (sm *SessionManager) GetSession(sessionID string) (*Session, error) {
-	sm.mu.RLock()
-	session, exists := sm.sessions[sessionID]
-	sm.mu.RUnlock()
-
-	if session == nil || !exists {
+	session, err := sm.store.Get(sessionID)
+	if err != nil || session == nil {
 		return nil, fmt.Errorf("session not found")
 	}
 
@@ -82,51 +85,37 @@ func (sm *SessionManager) GetSession(sessionID string) (*Session, error) {
 		sm.DeleteSession(sessionID)
 		return nil, fmt.Errorf("session expired")
 	}
+	if session.Pending {
+		return session, nil
+	}
 
-	session.LastActivity = time.Now()
-	session.ExpiresAt = session.LastActivity.Add(15 * time.Minute)
+	now := time.Now()
+	session, err = sm.store.Touch(sessionID, now, now.Add(sm.ttl))
+	if err != nil {
+		return nil, fmt.Errorf("session expired")
+	}
 
 	return session, nil
 }
 
 func (sm *SessionManager) DeleteSession(sessionID string) {
-	sm.mu.Lock()
-	delete(sm.sessions, sessionID)
-	sm.mu.Unlock()
+	if err := sm.store.Delete(sessionID); err != nil {
+		log.Printf("Failed to delete session: %v", err)
+	}
 }
 
 func (sm *SessionManager) GetUserSessions(userID string) []*Session {
-	result := []*Session{}
-
-	sm.mu.RLock()
-	for _, session := range sm.sessions {
-		if session.UserID == userID {
-			result = append(result, session)
-		}
+	sessions, err := sm.store.ListByUser(userID)
+	if err != nil {
+		log.Printf("Failed to list user sessions: %v", err)
+		return []*Session{}
 	}
-	sm.mu.RUnlock()
-
-	return result
+	return sessions
 }
 
 func (sm *SessionManager) CleanupExpiredSessions() {
-	timestamp := time.Now()
-	expiredSessions := []string{}
-
-	sm.mu.RLock()
-	for id, session := range sm.sessions {
-		if timestamp.After(session.ExpiresAt) {
-			expiredSessions = append(expiredSessions, id)
-		}
-	}
-	sm.mu.RUnlock()
-
-	if len(expiredSessions) > 0 {
-		sm.mu.Lock()
-		for _, id := range expiredSessions {
-			delete(sm.sessions, id)
-		}
-		sm.mu.Unlock()
+	if err := sm.store.DeleteExpired(time.Now()); err != nil {
+		log.Printf("Failed to clean up expired sessions: %v", err)
 	}
 }
 
@@ -0,0 +1,139 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// хранилище сессий
+type Store interface {
+	// сохранение новой сессии; самые старые сессии пользователя
+	// удаляются так, чтобы вместе с новой их было не больше maxPerUser
+	Create(session *Session, maxPerUser int) error
+	Get(sessionID string) (*Session, error)
+	// продление активной сессии; истёкшая сессия не продлевается
+	Touch(sessionID string, lastActivity, expiresAt time.Time) (*Session, error)
+	Delete(sessionID string) error
+	ListByUser(userID string) ([]*Session, error)
+	DeleteExpired(now time.Time) error
+}
+
+// хранилище сессий в памяти процесса
+type MemoryStore struct {
+	sessions map[string]*Session
+	mu       sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]*Session)}
+}
+
+func (ms *MemoryStore) Create(session *Session, maxPerUser int) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var userSessions []*Session
+	for _, sess := range ms.sessions {
+		if sess.UserID == session.UserID {
+			userSessions = append(userSessions, sess)
+		}
+	}
+	sort.Slice(userSessions, func(i, j int) bool { return userSessions[i].CreatedAt.Before(userSessions[j].CreatedAt) })
+	for len(userSessions) >= maxPerUser {
+		delete(ms.sessions, userSessions[0].ID)
+		userSessions = userSessions[1:]
+	}
+
+	copied := *session
+	ms.sessions[session.ID] = &copied
+	return nil
+}
+
+func (ms *MemoryStore) Get(sessionID string) (*Session, error) {
+	ms.mu.RLock()
+	session, exists := ms.sessions[sessionID]
+	ms.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (ms *MemoryStore) Touch(sessionID string, lastActivity, expiresAt time.Time) (*Session, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	session, exists := ms.sessions[sessionID]
+	if !exists || lastActivity.After(session.ExpiresAt) {
+		return nil, fmt.Errorf("session not found")
+	}
+	session.LastActivity = lastActivity
+	session.ExpiresAt = expiresAt
+
+	copied := *session
+	return &copied, nil
+}
+
+func (ms *MemoryStore) Delete(sessionID string) error {
+	ms.mu.Lock()
+	delete(ms.sessions, sessionID)
+	ms.mu.Unlock()
+	return nil
+}
+
+func (ms *MemoryStore) ListByUser(userID string) ([]*Session, error) {
+	result := []*Session{}
+
+	ms.mu.RLock()
+	for _, session := range ms.sessions {
+		if session.UserID == userID {
+			copied := *session
+			result = append(result, &copied)
+		}
+	}
+	ms.mu.RUnlock()
+
+	return result, nil
+}
+
+func (ms *MemoryStore) DeleteExpired(now time.Time) error {
+	ms.mu.Lock()
+	for id, session := range ms.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(ms.sessions, id)
+		}
+	}
+	ms.mu.Unlock()
+	return nil
+}
+
+// все сессии, например для сохранения в файл
+func (ms *MemoryStore) All() []*Session {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	sessions := make([]*Session, 0, len(ms.sessions))
+	for _, session := range ms.sessions {
+		copied := *session
+		sessions = append(sessions, &copied)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// замена содержимого сохранёнными сессиями
+func (ms *MemoryStore) Load(sessions []*Session) {
+	loaded := make(map[string]*Session, len(sessions))
+	for _, session := range sessions {
+		copied := *session
+		loaded[session.ID] = &copied
+	}
+
+	ms.mu.Lock()
+	ms.sessions = loaded
+	ms.mu.Unlock()
+}
@@ -0,0 +1,177 @@
+package statements
+
+import (
+	"encoding/xml"
+	"io"
+	"mfp/money"
+	"strconv"
+)
+
+// пространство имён ISO 20022 BankToCustomerStatement, версия 02
+const camtNamespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+const camtDateTime = "2006-01-02T15:04:05"
+
+type camtDocument struct {
+	XMLName   xml.Name `xml:"Document"`
+	Namespace string   `xml:"xmlns,attr"`
+	Statement struct {
+		Header struct {
+			MessageID string `xml:"MsgId"`
+			Created   string `xml:"CreDtTm"`
+		} `xml:"GrpHdr"`
+		Stmt camtStatement `xml:"Stmt"`
+	} `xml:"BkToCstmrStmt"`
+}
+
+type camtStatement struct {
+	ID      string `xml:"Id"`
+	Created string `xml:"CreDtTm"`
+	Period  struct {
+		From string `xml:"FrDtTm"`
+		To   string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Account struct {
+		ID struct {
+			Other struct {
+				ID string `xml:"Id"`
+			} `xml:"Othr"`
+		} `xml:"Id"`
+		Currency string `xml:"Ccy"`
+		Owner    struct {
+			Name string `xml:"Nm"`
+		} `xml:"Ownr"`
+		Servicer struct {
+			Institution struct {
+				Other struct {
+					ID string `xml:"Id"`
+				} `xml:"Othr"`
+			} `xml:"FinInstnId"`
+		} `xml:"Svcr"`
+	} `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Summary  struct {
+		Total  camtTotal `xml:"TtlNtries"`
+		Credit camtTotal `xml:"TtlCdtNtries"`
+		Debit  camtTotal `xml:"TtlDbtNtries"`
+	} `xml:"TxsSummry"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type    string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount  camtAmount `xml:"Amt"`
+	CdtDbt  string     `xml:"CdtDbtInd"`
+	DateISO string     `xml:"Dt>Dt"`
+}
+
+type camtTotal struct {
+	Count int    `xml:"NbOfNtries"`
+	Sum   string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	Reference string     `xml:"NtryRef"`
+	Amount    camtAmount `xml:"Amt"`
+	CdtDbt    string     `xml:"CdtDbtInd"`
+	Status    string     `xml:"Sts"`
+	Booked    string     `xml:"BookgDt>DtTm"`
+	Value     string     `xml:"ValDt>DtTm"`
+	Code      string     `xml:"BkTxCd>Prtry>Cd"`
+	Issuer    string     `xml:"BkTxCd>Prtry>Issr"`
+	Details   struct {
+		EndToEndID   string `xml:"TxDtls>Refs>EndToEndId"`
+		Counterparty string `xml:"TxDtls>RltdPties>CdtrAcct>Id>Othr>Id,omitempty"`
+		Info         string `xml:"TxDtls>AddtlTxInf,omitempty"`
+	} `xml:"NtryDtls"`
+}
+
+// выписка в формате ISO 20022 camt.053
+func WriteCAMT053(w io.Writer, st *Statement) error {
+	doc := camtDocument{Namespace: camtNamespace}
+	id := st.AccountID + "-" + st.From.Format("20060102") + "-" + st.LastDay().Format("20060102")
+
+	doc.Statement.Header.MessageID = id
+	doc.Statement.Header.Created = st.GeneratedAt.Format(camtDateTime)
+
+	stmt := &doc.Statement.Stmt
+	stmt.ID = id
+	stmt.Created = st.GeneratedAt.Format(camtDateTime)
+	stmt.Period.From = st.From.Format(camtDateTime)
+	stmt.Period.To = st.LastDay().Format(camtDateTime)
+	stmt.Account.ID.Other.ID = st.AccountID
+	stmt.Account.Currency = st.Currency
+	stmt.Account.Owner.Name = st.Owner
+	stmt.Account.Servicer.Institution.Other.ID = bankID
+
+	stmt.Balances = []camtBalance{
+		camtBalanceOf("OPBD", st.Opening, st.From.Format("2006-01-02")),
+		camtBalanceOf("CLBD", st.Closing, st.LastDay().Format("2006-01-02")),
+	}
+
+	credits, debits := 0, 0
+	for _, line := range st.Lines {
+		tx := line.Transaction
+		entry := camtEntry{
+			Reference: strconv.Itoa(tx.ID),
+			Amount:    camtAmount{Currency: tx.Amount.Currency, Value: tx.Amount.String()},
+			CdtDbt:    "DBIT",
+			Status:    "BOOK",
+			Booked:    tx.Timestamp.Format(camtDateTime),
+			Value:     tx.Timestamp.Format(camtDateTime),
+			Code:      tx.Type,
+			Issuer:    bankID,
+		}
+		if tx.IsIncoming() {
+			entry.CdtDbt = "CRDT"
+			credits++
+		} else {
+			debits++
+		}
+		entry.Details.EndToEndID = strconv.Itoa(tx.ID)
+		entry.Details.Counterparty = tx.Counterparty()
+		if tx.Rate != "" {
+			entry.Details.Info = "rate " + tx.Rate
+		}
+		stmt.Entries = append(stmt.Entries, entry)
+	}
+
+	total, err := st.TotalIn.Add(st.TotalOut)
+	if err != nil {
+		return err
+	}
+	stmt.Summary.Total = camtTotal{Count: len(st.Lines), Sum: total.String()}
+	stmt.Summary.Credit = camtTotal{Count: credits, Sum: st.TotalIn.String()}
+	stmt.Summary.Debit = camtTotal{Count: debits, Sum: st.TotalOut.String()}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// баланс camt.053: сумма без знака и признак CRDT или DBIT
+func camtBalanceOf(code string, balance money.Money, date string) camtBalance {
+	indicator := "CRDT"
+	if balance.IsNegative() {
+		indicator = "DBIT"
+		balance = balance.Neg()
+	}
+	return camtBalance{
+		Type:    code,
+		Amount:  camtAmount{Currency: balance.Currency, Value: balance.String()},
+		CdtDbt:  indicator,
+		DateISO: date,
+	}
+}
@@ -0,0 +1,46 @@
+package statements
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// выписка в CSV: заголовок счёта, входящий баланс, операции
+// с нарастающим балансом и исходящий баланс
+func WriteCSV(w io.Writer, st *Statement) error {
+	cw := csv.NewWriter(w)
+
+	records := [][]string{
+		{"account", st.AccountID},
+		{"owner", st.Owner},
+		{"currency", st.Currency},
+		{"period", st.From.Format("2006-01-02"), st.LastDay().Format("2006-01-02")},
+		{},
+		{"date", "id", "type", "counterparty", "amount", "balance", "rate"},
+		{"", "", "opening_balance", "", "", st.Opening.String(), ""},
+	}
+	for _, line := range st.Lines {
+		tx := line.Transaction
+		records = append(records, []string{
+			tx.Timestamp.Format("2006-01-02 15:04:05"),
+			strconv.Itoa(tx.ID),
+			tx.Type,
+			tx.Counterparty(),
+			line.Signed().String(),
+			line.Balance.String(),
+			tx.Rate,
+		})
+	}
+	records = append(records,
+		[]string{"", "", "closing_balance", "", "", st.Closing.String(), ""},
+		[]string{},
+		[]string{"total_in", st.TotalIn.String()},
+		[]string{"total_out", st.TotalOut.String()},
+	)
+
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,133 @@
+package statements
+
+import (
+	"encoding/xml"
+	"io"
+	"mfp/account"
+	"strconv"
+	"time"
+)
+
+// идентификатор банка в выгрузках OFX и camt.053
+const bankID = "MFP"
+
+// формат даты OFX: YYYYMMDDHHMMSS
+const ofxTime = "20060102150405"
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Response struct {
+			Status   ofxStatus `xml:"STATUS"`
+			Server   string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		Transaction struct {
+			UID       string       `xml:"TRNUID"`
+			Status    ofxStatus    `xml:"STATUS"`
+			Statement ofxStatement `xml:"STMTRS"`
+		} `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStatement struct {
+	Currency string `xml:"CURDEF"`
+	Account  struct {
+		BankID string `xml:"BANKID"`
+		ID     string `xml:"ACCTID"`
+		Type   string `xml:"ACCTTYPE"`
+	} `xml:"BANKACCTFROM"`
+	List struct {
+		Start        string           `xml:"DTSTART"`
+		End          string           `xml:"DTEND"`
+		Transactions []ofxTransaction `xml:"STMTTRN"`
+	} `xml:"BANKTRANLIST"`
+	Ledger ofxBalance `xml:"LEDGERBAL"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FITID  string `xml:"FITID"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+// выписка в OFX 2.2 (XML)
+func WriteOFX(w io.Writer, st *Statement) error {
+	var doc ofxDocument
+	ok := ofxStatus{Code: 0, Severity: "INFO"}
+
+	doc.SignOn.Response.Status = ok
+	doc.SignOn.Response.Server = st.GeneratedAt.Format(ofxTime)
+	doc.SignOn.Response.Language = "RUS"
+
+	doc.Bank.Transaction.UID = st.AccountID + "-" + strconv.FormatInt(st.GeneratedAt.Unix(), 10)
+	doc.Bank.Transaction.Status = ok
+
+	stmt := &doc.Bank.Transaction.Statement
+	stmt.Currency = st.Currency
+	stmt.Account.BankID = bankID
+	stmt.Account.ID = st.AccountID
+	stmt.Account.Type = "CHECKING"
+	stmt.List.Start = st.From.Format(ofxTime)
+	stmt.List.End = st.To.Format(ofxTime)
+	for _, line := range st.Lines {
+		tx := line.Transaction
+		stmt.List.Transactions = append(stmt.List.Transactions, ofxTransaction{
+			Type:   ofxTransactionType(tx),
+			Posted: tx.Timestamp.Format(ofxTime),
+			Amount: line.Signed().String(),
+			FITID:  strconv.Itoa(tx.ID),
+			Name:   tx.Counterparty(),
+			Memo:   tx.Type,
+		})
+	}
+	stmt.Ledger = ofxBalance{Amount: st.Closing.String(), AsOf: st.To.Add(-time.Second).Format(ofxTime)}
+
+	if _, err := io.WriteString(w, xml.Header+
+		`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`+"\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func ofxTransactionType(tx *account.Transaction) string {
+	switch tx.Type {
+	case account.TypeDeposit:
+		return "DEP"
+	case account.TypeWithdrawal:
+		return "ATM"
+	case account.TypeTransferIn, account.TypeTransferOut:
+		return "XFER"
+	case account.TypeOverdraftInterest, account.TypeInterest:
+		return "INT"
+	case account.TypeFee:
+		return "SRVCHG"
+	case account.TypeCardPayment:
+		return "POS"
+	}
+	if tx.IsIncoming() {
+		return "CREDIT"
+	}
+	return "DEBIT"
+}
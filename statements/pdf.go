@@ -0,0 +1,144 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// параметры страницы A4 в пунктах
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	pageMargin   = 50
+	lineHeight   = 14
+	linesPerPage = (pageHeight - 2*pageMargin) / lineHeight
+)
+
+// простая PDF-выписка моноширинным шрифтом Courier без внешних зависимостей;
+// стандартные шрифты PDF не содержат кириллицы, поэтому она транслитерируется
+func WritePDF(w io.Writer, st *Statement) error {
+	lines := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		"Account:  " + st.AccountID,
+		"Owner:    " + st.Owner,
+		"Currency: " + st.Currency,
+		"Period:   " + st.From.Format("2006-01-02") + " - " + st.LastDay().Format("2006-01-02"),
+		"",
+		fmt.Sprintf("%-19s %-12s %-18s %14s %14s", "Date", "Type", "Counterparty", "Amount", "Balance"),
+		strings.Repeat("-", 81),
+		fmt.Sprintf("%-19s %-12s %-18s %14s %14s", "", "opening", "", "", st.Opening.String()),
+	}
+	for _, line := range st.Lines {
+		tx := line.Transaction
+		lines = append(lines, fmt.Sprintf("%-19s %-12s %-18s %14s %14s",
+			tx.Timestamp.Format("2006-01-02 15:04:05"), truncate(tx.Type, 12), truncate(tx.Counterparty(), 18),
+			line.Signed().String(), line.Balance.String()))
+	}
+	lines = append(lines,
+		fmt.Sprintf("%-19s %-12s %-18s %14s %14s", "", "closing", "", "", st.Closing.String()),
+		strings.Repeat("-", 81),
+		"Total in:  "+st.TotalIn.Format(),
+		"Total out: "+st.TotalOut.Format(),
+		"",
+		"Generated "+st.GeneratedAt.Format("2006-01-02 15:04:05"),
+	)
+
+	var pages [][]string
+	for len(lines) > linesPerPage {
+		pages = append(pages, lines[:linesPerPage])
+		lines = lines[linesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	return writePDFPages(w, pages)
+}
+
+// объекты: 1 — каталог, 2 — дерево страниц, 3 — шрифт,
+// затем по паре «страница, содержимое» на каждую страницу
+func writePDFPages(w io.Writer, pages [][]string) error {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT /F1 9 Tf %d TL %d %d Td\n", lineHeight, pageMargin, pageHeight-pageMargin)
+		for _, text := range page {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDF(text))
+		}
+		content.WriteString("ET")
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// транслитерация кириллицы для стандартного шрифта
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'ә': "a", 'ғ': "g", 'қ': "q", 'ң': "n", 'ө': "o", 'ұ': "u", 'ү': "u", 'һ': "h", 'і': "i",
+}
+
+// экранирование строки PDF; символы вне ASCII транслитерируются или заменяются на '?'
+func escapePDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		default:
+			lower := []rune(strings.ToLower(string(r)))[0]
+			latin, ok := translit[lower]
+			if !ok {
+				b.WriteByte('?')
+				continue
+			}
+			if lower != r && latin != "" {
+				latin = strings.ToUpper(latin[:1]) + latin[1:]
+			}
+			b.WriteString(latin)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,143 @@
+package statements
+
+import (
+	"fmt"
+	"io"
+	"mfp/account"
+	"mfp/money"
+	"mfp/storage"
+	"sort"
+	"time"
+)
+
+// строка выписки: операция и баланс счёта после неё
+type Line struct {
+	Transaction *account.Transaction `json:"transaction"`
+	Balance     money.Money          `json:"balance"`
+}
+
+// сумма операции со знаком: поступления положительные, списания отрицательные
+func (l Line) Signed() money.Money {
+	if l.Transaction.IsIncoming() {
+		return l.Transaction.Amount
+	}
+	return l.Transaction.Amount.Neg()
+}
+
+// выписка по счёту за период [From, To)
+type Statement struct {
+	AccountID   string      `json:"account_id"`
+	Owner       string      `json:"owner"`
+	Currency    string      `json:"currency"`
+	From        time.Time   `json:"from"`
+	To          time.Time   `json:"to"`
+	Opening     money.Money `json:"opening_balance"`
+	Closing     money.Money `json:"closing_balance"`
+	TotalIn     money.Money `json:"total_in"`
+	TotalOut    money.Money `json:"total_out"`
+	Lines       []Line      `json:"lines"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
+
+// формирование выписки: входящий баланс восстанавливается от текущего
+// вычитанием всех операций с начала периода
+func Generate(store storage.Store, accountID string, from, to time.Time) (*Statement, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("period start must be before its end")
+	}
+
+	acc, err := store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := store.QueryTransactions(acc.ID, storage.TransactionFilter{From: from, To: to, Sort: storage.SortDateAsc})
+	if err != nil {
+		return nil, err
+	}
+	// нужны только итоги операций после периода
+	after, err := store.QueryTransactions(acc.ID, storage.TransactionFilter{From: to, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	closing, err := unwind(acc.Balance, after.Summary)
+	if err != nil {
+		return nil, err
+	}
+	opening, err := unwind(closing, period.Summary)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Statement{
+		AccountID:   acc.ID,
+		Owner:       acc.Name,
+		Currency:    acc.Currency(),
+		From:        from,
+		To:          to,
+		Opening:     opening,
+		Closing:     closing,
+		TotalIn:     period.Summary.In,
+		TotalOut:    period.Summary.Out,
+		Lines:       []Line{},
+		GeneratedAt: time.Now(),
+	}
+
+	transactions := period.Transactions
+	sort.SliceStable(transactions, func(i, j int) bool { return transactions[i].Timestamp.Before(transactions[j].Timestamp) })
+
+	// в выписку попадают только проведённые операции: незавершённые
+	// и отменённые оплаты картой баланс не меняли
+	balance := opening
+	for _, tx := range transactions {
+		if !tx.IsBooked() {
+			continue
+		}
+		line := Line{Transaction: tx}
+		if balance, err = balance.Add(line.Signed()); err != nil {
+			return nil, err
+		}
+		line.Balance = balance
+		st.Lines = append(st.Lines, line)
+	}
+	return st, nil
+}
+
+// баланс до операций, попавших в итоги
+func unwind(balance money.Money, summary storage.TransactionSummary) (money.Money, error) {
+	balance, err := balance.Sub(summary.In)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return balance.Add(summary.Out)
+}
+
+// формат выгрузки выписки
+type Format struct {
+	ContentType string
+	Extension   string
+	Write       func(w io.Writer, st *Statement) error
+}
+
+// поддерживаемые форматы по имени параметра format
+var formats = map[string]Format{
+	"csv":     {"text/csv; charset=utf-8", "csv", WriteCSV},
+	"pdf":     {"application/pdf", "pdf", WritePDF},
+	"ofx":     {"application/x-ofx", "ofx", WriteOFX},
+	"camt053": {"application/xml", "xml", WriteCAMT053},
+}
+
+// формат по имени
+func Lookup(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unknown statement format %q, expected csv, pdf, ofx or camt053", name)
+	}
+	return format, nil
+}
+
+// последний день периода для отображения: To не входит в период
+func (st *Statement) LastDay() time.Time {
+	return st.To.Add(-time.Nanosecond)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"mfp/account"
+)
+
+// карты хранятся вместе со счетами в account.AccountList, поэтому
+// файловое хранилище сохраняет их в том же файле
+
+func (ms *MemoryStore) IssueCard(c *account.Card) error {
+	err := ms.accounts.IssueCard(c)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return ErrAccountNotFound
+	}
+	return err
+}
+
+func (ms *MemoryStore) GetCard(id string) (*account.Card, error) {
+	return ms.accounts.GetCard(id)
+}
+
+func (ms *MemoryStore) GetCards(accountIDs []string) ([]*account.Card, error) {
+	return ms.accounts.GetCards(accountIDs), nil
+}
+
+func (ms *MemoryStore) SetCardStatus(id, status, reason string) error {
+	return ms.accounts.SetCardStatus(id, status, reason)
+}
+
+func (ms *MemoryStore) SetCardPIN(id, pinHash string) error {
+	return ms.accounts.SetCardPIN(id, pinHash)
+}
+
+func (ms *MemoryStore) ReissueCard(id string) (*account.Card, error) {
+	return ms.accounts.ReissueCard(id)
+}
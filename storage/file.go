@@ -0,0 +1,503 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/fees"
+	"mfp/fx"
+	"mfp/keyring"
+	"mfp/money"
+	"mfp/pending"
+	"mfp/schedule"
+	"mfp/session"
+	"mfp/token"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// операция ничего не изменила, и файл переписывать не нужно
+var errUnchanged = errors.New("nothing changed")
+
+// хранилище в JSON-файле: данные держатся в памяти, а каждое изменение
+// выполняется над копией, которая целиком записывается во временный файл
+// и переименовывается поверх прежнего. Копия заменяет данные в памяти
+// только после успешной записи, поэтому при ошибке диска память и файл
+// не расходятся. В файле лежит всё состояние, включая ключи
+// идемпотентности, сессии и токены обновления; блокировки запланированных
+// переводов и продление сессий не записываются
+type FileStore struct {
+	*MemoryStore
+	filename string
+	writeMu  sync.Mutex // сериализует изменения вместе с записью файла
+}
+
+// открытие файлового хранилища; отсутствующий файл создаётся при первой записи
+func NewFileStore(filename string, keys *keyring.Keyring) (*FileStore, error) {
+	accounts := account.NewAccountList()
+	accounts.SetKeyring(keys)
+	ms := newMemoryStore(accounts)
+
+	data, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read data file: %v", err)
+	}
+	if err == nil {
+		if err := ms.unmarshalSnapshot(data); err != nil {
+			return nil, err
+		}
+	}
+	return &FileStore{MemoryStore: ms, filename: filename}, nil
+}
+
+// выполнение op над копией данных и запись копии в файл; при ошибке op
+// или записи данные в памяти не меняются
+func (fs *FileStore) update(op func(ms *MemoryStore) error) error {
+	fs.writeMu.Lock()
+	defer fs.writeMu.Unlock()
+
+	next := fs.MemoryStore.clone()
+	if err := op(next); err != nil {
+		if errors.Is(err, errUnchanged) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := next.marshalSnapshot()
+	if err != nil {
+		return err
+	}
+	if err := writeFile(fs.filename, data); err != nil {
+		return fmt.Errorf("failed to persist data: %v", err)
+	}
+	next.accounts.MarkSaved()
+	fs.MemoryStore.replace(next)
+	return nil
+}
+
+// денежная операция с ключом идемпотентности; повтор по ключу
+// файл не меняет
+func (fs *FileStore) updateIdempotent(op func(ms *MemoryStore) (*StoredResponse, error)) (*StoredResponse, error) {
+	var stored *StoredResponse
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		if stored, err = op(ms); err == nil && stored != nil {
+			return errUnchanged
+		}
+		return err
+	})
+	return stored, err
+}
+
+// запись во временный файл рядом с filename и переименование, чтобы
+// при сбое на диске остался либо прежний, либо новый файл целиком
+func writeFile(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func (fs *FileStore) CreateAccount(acc *account.Account) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.CreateAccount(acc)
+	})
+}
+
+func (fs *FileStore) CreateAccountWithCard(acc *account.Account, c *account.Card) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.CreateAccountWithCard(acc, c)
+	})
+}
+
+func (fs *FileStore) SetRole(accountID, role string) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetRole(accountID, role)
+	})
+}
+
+func (fs *FileStore) SetStatus(accountID, status, reason string) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetStatus(accountID, status, reason)
+	})
+}
+
+func (fs *FileStore) RecordAudit(event *AuditEvent) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.RecordAudit(event)
+	})
+}
+
+func (fs *FileStore) Deposit(accountID string, amount money.Money, idem *Idempotency) (*StoredResponse, error) {
+	return fs.updateIdempotent(func(ms *MemoryStore) (*StoredResponse, error) {
+		return ms.Deposit(accountID, amount, idem)
+	})
+}
+
+func (fs *FileStore) Withdraw(accountID string, amount money.Money, idem *Idempotency) (*StoredResponse, error) {
+	return fs.updateIdempotent(func(ms *MemoryStore) (*StoredResponse, error) {
+		return ms.Withdraw(accountID, amount, idem)
+	})
+}
+
+func (fs *FileStore) Transfer(fromAccount, toAccount string, amount money.Money, quoteID string, idem *Idempotency) (*StoredResponse, error) {
+	return fs.updateIdempotent(func(ms *MemoryStore) (*StoredResponse, error) {
+		return ms.Transfer(fromAccount, toAccount, amount, quoteID, idem)
+	})
+}
+
+func (fs *FileStore) SetOverdraft(accountID string, limit money.Money, annualRate string) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetOverdraft(accountID, limit, annualRate)
+	})
+}
+
+// последний обработанный день меняется при каждом вызове, поэтому файл
+// сохраняется даже без списания
+func (fs *FileStore) ChargeOverdraftInterest(accountID string, through time.Time) (money.Money, error) {
+	var interest money.Money
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		interest, err = ms.ChargeOverdraftInterest(accountID, through)
+		return err
+	})
+	return interest, err
+}
+
+func (fs *FileStore) RequestLimits(accountID string, requested account.SpendingLimits, cooling time.Duration) (account.SpendingLimits, *account.PendingLimits, error) {
+	var limits account.SpendingLimits
+	var pending *account.PendingLimits
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		limits, pending, err = ms.RequestLimits(accountID, requested, cooling)
+		return err
+	})
+	return limits, pending, err
+}
+
+func (fs *FileStore) SetLimits(accountID string, limits account.SpendingLimits) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetLimits(accountID, limits)
+	})
+}
+
+func (fs *FileStore) SetConfirmThreshold(accountID string, threshold *money.Money) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetConfirmThreshold(accountID, threshold)
+	})
+}
+
+func (fs *FileStore) SetProduct(accountID, product, annualRate string, maturity *time.Time) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetProduct(accountID, product, annualRate, maturity)
+	})
+}
+
+// дата последнего начисления меняется при каждом вызове, поэтому файл
+// сохраняется даже без зачисления
+func (fs *FileStore) AccrueInterest(accountID string, through time.Time) (money.Money, error) {
+	var credited money.Money
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		credited, err = ms.AccrueInterest(accountID, through)
+		return err
+	})
+	return credited, err
+}
+
+func (fs *FileStore) SetRates(rates []fx.Rate) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetRates(rates)
+	})
+}
+
+func (fs *FileStore) CreateQuote(quote *fx.Quote) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.CreateQuote(quote)
+	})
+}
+
+func (fs *FileStore) SetFeeRules(rules []fees.Rule) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetFeeRules(rules)
+	})
+}
+
+func (fs *FileStore) IssueCard(c *account.Card) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.IssueCard(c)
+	})
+}
+
+func (fs *FileStore) SetCardStatus(id, status, reason string) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetCardStatus(id, status, reason)
+	})
+}
+
+func (fs *FileStore) SetCardPIN(id, pinHash string) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetCardPIN(id, pinHash)
+	})
+}
+
+func (fs *FileStore) ReissueCard(id string) (*account.Card, error) {
+	var replacement *account.Card
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		replacement, err = ms.ReissueCard(id)
+		return err
+	})
+	return replacement, err
+}
+
+// отказ из-за неверных реквизитов меняет счётчик карты, поэтому
+// сохраняется, а не отбрасывается вместе с ошибкой
+func (fs *FileStore) AuthorizeCard(a *account.Authorization) (*account.Hold, error) {
+	var hold *account.Hold
+	var declined error
+	err := fs.update(func(ms *MemoryStore) error {
+		hold, declined = ms.AuthorizeCard(a)
+		if errors.Is(declined, account.ErrInvalidCardDetails) {
+			return nil
+		}
+		return declined
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, declined
+}
+
+func (fs *FileStore) CaptureHold(id int64, amount *money.Money) (*account.Hold, error) {
+	var hold *account.Hold
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		hold, err = ms.CaptureHold(id, amount)
+		return err
+	})
+	return hold, err
+}
+
+func (fs *FileStore) ReverseHold(id int64) (*account.Hold, error) {
+	var hold *account.Hold
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		hold, err = ms.ReverseHold(id)
+		return err
+	})
+	return hold, err
+}
+
+func (fs *FileStore) ExpireHolds(now time.Time) (int, error) {
+	var expired int
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		if expired, err = ms.ExpireHolds(now); err == nil && expired == 0 {
+			return errUnchanged
+		}
+		return err
+	})
+	return expired, err
+}
+
+func (fs *FileStore) SetTwoFactor(accountID string, tf *account.TwoFactor) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetTwoFactor(accountID, tf)
+	})
+}
+
+func (fs *FileStore) UpdateTwoFactor(accountID string, change func(tf *account.TwoFactor) error) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.UpdateTwoFactor(accountID, change)
+	})
+}
+
+func (fs *FileStore) UpdateLoginAttempts(accountID string, change func(a *account.LoginAttempts) error) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.UpdateLoginAttempts(accountID, change)
+	})
+}
+
+func (fs *FileStore) UpdateUnknownLoginAttempts(phone string, change func(a *account.LoginAttempts) error) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.UpdateUnknownLoginAttempts(phone, change)
+	})
+}
+
+func (fs *FileStore) CreatePendingTransfer(t *pending.Transfer, idem *Idempotency) (*StoredResponse, error) {
+	return fs.updateIdempotent(func(ms *MemoryStore) (*StoredResponse, error) {
+		return ms.CreatePendingTransfer(t, idem)
+	})
+}
+
+func (fs *FileStore) UpdatePendingTransfer(id int64, change func(t *pending.Transfer) error) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.UpdatePendingTransfer(id, change)
+	})
+}
+
+func (fs *FileStore) ExpirePendingTransfers(now time.Time) (int, error) {
+	var expired int
+	err := fs.update(func(ms *MemoryStore) (err error) {
+		if expired, err = ms.ExpirePendingTransfers(now); err == nil && expired == 0 {
+			return errUnchanged
+		}
+		return err
+	})
+	return expired, err
+}
+
+func (fs *FileStore) CreateScheduledTransfer(t *schedule.Transfer) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.CreateScheduledTransfer(t)
+	})
+}
+
+func (fs *FileStore) SetScheduledTransferStatus(id int64, from, to string) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SetScheduledTransferStatus(id, from, to)
+	})
+}
+
+// блокировка на время запуска нужна только работающему процессу,
+// поэтому ставится в памяти без записи файла
+func (fs *FileStore) ClaimDueTransfers(now time.Time, limit int, lease time.Duration) ([]*schedule.Transfer, error) {
+	fs.writeMu.Lock()
+	defer fs.writeMu.Unlock()
+	return fs.MemoryStore.ClaimDueTransfers(now, limit, lease)
+}
+
+func (fs *FileStore) SaveScheduledRun(t *schedule.Transfer) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.SaveScheduledRun(t)
+	})
+}
+
+func (fs *FileStore) AddNotification(n *Notification) error {
+	return fs.update(func(ms *MemoryStore) error {
+		return ms.AddNotification(n)
+	})
+}
+
+// файл всегда переписывается целиком текущим ключом, поэтому limit
+// не ограничивает число перешифрованных записей
+func (fs *FileStore) Reencrypt(limit int) (int, error) {
+	stale := fs.accounts.StaleRecords()
+	if stale == 0 {
+		return 0, nil
+	}
+	return stale, fs.update(func(ms *MemoryStore) error { return nil })
+}
+
+func (fs *FileStore) Sessions() session.Store {
+	return fileSessions{fs}
+}
+
+func (fs *FileStore) RefreshTokens() token.Store {
+	return fileTokens{fs}
+}
+
+// сессии файлового хранилища: чтение из памяти, изменения через update
+type fileSessions struct {
+	fs *FileStore
+}
+
+func (s fileSessions) Create(sess *session.Session, maxPerUser int) error {
+	return s.fs.update(func(ms *MemoryStore) error {
+		return ms.sessions.Create(sess, maxPerUser)
+	})
+}
+
+func (s fileSessions) Get(sessionID string) (*session.Session, error) {
+	return s.fs.sessions.Get(sessionID)
+}
+
+// продление происходит на каждый запрос, поэтому в файл не пишется:
+// после перезапуска сессия истекает по последнему записанному сроку
+func (s fileSessions) Touch(sessionID string, lastActivity, expiresAt time.Time) (*session.Session, error) {
+	s.fs.writeMu.Lock()
+	defer s.fs.writeMu.Unlock()
+	return s.fs.sessions.Touch(sessionID, lastActivity, expiresAt)
+}
+
+func (s fileSessions) Delete(sessionID string) error {
+	return s.fs.update(func(ms *MemoryStore) error {
+		return ms.sessions.Delete(sessionID)
+	})
+}
+
+func (s fileSessions) ListByUser(userID string) ([]*session.Session, error) {
+	return s.fs.sessions.ListByUser(userID)
+}
+
+func (s fileSessions) DeleteExpired(now time.Time) error {
+	return s.fs.update(func(ms *MemoryStore) error {
+		return ms.sessions.DeleteExpired(now)
+	})
+}
+
+// токены обновления файлового хранилища; все методы меняют данные
+type fileTokens struct {
+	fs *FileStore
+}
+
+func (t fileTokens) Create(rt *token.RefreshToken) error {
+	return t.fs.update(func(ms *MemoryStore) error {
+		return ms.tokens.Create(rt)
+	})
+}
+
+// при повторном предъявлении токена MemoryStore отзывает цепочку и
+// возвращает ошибку, а update ошибочное изменение отбрасывает, поэтому
+// отзыв сохраняется отдельно
+func (t fileTokens) Rotate(hash string, now time.Time, next *token.RefreshToken) (*token.RefreshToken, error) {
+	var rotated *token.RefreshToken
+	err := t.fs.update(func(ms *MemoryStore) (err error) {
+		rotated, err = ms.tokens.Rotate(hash, now, next)
+		return err
+	})
+	if errors.Is(err, token.ErrReused) {
+		if err := t.Revoke(hash, now); err != nil {
+			return nil, err
+		}
+	}
+	return rotated, err
+}
+
+func (t fileTokens) Revoke(hash string, now time.Time) error {
+	return t.fs.update(func(ms *MemoryStore) error {
+		return ms.tokens.Revoke(hash, now)
+	})
+}
+
+func (t fileTokens) RevokeUser(userID string, now time.Time) error {
+	return t.fs.update(func(ms *MemoryStore) error {
+		return ms.tokens.RevokeUser(userID, now)
+	})
+}
+
+func (t fileTokens) DeleteExpired(now time.Time) error {
+	return t.fs.update(func(ms *MemoryStore) error {
+		return ms.tokens.DeleteExpired(now)
+	})
+}
+
+var (
+	_ Store         = (*MemoryStore)(nil)
+	_ Store         = (*FileStore)(nil)
+	_ session.Store = fileSessions{}
+	_ token.Store   = fileTokens{}
+)
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/keyring"
+	"mfp/money"
+	"mfp/schedule"
+	"mfp/session"
+	"mfp/token"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAccount(n int, balance int64) *account.Account {
+	return &account.Account{
+		ID:           fmt.Sprintf("KZ%014d", n),
+		Password:     "-",
+		Balance:      money.FromMinor(balance, money.DefaultCurrency),
+		Name:         "Test",
+		Phone:        fmt.Sprintf("7700000%04d", n),
+		Age:          30,
+		Role:         account.RoleCustomer,
+		Status:       account.StatusActive,
+		CreatedAt:    time.Now(),
+		ExpiredAt:    time.Now().AddDate(5, 0, 0),
+		Transactions: []account.Transaction{},
+	}
+}
+
+func openFileStore(t *testing.T, filename string, keys *keyring.Keyring) *FileStore {
+	t.Helper()
+	fs, err := NewFileStore(filename, keys)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return fs
+}
+
+// после перезапуска сохраняются не только аккаунты, но и ключи
+// идемпотентности, сессии, токены, запланированные переводы и журналы
+func TestFileStoreReopen(t *testing.T) {
+	keys := keyring.NewEphemeral()
+	filename := filepath.Join(t.TempDir(), "data.json")
+	fs := openFileStore(t, filename, keys)
+
+	from, to := testAccount(1, 100000), testAccount(2, 0)
+	for _, acc := range []*account.Account{from, to} {
+		if err := fs.CreateAccount(acc); err != nil {
+			t.Fatalf("CreateAccount: %v", err)
+		}
+	}
+	idem := &Idempotency{Key: "k1", Endpoint: "transfer", RequestHash: "h1", Response: StoredResponse{StatusCode: http.StatusOK, Body: []byte("ok")}}
+	if _, err := fs.Transfer(from.ID, to.ID, money.FromMinor(10000, money.DefaultCurrency), "", idem); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	sess := &session.Session{ID: "s1", UserID: from.ID, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := fs.Sessions().Create(sess, 5); err != nil {
+		t.Fatalf("Sessions().Create: %v", err)
+	}
+	raw, refresh := token.NewRefreshToken(from.ID, "", time.Now(), time.Hour)
+	if err := fs.RefreshTokens().Create(refresh); err != nil {
+		t.Fatalf("RefreshTokens().Create: %v", err)
+	}
+	scheduled, err := schedule.New(from.ID, to.ID, money.FromMinor(100, money.DefaultCurrency), schedule.Daily, time.Now().Add(time.Hour), nil, 0)
+	if err != nil {
+		t.Fatalf("schedule.New: %v", err)
+	}
+	if err := fs.CreateScheduledTransfer(scheduled); err != nil {
+		t.Fatalf("CreateScheduledTransfer: %v", err)
+	}
+	if err := fs.RecordAudit(&AuditEvent{ActorID: from.ID, Action: "test"}); err != nil {
+		t.Fatalf("RecordAudit: %v", err)
+	}
+	unknown := "77009999999"
+	if err := fs.UpdateUnknownLoginAttempts(unknown, func(a *account.LoginAttempts) error {
+		a.Failures = 2
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateUnknownLoginAttempts: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), unknown) || strings.Contains(string(data), from.Phone) {
+		t.Error("data file contains a plaintext phone")
+	}
+
+	reopened := openFileStore(t, filename, keys)
+
+	stored, err := reopened.Transfer(from.ID, to.ID, money.FromMinor(10000, money.DefaultCurrency), "", idem)
+	if err != nil || stored == nil || string(stored.Body) != "ok" {
+		t.Errorf("replayed Transfer = %+v, %v, want stored response", stored, err)
+	}
+	acc, err := reopened.GetAccount(from.ID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if acc.Balance.Amount != 90000 || acc.Phone != from.Phone {
+		t.Errorf("account = %s %s, want 90000 %s", acc.Balance, acc.Phone, from.Phone)
+	}
+	if _, err := reopened.Sessions().Get("s1"); err != nil {
+		t.Errorf("Sessions().Get: %v", err)
+	}
+	if _, err := reopened.RefreshTokens().Rotate(token.Hash(raw), time.Now(), &token.RefreshToken{Hash: "next", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Errorf("RefreshTokens().Rotate: %v", err)
+	}
+	if got, err := reopened.GetScheduledTransfer(scheduled.ID); err != nil || got.AccountID != from.ID {
+		t.Errorf("GetScheduledTransfer = %+v, %v", got, err)
+	}
+	next, err := schedule.New(from.ID, to.ID, money.FromMinor(100, money.DefaultCurrency), schedule.Once, time.Now(), nil, 0)
+	if err != nil {
+		t.Fatalf("schedule.New: %v", err)
+	}
+	if err := reopened.CreateScheduledTransfer(next); err != nil || next.ID != scheduled.ID+1 {
+		t.Errorf("CreateScheduledTransfer after reopen = %d, %v, want %d", next.ID, err, scheduled.ID+1)
+	}
+	if events, err := reopened.GetAuditLog(10); err != nil || len(events) != 1 {
+		t.Errorf("GetAuditLog = %d events, %v, want 1", len(events), err)
+	}
+	if err := reopened.UpdateUnknownLoginAttempts(unknown, func(a *account.LoginAttempts) error {
+		if a.Failures != 2 {
+			return fmt.Errorf("failures = %d, want 2", a.Failures)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("UpdateUnknownLoginAttempts: %v", err)
+	}
+}
+
+// при ошибке записи файла изменение не попадает и в память
+func TestFileStoreWriteFailure(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing", "data.json")
+	fs := openFileStore(t, filename, keyring.NewEphemeral())
+
+	acc := testAccount(1, 100000)
+	if err := fs.CreateAccount(acc); err == nil {
+		t.Fatal("CreateAccount succeeded without a writable directory")
+	}
+	if _, err := fs.GetAccount(acc.ID); !errors.Is(err, account.ErrAccountNotFound) {
+		t.Errorf("GetAccount after failed write = %v, want %v", err, account.ErrAccountNotFound)
+	}
+	if err := fs.Sessions().Create(&session.Session{ID: "s1", UserID: acc.ID, ExpiresAt: time.Now().Add(time.Hour)}, 5); err == nil {
+		t.Fatal("Sessions().Create succeeded without a writable directory")
+	}
+	if _, err := fs.Sessions().Get("s1"); err == nil {
+		t.Error("session kept after failed write")
+	}
+}
+
+// файл прежнего формата с одними аккаунтами читается
+func TestFileStoreLegacyFormat(t *testing.T) {
+	keys := keyring.NewEphemeral()
+	accounts := account.NewAccountList()
+	accounts.SetKeyring(keys)
+	acc := testAccount(1, 100000)
+	if err := accounts.AddAccount(acc); err != nil {
+		t.Fatalf("AddAccount: %v", err)
+	}
+	data, err := accounts.MarshalSealed()
+	if err != nil {
+		t.Fatalf("MarshalSealed: %v", err)
+	}
+	filename := filepath.Join(t.TempDir(), "accounts.json")
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := openFileStore(t, filename, keys)
+	got, err := fs.GetAccountByPhone(acc.Phone)
+	if err != nil || got.ID != acc.ID || got.Balance.Amount != 100000 {
+		t.Errorf("GetAccountByPhone = %+v, %v", got, err)
+	}
+}
+
+// счётчик неверных реквизитов сохраняется, хотя авторизация отклонена
+func TestFileStoreCardFailuresPersist(t *testing.T) {
+	keys := keyring.NewEphemeral()
+	filename := filepath.Join(t.TempDir(), "data.json")
+	fs := openFileStore(t, filename, keys)
+
+	acc := testAccount(1, 100000)
+	card := &account.Card{ID: "card-1", AccountID: acc.ID, PAN: "4000000000000001", CVC2: "123",
+		ExpiryMonth: 1, ExpiryYear: time.Now().Year() + 3, Status: account.CardActive}
+	if err := fs.CreateAccountWithCard(acc, card); err != nil {
+		t.Fatalf("CreateAccountWithCard: %v", err)
+	}
+	for range 2 {
+		if err := authorizeCard(fs, card, "999"); !errors.Is(err, account.ErrInvalidCardDetails) {
+			t.Fatalf("AuthorizeCard = %v, want %v", err, account.ErrInvalidCardDetails)
+		}
+	}
+
+	reopened := openFileStore(t, filename, keys)
+	if err := authorizeCard(reopened, card, "999"); !errors.Is(err, account.ErrInvalidCardDetails) {
+		t.Fatalf("AuthorizeCard = %v, want %v", err, account.ErrInvalidCardDetails)
+	}
+	got, err := reopened.GetCard(card.ID)
+	if err != nil {
+		t.Fatalf("GetCard: %v", err)
+	}
+	if got.Status != account.CardBlocked {
+		t.Errorf("status = %s, want %s", got.Status, account.CardBlocked)
+	}
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mfp/account"
+	"mfp/money"
+	"sort"
+	"strings"
+	"time"
+)
+
+// порядок сортировки истории операций
+const (
+	SortDateDesc   = "date_desc" // по умолчанию: новые сверху
+	SortDateAsc    = "date_asc"
+	SortAmountDesc = "amount_desc"
+	SortAmountAsc  = "amount_asc"
+)
+
+// отбор операций счёта; нулевые поля не ограничивают выборку
+type TransactionFilter struct {
+	From         time.Time // включительно
+	To           time.Time // не включительно
+	Types        []string
+	MinAmount    *money.Money
+	MaxAmount    *money.Money
+	Counterparty string // счёт другой стороны перевода
+	Sort         string
+	Cursor       string // NextCursor предыдущей страницы
+	Limit        int    // 0 — без ограничения
+}
+
+// итоги по всем операциям, подходящим под фильтр, а не только по странице;
+// непроведённые оплаты картой в суммы не входят
+type TransactionSummary struct {
+	Count int         `json:"count"`
+	In    money.Money `json:"in"`  // сумма поступлений
+	Out   money.Money `json:"out"` // сумма списаний
+}
+
+// страница истории операций
+type TransactionPage struct {
+	Transactions []*account.Transaction `json:"transactions"`
+	NextCursor   string                 `json:"next_cursor,omitempty"`
+	Summary      TransactionSummary     `json:"summary"`
+}
+
+// позиция в истории: значения ключа сортировки последней операции страницы
+type Cursor struct {
+	Time   time.Time `json:"t"`
+	Amount int64     `json:"a"`
+	ID     int64     `json:"id"`
+}
+
+// проверка фильтра; тип transfer раскрывается в transfer_in и transfer_out
+func (f *TransactionFilter) Validate() error {
+	switch f.Sort {
+	case "":
+		f.Sort = SortDateDesc
+	case SortDateDesc, SortDateAsc, SortAmountDesc, SortAmountAsc:
+	default:
+		return fmt.Errorf("unknown sort %q", f.Sort)
+	}
+
+	if f.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil {
+		if cmp, err := f.MinAmount.Cmp(*f.MaxAmount); err != nil {
+			return err
+		} else if cmp > 0 {
+			return fmt.Errorf("min_amount must not exceed max_amount")
+		}
+	}
+
+	var types []string
+	for _, t := range f.Types {
+		switch t {
+		case "transfer":
+			types = append(types, account.TypeTransferIn, account.TypeTransferOut)
+		case account.TypeDeposit, account.TypeWithdrawal, account.TypeTransferIn, account.TypeTransferOut,
+			account.TypeOverdraftInterest, account.TypeInterest, account.TypeFee, account.TypeCardPayment:
+			types = append(types, t)
+		default:
+			return fmt.Errorf("unknown transaction type %q", t)
+		}
+	}
+	f.Types = types
+
+	if f.Cursor != "" {
+		if _, err := DecodeCursor(f.Cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// подходит ли операция под фильтр (без учёта курсора)
+func (f *TransactionFilter) Match(tx *account.Transaction) bool {
+	if !f.From.IsZero() && tx.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !tx.Timestamp.Before(f.To) {
+		return false
+	}
+	if len(f.Types) > 0 && !contains(f.Types, tx.Type) {
+		return false
+	}
+	if f.MinAmount != nil && tx.Amount.Amount < f.MinAmount.Amount {
+		return false
+	}
+	if f.MaxAmount != nil && tx.Amount.Amount > f.MaxAmount.Amount {
+		return false
+	}
+	if f.Counterparty != "" && tx.Counterparty() != f.Counterparty {
+		return false
+	}
+	return true
+}
+
+// идёт ли операция после курсора в выбранном порядке сортировки
+func (f *TransactionFilter) After(tx *account.Transaction, c Cursor) bool {
+	id := int64(tx.ID)
+	switch f.Sort {
+	case SortDateAsc:
+		return tx.Timestamp.After(c.Time) || (tx.Timestamp.Equal(c.Time) && id > c.ID)
+	case SortAmountDesc:
+		return tx.Amount.Amount < c.Amount || (tx.Amount.Amount == c.Amount && id < c.ID)
+	case SortAmountAsc:
+		return tx.Amount.Amount > c.Amount || (tx.Amount.Amount == c.Amount && id > c.ID)
+	}
+	return tx.Timestamp.Before(c.Time) || (tx.Timestamp.Equal(c.Time) && id < c.ID)
+}
+
+// фильтрация, сортировка и разбиение на страницы истории в памяти;
+// currency — валюта счёта для нулевых итогов
+func FilterTransactions(transactions []*account.Transaction, currency string, f TransactionFilter) (*TransactionPage, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	page := &TransactionPage{
+		Transactions: []*account.Transaction{},
+		Summary:      TransactionSummary{In: money.Zero(currency), Out: money.Zero(currency)},
+	}
+
+	var matched []*account.Transaction
+	for _, tx := range transactions {
+		if !f.Match(tx) {
+			continue
+		}
+		matched = append(matched, tx)
+
+		page.Summary.Count++
+		if !tx.IsBooked() {
+			continue
+		}
+		var err error
+		if tx.IsIncoming() {
+			page.Summary.In, err = page.Summary.In.Add(tx.Amount)
+		} else {
+			page.Summary.Out, err = page.Summary.Out.Add(tx.Amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		return f.After(b, cursorOf(a))
+	})
+
+	if f.Cursor != "" {
+		cursor, _ := DecodeCursor(f.Cursor)
+		start := sort.Search(len(matched), func(i int) bool { return f.After(matched[i], cursor) })
+		matched = matched[start:]
+	}
+
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+		page.NextCursor = NextCursor(matched[len(matched)-1])
+	}
+	page.Transactions = append(page.Transactions, matched...)
+	return page, nil
+}
+
+// курсор, указывающий на операцию
+func cursorOf(tx *account.Transaction) Cursor {
+	return Cursor{Time: tx.Timestamp, Amount: tx.Amount.Amount, ID: int64(tx.ID)}
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// курсор для последней операции страницы
+func NextCursor(tx *account.Transaction) string {
+	data, _ := json.Marshal(cursorOf(tx))
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"mfp/account"
+	"mfp/money"
+	"time"
+)
+
+// блокировки по картам хранятся вместе со счетами в account.AccountList
+
+func (ms *MemoryStore) AuthorizeCard(a *account.Authorization) (*account.Hold, error) {
+	return ms.accounts.Authorize(a)
+}
+
+func (ms *MemoryStore) GetHold(id int64) (*account.Hold, error) {
+	return ms.accounts.GetHold(id)
+}
+
+func (ms *MemoryStore) CaptureHold(id int64, amount *money.Money) (*account.Hold, error) {
+	if amount == nil {
+		hold, err := ms.accounts.GetHold(id)
+		if err != nil {
+			return nil, err
+		}
+		amount = &hold.Amount
+	}
+	return ms.accounts.CaptureHold(id, *amount)
+}
+
+func (ms *MemoryStore) ReverseHold(id int64) (*account.Hold, error) {
+	return ms.accounts.ReverseHold(id)
+}
+
+func (ms *MemoryStore) ExpireHolds(now time.Time) (int, error) {
+	return ms.accounts.ExpireHolds(now), nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"errors"
+	"mfp/account"
+	"time"
+)
+
+// сколько незарегистрированных телефонов хранится до очистки устаревших
+const maxUnknownLogins = 10000
+
+// счётчик неудачных входов хранится вместе со счётом в account.AccountList
+
+func (ms *MemoryStore) GetLoginAttempts(accountID string) (*account.LoginAttempts, error) {
+	attempts, err := ms.accounts.GetLoginAttempts(accountID)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return nil, ErrAccountNotFound
+	}
+	return attempts, err
+}
+
+func (ms *MemoryStore) UpdateLoginAttempts(accountID string, change func(a *account.LoginAttempts) error) error {
+	err := ms.accounts.UpdateLoginAttempts(accountID, change)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return ErrAccountNotFound
+	}
+	return err
+}
+
+// счётчик ищется по слепому индексу, чтобы открытые телефоны
+// не попадали в файл
+func (ms *MemoryStore) UpdateUnknownLoginAttempts(phone string, change func(a *account.LoginAttempts) error) error {
+	index := ms.accounts.BlindIndex(phone)
+
+	ms.loginMu.Lock()
+	defer ms.loginMu.Unlock()
+
+	attempts := ms.unknownLogins[index]
+	if err := change(&attempts); err != nil {
+		return err
+	}
+	if attempts == (account.LoginAttempts{}) {
+		delete(ms.unknownLogins, index)
+		return nil
+	}
+	ms.unknownLogins[index] = attempts
+
+	if len(ms.unknownLogins) > maxUnknownLogins {
+		ms.pruneUnknownLogins(time.Now())
+	}
+	return nil
+}
+
+// удаление счётчиков без блокировки и без неудач за последние сутки
+func (ms *MemoryStore) pruneUnknownLogins(now time.Time) {
+	for index, a := range ms.unknownLogins {
+		if a.Locked(now) || (a.LastFailureAt != nil && now.Sub(*a.LastFailureAt) < account.MaxLockout) {
+			continue
+		}
+		delete(ms.unknownLogins, index)
+	}
+}
@@ -0,0 +1,492 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"mfp/account"
+	"mfp/fees"
+	"mfp/fx"
+	"mfp/money"
+	"mfp/pending"
+	"mfp/session"
+	"mfp/token"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ключ идемпотентности, сохранённый в памяти
+type memoryIdempotencyKey struct {
+	requestHash string
+	response    StoredResponse
+}
+
+// хранилище в памяти процесса поверх account.AccountList;
+// данные теряются при перезапуске, подходит для локального запуска и тестов
+type MemoryStore struct {
+	accounts *account.AccountList
+	sessions *session.MemoryStore
+	tokens   *token.MemoryStore
+
+	mu   sync.Mutex // сериализует денежные операции вместе с проверкой ключей
+	keys map[string]memoryIdempotencyKey
+
+	auditMu       sync.RWMutex // журнал аудита и уведомления
+	audit         []*AuditEvent
+	notifications []*Notification
+
+	// курсы, котировки и тарифы защищены mu вместе с денежными операциями
+	rates    map[[2]string]fx.Rate
+	quotes   map[string]*fx.Quote
+	feeRules []fees.Rule
+
+	schedMu         sync.Mutex
+	scheduled       map[int64]*memoryScheduled
+	nextScheduledID int64
+
+	pendingMu     sync.Mutex
+	pending       map[int64]*pending.Transfer
+	nextPendingID int64
+
+	loginMu       sync.Mutex
+	unknownLogins map[string]account.LoginAttempts // по слепому индексу телефона
+}
+
+func NewMemoryStore() *MemoryStore {
+	return newMemoryStore(account.NewAccountList())
+}
+
+func newMemoryStore(accounts *account.AccountList) *MemoryStore {
+	return &MemoryStore{
+		accounts:  accounts,
+		sessions:  session.NewMemoryStore(),
+		tokens:    token.NewMemoryStore(),
+		keys:      make(map[string]memoryIdempotencyKey),
+		rates:     make(map[[2]string]fx.Rate),
+		quotes:    make(map[string]*fx.Quote),
+		scheduled: make(map[int64]*memoryScheduled),
+		pending:   make(map[int64]*pending.Transfer),
+
+		unknownLogins: make(map[string]account.LoginAttempts),
+	}
+}
+
+func (ms *MemoryStore) CreateAccount(acc *account.Account) error {
+	return ms.accounts.AddAccount(acc)
+}
+
+func (ms *MemoryStore) CreateAccountWithCard(acc *account.Account, c *account.Card) error {
+	return ms.accounts.AddAccountWithCard(acc, c)
+}
+
+func (ms *MemoryStore) GetAccount(id string) (*account.Account, error) {
+	return ms.accounts.GetAccount(id)
+}
+
+// AccountList ищет и по ID, и по телефону; здесь нужен только телефон
+func (ms *MemoryStore) GetAccountByPhone(phone string) (*account.Account, error) {
+	acc, err := ms.accounts.GetAccount(phone)
+	if err != nil || acc.Phone != phone {
+		return nil, ErrAccountNotFound
+	}
+	return acc, nil
+}
+
+func (ms *MemoryStore) GetOwnedAccounts(ownerID string) ([]*account.Account, error) {
+	return ms.accounts.GetOwnedAccounts(ownerID), nil
+}
+
+func (ms *MemoryStore) GetAccounts() ([]*account.Account, error) {
+	return ms.accounts.GetAccounts(), nil
+}
+
+func (ms *MemoryStore) SearchAccounts(query string) ([]*account.Account, error) {
+	return ms.accounts.SearchAccounts(query), nil
+}
+
+func (ms *MemoryStore) SetRole(accountID, role string) error {
+	if err := ms.accounts.SetRole(accountID, role); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// в памяти телефоны не шифруются, а CVC2 сразу заменяется кодом
+// проверки, поэтому перешифровывать нечего
+func (ms *MemoryStore) Reencrypt(limit int) (int, error) {
+	return 0, nil
+}
+
+func (ms *MemoryStore) RecordAudit(event *AuditEvent) error {
+	ms.auditMu.Lock()
+	defer ms.auditMu.Unlock()
+
+	copied := *event
+	copied.ID = int64(len(ms.audit) + 1)
+	if copied.CreatedAt.IsZero() {
+		copied.CreatedAt = time.Now()
+	}
+	ms.audit = append(ms.audit, &copied)
+	return nil
+}
+
+// последние записи журнала аудита, новые сверху
+func (ms *MemoryStore) GetAuditLog(limit int) ([]*AuditEvent, error) {
+	ms.auditMu.RLock()
+	defer ms.auditMu.RUnlock()
+
+	events := []*AuditEvent{}
+	for i := len(ms.audit) - 1; i >= 0 && len(events) < limit; i-- {
+		copied := *ms.audit[i]
+		events = append(events, &copied)
+	}
+	return events, nil
+}
+
+func (ms *MemoryStore) SetStatus(accountID, status, reason string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.accounts.SetStatus(accountID, status, reason); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+
+	if account.CheckLogin(status) != nil {
+		sessions, err := ms.sessions.ListByUser(accountID)
+		if err != nil {
+			return err
+		}
+		for _, sess := range sessions {
+			ms.sessions.Delete(sess.ID)
+		}
+		if err := ms.tokens.RevokeUser(accountID, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Deposit(accountID string, amount money.Money, idem *Idempotency) (*StoredResponse, error) {
+	return ms.idempotent(accountID, idem, func() error {
+		return ms.accounts.Deposit(accountID, amount)
+	})
+}
+
+func (ms *MemoryStore) Withdraw(accountID string, amount money.Money, idem *Idempotency) (*StoredResponse, error) {
+	return ms.idempotent(accountID, idem, func() error {
+		acc, err := ms.accounts.GetAccount(accountID)
+		if err != nil {
+			return err
+		}
+		fee, err := ms.fee(fees.OpWithdrawal, acc, nil, amount)
+		if err != nil {
+			return err
+		}
+		return ms.accounts.Withdraw(accountID, amount, fee)
+	})
+}
+
+func (ms *MemoryStore) Transfer(fromAccount, toAccount string, amount money.Money, quoteID string, idem *Idempotency) (*StoredResponse, error) {
+	return ms.idempotent(fromAccount, idem, func() error {
+		from, err := ms.accounts.GetAccount(fromAccount)
+		if err != nil {
+			return fmt.Errorf("source account not found")
+		}
+		to, err := ms.accounts.GetAccount(toAccount)
+		if err != nil {
+			return fmt.Errorf("destination account not found")
+		}
+		if amount.Currency != from.Currency() {
+			return fmt.Errorf("source account is in %s, amount is in %s", from.Currency(), amount.Currency)
+		}
+		fee, err := ms.fee(fees.OpTransfer, from, to, amount)
+		if err != nil {
+			return err
+		}
+		if from.Currency() == to.Currency() {
+			if quoteID != "" {
+				return fmt.Errorf("quote is only used for transfers between currencies")
+			}
+			return ms.accounts.Transfer(fromAccount, toAccount, amount, fee)
+		}
+
+		var quote *fx.Quote
+		if quoteID != "" {
+			stored, ok := ms.quotes[quoteID]
+			if !ok {
+				return fx.ErrQuoteNotFound
+			}
+			if err := stored.Check(from.ID, amount, to.Currency(), time.Now()); err != nil {
+				return err
+			}
+			quote = stored
+		} else {
+			rate, buy, err := fx.Exchange(ms.rateList(), amount, to.Currency())
+			if err != nil {
+				return err
+			}
+			quote = &fx.Quote{Sell: amount, Buy: buy, Rate: rate}
+		}
+
+		if err := ms.accounts.TransferConverted(from.ID, to.ID, quote.Sell, quote.Buy, quote.Rate, fee); err != nil {
+			return err
+		}
+		if quoteID != "" {
+			now := time.Now()
+			quote.UsedAt = &now
+		}
+		return nil
+	})
+}
+
+func (ms *MemoryStore) SetOverdraft(accountID string, limit money.Money, annualRate string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.accounts.SetOverdraft(accountID, limit, annualRate); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (ms *MemoryStore) ChargeOverdraftInterest(accountID string, through time.Time) (money.Money, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	interest, err := ms.accounts.ChargeOverdraftInterest(accountID, through)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return interest, ErrAccountNotFound
+	}
+	return interest, err
+}
+
+func (ms *MemoryStore) RequestLimits(accountID string, requested account.SpendingLimits, cooling time.Duration) (account.SpendingLimits, *account.PendingLimits, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	limits, pending, err := ms.accounts.RequestLimits(accountID, requested, cooling)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return limits, pending, ErrAccountNotFound
+	}
+	return limits, pending, err
+}
+
+func (ms *MemoryStore) SetLimits(accountID string, limits account.SpendingLimits) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.accounts.SetLimits(accountID, limits); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (ms *MemoryStore) SetConfirmThreshold(accountID string, threshold *money.Money) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.accounts.SetConfirmThreshold(accountID, threshold); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (ms *MemoryStore) SetProduct(accountID, product, annualRate string, maturity *time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.accounts.SetProduct(accountID, product, annualRate, maturity); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (ms *MemoryStore) AccrueInterest(accountID string, through time.Time) (money.Money, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	credited, err := ms.accounts.AccrueInterest(accountID, through)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return credited, ErrAccountNotFound
+	}
+	return credited, err
+}
+
+func (ms *MemoryStore) SetRates(rates []fx.Rate) error {
+	for i := range rates {
+		if err := rates[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	for _, r := range rates {
+		r.UpdatedAt = now
+		ms.rates[[2]string{r.From, r.To}] = r
+	}
+	return nil
+}
+
+func (ms *MemoryStore) GetRates() ([]fx.Rate, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.rateList(), nil
+}
+
+func (ms *MemoryStore) CreateQuote(quote *fx.Quote) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	for id, q := range ms.quotes {
+		if now.After(q.ExpiresAt) {
+			delete(ms.quotes, id)
+		}
+	}
+	copied := *quote
+	ms.quotes[quote.ID] = &copied
+	return nil
+}
+
+// курсы по парам валют; вызывается под ms.mu
+func (ms *MemoryStore) rateList() []fx.Rate {
+	rates := make([]fx.Rate, 0, len(ms.rates))
+	for _, r := range ms.rates {
+		rates = append(rates, r)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].From != rates[j].From {
+			return rates[i].From < rates[j].From
+		}
+		return rates[i].To < rates[j].To
+	})
+	return rates
+}
+
+func (ms *MemoryStore) SetFeeRules(rules []fees.Rule) error {
+	if err := fees.ValidateRules(rules); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.feeRules = append([]fees.Rule(nil), rules...)
+	return nil
+}
+
+func (ms *MemoryStore) GetFeeRules() ([]fees.Rule, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return append([]fees.Rule{}, ms.feeRules...), nil
+}
+
+func (ms *MemoryStore) EstimateFee(accountID, kind, toAccount string, amount money.Money) (money.Money, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	from, err := ms.accounts.GetAccount(accountID)
+	if err != nil {
+		return money.Money{}, ErrAccountNotFound
+	}
+	var to *account.Account
+	if kind == fees.OpTransfer {
+		if to, err = ms.accounts.GetAccount(toAccount); err != nil {
+			return money.Money{}, fmt.Errorf("destination account not found")
+		}
+	}
+	if amount.Currency != from.Currency() {
+		return money.Money{}, fmt.Errorf("account is in %s, amount is in %s", from.Currency(), amount.Currency)
+	}
+	return ms.fee(kind, from, to, amount)
+}
+
+// комиссия за операцию со счёта from; to — получатель перевода или nil.
+// вызывается под ms.mu
+func (ms *MemoryStore) fee(kind string, from, to *account.Account, amount money.Money) (money.Money, error) {
+	rule := fees.Find(ms.feeRules, kind, amount.Currency)
+	if rule == nil {
+		return money.Zero(amount.Currency), nil
+	}
+
+	op := fees.Operation{Kind: kind, Amount: amount, Product: from.EffectiveProduct()}
+	if rule.UsesVolume() {
+		_, op.MonthlyVolume = from.Spent(time.Now())
+	}
+	if to != nil {
+		op.Internal = from.ClientID() == to.ClientID()
+	}
+	return rule.Fee(op)
+}
+
+func (ms *MemoryStore) QueryTransactions(accountID string, filter TransactionFilter) (*TransactionPage, error) {
+	acc, err := ms.accounts.GetAccount(accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	transactions, err := ms.accounts.GetTransactions(acc.ID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	return FilterTransactions(transactions, acc.Currency(), filter)
+}
+
+func (ms *MemoryStore) Sessions() session.Store {
+	return ms.sessions
+}
+
+func (ms *MemoryStore) RefreshTokens() token.Store {
+	return ms.tokens
+}
+
+// выполнение операции с проверкой и сохранением ключа идемпотентности
+func (ms *MemoryStore) idempotent(accountID string, idem *Idempotency, op func() error) (*StoredResponse, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var keyID string
+	if idem != nil {
+		keyID = accountID + "\x00" + idem.Endpoint + "\x00" + idem.Key
+		if stored, exists := ms.keys[keyID]; exists {
+			if stored.requestHash != idem.RequestHash {
+				return nil, ErrIdempotencyKeyReused
+			}
+			response := stored.response
+			return &response, nil
+		}
+	}
+
+	if err := op(); err != nil {
+		if errors.Is(err, account.ErrAccountNotFound) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	if idem != nil {
+		ms.keys[keyID] = memoryIdempotencyKey{requestHash: idem.RequestHash, response: idem.Result()}
+	}
+	return nil, nil
+}
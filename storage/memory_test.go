@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"mfp/account"
+	"mfp/money"
+	"mfp/pending"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// параллельные переводы между несколькими счетами во встречных
+// направлениях: ни один баланс не уходит в минус, сумма сохраняется
+func TestMemoryStoreConcurrentTransfers(t *testing.T) {
+	const (
+		accounts  = 5
+		workers   = 20
+		transfers = 50
+		initial   = 100000 // 1000.00
+	)
+
+	ms := NewMemoryStore()
+	ids := make([]string, accounts)
+	for i := range ids {
+		acc := &account.Account{
+			ID:           fmt.Sprintf("KZ%014d", i+1),
+			Password:     "-",
+			Balance:      money.FromMinor(initial, money.DefaultCurrency),
+			Name:         "Test",
+			Phone:        fmt.Sprintf("7700000%04d", i+1),
+			Age:          30,
+			Role:         account.RoleCustomer,
+			Status:       account.StatusActive,
+			CreatedAt:    time.Now(),
+			ExpiredAt:    time.Now().AddDate(5, 0, 0),
+			Transactions: []account.Transaction{},
+		}
+		if err := ms.CreateAccount(acc); err != nil {
+			t.Fatalf("CreateAccount: %v", err)
+		}
+		ids[i] = acc.ID
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*transfers)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for range transfers {
+				from := rnd.Intn(accounts)
+				to := (from + 1 + rnd.Intn(accounts-1)) % accounts
+				amount := money.FromMinor(int64(1+rnd.Intn(50000)), money.DefaultCurrency)
+				_, err := ms.Transfer(ids[from], ids[to], amount, "", nil)
+				if err != nil && !errors.Is(err, account.ErrInsufficientFunds) {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Transfer: %v", err)
+	}
+
+	var total int64
+	for _, id := range ids {
+		acc, err := ms.GetAccount(id)
+		if err != nil {
+			t.Fatalf("GetAccount: %v", err)
+		}
+		if acc.Balance.IsNegative() {
+			t.Errorf("account %s balance is negative: %s", id, acc.Balance)
+		}
+		total += acc.Balance.Amount
+	}
+	if total != accounts*initial {
+		t.Errorf("total balance = %s, want %s", money.FromMinor(total, money.DefaultCurrency),
+			money.FromMinor(accounts*initial, money.DefaultCurrency))
+	}
+}
+
+// повтор создания перевода с тем же ключом возвращает первый ответ
+// и второй перевод не создаёт
+func TestMemoryStoreCreatePendingTransferIdempotent(t *testing.T) {
+	ms := NewMemoryStore()
+	amount := money.FromMinor(1000000, money.DefaultCurrency)
+
+	create := func(key, hash string) (*pending.Transfer, *StoredResponse, error) {
+		transfer := pending.New("A1", "A2", amount, "", pending.MethodPIN, time.Now(), time.Minute)
+		idem := &Idempotency{
+			Key:         key,
+			Endpoint:    "transfer",
+			RequestHash: hash,
+			Response:    StoredResponse{StatusCode: http.StatusAccepted},
+		}
+		idem.Body = func() []byte { return []byte(strconv.FormatInt(transfer.ID, 10)) }
+		stored, err := ms.CreatePendingTransfer(transfer, idem)
+		return transfer, stored, err
+	}
+
+	first, stored, err := create("k1", "h1")
+	if err != nil || stored != nil {
+		t.Fatalf("first CreatePendingTransfer = %v, %v", stored, err)
+	}
+
+	second, stored, err := create("k1", "h1")
+	if err != nil {
+		t.Fatalf("retry CreatePendingTransfer: %v", err)
+	}
+	if stored == nil || stored.StatusCode != http.StatusAccepted || string(stored.Body) != strconv.FormatInt(first.ID, 10) {
+		t.Fatalf("retry stored response = %+v, want 202 with ID %d", stored, first.ID)
+	}
+	if second.ID != 0 {
+		t.Errorf("retry created pending transfer %d", second.ID)
+	}
+
+	if _, _, err := create("k1", "other"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("reused key error = %v, want %v", err, ErrIdempotencyKeyReused)
+	}
+
+	third, stored, err := create("k2", "h1")
+	if err != nil || stored != nil || third.ID == first.ID {
+		t.Errorf("new key CreatePendingTransfer = %d, %v, %v", third.ID, stored, err)
+	}
+}
+
+// аккаунт, к которому не удалось выпустить первую карту, не создаётся
+func TestMemoryStoreCreateAccountWithCard(t *testing.T) {
+	newCard := func(accountID, pan string) *account.Card {
+		return &account.Card{ID: "card-" + accountID, AccountID: accountID, PAN: pan, CVC2: "123",
+			ExpiryMonth: 1, ExpiryYear: time.Now().Year() + 3, Status: account.CardActive}
+	}
+
+	tests := []struct {
+		name      string
+		status    string
+		pan       string
+		wantErr   bool
+		wantCards int
+	}{
+		{name: "without card", status: account.StatusActive},
+		{name: "with card", status: account.StatusActive, pan: "4000000000000002", wantCards: 1},
+		{name: "frozen account", status: account.StatusFrozen, pan: "4000000000000002", wantErr: true},
+		{name: "taken card number", status: account.StatusActive, pan: "4000000000000001", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := NewMemoryStore()
+			existing := testAccount(1, 0)
+			if err := ms.CreateAccountWithCard(existing, newCard(existing.ID, "4000000000000001")); err != nil {
+				t.Fatalf("CreateAccountWithCard: %v", err)
+			}
+
+			acc := testAccount(2, 0)
+			acc.Status = tt.status
+			var card *account.Card
+			if tt.pan != "" {
+				card = newCard(acc.ID, tt.pan)
+			}
+			err := ms.CreateAccountWithCard(acc, card)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateAccountWithCard error = %v, want error %v", err, tt.wantErr)
+			}
+
+			stored, getErr := ms.GetAccount(acc.ID)
+			if tt.wantErr {
+				if getErr == nil {
+					t.Error("account was created without its card")
+				}
+				return
+			}
+			if getErr != nil {
+				t.Fatalf("GetAccount: %v", getErr)
+			}
+			if len(stored.Cards) != tt.wantCards {
+				t.Errorf("cards = %d, want %d", len(stored.Cards), tt.wantCards)
+			}
+			for _, c := range stored.Cards {
+				if c.CVC2 != "" {
+					t.Error("plaintext CVC2 stored")
+				}
+			}
+		})
+	}
+}
+
+// авторизация картой с заданным CVC2 на небольшую сумму
+func authorizeCard(store Store, card *account.Card, cvc2 string) error {
+	_, err := store.AuthorizeCard(&account.Authorization{
+		PAN:         card.PAN,
+		ExpiryMonth: card.ExpiryMonth,
+		ExpiryYear:  card.ExpiryYear,
+		CVC2:        cvc2,
+		Amount:      money.FromMinor(100, money.DefaultCurrency),
+		Merchant:    "Test",
+		TTL:         time.Hour,
+		Policy:      account.LoginPolicy{MaxFailures: 3},
+	})
+	return err
+}
+
+// неверные CVC2 подряд блокируют карту; успешная авторизация и снятие
+// блокировки сбрасывают счётчик
+func TestMemoryStoreAuthorizeCardFailures(t *testing.T) {
+	const unblock = "unblock"
+
+	tests := []struct {
+		name       string
+		steps      []string // CVC2 авторизации или unblock
+		wantStatus string
+	}{
+		{name: "below limit", steps: []string{"999", "999"}, wantStatus: account.CardActive},
+		{name: "limit reached", steps: []string{"999", "999", "999"}, wantStatus: account.CardBlocked},
+		{name: "success resets", steps: []string{"999", "999", "123", "999", "999"}, wantStatus: account.CardActive},
+		{name: "unblock resets", steps: []string{"999", "999", "999", unblock, "999", "999"}, wantStatus: account.CardActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := NewMemoryStore()
+			acc := testAccount(1, 100000)
+			card := &account.Card{ID: "card-1", AccountID: acc.ID, PAN: "4000000000000001", CVC2: "123",
+				ExpiryMonth: 1, ExpiryYear: time.Now().Year() + 3, Status: account.CardActive}
+			if err := ms.CreateAccountWithCard(acc, card); err != nil {
+				t.Fatalf("CreateAccountWithCard: %v", err)
+			}
+
+			for _, step := range tt.steps {
+				if step == unblock {
+					if err := ms.SetCardStatus(card.ID, account.CardActive, ""); err != nil {
+						t.Fatalf("SetCardStatus: %v", err)
+					}
+					continue
+				}
+				err := authorizeCard(ms, card, step)
+				if step == "123" && err != nil {
+					t.Fatalf("AuthorizeCard: %v", err)
+				}
+				if step != "123" && err == nil {
+					t.Fatal("AuthorizeCard accepted an invalid CVC2")
+				}
+			}
+
+			got, err := ms.GetCard(card.ID)
+			if err != nil {
+				t.Fatalf("GetCard: %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if tt.wantStatus == account.CardBlocked && got.StatusReason != account.CardReasonInvalidDetails {
+				t.Errorf("status reason = %q, want %q", got.StatusReason, account.CardReasonInvalidDetails)
+			}
+		})
+	}
+}
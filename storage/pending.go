@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"mfp/pending"
+	"time"
+)
+
+func (ms *MemoryStore) CreatePendingTransfer(t *pending.Transfer, idem *Idempotency) (*StoredResponse, error) {
+	return ms.idempotent(t.AccountID, idem, func() error {
+		ms.pendingMu.Lock()
+		defer ms.pendingMu.Unlock()
+
+		ms.nextPendingID++
+		t.ID = ms.nextPendingID
+		copied := *t
+		ms.pending[t.ID] = &copied
+		return nil
+	})
+}
+
+func (ms *MemoryStore) GetPendingTransfer(id int64) (*pending.Transfer, error) {
+	ms.pendingMu.Lock()
+	defer ms.pendingMu.Unlock()
+
+	t, ok := ms.pending[id]
+	if !ok {
+		return nil, pending.ErrNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (ms *MemoryStore) UpdatePendingTransfer(id int64, change func(t *pending.Transfer) error) error {
+	ms.pendingMu.Lock()
+	defer ms.pendingMu.Unlock()
+
+	t, ok := ms.pending[id]
+	if !ok {
+		return pending.ErrNotFound
+	}
+	copied := *t
+	if err := change(&copied); err != nil {
+		return err
+	}
+	ms.pending[id] = &copied
+	return nil
+}
+
+func (ms *MemoryStore) ExpirePendingTransfers(now time.Time) (int, error) {
+	ms.pendingMu.Lock()
+	defer ms.pendingMu.Unlock()
+
+	expired := 0
+	for _, t := range ms.pending {
+		if t.Expire(now) {
+			expired++
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"mfp/schedule"
+	"sort"
+	"time"
+)
+
+// запланированный перевод в памяти и срок его блокировки планировщиком
+type memoryScheduled struct {
+	transfer    schedule.Transfer
+	lockedUntil time.Time
+}
+
+func (ms *MemoryStore) CreateScheduledTransfer(t *schedule.Transfer) error {
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+
+	ms.nextScheduledID++
+	t.ID = ms.nextScheduledID
+	ms.scheduled[t.ID] = &memoryScheduled{transfer: *t}
+	return nil
+}
+
+func (ms *MemoryStore) GetScheduledTransfer(id int64) (*schedule.Transfer, error) {
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+
+	stored, ok := ms.scheduled[id]
+	if !ok {
+		return nil, schedule.ErrNotFound
+	}
+	copied := stored.transfer
+	return &copied, nil
+}
+
+func (ms *MemoryStore) GetScheduledTransfers(accountIDs []string) ([]*schedule.Transfer, error) {
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+
+	transfers := []*schedule.Transfer{}
+	for _, stored := range ms.scheduled {
+		if contains(accountIDs, stored.transfer.AccountID) {
+			copied := stored.transfer
+			transfers = append(transfers, &copied)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].ID > transfers[j].ID })
+	return transfers, nil
+}
+
+func (ms *MemoryStore) SetScheduledTransferStatus(id int64, from, to string) error {
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+
+	stored, ok := ms.scheduled[id]
+	if !ok {
+		return schedule.ErrNotFound
+	}
+	if stored.transfer.Status != from {
+		return fmt.Errorf("scheduled transfer is %s, not %s", stored.transfer.Status, from)
+	}
+	if from == schedule.StatusPaused && to == schedule.StatusActive {
+		stored.transfer.Resume(time.Now())
+		return nil
+	}
+	stored.transfer.Status = to
+	stored.transfer.UpdatedAt = time.Now()
+	return nil
+}
+
+func (ms *MemoryStore) ClaimDueTransfers(now time.Time, limit int, lease time.Duration) ([]*schedule.Transfer, error) {
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+
+	var due []*memoryScheduled
+	for _, stored := range ms.scheduled {
+		t := stored.transfer
+		if t.Status == schedule.StatusActive && !t.NextRunAt.After(now) && !stored.lockedUntil.After(now) {
+			due = append(due, stored)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].transfer.NextRunAt.Before(due[j].transfer.NextRunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	transfers := make([]*schedule.Transfer, 0, len(due))
+	for _, stored := range due {
+		stored.lockedUntil = now.Add(lease)
+		copied := stored.transfer
+		transfers = append(transfers, &copied)
+	}
+	return transfers, nil
+}
+
+func (ms *MemoryStore) SaveScheduledRun(t *schedule.Transfer) error {
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+
+	stored, ok := ms.scheduled[t.ID]
+	if !ok {
+		return schedule.ErrNotFound
+	}
+	status := stored.transfer.Status
+	stored.transfer = *t
+	if status != schedule.StatusActive {
+		stored.transfer.Status = status
+	}
+	stored.lockedUntil = time.Time{}
+	return nil
+}
+
+func (ms *MemoryStore) AddNotification(n *Notification) error {
+	ms.auditMu.Lock()
+	defer ms.auditMu.Unlock()
+
+	copied := *n
+	copied.ID = int64(len(ms.notifications) + 1)
+	if copied.CreatedAt.IsZero() {
+		copied.CreatedAt = time.Now()
+	}
+	n.ID, n.CreatedAt = copied.ID, copied.CreatedAt
+	ms.notifications = append(ms.notifications, &copied)
+	return nil
+}
+
+// последние уведомления счёта, новые сверху
+func (ms *MemoryStore) GetNotifications(accountID string, limit int) ([]*Notification, error) {
+	ms.auditMu.RLock()
+	defer ms.auditMu.RUnlock()
+
+	notifications := []*Notification{}
+	for i := len(ms.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		if ms.notifications[i].AccountID == accountID {
+			copied := *ms.notifications[i]
+			notifications = append(notifications, &copied)
+		}
+	}
+	return notifications, nil
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"mfp/account"
+	"mfp/fees"
+	"mfp/fx"
+	"mfp/pending"
+	"mfp/schedule"
+	"mfp/session"
+	"mfp/token"
+	"sort"
+)
+
+// версия формата файла; в файлах без версии записаны только аккаунты
+const snapshotVersion = 2
+
+// всё состояние хранилища в памяти для записи в файл. Блокировки
+// запланированных переводов не сохраняются: после перезапуска их
+// некому снимать
+type snapshot struct {
+	Version         int                              `json:"version"`
+	Accounts        json.RawMessage                  `json:"accounts"`
+	IdempotencyKeys []snapshotKey                    `json:"idempotency_keys"`
+	Rates           []fx.Rate                        `json:"rates"`
+	Quotes          []*fx.Quote                      `json:"quotes"`
+	FeeRules        []fees.Rule                      `json:"fee_rules"`
+	Audit           []*AuditEvent                    `json:"audit"`
+	Notifications   []*Notification                  `json:"notifications"`
+	Scheduled       []*schedule.Transfer             `json:"scheduled"`
+	NextScheduledID int64                            `json:"next_scheduled_id"`
+	Pending         []*pending.Transfer              `json:"pending"`
+	NextPendingID   int64                            `json:"next_pending_id"`
+	UnknownLogins   map[string]account.LoginAttempts `json:"unknown_logins"` // по слепому индексу телефона
+	Sessions        []*session.Session               `json:"sessions"`
+	RefreshTokens   []*token.RefreshToken            `json:"refresh_tokens"`
+}
+
+// ключ идемпотентности в файле
+type snapshotKey struct {
+	ID          string `json:"id"`
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code"`
+	Body        []byte `json:"body"`
+}
+
+// копия хранилища, которую можно менять, не затрагивая исходное
+func (ms *MemoryStore) clone() *MemoryStore {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+	ms.pendingMu.Lock()
+	defer ms.pendingMu.Unlock()
+	ms.auditMu.RLock()
+	defer ms.auditMu.RUnlock()
+	ms.loginMu.Lock()
+	defer ms.loginMu.Unlock()
+
+	next := newMemoryStore(ms.accounts.Clone())
+	next.sessions.Load(ms.sessions.All())
+	next.tokens.Load(ms.tokens.All())
+	for id, key := range ms.keys {
+		next.keys[id] = key
+	}
+	next.audit = append([]*AuditEvent(nil), ms.audit...)
+	next.notifications = append([]*Notification(nil), ms.notifications...)
+	for pair, r := range ms.rates {
+		next.rates[pair] = r
+	}
+	for id, q := range ms.quotes {
+		copied := *q
+		next.quotes[id] = &copied
+	}
+	next.feeRules = append([]fees.Rule(nil), ms.feeRules...)
+	for id, stored := range ms.scheduled {
+		copied := *stored
+		next.scheduled[id] = &copied
+	}
+	next.nextScheduledID = ms.nextScheduledID
+	for id, t := range ms.pending {
+		copied := *t
+		next.pending[id] = &copied
+	}
+	next.nextPendingID = ms.nextPendingID
+	for index, a := range ms.unknownLogins {
+		next.unknownLogins[index] = a
+	}
+	return next
+}
+
+// замена содержимого хранилища содержимым next; next дальше не используется
+func (ms *MemoryStore) replace(next *MemoryStore) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.schedMu.Lock()
+	defer ms.schedMu.Unlock()
+	ms.pendingMu.Lock()
+	defer ms.pendingMu.Unlock()
+	ms.auditMu.Lock()
+	defer ms.auditMu.Unlock()
+	ms.loginMu.Lock()
+	defer ms.loginMu.Unlock()
+
+	ms.accounts.Replace(next.accounts)
+	ms.sessions.Load(next.sessions.All())
+	ms.tokens.Load(next.tokens.All())
+	ms.keys = next.keys
+	ms.audit, ms.notifications = next.audit, next.notifications
+	ms.rates, ms.quotes, ms.feeRules = next.rates, next.quotes, next.feeRules
+	ms.scheduled, ms.nextScheduledID = next.scheduled, next.nextScheduledID
+	ms.pending, ms.nextPendingID = next.pending, next.nextPendingID
+	ms.unknownLogins = next.unknownLogins
+}
+
+// состояние хранилища в формате файла; вызывается для копии из clone,
+// которую никто больше не видит, поэтому без блокировок. Записи из map
+// сортируются, чтобы одинаковое состояние давало одинаковый файл
+func (ms *MemoryStore) marshalSnapshot() ([]byte, error) {
+	accounts, err := ms.accounts.MarshalSealed()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := snapshot{
+		Version:         snapshotVersion,
+		Accounts:        accounts,
+		IdempotencyKeys: make([]snapshotKey, 0, len(ms.keys)),
+		Rates:           ms.rateList(),
+		Quotes:          make([]*fx.Quote, 0, len(ms.quotes)),
+		FeeRules:        ms.feeRules,
+		Audit:           ms.audit,
+		Notifications:   ms.notifications,
+		Scheduled:       make([]*schedule.Transfer, 0, len(ms.scheduled)),
+		NextScheduledID: ms.nextScheduledID,
+		Pending:         make([]*pending.Transfer, 0, len(ms.pending)),
+		NextPendingID:   ms.nextPendingID,
+		UnknownLogins:   ms.unknownLogins,
+		Sessions:        ms.sessions.All(),
+		RefreshTokens:   ms.tokens.All(),
+	}
+	for id, key := range ms.keys {
+		snap.IdempotencyKeys = append(snap.IdempotencyKeys, snapshotKey{
+			ID:          id,
+			RequestHash: key.requestHash,
+			StatusCode:  key.response.StatusCode,
+			Body:        key.response.Body,
+		})
+	}
+	sort.Slice(snap.IdempotencyKeys, func(i, j int) bool { return snap.IdempotencyKeys[i].ID < snap.IdempotencyKeys[j].ID })
+	for _, q := range ms.quotes {
+		snap.Quotes = append(snap.Quotes, q)
+	}
+	sort.Slice(snap.Quotes, func(i, j int) bool { return snap.Quotes[i].ID < snap.Quotes[j].ID })
+	for _, stored := range ms.scheduled {
+		snap.Scheduled = append(snap.Scheduled, &stored.transfer)
+	}
+	sort.Slice(snap.Scheduled, func(i, j int) bool { return snap.Scheduled[i].ID < snap.Scheduled[j].ID })
+	for _, t := range ms.pending {
+		snap.Pending = append(snap.Pending, t)
+	}
+	sort.Slice(snap.Pending, func(i, j int) bool { return snap.Pending[i].ID < snap.Pending[j].ID })
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %v", err)
+	}
+	return data, nil
+}
+
+// загрузка состояния из файла; файл без версии содержит только аккаунты
+func (ms *MemoryStore) unmarshalSnapshot(data []byte) error {
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %v", err)
+	}
+	if snap.Version == 0 {
+		return ms.accounts.UnmarshalSealed(data)
+	}
+	if snap.Version > snapshotVersion {
+		return fmt.Errorf("data file version %d is newer than supported %d", snap.Version, snapshotVersion)
+	}
+	if err := ms.accounts.UnmarshalSealed(snap.Accounts); err != nil {
+		return err
+	}
+
+	for _, key := range snap.IdempotencyKeys {
+		ms.keys[key.ID] = memoryIdempotencyKey{
+			requestHash: key.RequestHash,
+			response:    StoredResponse{StatusCode: key.StatusCode, Body: key.Body},
+		}
+	}
+	for _, r := range snap.Rates {
+		ms.rates[[2]string{r.From, r.To}] = r
+	}
+	for _, q := range snap.Quotes {
+		ms.quotes[q.ID] = q
+	}
+	ms.feeRules = snap.FeeRules
+	ms.audit, ms.notifications = snap.Audit, snap.Notifications
+	for _, t := range snap.Scheduled {
+		ms.scheduled[t.ID] = &memoryScheduled{transfer: *t}
+	}
+	ms.nextScheduledID = snap.NextScheduledID
+	for _, t := range snap.Pending {
+		ms.pending[t.ID] = t
+	}
+	ms.nextPendingID = snap.NextPendingID
+	for index, a := range snap.UnknownLogins {
+		ms.unknownLogins[index] = a
+	}
+	ms.sessions.Load(snap.Sessions)
+	ms.tokens.Load(snap.RefreshTokens)
+	return nil
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"errors"
+	"mfp/account"
+	"mfp/fees"
+	"mfp/fx"
+	"mfp/money"
+	"mfp/pending"
+	"mfp/schedule"
+	"mfp/session"
+	"mfp/token"
+	"time"
+)
+
+// счёт с указанным ID не существует
+var ErrAccountNotFound = errors.New("account not found")
+
+// ключ повторно использован с другим телом запроса
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
+// сохранённый ответ на запрос с ключом идемпотентности
+type StoredResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// параметры идемпотентного запроса
+type Idempotency struct {
+	Key         string         // значение заголовка Idempotency-Key
+	Endpoint    string         // операция, к которой привязан ключ
+	RequestHash string         // хеш тела запроса для обнаружения подмены
+	Response    StoredResponse // ответ, сохраняемый при успешном выполнении
+	// тело ответа, которое известно только после выполнения, например
+	// с ID созданной записи; если задано, заменяет Response.Body
+	Body func() []byte
+}
+
+// ответ для сохранения после успешного выполнения операции
+func (i *Idempotency) Result() StoredResponse {
+	response := i.Response
+	if i.Body != nil {
+		response.Body = i.Body()
+	}
+	return response
+}
+
+// запись журнала аудита о привилегированном действии
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	ActorID   string    `json:"actor_id"`   // кто выполнил действие
+	ActorRole string    `json:"actor_role"` // роль в момент действия
+	Action    string    `json:"action"`
+	TargetID  string    `json:"target_id"` // затронутый аккаунт
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// уведомление клиенту, например о неудавшемся запланированном переводе
+type Notification struct {
+	ID        int64     `json:"id"`
+	AccountID string    `json:"account_id"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// хранилище данных банка: PostgreSQL, память процесса или JSON-файл
+type Store interface {
+	CreateAccount(acc *account.Account) error
+	// открытие аккаунта вместе с первой картой: если карту выпустить
+	// не удалось, аккаунт не создаётся; c может быть nil
+	CreateAccountWithCard(acc *account.Account, c *account.Card) error
+	GetAccount(id string) (*account.Account, error)
+	// основной аккаунт по телефону; валютные счета по телефону не ищутся
+	GetAccountByPhone(phone string) (*account.Account, error)
+	// основной аккаунт и его валютные счета
+	GetOwnedAccounts(ownerID string) ([]*account.Account, error)
+	GetAccounts() ([]*account.Account, error)
+	SearchAccounts(query string) ([]*account.Account, error)
+	SetRole(accountID, role string) error
+	// смена статуса аккаунта (active, frozen, blocked, closed);
+	// при блокировке и закрытии сессии аккаунта удаляются
+	SetStatus(accountID, status, reason string) error
+
+	// денежные операции; при повторе ключа идемпотентности
+	// возвращается сохранённый ответ, а операция не выполняется
+	Deposit(accountID string, amount money.Money, idem *Idempotency) (*StoredResponse, error)
+	Withdraw(accountID string, amount money.Money, idem *Idempotency) (*StoredResponse, error)
+	// перевод; если валюты счетов различаются, сумма конвертируется
+	// по котировке quoteID, а без неё — по текущему курсу
+	Transfer(fromAccount, toAccount string, amount money.Money, quoteID string, idem *Idempotency) (*StoredResponse, error)
+	// лимит и годовая ставка овердрафта
+	SetOverdraft(accountID string, limit money.Money, annualRate string) error
+	// списание процентов за отрицательный остаток на конец каждого ещё
+	// не обработанного дня по through включительно; повторный вызов
+	// за те же дни ничего не списывает
+	ChargeOverdraftInterest(accountID string, through time.Time) (money.Money, error)
+	// запрос клиента на смену лимитов расходов: понижения действуют сразу,
+	// повышения — через cooling или после одобрения администратором
+	RequestLimits(accountID string, requested account.SpendingLimits, cooling time.Duration) (account.SpendingLimits, *account.PendingLimits, error)
+	// установка лимитов администратором, в том числе одобрение повышения
+	SetLimits(accountID string, limits account.SpendingLimits) error
+	// порог подтверждения переводов счёта; nil возвращает порог по умолчанию
+	SetConfirmThreshold(accountID string, threshold *money.Money) error
+	// смена продукта и процентной ставки; проценты за прошедшие дни
+	// начисляются по прежним условиям
+	SetProduct(accountID, product, annualRate string, maturity *time.Time) error
+	// начисление процентов за дни после последнего начисленного по through
+	// включительно с капитализацией в конце месяца и срока вклада;
+	// возвращает зачисленную сумму, повторный вызов ничего не начисляет
+	AccrueInterest(accountID string, through time.Time) (money.Money, error)
+	// выпуск карты к активному счёту c.AccountID
+	IssueCard(c *account.Card) error
+	GetCard(id string) (*account.Card, error)
+	// карты перечисленных счетов, новые сверху
+	GetCards(accountIDs []string) ([]*account.Card, error)
+	// смена статуса карты с проверкой перехода, см. account.Card.SetStatus
+	SetCardStatus(id, status, reason string) error
+	SetCardPIN(id, pinHash string) error
+	// перевыпуск карты с прежним PIN; старая карта блокируется
+	ReissueCard(id string) (*account.Card, error)
+	// авторизация платежа по карте: проверка реквизитов, доступного
+	// остатка и лимитов и блокировка суммы на счёте карты
+	AuthorizeCard(a *account.Authorization) (*account.Hold, error)
+	GetHold(id int64) (*account.Hold, error)
+	// списание по блокировке; nil списывает всю заблокированную сумму,
+	// несписанный остаток разблокируется
+	CaptureHold(id int64, amount *money.Money) (*account.Hold, error)
+	ReverseHold(id int64) (*account.Hold, error)
+	// снятие несписанных блокировок со сроком не позже now
+	ExpireHolds(now time.Time) (int, error)
+
+	// страница истории операций счёта с итогами по фильтру
+	QueryTransactions(accountID string, filter TransactionFilter) (*TransactionPage, error)
+
+	// курсы обмена: SetRates добавляет или заменяет курсы переданных пар
+	SetRates(rates []fx.Rate) error
+	GetRates() ([]fx.Rate, error)
+	CreateQuote(quote *fx.Quote) error
+
+	// тарифы комиссий; SetFeeRules заменяет весь набор
+	SetFeeRules(rules []fees.Rule) error
+	GetFeeRules() ([]fees.Rule, error)
+	// комиссия, которую сейчас взяла бы операция kind (transfer, withdrawal)
+	// со счёта accountID; toAccount нужен только для перевода
+	EstimateFee(accountID, kind, toAccount string, amount money.Money) (money.Money, error)
+
+	// запланированные переводы
+	CreateScheduledTransfer(t *schedule.Transfer) error
+	GetScheduledTransfer(id int64) (*schedule.Transfer, error)
+	// переводы с перечисленных счетов, новые сверху
+	GetScheduledTransfers(accountIDs []string) ([]*schedule.Transfer, error)
+	// смена статуса, только если текущий статус равен from
+	SetScheduledTransferStatus(id int64, from, to string) error
+	// активные переводы со сроком не позже now; выбранные переводы
+	// не выдаются другим вызовам на время lease
+	ClaimDueTransfers(now time.Time, limit int, lease time.Duration) ([]*schedule.Transfer, error)
+	// сохранение результата запуска и снятие блокировки; статус,
+	// изменённый клиентом во время запуска, не перезаписывается
+	SaveScheduledRun(t *schedule.Transfer) error
+
+	// крупные переводы, ожидающие подтверждения; повтор запроса
+	// с тем же ключом идемпотентности возвращает сохранённый ответ
+	// и второй перевод не создаёт
+	CreatePendingTransfer(t *pending.Transfer, idem *Idempotency) (*StoredResponse, error)
+	GetPendingTransfer(id int64) (*pending.Transfer, error)
+	// изменение под блокировкой; при ошибке change ничего не сохраняется
+	UpdatePendingTransfer(id int64, change func(t *pending.Transfer) error) error
+	// отметка неподтверждённых переводов со сроком не позже now
+	ExpirePendingTransfers(now time.Time) (int, error)
+
+	AddNotification(n *Notification) error
+	GetNotifications(accountID string, limit int) ([]*Notification, error)
+
+	// второй фактор входа; account.ErrTwoFactorNotFound, если клиент
+	// его не подключал
+	GetTwoFactor(accountID string) (*account.TwoFactor, error)
+	// замена второго фактора; nil отключает его
+	SetTwoFactor(accountID string, tf *account.TwoFactor) error
+	// изменение второго фактора под блокировкой, например отметка
+	// использованного кода; при ошибке change ничего не сохраняется
+	UpdateTwoFactor(accountID string, change func(tf *account.TwoFactor) error) error
+
+	// неудачные попытки входа; у клиента без неудач счётчик пустой
+	GetLoginAttempts(accountID string) (*account.LoginAttempts, error)
+	// изменение счётчика под блокировкой, чтобы параллельные попытки
+	// не обошли паузу; при ошибке change ничего не сохраняется
+	UpdateLoginAttempts(accountID string, change func(a *account.LoginAttempts) error) error
+	// счётчик для незарегистрированного телефона: паузы и блокировки
+	// такие же, как у клиентов, чтобы ответ не выдавал, есть ли номер
+	UpdateUnknownLoginAttempts(phone string, change func(a *account.LoginAttempts) error) error
+
+	// перешифрование текущим ключом не более limit записей с телефонами,
+	// CVC2 и секретами TOTP, открытыми или зашифрованными прежним ключом; возвращает
+	// число записей, 0 — перешифровывать больше нечего
+	Reencrypt(limit int) (int, error)
+
+	RecordAudit(event *AuditEvent) error
+	GetAuditLog(limit int) ([]*AuditEvent, error)
+
+	Sessions() session.Store
+	RefreshTokens() token.Store
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"errors"
+	"mfp/account"
+)
+
+// второй фактор хранится вместе со счётом в account.AccountList
+
+func (ms *MemoryStore) GetTwoFactor(accountID string) (*account.TwoFactor, error) {
+	tf, err := ms.accounts.GetTwoFactor(accountID)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return nil, ErrAccountNotFound
+	}
+	return tf, err
+}
+
+func (ms *MemoryStore) SetTwoFactor(accountID string, tf *account.TwoFactor) error {
+	err := ms.accounts.SetTwoFactor(accountID, tf)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return ErrAccountNotFound
+	}
+	return err
+}
+
+func (ms *MemoryStore) UpdateTwoFactor(accountID string, change func(tf *account.TwoFactor) error) error {
+	err := ms.accounts.UpdateTwoFactor(accountID, change)
+	if errors.Is(err, account.ErrAccountNotFound) {
+		return ErrAccountNotFound
+	}
+	return err
+}
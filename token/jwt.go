@@ -0,0 +1,107 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token expired")
+)
+
+// часы клиента и сервера могут расходиться
+const clockSkew = time.Minute
+
+// заголовок всех токенов: подпись HMAC-SHA256, другие алгоритмы
+// не принимаются
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// поля токена доступа
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"` // ID аккаунта
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+	FamilyID  string `json:"sid"` // цепочка токенов обновления, из которой выдан токен
+}
+
+// подпись и проверка токенов доступа (JWT, HS256)
+type Signer struct {
+	key    []byte
+	issuer string
+}
+
+func NewSigner(key []byte, issuer string) *Signer {
+	return &Signer{key: key, issuer: issuer}
+}
+
+// подпись случайным ключом только в памяти процесса, для хранилища
+// без данных на диске: токены не переживают перезапуск
+func NewEphemeralSigner(issuer string) *Signer {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("Key generation failed: %v", err))
+	}
+	return NewSigner(key, issuer)
+}
+
+func (s *Signer) Sign(claims Claims) (string, error) {
+	claims.Issuer = s.issuer
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %v", err)
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(s.mac(signed)), nil
+}
+
+// проверка подписи, издателя и срока токена
+func (s *Signer) Parse(raw string, now time.Time) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	// заголовок сравнивается целиком: так не пройдёт ни "alg":"none",
+	// ни подпись другим алгоритмом
+	if parts[0] != header {
+		return nil, ErrInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, s.mac(parts[0]+"."+parts[1])) {
+		return nil, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalid
+	}
+	if claims.Issuer != s.issuer || claims.Subject == "" {
+		return nil, ErrInvalid
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return nil, ErrInvalid
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+func (s *Signer) mac(signed string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerParse(t *testing.T) {
+	signer := NewSigner([]byte("test-key"), "mfp")
+	now := time.Unix(1700000000, 0)
+	valid := Claims{Subject: "user-1", IssuedAt: now.Unix(), ExpiresAt: now.Add(15 * time.Minute).Unix(), ID: "jti", FamilyID: "fam"}
+
+	sign := func(s *Signer, claims Claims) string {
+		raw, err := s.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	// токен с другим заголовком и подписью, посчитанной по нему тем же ключом
+	withHeader := func(h string) string {
+		parts := strings.Split(sign(signer, valid), ".")
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(h))
+		signed := encoded + "." + parts[1]
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signer.mac(signed))
+	}
+	withClaims := func(change func(*Claims)) string {
+		claims := valid
+		change(&claims)
+		return sign(signer, claims)
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", raw: sign(signer, valid), now: now},
+		{name: "valid until expiry", raw: sign(signer, valid), now: now.Add(15*time.Minute - time.Second)},
+		{name: "expired exactly at exp", raw: sign(signer, valid), now: now.Add(15 * time.Minute), wantErr: ErrExpired},
+		{name: "expired", raw: sign(signer, valid), now: now.Add(time.Hour), wantErr: ErrExpired},
+		{name: "issued within clock skew", raw: withClaims(func(c *Claims) { c.IssuedAt = now.Add(clockSkew).Unix() }), now: now},
+		{name: "issued in the future", raw: withClaims(func(c *Claims) { c.IssuedAt = now.Add(2 * clockSkew).Unix() }), now: now, wantErr: ErrInvalid},
+		{name: "alg none", raw: withHeader(`{"alg":"none","typ":"JWT"}`), now: now, wantErr: ErrInvalid},
+		{name: "alg HS512", raw: withHeader(`{"alg":"HS512","typ":"JWT"}`), now: now, wantErr: ErrInvalid},
+		{name: "alg RS256", raw: withHeader(`{"alg":"RS256","typ":"JWT"}`), now: now, wantErr: ErrInvalid},
+		{name: "reordered header", raw: withHeader(`{"typ":"JWT","alg":"HS256"}`), now: now, wantErr: ErrInvalid},
+		{name: "other key", raw: sign(NewSigner([]byte("other-key"), "mfp"), valid), now: now, wantErr: ErrInvalid},
+		{name: "other issuer", raw: sign(NewSigner([]byte("test-key"), "other"), valid), now: now, wantErr: ErrInvalid},
+		{name: "missing subject", raw: withClaims(func(c *Claims) { c.Subject = "" }), now: now, wantErr: ErrInvalid},
+		{name: "unsigned", raw: strings.Join(strings.Split(sign(signer, valid), ".")[:2], ".") + ".", now: now, wantErr: ErrInvalid},
+		{name: "two parts", raw: "a.b", now: now, wantErr: ErrInvalid},
+		{name: "empty", raw: "", now: now, wantErr: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := signer.Parse(tt.raw, tt.now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			if claims.Subject != valid.Subject || claims.FamilyID != valid.FamilyID || claims.Issuer != "mfp" {
+				t.Errorf("Parse() = %+v", claims)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package token
+
+import (
+	"log"
+	"time"
+)
+
+// выдача токенов для мобильных и серверных клиентов: короткий токен
+// доступа (JWT) не проверяется по хранилищу, а долгий токен обновления
+// одноразовый и может быть отозван
+type Manager struct {
+	signer     *Signer
+	store      Store
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// пара токенов в ответе клиенту
+type Pair struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"` // секунд
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+}
+
+func NewManager(signer *Signer, store Store, accessTTL, refreshTTL time.Duration) *Manager {
+	m := &Manager{
+		signer:     signer,
+		store:      store,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+
+	go func() {
+		for {
+			time.Sleep(1 * time.Hour)
+			if err := m.store.DeleteExpired(time.Now()); err != nil {
+				log.Printf("Failed to clean up expired refresh tokens: %v", err)
+			}
+		}
+	}()
+	return m
+}
+
+// токены после входа; начинают новую цепочку
+func (m *Manager) Issue(userID string) (*Pair, error) {
+	now := time.Now()
+	raw, refresh := NewRefreshToken(userID, "", now, m.refreshTTL)
+	if err := m.store.Create(refresh); err != nil {
+		return nil, err
+	}
+	return m.pair(refresh, raw, now)
+}
+
+// обмен токена обновления на новую пару; прежний токен больше
+// не принимается
+func (m *Manager) Refresh(raw string) (*Pair, error) {
+	now := time.Now()
+	nextRaw, next := NewRefreshToken("", "", now, m.refreshTTL)
+	if _, err := m.store.Rotate(Hash(raw), now, next); err != nil {
+		return nil, err
+	}
+	return m.pair(next, nextRaw, now)
+}
+
+// отзыв цепочки токена обновления, например при выходе из приложения.
+// Выданные из неё токены доступа действуют до своего срока
+func (m *Manager) Revoke(raw string) error {
+	return m.store.Revoke(Hash(raw), time.Now())
+}
+
+func (m *Manager) RevokeUser(userID string) error {
+	return m.store.RevokeUser(userID, time.Now())
+}
+
+// проверка токена доступа
+func (m *Manager) Authenticate(access string) (*Claims, error) {
+	return m.signer.Parse(access, time.Now())
+}
+
+func (m *Manager) pair(refresh *RefreshToken, raw string, now time.Time) (*Pair, error) {
+	access, err := m.signer.Sign(Claims{
+		Subject:   refresh.UserID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(m.accessTTL).Unix(),
+		ID:        randomString(),
+		FamilyID:  refresh.FamilyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Pair{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(m.accessTTL.Seconds()),
+		RefreshToken:     raw,
+		RefreshExpiresIn: int(m.refreshTTL.Seconds()),
+	}, nil
+}
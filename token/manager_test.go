@@ -0,0 +1,120 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManagerRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// действия над выданной парой; возвращает токен, который предъявляется последним
+		prepare func(t *testing.T, m *Manager, first *Pair) string
+		wantErr error
+		// после ошибки отозваны все токены цепочки, включая самый новый
+		familyRevoked bool
+	}{
+		{
+			name:    "first rotation",
+			prepare: func(t *testing.T, m *Manager, first *Pair) string { return first.RefreshToken },
+		},
+		{
+			name: "rotated token",
+			prepare: func(t *testing.T, m *Manager, first *Pair) string {
+				return mustRefresh(t, m, first.RefreshToken).RefreshToken
+			},
+		},
+		{
+			name:    "unknown token",
+			prepare: func(t *testing.T, m *Manager, first *Pair) string { return "unknown" },
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "reused token revokes family",
+			prepare: func(t *testing.T, m *Manager, first *Pair) string {
+				mustRefresh(t, m, first.RefreshToken)
+				return first.RefreshToken
+			},
+			wantErr:       ErrReused,
+			familyRevoked: true,
+		},
+		{
+			name: "revoked token",
+			prepare: func(t *testing.T, m *Manager, first *Pair) string {
+				if err := m.Revoke(first.RefreshToken); err != nil {
+					t.Fatal(err)
+				}
+				return first.RefreshToken
+			},
+			wantErr: ErrRevoked,
+		},
+		{
+			name: "revoked user",
+			prepare: func(t *testing.T, m *Manager, first *Pair) string {
+				if err := m.RevokeUser("user-1"); err != nil {
+					t.Fatal(err)
+				}
+				return first.RefreshToken
+			},
+			wantErr: ErrRevoked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(NewSigner([]byte("test-key"), "mfp"), NewMemoryStore(), time.Minute, time.Hour)
+			first, err := m.Issue("user-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := tt.prepare(t, m, first)
+
+			store := m.store.(*MemoryStore)
+
+			pair, err := m.Refresh(raw)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Refresh() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.familyRevoked {
+					for _, rt := range store.tokens {
+						if rt.RevokedAt == nil {
+							t.Errorf("token created at %v is not revoked", rt.CreatedAt)
+						}
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Refresh() unexpected error: %v", err)
+			}
+
+			claims, err := m.Authenticate(pair.AccessToken)
+			if err != nil {
+				t.Fatalf("Authenticate() unexpected error: %v", err)
+			}
+			if claims.Subject != "user-1" {
+				t.Errorf("Subject = %q, want user-1", claims.Subject)
+			}
+			if pair.RefreshToken == raw {
+				t.Error("Refresh() returned the same refresh token")
+			}
+			if _, err := m.Refresh(raw); !errors.Is(err, ErrReused) {
+				t.Errorf("second Refresh() of the same token error = %v, want %v", err, ErrReused)
+			}
+			if _, err := m.Refresh(pair.RefreshToken); !errors.Is(err, ErrRevoked) {
+				t.Errorf("Refresh() of the newest token after reuse error = %v, want %v", err, ErrRevoked)
+			}
+		})
+	}
+}
+
+func mustRefresh(t *testing.T, m *Manager, raw string) *Pair {
+	t.Helper()
+	pair, err := m.Refresh(raw)
+	if err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	return pair
+}
@@ -0,0 +1,205 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("refresh token not found")
+	ErrRevoked  = errors.New("refresh token revoked")
+	// заменённый токен предъявлен повторно: его, вероятно, украли,
+	// поэтому отзывается вся цепочка
+	ErrReused = errors.New("refresh token reused")
+)
+
+// токен обновления. Каждое обновление заменяет токен следующим в той же
+// цепочке (FamilyID); в хранилище лежит только хеш токена
+type RefreshToken struct {
+	Hash      string     `json:"hash"`
+	FamilyID  string     `json:"family_id"`
+	UserID    string     `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"` // заменён следующим токеном цепочки
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// новый токен обновления; familyID пустой — начало новой цепочки.
+// возвращает сам токен, который отдаётся клиенту, и запись для хранилища
+func NewRefreshToken(userID, familyID string, now time.Time, ttl time.Duration) (string, *RefreshToken) {
+	raw := randomString()
+	if familyID == "" {
+		familyID = randomString()
+	}
+	return raw, &RefreshToken{
+		Hash:      Hash(raw),
+		FamilyID:  familyID,
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// хеш токена обновления для поиска в хранилище
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// можно ли обменять токен на новый
+func (t *RefreshToken) Check(now time.Time) error {
+	switch {
+	case t.RevokedAt != nil:
+		return ErrRevoked
+	case t.RotatedAt != nil:
+		return ErrReused
+	case !now.Before(t.ExpiresAt):
+		return ErrExpired
+	}
+	return nil
+}
+
+// хранилище токенов обновления
+type Store interface {
+	Create(t *RefreshToken) error
+	// замена токена с хешем hash следующим токеном next той же цепочки
+	// (FamilyID и UserID заполняются хранилищем); возвращает заменённый
+	// токен. При ErrReused цепочка уже отозвана
+	Rotate(hash string, now time.Time, next *RefreshToken) (*RefreshToken, error)
+	// отзыв цепочки, в которую входит токен с хешем hash
+	Revoke(hash string, now time.Time) error
+	// отзыв всех токенов пользователя, например при блокировке аккаунта
+	RevokeUser(userID string, now time.Time) error
+	DeleteExpired(now time.Time) error
+}
+
+// хранилище токенов обновления в памяти процесса
+type MemoryStore struct {
+	tokens map[string]*RefreshToken
+	mu     sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: make(map[string]*RefreshToken)}
+}
+
+func (ms *MemoryStore) Create(t *RefreshToken) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	copied := *t
+	ms.tokens[t.Hash] = &copied
+	return nil
+}
+
+func (ms *MemoryStore) Rotate(hash string, now time.Time, next *RefreshToken) (*RefreshToken, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	t, exists := ms.tokens[hash]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	if err := t.Check(now); err != nil {
+		if errors.Is(err, ErrReused) {
+			ms.revokeFamily(t.FamilyID, now)
+		}
+		return nil, err
+	}
+	t.RotatedAt = &now
+
+	copied := *next
+	copied.FamilyID, copied.UserID = t.FamilyID, t.UserID
+	ms.tokens[copied.Hash] = &copied
+	*next = copied
+
+	rotated := *t
+	return &rotated, nil
+}
+
+func (ms *MemoryStore) Revoke(hash string, now time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	t, exists := ms.tokens[hash]
+	if !exists {
+		return ErrNotFound
+	}
+	ms.revokeFamily(t.FamilyID, now)
+	return nil
+}
+
+func (ms *MemoryStore) RevokeUser(userID string, now time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, t := range ms.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) DeleteExpired(now time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for hash, t := range ms.tokens {
+		if now.After(t.ExpiresAt) {
+			delete(ms.tokens, hash)
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) revokeFamily(familyID string, now time.Time) {
+	for _, t := range ms.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+}
+
+func randomString() string {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(fmt.Sprintf("Token generation failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+// все токены, например для сохранения в файл
+func (ms *MemoryStore) All() []*RefreshToken {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	tokens := make([]*RefreshToken, 0, len(ms.tokens))
+	for _, t := range ms.tokens {
+		copied := *t
+		tokens = append(tokens, &copied)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Hash < tokens[j].Hash })
+	return tokens
+}
+
+// замена содержимого сохранёнными токенами
+func (ms *MemoryStore) Load(tokens []*RefreshToken) {
+	loaded := make(map[string]*RefreshToken, len(tokens))
+	for _, t := range tokens {
+		copied := *t
+		loaded[t.Hash] = &copied
+	}
+
+	ms.mu.Lock()
+	ms.tokens = loaded
+	ms.mu.Unlock()
+}